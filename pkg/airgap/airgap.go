@@ -0,0 +1,371 @@
+// Package airgap is the offline bundle: one archive holding everything a
+// platform install would otherwise download, so it can run on nodes with no
+// outbound internet at all.
+//
+// `kubenest bundle pack` writes it on a machine that HAS egress; `platform
+// install --offline-bundle` reads it on one that may not, and pushes its
+// contents to the nodes over the SSH connections the installer already holds.
+// The archive is a plain tar so it can be checked, copied and carried by
+// whatever a customer's transfer process allows:
+//
+//	index.json        bundle, architecture, and every file's size and sha256
+//	manifest.yaml     the bundle manifest, as the control plane served it
+//	k3s/…             the installer script, binary and system-image tarball
+//	charts/…          every pinned chart, as <chart>-<version>.tgz
+//	manifests/…       the Gateway API and system-upgrade-controller releases
+//	bin/…             the pinned deprecation scanner
+//	images/…          one OCI image-layout tarball per image the manifest lists
+//
+// WHAT GOES IN IS NOT DECIDED HERE. Each component package names its own
+// artifacts (k3s.Artifacts, gatewayapi.Artifacts, the components' Chart
+// functions), exactly as the egress list is built from the installers' own
+// chart repositories: an archive assembled from a list kept in this package
+// would drift from what the installers read, and the drift would surface as
+// a pod stuck pulling an image at a site with no way to pull it.
+//
+// The archive is UNTRUSTED when it is read. It has travelled by USB stick or
+// file share, so preflight proves it against the manifest the control plane
+// serves for the bundle — every expected file present, every file's digest
+// what the index says — before anything is pushed anywhere (Verify).
+package airgap
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"kubenest.io/cli/pkg/backup"
+	"kubenest.io/cli/pkg/component/certmanager"
+	"kubenest.io/cli/pkg/component/day2"
+	"kubenest.io/cli/pkg/component/gatewayapi"
+	"kubenest.io/cli/pkg/component/observability"
+	"kubenest.io/cli/pkg/component/secrets"
+	"kubenest.io/cli/pkg/component/traefik"
+	"kubenest.io/cli/pkg/deprecation"
+	"kubenest.io/cli/pkg/k3s"
+	"kubenest.io/cli/pkg/manifest"
+	"kubenest.io/cli/pkg/storage"
+)
+
+// The two members every archive starts with.
+const (
+	indexName    = "index.json"
+	manifestName = "manifest.yaml"
+)
+
+// Index is the archive's table of contents, written first so a reader knows
+// what to expect before it has read a byte of anything else.
+type Index struct {
+	Bundle string    `json:"bundle"`
+	Arch   string    `json:"arch"`
+	Packed time.Time `json:"packed"`
+	Files  []File    `json:"files"`
+}
+
+// File is one member's identity. The digest is what makes a bit-flipped
+// image tarball a preflight refusal instead of a pod in ImagePullBackOff.
+type File struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// item is one thing to pack. Exactly one source is set.
+type item struct {
+	name string
+	// url is a plain download.
+	url string
+	// chart is fetched from its repository's index, or its OCI registry.
+	chart *k3s.HelmChart
+	// image is a container image reference.
+	image string
+}
+
+// contents is everything an archive for m and arch must hold, in archive
+// order. It reads the ONLINE form of every chart — the one naming a
+// repository — whatever m's own Offline says.
+func contents(m *manifest.Manifest, arch string) ([]item, error) {
+	online := *m
+	online.Offline = nil
+
+	var items []item
+	addFiles := func(artifacts []manifest.Artifact, err error) error {
+		if err != nil {
+			return err
+		}
+		for _, a := range artifacts {
+			items = append(items, item{name: a.Name, url: a.URL})
+		}
+		return nil
+	}
+	if err := addFiles(k3s.Artifacts(&online, arch)); err != nil {
+		return nil, err
+	}
+
+	agentVersion, err := online.Core.Version("kubenest-agent")
+	if err != nil {
+		return nil, err
+	}
+	agentChart, err := online.Airgap.Chart()
+	if err != nil {
+		return nil, err
+	}
+	charts := []func() (k3s.HelmChart, error){
+		func() (k3s.HelmChart, error) { return traefik.Chart(&online) },
+		func() (k3s.HelmChart, error) { return certmanager.Chart(&online) },
+		func() (k3s.HelmChart, error) { return storage.Chart(&online) },
+		func() (k3s.HelmChart, error) { return backup.Chart(&online) },
+		func() (k3s.HelmChart, error) { return day2.Chart(&online) },
+		// The agent's reference is minted per cluster online; offline it is
+		// the manifest's, and the file name is the same either way.
+		func() (k3s.HelmChart, error) {
+			return k3s.HelmChart{Chart: agentChart, Version: agentVersion}, nil
+		},
+	}
+	for _, chart := range charts {
+		h, err := chart()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item{name: k3s.ChartArtifact(h), chart: &h})
+	}
+	// A profile's charts travel whenever the bundle offers it: the archive is
+	// packed once per bundle, not per install, and a site that adds the
+	// profile later has no more egress then than it had at install. Its
+	// images are in the manifest's airgap image list like every other.
+	if _, offered := online.Profiles[observability.Profile]; offered {
+		profileCharts, err := observability.Charts(&online)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range profileCharts {
+			items = append(items, item{name: k3s.ChartArtifact(h), chart: &h})
+		}
+	}
+	for _, p := range []struct {
+		profile string
+		chart   func(*manifest.Manifest) (k3s.HelmChart, error)
+	}{
+		{secrets.Profile, secrets.Chart},
+		{storage.ReplicatedProfile, storage.ReplicatedChart},
+	} {
+		if _, offered := online.Profiles[p.profile]; !offered {
+			continue
+		}
+		h, err := p.chart(&online)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item{name: k3s.ChartArtifact(h), chart: &h})
+	}
+
+	if err := addFiles(gatewayapi.Artifacts(&online)); err != nil {
+		return nil, err
+	}
+	if err := addFiles(day2.UpgradeControllerArtifacts(&online)); err != nil {
+		return nil, err
+	}
+	if err := addFiles(deprecation.Artifacts(&online, arch)); err != nil {
+		return nil, err
+	}
+
+	images, err := online.Airgap.ImageList()
+	if err != nil {
+		return nil, err
+	}
+	plugin, err := backup.PluginImage(&online)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, image := range append(append([]string(nil), images...), plugin) {
+		name := imageArtifact(image)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		items = append(items, item{name: name, image: image})
+	}
+	return items, nil
+}
+
+// imageArtifact is where an image's tarball sits in the archive. The name
+// only has to be unique and legible; the image's own name travels inside the
+// tarball, which is what containerd reads.
+func imageArtifact(ref string) string {
+	return k3s.ImagesPrefix + strings.NewReplacer("/", "_", ":", "_", "@", "_").Replace(ref) + ".tar"
+}
+
+// Archive is an opened offline bundle. It satisfies manifest.Artifacts, and
+// preflight's view of an offline bundle.
+type Archive struct {
+	path     string
+	f        *os.File
+	index    Index
+	manifest []byte
+	// members is where each member's bytes start in the file, and how many
+	// there are. A tar is uncompressed, so a member is a plain byte range
+	// and can be streamed — and re-streamed — without reading the rest.
+	members map[string]span
+}
+
+type span struct{ offset, size int64 }
+
+// Open reads an archive's index and records where every member lies. No
+// member's content is read; Verify does that.
+func Open(path string) (*Archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &Archive{path: path, f: f, members: map[string]span{}}
+	if err := a.scan(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s is not an offline bundle: %w", path, err)
+	}
+	return a, nil
+}
+
+func (a *Archive) scan() error {
+	tr := tar.NewReader(a.f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		// archive/tar reads headers in whole blocks and nothing past them,
+		// so the file offset here is the first byte of this member.
+		offset, err := a.f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		a.members[hdr.Name] = span{offset: offset, size: hdr.Size}
+		switch hdr.Name {
+		case indexName:
+			if err := json.NewDecoder(tr).Decode(&a.index); err != nil {
+				return fmt.Errorf("unreadable %s: %w", indexName, err)
+			}
+		case manifestName:
+			if a.manifest, err = io.ReadAll(tr); err != nil {
+				return err
+			}
+		}
+	}
+	if a.index.Bundle == "" {
+		return fmt.Errorf("it has no %s", indexName)
+	}
+	if a.manifest == nil {
+		return fmt.Errorf("it has no %s", manifestName)
+	}
+	return nil
+}
+
+// Close releases the archive file.
+func (a *Archive) Close() error { return a.f.Close() }
+
+// Path is the archive file.
+func (a *Archive) Path() string { return a.path }
+
+// Bundle is the bundle version the archive was packed for.
+func (a *Archive) Bundle() string { return a.index.Bundle }
+
+// Arch is the node architecture the archive was packed for.
+func (a *Archive) Arch() string { return a.index.Arch }
+
+// Open returns one indexed member. An unindexed member is not returned even
+// if the tar holds it: the index is what Verify proved.
+func (a *Archive) Open(name string) (*io.SectionReader, error) {
+	for _, f := range a.index.Files {
+		if f.Name != name {
+			continue
+		}
+		s, ok := a.members[name]
+		if !ok {
+			break
+		}
+		return io.NewSectionReader(a.f, s.offset, s.size), nil
+	}
+	return nil, fmt.Errorf("%s is not in the offline bundle %s", name, a.path)
+}
+
+// Names lists every indexed member, in archive order.
+func (a *Archive) Names() []string {
+	names := make([]string, 0, len(a.index.Files))
+	for _, f := range a.index.Files {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// Manifest parses the manifest the archive was packed from.
+func (a *Archive) Manifest() (*manifest.Manifest, error) {
+	m, err := manifest.Parse(a.manifest)
+	if err != nil {
+		return nil, fmt.Errorf("the offline bundle's manifest: %w", err)
+	}
+	return m, nil
+}
+
+// Verify proves the archive is the one m needs: packed for the same bundle,
+// holding every artifact m's pins name, and every file byte-for-byte what the
+// index records. It reads the whole archive once, which is the price of
+// finding a truncated copy here rather than on a node.
+//
+// Every problem is reported, not the first: an operator who fixes one and
+// re-runs to find the next has been failed by the installer.
+func (a *Archive) Verify(m *manifest.Manifest) error {
+	if a.index.Bundle != m.Bundle {
+		return fmt.Errorf("the offline bundle is for bundle %s, not %s: pack it with `kubenest bundle pack --bundle %s`", a.index.Bundle, m.Bundle, m.Bundle)
+	}
+	want, err := contents(m, a.index.Arch)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	indexed := map[string]File{}
+	for _, f := range a.index.Files {
+		indexed[f.Name] = f
+	}
+	for _, it := range want {
+		if _, ok := indexed[it.name]; !ok {
+			problems = append(problems, "missing "+it.name+": the manifest's pins need it and the archive does not carry it")
+		}
+	}
+	for _, f := range a.index.Files {
+		s, ok := a.members[f.Name]
+		if !ok {
+			problems = append(problems, "missing "+f.Name+": the index lists it and the archive does not hold it")
+			continue
+		}
+		if s.size != f.Size {
+			problems = append(problems, fmt.Sprintf("%s is %d bytes, the index says %d", f.Name, s.size, f.Size))
+			continue
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, io.NewSectionReader(a.f, s.offset, s.size)); err != nil {
+			return fmt.Errorf("reading %s: %w", f.Name, err)
+		}
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != f.SHA256 {
+			problems = append(problems, fmt.Sprintf("%s has sha256 %s, the index says %s", f.Name, sum, f.SHA256))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("the offline bundle %s does not match bundle %s:\n  %s", a.path, m.Bundle, strings.Join(problems, "\n  "))
+}
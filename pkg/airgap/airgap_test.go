@@ -0,0 +1,273 @@
+package airgap
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kubenest.io/cli/pkg/manifest"
+)
+
+const packBundle = `bundle: "1.4"
+core:
+  k3s: v1.33.1+k3s1
+  gateway-api: v1.3.0
+  traefik: 34.0.0
+  cert-manager: v1.17.2
+  openebs-lvm-localpv: 1.10.0
+  velero: 10.0.1
+  system-upgrade-controller: v0.15.2
+  kured: 5.6.1
+  kubenest-agent: 0.4.0
+backup:
+  object-store-plugin:
+    provider: aws
+    version: v1.14.2
+upgrade:
+  deprecation-scanner: { tool: pluto, version: v5.21.0, dataset: v5.21.0 }
+airgap:
+  images:
+    - docker.io/traefik:v3.3.2
+    - quay.io/jetstack/cert-manager-controller:v1.17.2
+  agent-chart: oci://ghcr.io/kubenesthq/charts/kubenest-operator-2
+limits:
+  timeouts:
+    component-ready: 10m
+`
+
+// packed writes an archive holding every file contents names, each with a
+// body naming itself, without downloading anything.
+func packed(t *testing.T, raw string) string {
+	t.Helper()
+	m, err := manifest.Parse([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := contents(m, "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	index := Index{Bundle: m.Bundle, Arch: "amd64", Packed: time.Now()}
+	paths := map[string]string{}
+	for i, it := range items {
+		body := []byte("content of " + it.name)
+		path := filepath.Join(dir, fmt.Sprint(i))
+		if err := os.WriteFile(path, body, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(body)
+		index.Files = append(index.Files, File{Name: it.name, Size: int64(len(body)), SHA256: hex.EncodeToString(sum[:])})
+		paths[it.name] = path
+	}
+	archive := filepath.Join(dir, "bundle.tar")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := writeArchive(f, []byte(raw), index, paths); err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+// An archive carries every class of artifact, and a member reads back as the
+// exact bytes written — the byte range Open records is the member's.
+func TestArchiveCarriesEveryPinnedArtifact(t *testing.T) {
+	a, err := Open(packed(t, packBundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	for _, want := range []string{
+		"k3s/v1.33.1+k3s1/install.sh",
+		"k3s/v1.33.1+k3s1/k3s-amd64",
+		"k3s/v1.33.1+k3s1/k3s-airgap-images-amd64.tar.zst",
+		"charts/traefik-34.0.0.tgz",
+		"charts/kubenest-operator-2-0.4.0.tgz",
+		"manifests/gateway-api/v1.3.0/standard-install.yaml",
+		"manifests/system-upgrade-controller/v0.15.2/crd.yaml",
+		"bin/pluto/v5.21.0/pluto_5.21.0_linux_amd64.tar.gz",
+		"images/docker.io_traefik_v3.3.2.tar",
+		// Not listed in airgap.images: derived from the plugin pin.
+		"images/velero_velero-plugin-for-aws_v1.14.2.tar",
+	} {
+		got, err := manifest.ReadArtifact(a, want)
+		if err != nil {
+			t.Errorf("%s: %v", want, err)
+			continue
+		}
+		if string(got) != "content of "+want {
+			t.Errorf("%s reads back as %q", want, got)
+		}
+	}
+	if err := a.Verify(mustParse(t, packBundle)); err != nil {
+		t.Errorf("an intact archive must verify: %v", err)
+	}
+}
+
+// Verify is the offline install's preflight: a moved pin, a corrupted file
+// and a different bundle are all refusals, each named.
+func TestVerifyRefusesAnArchiveThatIsNotTheManifests(t *testing.T) {
+	path := packed(t, packBundle)
+	a, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	moved := mustParse(t, strings.Replace(packBundle, "traefik: 34.0.0", "traefik: 34.1.0", 1))
+	err = a.Verify(moved)
+	if err == nil || !strings.Contains(err.Error(), "missing charts/traefik-34.1.0.tgz") {
+		t.Errorf("a pin the archive does not carry must be named, got %v", err)
+	}
+
+	other := mustParse(t, strings.Replace(packBundle, `bundle: "1.4"`, `bundle: "1.5"`, 1))
+	if err := a.Verify(other); err == nil || !strings.Contains(err.Error(), "is for bundle 1.4, not 1.5") {
+		t.Errorf("an archive for another bundle must be refused, got %v", err)
+	}
+
+	// Flip one byte inside a member, as a bad copy would.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := strings.Index(string(data), "content of charts/kured")
+	data[at] = 'C'
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	corrupt, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer corrupt.Close()
+	err = corrupt.Verify(mustParse(t, packBundle))
+	if err == nil || !strings.Contains(err.Error(), "charts/kured-5.6.1.tgz has sha256") {
+		t.Errorf("a corrupted member must be named, got %v", err)
+	}
+}
+
+func TestPackRefusesAManifestWithoutAnImageList(t *testing.T) {
+	raw := strings.Replace(packBundle, "    - docker.io/traefik:v3.3.2\n    - quay.io/jetstack/cert-manager-controller:v1.17.2\n", "", 1)
+	raw = strings.Replace(raw, "  images:\n", "  images: []\n", 1)
+	_, err := Pack(context.Background(), PackOptions{Manifest: []byte(raw), Arch: "amd64"}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "airgap.images") {
+		t.Fatalf("want a refusal naming airgap.images, got %v", err)
+	}
+}
+
+func TestReferencesNormaliseAsContainerdNamesThem(t *testing.T) {
+	for in, want := range map[string]string{
+		"traefik:v3.3.2":                  "docker.io/library/traefik:v3.3.2",
+		"docker.io/traefik:v3.3.2":        "docker.io/library/traefik:v3.3.2",
+		"velero/velero:v1.16.0":           "docker.io/velero/velero:v1.16.0",
+		"quay.io/jetstack/cert-manager:1": "quay.io/jetstack/cert-manager:1",
+		"localhost:5000/app":              "localhost:5000/app:latest",
+		"ghcr.io/a/b:1.0@sha256:0123abcd": "ghcr.io/a/b:1.0@sha256:0123abcd",
+	} {
+		ref, err := parseReference(in)
+		if err != nil {
+			t.Errorf("%s: %v", in, err)
+			continue
+		}
+		if ref.Name() != want {
+			t.Errorf("%s normalises to %s, want %s", in, ref.Name(), want)
+		}
+	}
+}
+
+// A registry that serves a multi-arch index behind a token challenge: the
+// packed image is the requested architecture's, as an OCI layout containerd
+// will file under the image's full name.
+func TestPullImageWritesTheArchitecturesOCILayout(t *testing.T) {
+	layer := []byte("layer bytes")
+	config := []byte(`{"architecture":"arm64"}`)
+	digest := func(b []byte) string { s := sha256.Sum256(b); return "sha256:" + hex.EncodeToString(s[:]) }
+	arm, _ := json.Marshal(imageManifest{
+		MediaType: mediaOCIManifest,
+		Config:    descriptor{MediaType: "application/vnd.oci.image.config.v1+json", Digest: digest(config), Size: int64(len(config))},
+		Layers:    []descriptor{{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Digest: digest(layer), Size: int64(len(layer))}},
+	})
+	index := fmt.Sprintf(`{"mediaType":%q,"manifests":[
+		{"digest":"sha256:%064d","size":1,"platform":{"os":"linux","architecture":"amd64"}},
+		{"digest":%q,"size":%d,"platform":{"os":"linux","architecture":"arm64"}}]}`,
+		mediaOCIIndex, 0, digest(arm), len(arm))
+
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			io.WriteString(w, `{"token":"anon"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer anon" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+srv.URL+`/token",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/platform/app/manifests/1.0":
+			io.WriteString(w, index)
+		case "/v2/platform/app/manifests/" + digest(arm):
+			w.Write(arm)
+		case "/v2/platform/app/blobs/" + digest(config):
+			w.Write(config)
+		case "/v2/platform/app/blobs/" + digest(layer):
+			w.Write(layer)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	image := strings.TrimPrefix(srv.URL, "https://") + "/platform/app:1.0"
+	var out strings.Builder
+	if err := pullImage(context.Background(), srv.Client(), image, "arm64", &out); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{}
+	tr := tar.NewReader(strings.NewReader(out.String()))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(tr)
+		files[hdr.Name] = string(body)
+	}
+	if files["blobs/sha256/"+strings.TrimPrefix(digest(layer), "sha256:")] != string(layer) {
+		t.Error("the arm64 layer must be in the layout")
+	}
+	if !strings.Contains(files["index.json"], `"io.containerd.image.name":"`+image+`"`) {
+		t.Errorf("containerd must be told the image's full name:\n%s", files["index.json"])
+	}
+	if !strings.Contains(files["index.json"], digest(arm)) {
+		t.Errorf("the layout must point at the arm64 manifest, not the index:\n%s", files["index.json"])
+	}
+}
+
+func mustParse(t *testing.T, raw string) *manifest.Manifest {
+	t.Helper()
+	m, err := manifest.Parse([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
@@ -0,0 +1,217 @@
+package airgap
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"kubenest.io/cli/pkg/manifest"
+)
+
+// client is what every download goes through. A variable so tests can point
+// it at a local TLS server. No overall timeout: an image layer can take
+// longer than any fixed bound on a slow link, and the context bounds the run.
+var client = &http.Client{}
+
+// PackOptions is one `kubenest bundle pack`.
+type PackOptions struct {
+	// Manifest is the bundle manifest exactly as the control plane served
+	// it. It is carried in the archive byte for byte.
+	Manifest []byte
+	// Arch is the nodes' architecture: amd64 or arm64. An archive is for one.
+	Arch string
+	// Logf narrates progress. Packing downloads gigabytes; silence for ten
+	// minutes reads as a hang.
+	Logf func(format string, args ...any)
+}
+
+// Pack downloads everything the manifest's pins name and writes the archive
+// to w. Each file is downloaded to a scratch directory first — the index,
+// which goes first in the archive, needs every digest — and removed after.
+//
+// Nothing partial is ever a valid archive: any failed download fails the
+// pack, and the caller writes to a temporary name it renames only on success.
+func Pack(ctx context.Context, opts PackOptions, w io.Writer) (Index, error) {
+	m, err := manifest.Parse(opts.Manifest)
+	if err != nil {
+		return Index{}, err
+	}
+	items, err := contents(m, opts.Arch)
+	if err != nil {
+		return Index{}, err
+	}
+	logf := opts.Logf
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+
+	scratch, err := os.MkdirTemp("", "kubenest-bundle-")
+	if err != nil {
+		return Index{}, err
+	}
+	defer os.RemoveAll(scratch)
+
+	index := Index{Bundle: m.Bundle, Arch: opts.Arch, Packed: time.Now().UTC()}
+	paths := map[string]string{}
+	for i, it := range items {
+		logf("[%d/%d] %s", i+1, len(items), it.name)
+		path := filepath.Join(scratch, fmt.Sprintf("%04d", i))
+		f, err := download(ctx, it, opts.Arch, path)
+		if err != nil {
+			return Index{}, fmt.Errorf("packing %s: %w", it.name, err)
+		}
+		index.Files = append(index.Files, f)
+		paths[it.name] = path
+	}
+
+	if err := writeArchive(w, opts.Manifest, index, paths); err != nil {
+		return Index{}, err
+	}
+	return index, nil
+}
+
+// download fetches one item to path and returns its index entry.
+func download(ctx context.Context, it item, arch, path string) (File, error) {
+	out, err := os.Create(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer out.Close()
+	h := sha256.New()
+	counted := &countingWriter{w: io.MultiWriter(out, h)}
+
+	switch {
+	case it.url != "":
+		err = fetchTo(ctx, it.url, counted)
+	case it.chart != nil && it.chart.Repo == "":
+		// An oci:// chart names its registry, not a repository.
+		err = pullChart(ctx, client, it.chart.Chart, it.chart.Version, counted)
+	case it.chart != nil:
+		var chartURL string
+		if chartURL, err = resolveChart(ctx, it.chart.Repo, it.chart.Chart, it.chart.Version); err == nil {
+			err = fetchTo(ctx, chartURL, counted)
+		}
+	case it.image != "":
+		err = pullImage(ctx, client, it.image, arch, counted)
+	default:
+		err = fmt.Errorf("nothing to pack")
+	}
+	if err != nil {
+		return File{}, err
+	}
+	if err := out.Close(); err != nil {
+		return File{}, err
+	}
+	return File{Name: it.name, Size: counted.n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// resolveChart finds a chart version's package URL in its repository's
+// index. The pin must be there exactly: a repository that no longer carries
+// it cannot be packed from, and "nearest" is not a version.
+func resolveChart(ctx context.Context, repo, chart, version string) (string, error) {
+	base := strings.TrimRight(repo, "/") + "/"
+	var raw strings.Builder
+	if err := fetchTo(ctx, base+"index.yaml", &raw); err != nil {
+		return "", err
+	}
+	var index struct {
+		Entries map[string][]struct {
+			Version string   `yaml:"version"`
+			URLs    []string `yaml:"urls"`
+		} `yaml:"entries"`
+	}
+	if err := yaml.Unmarshal([]byte(raw.String()), &index); err != nil {
+		return "", fmt.Errorf("%sindex.yaml: %w", base, err)
+	}
+	for _, e := range index.Entries[chart] {
+		if strings.TrimPrefix(e.Version, "v") != strings.TrimPrefix(version, "v") || len(e.URLs) == 0 {
+			continue
+		}
+		u, err := url.Parse(e.URLs[0])
+		if err != nil {
+			return "", err
+		}
+		b, err := url.Parse(base)
+		if err != nil {
+			return "", err
+		}
+		return b.ResolveReference(u).String(), nil
+	}
+	return "", fmt.Errorf("%s does not carry %s %s", repo, chart, version)
+}
+
+func fetchTo(ctx context.Context, url string, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: HTTP %d", url, resp.StatusCode)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// writeArchive writes the index, the manifest, then every file in index
+// order.
+func writeArchive(w io.Writer, rawManifest []byte, index Index, paths map[string]string) error {
+	tw := tar.NewWriter(w)
+	indexJSON, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	for _, small := range []struct {
+		name    string
+		content []byte
+	}{{indexName, indexJSON}, {manifestName, rawManifest}} {
+		if err := tw.WriteHeader(&tar.Header{Name: small.name, Mode: 0o644, Size: int64(len(small.content)), ModTime: index.Packed}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(small.content); err != nil {
+			return err
+		}
+	}
+	for _, f := range index.Files {
+		if err := tw.WriteHeader(&tar.Header{Name: f.Name, Mode: 0o644, Size: f.Size, ModTime: index.Packed}); err != nil {
+			return err
+		}
+		in, err := os.Open(paths[f.Name])
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, in)
+		in.Close()
+		if err != nil {
+			return fmt.Errorf("writing %s: %w", f.Name, err)
+		}
+	}
+	return tw.Close()
+}
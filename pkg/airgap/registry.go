@@ -0,0 +1,359 @@
+package airgap
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// The registry half of packing: images, and the agent's OCI chart, pulled
+// over the registry HTTP API with anonymous tokens. Only public artifacts are
+// packed — everything a bundle pins is public, and a registry that demands
+// credentials is a packing error, not something to prompt for.
+
+// Media types this client asks for and understands.
+const (
+	mediaOCIIndex      = "application/vnd.oci.image.index.v1+json"
+	mediaOCIManifest   = "application/vnd.oci.image.manifest.v1+json"
+	mediaDockerList    = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaDockerV2      = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaHelmChartData = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+)
+
+// reference is a parsed image reference, normalised the way containerd
+// names images: docker.io/library/traefik:v3.3.2, never traefik:v3.3.2.
+type reference struct {
+	registry   string
+	repository string
+	tag        string
+	digest     string
+}
+
+// parseReference normalises an image reference. Tag and digest may both be
+// present; the digest is what is pulled.
+func parseReference(s string) (reference, error) {
+	var r reference
+	rest := s
+	if at := strings.Index(rest, "@"); at >= 0 {
+		r.digest, rest = rest[at+1:], rest[:at]
+		if !strings.HasPrefix(r.digest, "sha256:") {
+			return reference{}, fmt.Errorf("image %q: only sha256 digests are supported", s)
+		}
+	}
+	if slash, colon := strings.LastIndex(rest, "/"), strings.LastIndex(rest, ":"); colon > slash {
+		r.tag, rest = rest[colon+1:], rest[:colon]
+	}
+	first, remainder, found := strings.Cut(rest, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		r.registry, r.repository = first, remainder
+	} else {
+		r.registry, r.repository = "docker.io", rest
+	}
+	if r.registry == "docker.io" && !strings.Contains(r.repository, "/") {
+		r.repository = "library/" + r.repository
+	}
+	if r.repository == "" {
+		return reference{}, fmt.Errorf("image %q has no repository", s)
+	}
+	if r.tag == "" && r.digest == "" {
+		r.tag = "latest"
+	}
+	return r, nil
+}
+
+// Name is the image's full name as containerd stores it.
+func (r reference) Name() string {
+	name := r.registry + "/" + r.repository
+	if r.tag != "" {
+		name += ":" + r.tag
+	}
+	if r.digest != "" {
+		name += "@" + r.digest
+	}
+	return name
+}
+
+// version is what the manifest request names: the digest when pinned by
+// one, otherwise the tag.
+func (r reference) version() string {
+	if r.digest != "" {
+		return r.digest
+	}
+	return r.tag
+}
+
+// host is the registry's API host. Docker Hub serves its API elsewhere than
+// its name.
+func (r reference) host() string {
+	if r.registry == "docker.io" {
+		return "registry-1.docker.io"
+	}
+	return r.registry
+}
+
+// registry is a client for one repository, holding its pull token once one
+// has been issued.
+type registry struct {
+	client *http.Client
+	ref    reference
+	token  string
+}
+
+// get fetches one registry path, answering one Bearer challenge with an
+// anonymous pull token.
+func (c *registry) get(ctx context.Context, path string, accept ...string) (*http.Response, error) {
+	do := func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+			"https://"+c.ref.host()+"/v2/"+c.ref.repository+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range accept {
+			req.Header.Add("Accept", a)
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		return c.client.Do(req)
+	}
+	resp, err := do()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && c.token == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := c.authorize(ctx, challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = do(); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s/%s%s: HTTP %d", c.ref.registry, c.ref.repository, path, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// authorize fetches an anonymous pull token from the challenge's realm.
+func (c *registry) authorize(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return fmt.Errorf("%s wants %q authentication: only public images can be packed", c.ref.registry, scheme)
+	}
+	fields := map[string]string{}
+	for _, p := range strings.Split(params, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if ok {
+			fields[k] = strings.Trim(v, `"`)
+		}
+	}
+	if fields["realm"] == "" {
+		return fmt.Errorf("%s sent an authentication challenge with no realm", c.ref.registry)
+	}
+	q := url.Values{}
+	if fields["service"] != "" {
+		q.Set("service", fields["service"])
+	}
+	q.Set("scope", "repository:"+c.ref.repository+":pull")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fields["realm"]+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s refused an anonymous pull token for %s: HTTP %d — only public images can be packed", c.ref.registry, c.ref.repository, resp.StatusCode)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return err
+	}
+	c.token = body.Token
+	if c.token == "" {
+		c.token = body.AccessToken
+	}
+	return nil
+}
+
+// descriptor is the OCI content descriptor, which Docker's v2 schema shares.
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+	} `json:"platform,omitempty"`
+}
+
+// imageManifest covers an index, a list and a single-platform manifest.
+type imageManifest struct {
+	MediaType string       `json:"mediaType"`
+	Manifests []descriptor `json:"manifests"`
+	Config    descriptor   `json:"config"`
+	Layers    []descriptor `json:"layers"`
+}
+
+// manifest fetches a manifest by tag or digest, returning it with its own
+// descriptor. A manifest fetched by digest is checked against it.
+func (c *registry) manifest(ctx context.Context, version string) (imageManifest, descriptor, []byte, error) {
+	resp, err := c.get(ctx, "/manifests/"+version, mediaOCIIndex, mediaDockerList, mediaOCIManifest, mediaDockerV2)
+	if err != nil {
+		return imageManifest{}, descriptor{}, nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return imageManifest{}, descriptor{}, nil, err
+	}
+	var m imageManifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return imageManifest{}, descriptor{}, nil, fmt.Errorf("%s: unparsable manifest: %w", c.ref.Name(), err)
+	}
+	sum := sha256.Sum256(raw)
+	d := descriptor{
+		MediaType: m.MediaType,
+		Digest:    "sha256:" + hex.EncodeToString(sum[:]),
+		Size:      int64(len(raw)),
+	}
+	if d.MediaType == "" {
+		d.MediaType = resp.Header.Get("Content-Type")
+	}
+	if strings.HasPrefix(version, "sha256:") && version != d.Digest {
+		return imageManifest{}, descriptor{}, nil, fmt.Errorf("%s: the registry served a manifest with digest %s", c.ref.Name(), d.Digest)
+	}
+	return m, d, raw, nil
+}
+
+// platformManifest resolves an index to the manifest for linux/arch. A
+// single-platform manifest is returned as it is.
+func (c *registry) platformManifest(ctx context.Context, arch string) (imageManifest, descriptor, []byte, error) {
+	m, d, raw, err := c.manifest(ctx, c.ref.version())
+	if err != nil {
+		return imageManifest{}, descriptor{}, nil, err
+	}
+	if d.MediaType != mediaOCIIndex && d.MediaType != mediaDockerList && len(m.Manifests) == 0 {
+		return m, d, raw, nil
+	}
+	for _, child := range m.Manifests {
+		if child.Platform != nil && child.Platform.OS == "linux" && child.Platform.Architecture == arch {
+			return c.manifest(ctx, child.Digest)
+		}
+	}
+	return imageManifest{}, descriptor{}, nil, fmt.Errorf("%s has no linux/%s image", c.ref.Name(), arch)
+}
+
+// blob streams one blob to w, refusing it if its digest is not the one asked
+// for: the tarball is only as trustworthy as the check made while writing it.
+func (c *registry) blob(ctx context.Context, d descriptor, w io.Writer) error {
+	resp, err := c.get(ctx, "/blobs/"+d.Digest)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), resp.Body)
+	if err != nil {
+		return err
+	}
+	if n != d.Size {
+		return fmt.Errorf("%s: blob %s is %d bytes, the manifest says %d", c.ref.Name(), d.Digest, n, d.Size)
+	}
+	if sum := "sha256:" + hex.EncodeToString(h.Sum(nil)); sum != d.Digest {
+		return fmt.Errorf("%s: blob %s arrived with digest %s", c.ref.Name(), d.Digest, sum)
+	}
+	return nil
+}
+
+// pullImage writes image's linux/arch variant to w as an OCI image-layout
+// tarball — what k3s imports from its images directory at startup. The name
+// containerd files it under is the normalised reference, which is what a pod
+// naming the same image resolves to.
+func pullImage(ctx context.Context, client *http.Client, image, arch string, w io.Writer) error {
+	ref, err := parseReference(image)
+	if err != nil {
+		return err
+	}
+	c := &registry{client: client, ref: ref}
+	m, d, raw, err := c.platformManifest(ctx, arch)
+	if err != nil {
+		return err
+	}
+
+	d.Annotations = map[string]string{
+		"io.containerd.image.name":          ref.Name(),
+		"org.opencontainers.image.ref.name": ref.version(),
+	}
+	index, err := json.Marshal(map[string]any{"schemaVersion": 2, "manifests": []descriptor{d}})
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	small := func(name string, content []byte) error {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))}); err != nil {
+			return err
+		}
+		_, err := tw.Write(content)
+		return err
+	}
+	if err := small("oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`)); err != nil {
+		return err
+	}
+	if err := small("index.json", index); err != nil {
+		return err
+	}
+	if err := small(blobPath(d.Digest), raw); err != nil {
+		return err
+	}
+	for _, blob := range append([]descriptor{m.Config}, m.Layers...) {
+		if err := tw.WriteHeader(&tar.Header{Name: blobPath(blob.Digest), Mode: 0o644, Size: blob.Size}); err != nil {
+			return err
+		}
+		if err := c.blob(ctx, blob, tw); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// pullChart writes an OCI-distributed Helm chart's package to w.
+func pullChart(ctx context.Context, client *http.Client, chart, version string, w io.Writer) error {
+	// Helm stores a version's "+" as "_", because a tag may not hold one.
+	ref, err := parseReference(strings.TrimPrefix(chart, "oci://") + ":" + strings.ReplaceAll(version, "+", "_"))
+	if err != nil {
+		return err
+	}
+	c := &registry{client: client, ref: ref}
+	m, _, _, err := c.manifest(ctx, ref.tag)
+	if err != nil {
+		return err
+	}
+	for _, layer := range m.Layers {
+		if layer.MediaType == mediaHelmChartData {
+			return c.blob(ctx, layer, w)
+		}
+	}
+	return fmt.Errorf("%s:%s is not a Helm chart: it has no chart content layer", chart, version)
+}
+
+func blobPath(digest string) string {
+	return "blobs/" + strings.Replace(digest, ":", "/", 1)
+}
@@ -8,6 +8,7 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -16,6 +17,7 @@ import (
 	"strings"
 	"time"
 
+	"kubenest.io/cli/pkg/trace"
 	"kubenest.io/cli/pkg/version"
 )
 
@@ -110,7 +112,25 @@ func (c *Client) endpoint(p string) string {
 
 // do executes the request, decodes a JSON response into out (if non-nil), and
 // converts non-2xx responses into actionable errors.
-func (c *Client) do(req *http.Request, out any) error {
+//
+// In a traced run every request is a span named by its method and path. The
+// query string is left out, as are both bodies and every header.
+func (c *Client) do(req *http.Request, out any) (err error) {
+	_, span := trace.StartKind(req.Context(), trace.KindClient, req.Method+" "+req.URL.Path,
+		trace.String("http.request.method", req.Method),
+		trace.String("server.address", req.URL.Host),
+		trace.String("url.path", req.URL.Path))
+	defer func() {
+		var apiErr *Error
+		switch {
+		case errors.As(err, &apiErr):
+			span.Fail(fmt.Sprintf("%s %s: %d", req.Method, req.URL.Path, apiErr.Status))
+		case err != nil:
+			span.Fail(err.Error())
+		}
+		span.End()
+	}()
+
 	if c.token != "" && req.Header.Get("Authorization") == "" {
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
@@ -123,6 +143,7 @@ func (c *Client) do(req *http.Request, out any) error {
 		return fmt.Errorf("control plane %s is unreachable: %w", c.baseURL.Host, err)
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(trace.Int("http.response.status_code", resp.StatusCode))
 
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
 	if err != nil {
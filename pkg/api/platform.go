@@ -257,3 +257,62 @@ func (c *Client) MaintenanceWindow(ctx context.Context, clusterID string) (Maint
 	}
 	return out, nil
 }
+
+// PutOperationJournal stores a copy of an operation's local journal — the
+// whole document, replacing the last copy — so another workstation can resume
+// or uninstall what this one started. Scope: install:report.
+//
+// The journal holds no secrets by construction (pkg/stages), and the control
+// plane stores it opaquely: it is the CLI's record, read back only by the
+// CLI. kind is the operation, "install" or "upgrade".
+func (c *Client) PutOperationJournal(ctx context.Context, clusterID, kind string, journal json.RawMessage) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut,
+		c.operationJournalEndpoint(clusterID, kind), bytes.NewReader(journal))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, nil)
+}
+
+// OperationJournal reads the stored copy back. A cluster with none returns
+// nil and no error.
+func (c *Client) OperationJournal(ctx context.Context, clusterID, kind string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.operationJournalEndpoint(clusterID, kind), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	var raw json.RawMessage
+	if err := c.do(req, &raw); err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return raw, nil
+}
+
+// DeleteOperationJournal removes the stored copy. Uninstall calls it: a copy
+// outliving its cluster would be recovered by the next install under the same
+// name, as a record of work on machines that have since been wiped. A copy
+// already gone is not an error.
+func (c *Client) DeleteOperationJournal(ctx context.Context, clusterID, kind string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.operationJournalEndpoint(clusterID, kind), nil)
+	if err != nil {
+		return err
+	}
+	if err := c.do(req, nil); err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *Client) operationJournalEndpoint(clusterID, kind string) string {
+	return c.endpoint("/api/v1/clusters/" + url.PathEscape(clusterID) + "/journals/" + url.PathEscape(kind))
+}
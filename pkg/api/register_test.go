@@ -275,3 +275,54 @@ func asAPIError(err error, target **api.Error) bool {
 	}
 	return false
 }
+
+// The journal copy is stored opaquely and read back as written; a cluster
+// with no copy is no copy, which is every first install.
+func TestOperationJournalRoundTripsAndAbsentIsNil(t *testing.T) {
+	stored := map[string][]byte{}
+	c, _ := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			var body json.RawMessage
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			stored[r.URL.Path] = body
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodGet:
+			body, ok := stored[r.URL.Path]
+			if !ok {
+				http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(body)
+		case http.MethodDelete:
+			delete(stored, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	ctx := context.Background()
+
+	if got, err := c.OperationJournal(ctx, "c-1", "install"); got != nil || err != nil {
+		t.Fatalf("absent copy = %s, %v", got, err)
+	}
+	journal := json.RawMessage(`{"identity":{"kind":"install","cluster":"prod-1"}}`)
+	if err := c.PutOperationJournal(ctx, "c-1", "install", journal); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stored["/api/v1/clusters/c-1/journals/install"]; !ok {
+		t.Fatalf("stored at %v", stored)
+	}
+	got, err := c.OperationJournal(ctx, "c-1", "install")
+	if err != nil || string(got) != string(journal) {
+		t.Fatalf("read back %s, %v", got, err)
+	}
+	if err := c.DeleteOperationJournal(ctx, "c-1", "install"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteOperationJournal(ctx, "c-1", "install"); err != nil {
+		t.Errorf("deleting a copy already gone: %v", err)
+	}
+}
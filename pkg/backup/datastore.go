@@ -189,3 +189,18 @@ func writeDatastoreConfig(ctx context.Context, r k3s.Runner, config []byte) (boo
 		return false, fmt.Errorf("write datastore snapshot configuration: unexpected result %q", strings.TrimSpace(res.Stdout))
 	}
 }
+
+// DatastoreSnapshotsConfigured reports whether a server already carries the
+// snapshot configuration ConfigureDatastoreSnapshots writes. A server joining
+// a cluster whose existing members have it must be given it too, or the new
+// member is the one whose snapshots nobody is taking.
+func DatastoreSnapshotsConfigured(ctx context.Context, r k3s.Runner) (bool, error) {
+	res, err := r.Run(ctx, "sudo -n test -f "+datastoreConfigPath+" && echo configured || echo absent")
+	if err != nil {
+		return false, fmt.Errorf("read datastore snapshot configuration: %w", err)
+	}
+	if res.ExitCode != 0 {
+		return false, fmt.Errorf("read datastore snapshot configuration: exit %d: %s", res.ExitCode, firstLine(res.Stderr))
+	}
+	return strings.TrimSpace(res.Stdout) == "configured", nil
+}
@@ -42,6 +42,20 @@ var workloadExcludedNamespaces = []string{
 	"kubenest-system",
 }
 
+// Covers reports whether the workload backups include a namespace. A
+// component whose state must survive losing the cluster — the sealing keys
+// of the secrets profile are the case that matters — asserts it in its tests,
+// so adding its namespace to the list above fails there rather than in a
+// restore.
+func Covers(namespace string) bool {
+	for _, ns := range workloadExcludedNamespaces {
+		if ns == namespace {
+			return false
+		}
+	}
+	return true
+}
+
 // Target is one cluster's S3-compatible backup destination, customer
 // supplied: endpoint, bucket and credentials are theirs (backup-restore.mdx
 // "Configuring a target").
@@ -97,6 +111,15 @@ func (t Target) backupPrefix(kind string) string {
 	return path.Join(strings.Trim(t.Prefix, "/"), kind)
 }
 
+// Locations names where each kind of backup lands, in the target's own
+// terms: the bucket and prefix an operator would browse to find them.
+func (t Target) Locations() []string {
+	return []string{
+		fmt.Sprintf("workload backups (Velero): s3://%s/%s via %s, region %s", t.Bucket, t.backupPrefix("workload"), t.s3URL(), t.Region),
+		fmt.Sprintf("datastore snapshots (k3s embedded etcd): s3://%s/%s via %s, region %s", t.Bucket, t.backupPrefix("datastore"), t.s3URL(), t.Region),
+	}
+}
+
 // onAWS reports whether the endpoint is AWS itself. Everything else — MinIO,
 // Ceph, B2, … — gets path-style addressing (virtual-host style needs
 // wildcard DNS most stores don't have) and checksumAlgorithm "" (the
@@ -128,8 +151,10 @@ func (t Target) secretManifest() ([]byte, error) {
 	})
 }
 
-// storageLocationManifest renders the BackupStorageLocation.
-func (t Target) storageLocationManifest() ([]byte, error) {
+// StorageLocationManifest renders the BackupStorageLocation. It carries no
+// credential — it references the Secret by name — so it is the one target
+// document `platform install --plan` can show in full.
+func (t Target) StorageLocationManifest() ([]byte, error) {
 	config := map[string]any{
 		"region": t.Region,
 		"s3Url":  t.s3URL(),
@@ -234,7 +259,7 @@ func Configure(ctx context.Context, r k3s.Runner, bundle *manifest.Manifest, t T
 	if err := apply(ctx, r, "backup target credentials", secret); err != nil {
 		return err
 	}
-	location, err := t.storageLocationManifest()
+	location, err := t.StorageLocationManifest()
 	if err != nil {
 		return err
 	}
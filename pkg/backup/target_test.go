@@ -47,7 +47,7 @@ func TestTargetValidateNamesTheMissingPiece(t *testing.T) {
 }
 
 func TestStorageLocationSpeaksS3Compatible(t *testing.T) {
-	loc, err := testTarget().storageLocationManifest()
+	loc, err := testTarget().StorageLocationManifest()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -97,7 +97,7 @@ func TestStorageLocationOnAWSKeepsSDKDefaults(t *testing.T) {
 	target.Endpoint = "s3.ap-south-1.amazonaws.com"
 	target.Region = "ap-south-1"
 	target.Prefix = "prod-1"
-	loc, err := target.storageLocationManifest()
+	loc, err := target.StorageLocationManifest()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -128,7 +128,7 @@ func TestTargetPartitionsWorkloadAndDatastoreObjects(t *testing.T) {
 	target := testTarget()
 	target.Prefix = "/prod-1/"
 
-	loc, err := target.storageLocationManifest()
+	loc, err := target.StorageLocationManifest()
 	if err != nil {
 		t.Fatal(err)
 	}
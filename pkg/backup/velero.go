@@ -51,6 +51,17 @@ func pluginImage(p manifest.ObjectStorePlugin) (string, error) {
 	return "velero/velero-plugin-for-aws:" + p.Version, nil
 }
 
+// PluginImage is the object-store plugin image the bundle pins. An offline
+// bundle carries it: it is an init container, so no chart's image list
+// would name it.
+func PluginImage(bundle *manifest.Manifest) (string, error) {
+	p, err := bundle.Backup.Plugin()
+	if err != nil {
+		return "", err
+	}
+	return pluginImage(p)
+}
+
 // Chart renders the pinned HelmChart custom resource for Velero,
 // unconfigured: no BackupStorageLocation, no VolumeSnapshotLocation, no
 // credentials Secret. The node agent ships from day one with file-system
@@ -85,14 +96,14 @@ initContainers:
       - mountPath: /target
         name: plugins
 `, image)
-	return k3s.HelmChart{
+	return k3s.Sourced(bundle, k3s.HelmChart{
 		Name:            "kubenest-velero",
 		Repo:            chartRepo,
 		Chart:           "velero",
 		Version:         version,
 		TargetNamespace: Namespace,
 		ValuesYAML:      values,
-	}, nil
+	}), nil
 }
 
 // Install applies the chart and converges until every Velero pod (server and
@@ -28,6 +28,9 @@ type backupConn struct {
 	SSHUser    string
 	SSHKey     string
 	BundlePath string
+	// SpecFile is a cluster spec (kind: Cluster) supplying the name, the
+	// servers and the transport; flags override it.
+	SpecFile string
 }
 
 func (c *backupConn) register(cmd *cobra.Command) {
@@ -37,9 +40,15 @@ func (c *backupConn) register(cmd *cobra.Command) {
 	fs.StringVar(&c.SSHUser, "ssh-user", "", "SSH user on the server node")
 	fs.StringVar(&c.SSHKey, "ssh-key", "", "SSH private key file; defaults to ssh-agent or ~/.ssh/config")
 	fs.StringVar(&c.BundlePath, "bundle-manifest", "", "path to the cluster's bundle manifest (required until the cluster record resolves it)")
+	fs.StringVarP(&c.SpecFile, "file", "f", "", "cluster spec file (kind: Cluster) naming the cluster and its servers")
 }
 
-func (c *backupConn) validate() error {
+// validate applies the spec file, when there is one, underneath the flags and
+// then checks the result.
+func (c *backupConn) validate(cmd *cobra.Command) error {
+	if err := applySpecFile(c.SpecFile, func(s *ClusterSpec) { s.applyBackup(cmd.Flags().Changed, c) }); err != nil {
+		return err
+	}
 	if c.Cluster == "" {
 		return fmt.Errorf("--cluster is required")
 	}
@@ -95,7 +104,7 @@ proof objects and PVC bytes are compared, cleanup is awaited, and the shared
 fleet result is updated. A failed drill exits non-zero with its actionable
 stage and reason code.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := conn.validate(); err != nil {
+			if err := conn.validate(cmd); err != nil {
 				return err
 			}
 			bundle, client, err := conn.dial(cmd)
@@ -146,7 +155,7 @@ unconfigured — loud, but never blocking.`,
     --server 10.0.1.10 --ssh-user ubuntu \
     --bundle-manifest bundles/platform-1.0.yaml`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := conn.validate(); err != nil {
+			if err := conn.validate(cmd); err != nil {
 				return err
 			}
 			target.AccessKeyID = envFirst("KUBENEST_BACKUP_ACCESS_KEY_ID", "AWS_ACCESS_KEY_ID")
@@ -207,7 +216,7 @@ to complete — within the bundle manifest's limits.timeouts.backup. A backup
 that settles as anything but Completed is an error naming Velero's reason,
 not a silent log line.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := conn.validate(); err != nil {
+			if err := conn.validate(cmd); err != nil {
 				return err
 			}
 			bundle, client, err := conn.dial(cmd)
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"kubenest.io/cli/pkg/airgap"
+)
+
+// NewBundleCommand groups what can be done with a platform bundle itself,
+// rather than with a cluster running one.
+func NewBundleCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Work with platform bundles",
+	}
+	cmd.AddCommand(newBundlePackCommand())
+	return cmd
+}
+
+// PackFlags is the flag surface of `kubenest bundle pack`.
+type PackFlags struct {
+	Bundle string
+	Arch   string
+	Output string
+}
+
+func newBundlePackCommand() *cobra.Command {
+	var f PackFlags
+	cmd := &cobra.Command{
+		Use:   "pack",
+		Short: "Write one archive holding everything an offline install needs",
+		Long: `Download everything a platform install would fetch from the internet and
+write it to a single archive, for sites whose nodes have no outbound access.
+
+The archive holds the bundle manifest, the k3s installer, binary and system
+images, every pinned chart, the Gateway API and system-upgrade-controller
+releases, the deprecation scanner, and every container image the manifest
+lists — for one node architecture. Every file's digest is recorded, and the
+install verifies each one before pushing anything to a node.
+
+Run this on a machine with internet access and a control plane login; copy
+the archive to the site however your process allows; then install from it:
+
+  kubenest platform install --offline-bundle kubenest-bundle-1.4-amd64.tar ...`,
+		Example: `  kubenest bundle pack --bundle 1.4
+  kubenest bundle pack --bundle 1.4 --arch arm64 -o /media/usb/kubenest-1.4.tar`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if f.Bundle == "" {
+				return fmt.Errorf("--bundle is required: an archive is for exactly one bundle version")
+			}
+			if f.Output == "" {
+				f.Output = fmt.Sprintf("kubenest-bundle-%s-%s.tar", f.Bundle, f.Arch)
+			}
+			return runBundlePack(cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr(), f)
+		},
+	}
+	fs := cmd.Flags()
+	fs.StringVar(&f.Bundle, "bundle", "", "platform bundle version to pack (required)")
+	fs.StringVar(&f.Arch, "arch", "amd64", "node architecture: amd64 or arm64")
+	fs.StringVarP(&f.Output, "output", "o", "", "archive to write (default kubenest-bundle-<bundle>-<arch>.tar)")
+	return cmd
+}
+
+// runBundlePack is `kubenest bundle pack`.
+//
+// The archive is written under a temporary name and renamed only once it is
+// complete, so an interrupted pack never leaves a file that looks finished.
+// Progress goes to stderr.
+func runBundlePack(ctx context.Context, out, errOut io.Writer, f PackFlags) error {
+	client, err := controlPlaneClient()
+	if err != nil {
+		return err
+	}
+	raw, err := client.BundleManifest(ctx, f.Bundle)
+	if err != nil {
+		return err
+	}
+
+	partial := f.Output + ".partial"
+	file, err := os.Create(partial)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(partial)
+
+	fmt.Fprintf(errOut, "Packing bundle %s for %s into %s.\n", f.Bundle, f.Arch, f.Output)
+	w := bufio.NewWriterSize(file, 1<<20)
+	index, err := airgap.Pack(ctx, airgap.PackOptions{
+		Manifest: raw,
+		Arch:     f.Arch,
+		Logf:     func(format string, args ...any) { fmt.Fprintf(errOut, "  "+format+"\n", args...) },
+	}, w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(partial, f.Output); err != nil {
+		return err
+	}
+
+	var total int64
+	for _, file := range index.Files {
+		total += file.Size
+	}
+	fmt.Fprintf(out, "Wrote %s: bundle %s, %s, %d files, %.1f MiB.\n",
+		f.Output, index.Bundle, index.Arch, len(index.Files), float64(total)/(1<<20))
+	return nil
+}
@@ -1,10 +1,18 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"slices"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"kubenest.io/cli/pkg/config"
+	"kubenest.io/cli/pkg/install"
+	"kubenest.io/cli/pkg/k3s"
+	"kubenest.io/cli/pkg/stages"
 	"kubenest.io/cli/pkg/window"
 )
 
@@ -15,7 +23,7 @@ func NewClusterCommand() *cobra.Command {
 		Use:   "cluster",
 		Short: "Settings that belong to one cluster",
 	}
-	cmd.AddCommand(newSetWindowCommand())
+	cmd.AddCommand(newSetWindowCommand(), newSetRegistryCommand(), newSetNotifyCommand())
 	return cmd
 }
 
@@ -56,3 +64,193 @@ a window that silently shifts by an hour is worse than no window at all.`,
 	fs.StringVar(&spec.Timezone, "timezone", "", "IANA timezone name, e.g. Asia/Kolkata or UTC (required)")
 	return cmd
 }
+
+// RegistryFlags is the flag surface of `kubenest cluster set-registry`.
+type RegistryFlags struct {
+	Cluster     string
+	Org         string
+	Mirrors     []string
+	Credentials string
+	Clear       bool
+	SSHUser     string
+	SSHKey      string
+	BreakLock   bool
+}
+
+// newSetRegistryCommand changes where a running cluster's nodes pull images
+// from. It is the install's --registry-mirror, after the fact.
+func newSetRegistryCommand() *cobra.Command {
+	var f RegistryFlags
+	cmd := &cobra.Command{
+		Use:   "set-registry",
+		Short: "Set the registry mirrors and credentials every node pulls through",
+		Long: `Write a new /etc/rancher/k3s/registries.yaml to every node of a cluster and
+restart k3s onto it, one node at a time.
+
+Servers go first, then agents. After each restart the next node waits until
+the restarted one has been heard from since the restart and is Ready, a
+server's etcd member is healthy again, and every node in the cluster reports
+Ready, so the ha tier keeps its etcd quorum and a mirror that turns out to be
+unreachable stops the roll-out at the first node that finds out. A node whose file is already what it would be
+is not restarted, so running the same command again after a failure resumes.
+
+The flags replace the whole configuration, as at install: mirrors not named
+again are removed. Credentials come from KUBENEST_REGISTRY_USERNAME and
+KUBENEST_REGISTRY_PASSWORD, applied to every mirror, or from a
+--registry-credentials file naming each host, and reach the nodes over stdin.
+--clear removes the configuration, so pulls go to the registries themselves.
+
+Every node must be reachable over SSH before any is changed. The cluster's
+nodes come from the install journal, brought up to date first from its copies
+on the control plane and the primary server.`,
+		Example: `  KUBENEST_REGISTRY_USERNAME=kubenest KUBENEST_REGISTRY_PASSWORD=… \
+  kubenest cluster set-registry --cluster prod-1 \
+    --registry-mirror docker.io=https://mirror.example.com
+
+  kubenest cluster set-registry --cluster prod-1 --clear`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if f.Cluster == "" {
+				return fmt.Errorf("--cluster is required")
+			}
+			for _, m := range f.Mirrors {
+				if _, err := k3s.ParseMirror(m); err != nil {
+					return err
+				}
+			}
+			return runSetRegistry(cmd.Context(), cmd.OutOrStdout(), f)
+		},
+	}
+	fs := cmd.Flags()
+	fs.StringVar(&f.Cluster, "cluster", "", "cluster to configure (required)")
+	fs.StringVar(&f.Org, "org", "", "organization slug or id (only needed when your credential can see more than one)")
+	fs.StringArrayVar(&f.Mirrors, "registry-mirror", nil, "pull REGISTRY's images from a mirror, as REGISTRY=URL (repeatable; * mirrors every registry)")
+	fs.StringVar(&f.Credentials, "registry-credentials", "", "YAML file of registry credentials by host: <host>: {username, password}")
+	fs.BoolVar(&f.Clear, "clear", false, "remove the registry configuration from every node")
+	fs.StringVar(&f.SSHUser, "ssh-user", "", "SSH user on the target nodes")
+	fs.StringVar(&f.SSHKey, "ssh-key", "", "SSH private key file; defaults to ssh-agent or ~/.ssh/config")
+	fs.BoolVar(&f.BreakLock, "break-lock", false, "take over the cluster's lock from a run whose lease has expired (a live run is never broken)")
+	return cmd
+}
+
+// runSetRegistry is `kubenest cluster set-registry`.
+func runSetRegistry(ctx context.Context, out io.Writer, f RegistryFlags) error {
+	session, err := openNodeSession(ctx, out, NodeFlags{Cluster: f.Cluster, Org: f.Org, SSHUser: f.SSHUser, SSHKey: f.SSHKey, BreakLock: f.BreakLock})
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	ctx, stop := session.Lock.Guard(ctx)
+	defer stop()
+
+	fmt.Fprintf(out, "Rolling the registry configuration out to %s, one node at a time.\n\n", f.Cluster)
+	if err := install.SetRegistry(ctx, session, install.SetRegistryOptions{
+		Mirrors:     f.Mirrors,
+		Credentials: f.Credentials,
+		Clear:       f.Clear,
+	}); err != nil {
+		return err
+	}
+	switch {
+	case f.Clear:
+		fmt.Fprintf(out, "\nDone. No node of %s has a registry configuration; pulls go to the registries themselves.\n", f.Cluster)
+	case len(f.Mirrors) > 0:
+		fmt.Fprintf(out, "\nDone. Every node of %s pulls through %s.\n", f.Cluster, strings.Join(f.Mirrors, ", "))
+	default:
+		fmt.Fprintf(out, "\nDone. Every node of %s has the new registry credentials.\n", f.Cluster)
+	}
+	return nil
+}
+
+// NotifyFlags is the flag surface of `kubenest cluster set-notify`.
+type NotifyFlags struct {
+	Cluster  string
+	Webhook  string
+	Format   string
+	On       []string
+	Template string
+	Clear    bool
+}
+
+// newSetNotifyCommand adds a webhook told about the cluster's installs and
+// upgrades. It is kept in the CLI config, on the workstation that runs them.
+func newSetNotifyCommand() *cobra.Command {
+	f := NotifyFlags{Format: stages.FormatJSON}
+	cmd := &cobra.Command{
+		Use:   "set-notify",
+		Short: "Notify a webhook when the cluster's installs and upgrades start, fail, pause or complete",
+		Long: `Add a webhook that installs and upgrades of this cluster notify: when the run
+starts, when it fails (with the stage, the component and the sanitized
+cause), when it pauses for its maintenance window, and when it completes.
+
+--format is the payload: json posts the notification as a JSON object, slack
+an incoming-webhook message, teams a connector MessageCard. --template
+replaces the message line with a Go text/template over the notification's
+fields: .Kind, .Operation, .Cluster, .RunID, .BundleVersion, .Stage,
+.Component, .ReasonCode, .Message and .At.
+
+A notification that cannot be delivered is held and sent ahead of the next,
+tried a few times more when the run ends, then reported as a warning. It never
+fails the operation.
+
+The webhook URL is a credential — it is all it takes to post to the channel —
+so it is kept in the 0600 CLI config and never printed. Setting the same URL
+again replaces its settings; --clear removes every webhook of the cluster.`,
+		Example: `  kubenest cluster set-notify --cluster prod-1 --format slack \
+    --webhook https://hooks.slack.com/services/T000/B000/XXXX --on failed --on paused
+
+  kubenest cluster set-notify --cluster prod-1 --clear`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if f.Cluster == "" {
+				return fmt.Errorf("--cluster is required")
+			}
+			if f.Clear != (f.Webhook == "") {
+				return fmt.Errorf("give --webhook to add a webhook, or --clear to remove them all")
+			}
+			if !f.Clear {
+				if err := stages.ValidateWebhook(f.Webhook, f.Format, f.On, f.Template); err != nil {
+					return err
+				}
+			}
+			return runSetNotify(cmd.OutOrStdout(), f)
+		},
+	}
+	fs := cmd.Flags()
+	fs.StringVar(&f.Cluster, "cluster", "", "cluster to configure (required)")
+	fs.StringVar(&f.Webhook, "webhook", "", "URL to POST notifications to")
+	fs.StringVar(&f.Format, "format", f.Format, "payload format: json, slack or teams")
+	fs.StringArrayVar(&f.On, "on", nil, "notify only of started, failed, paused or completed (repeatable; default all)")
+	fs.StringVar(&f.Template, "template", "", "Go text/template for the message line")
+	fs.BoolVar(&f.Clear, "clear", false, "remove every webhook of the cluster")
+	return cmd
+}
+
+// runSetNotify is `kubenest cluster set-notify`.
+func runSetNotify(out io.Writer, f NotifyFlags) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if f.Clear {
+		delete(cfg.Notifications, f.Cluster)
+		if err := config.Save(cfg); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Runs on %s notify no webhook.\n", f.Cluster)
+		return nil
+	}
+	hooks := slices.DeleteFunc(cfg.WebhooksFor(f.Cluster), func(w config.Webhook) bool { return w.URL == f.Webhook })
+	hooks = append(hooks, config.Webhook{URL: f.Webhook, Format: f.Format, On: f.On, Template: f.Template})
+	if cfg.Notifications == nil {
+		cfg.Notifications = map[string][]config.Webhook{}
+	}
+	cfg.Notifications[f.Cluster] = hooks
+	if err := config.Save(cfg); err != nil {
+		return err
+	}
+	on := "every notification"
+	if len(f.On) > 0 {
+		on = strings.Join(f.On, ", ")
+	}
+	fmt.Fprintf(out, "Runs on %s notify %d webhook(s); this one, in %s format, of %s.\n", f.Cluster, len(hooks), f.Format, on)
+	return nil
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"kubenest.io/cli/pkg/config"
+)
+
+// The webhook is kept in the config, replaced when it is set again, and never
+// echoed: its URL is the channel's credential.
+func TestSetNotifyKeepsTheWebhookWithoutPrintingIt(t *testing.T) {
+	isolateHome(t)
+	const url = "https://hooks.slack.com/services/T000/B000/secret-token"
+	set := func(args ...string) (string, error) {
+		root := NewRootCommand()
+		root.SetArgs(append([]string{"cluster", "set-notify", "--cluster", "prod-1"}, args...))
+		var out bytes.Buffer
+		root.SetOut(&out)
+		root.SetErr(&out)
+		err := root.Execute()
+		return out.String(), err
+	}
+	if _, err := set("--webhook", url, "--format", "discord"); err == nil {
+		t.Fatal("an unknown format was accepted")
+	}
+	if _, err := set("--webhook", url, "--format", "slack"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := set("--webhook", url, "--format", "slack", "--on", "failed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "secret-token") {
+		t.Errorf("the webhook URL was printed: %s", out)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks := cfg.WebhooksFor("prod-1")
+	if len(hooks) != 1 || hooks[0].URL != url || len(hooks[0].On) != 1 {
+		t.Fatalf("webhooks = %+v, want the one, replaced", hooks)
+	}
+
+	if _, err := set("--clear"); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _ = config.Load()
+	if len(cfg.WebhooksFor("prod-1")) != 0 {
+		t.Error("--clear left a webhook")
+	}
+}
@@ -2,8 +2,14 @@ package cmd
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/spf13/cobra"
+
+	"kubenest.io/cli/pkg/install"
+	"kubenest.io/cli/pkg/k3s"
+	"kubenest.io/cli/pkg/stages"
+	"kubenest.io/cli/pkg/storage"
 )
 
 // The platform command surface follows docs.kubenest.io/platform/install. The
@@ -33,7 +39,46 @@ type InstallFlags struct {
 	SSHUser       string
 	SSHKey        string
 	StorageDevice string
-	BackupTarget  string
+	// ReplicatedDevice is the blank device every node pools for the
+	// replicated-storage profile.
+	ReplicatedDevice string
+	BackupTarget     string
+	// RegistryMirrors are REGISTRY=URL pairs written to every node's
+	// registries.yaml. RegistryCredentials is a file of per-host
+	// credentials; the credentials themselves never appear on the command
+	// line.
+	RegistryMirrors     []string
+	RegistryCredentials string
+	// HTTPProxy and HTTPSProxy are the proxy the nodes reach the internet
+	// through, without credentials; NoProxy is added to the cluster's own
+	// bypass list.
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    []string
+	// Parallelism is how many nodes are dialled or joined at once.
+	Parallelism int
+	// Plan renders every stage instead of applying it, after a read-only
+	// preflight. PlanDir writes the rendering to a directory rather than
+	// stdout, and implies Plan.
+	Plan    bool
+	PlanDir string
+	// OfflineBundle is an archive from `kubenest bundle pack`. The nodes then
+	// need no egress: everything they would download is pushed to them.
+	OfflineBundle string
+	// Output is text or ndjson.
+	Output string
+	// BreakLock takes over the cluster's operation lease from a run that
+	// let it expire. A live holder is refused regardless.
+	BreakLock bool
+	// RerunStages and FromStage redo stages the journal has completed.
+	RerunStages []string
+	FromStage   string
+	// Hooks is a file of site hooks to run beside the stages.
+	Hooks string
+	// TraceOTLP and TraceFile are where the run's trace goes: an OTLP/HTTP
+	// collector, a file, both, or neither.
+	TraceOTLP string
+	TraceFile string
 }
 
 // Validate applies the checks that need no manifest and no network: flag
@@ -52,7 +97,7 @@ func (f *InstallFlags) Validate() error {
 	switch f.HATier {
 	case "single-server", "ha":
 	case "":
-		return fmt.Errorf("--ha is required and permanent for the cluster: choose single-server or ha deliberately (see \"Choose your tiers before you run\")")
+		return fmt.Errorf("--ha is required: choose single-server or ha deliberately. A single-server cluster can be promoted later with `kubenest platform promote-ha`; an ha cluster never goes back (see \"Choose your tiers before you run\")")
 	default:
 		return fmt.Errorf("--ha %q is not a tier: the tiers are single-server and ha", f.HATier)
 	}
@@ -62,10 +107,72 @@ func (f *InstallFlags) Validate() error {
 	if f.HATier == "single-server" && len(f.Servers) > 1 {
 		return fmt.Errorf("--ha single-server takes exactly one --server, got %d", len(f.Servers))
 	}
+	for _, m := range f.RegistryMirrors {
+		if _, err := k3s.ParseMirror(m); err != nil {
+			return err
+		}
+	}
+	for _, proxy := range []struct{ flag, raw string }{{"http-proxy", f.HTTPProxy}, {"https-proxy", f.HTTPSProxy}} {
+		if proxy.raw == "" {
+			continue
+		}
+		if _, err := k3s.ParseProxyURL(proxy.flag, proxy.raw); err != nil {
+			return err
+		}
+	}
+	if len(f.NoProxy) > 0 && f.HTTPProxy == "" && f.HTTPSProxy == "" {
+		return fmt.Errorf("--no-proxy was given without --http-proxy or --https-proxy: there is no proxy for it to bypass")
+	}
+	if f.ReplicatedDevice != "" && !slices.Contains(f.Profiles, storage.ReplicatedProfile) {
+		return fmt.Errorf("--replicated-device is only for --profile %s: without it the device would be checked and never used", storage.ReplicatedProfile)
+	}
+	if f.Parallelism < 0 {
+		return fmt.Errorf("--parallel %d: name how many nodes to work on at once, or 0 for the default of %d", f.Parallelism, install.DefaultParallelism)
+	}
+	if err := validateOutput(f.Output); err != nil {
+		return err
+	}
+	if f.Output == OutputNDJSON && (f.Plan || f.PlanDir != "") {
+		return fmt.Errorf("--output ndjson streams a run's events, and --plan does not run: drop one of them")
+	}
+	if (len(f.RerunStages) > 0 || f.FromStage != "") && (f.Plan || f.PlanDir != "") {
+		return fmt.Errorf("--rerun-stage and --from-stage change what a run redoes, and --plan renders every stage without running any: drop one of them")
+	}
+	if f.Hooks != "" && (f.Plan || f.PlanDir != "") {
+		return fmt.Errorf("--hooks runs site commands beside the stages, and --plan runs no stage: drop one of them")
+	}
+	if (f.TraceOTLP != "" || f.TraceFile != "") && (f.Plan || f.PlanDir != "") {
+		return fmt.Errorf("--trace-otlp and --trace-file trace a run's stages, and --plan runs none: drop one of them")
+	}
 	return nil
 }
 
-// NewPlatformCommand groups the platform lifecycle: install, uninstall, upgrade.
+// Rerun is the stages the operator asked to redo.
+func (f InstallFlags) Rerun() stages.Rerun {
+	return stages.Rerun{Stages: f.RerunStages, From: f.FromStage}
+}
+
+// rerunFlags registers --rerun-stage and --from-stage.
+func rerunFlags(cmd *cobra.Command, rerun *[]string, from *string) {
+	fs := cmd.Flags()
+	fs.StringArrayVar(rerun, "rerun-stage", nil, "run this stage again although the journal has it completed (repeatable)")
+	fs.StringVar(from, "from-stage", "", "run this stage and every one after it again, although the journal has them completed")
+}
+
+// hooksFlag registers --hooks.
+func hooksFlag(cmd *cobra.Command, path *string) {
+	cmd.Flags().StringVar(path, "hooks", "", "YAML file of site hooks to run before or after named stages, here or on a node")
+}
+
+// traceFlags registers --trace-otlp and --trace-file.
+func traceFlags(cmd *cobra.Command, otlp, file *string) {
+	fs := cmd.Flags()
+	fs.StringVar(otlp, "trace-otlp", "", "send a trace of the run to this OpenTelemetry collector's OTLP/HTTP endpoint, e.g. http://otel-collector:4318")
+	fs.StringVar(file, "trace-file", "", "write a trace of the run to this file as OTLP JSON, to attach to a support ticket")
+}
+
+// NewPlatformCommand groups the platform lifecycle: install, uninstall,
+// upgrade, and changes to a running cluster's nodes.
 func NewPlatformCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "platform",
@@ -75,15 +182,22 @@ func NewPlatformCommand() *cobra.Command {
 		newPlatformInstallCommand(),
 		newPlatformUninstallCommand(),
 		newPlatformUpgradeCommand(),
+		newPlatformAddNodeCommand(),
+		newPlatformRemoveNodeCommand(),
+		newPlatformPromoteHACommand(),
 		newPlatformRollbackCommand(),
 		newPlatformRestoreCommand(),
 		newPlatformDiffCommand(),
+		newPlatformJournalCommand(),
 	)
 	return cmd
 }
 
 func newPlatformInstallCommand() *cobra.Command {
-	var f InstallFlags
+	var (
+		f        InstallFlags
+		specFile string
+	)
 
 	cmd := &cobra.Command{
 		Use:   "install",
@@ -94,7 +208,108 @@ SSH, as one versioned unit.
 
 Preflight checks everything before the first byte is written to any machine.
 SSH keys come from --ssh-key, ssh-agent or ~/.ssh/config and never leave this
-machine.`,
+machine.
+
+Every flag can instead come from a versioned cluster spec file (-f), so a
+cluster's shape is reviewed in a pull request rather than retyped. Flags given
+on the command line override the file field by field, and the result is
+validated exactly as the flags alone would be. A resume checks the resolved
+request against the journal, so an edited file is refused as a different
+install, just as an edited command line is.
+
+--plan runs preflight, which is read-only, and then renders what every later
+stage would do instead of doing it: the k3s command line, every manifest the
+installer would place in the k3s auto-deploy directory, the Gateway defaults,
+the StorageClass and the backup locations. Credentials are never rendered.
+
+--offline-bundle installs with no egress from the nodes: the archive written
+by ` + "`kubenest bundle pack`" + ` is verified against the bundle's pins in preflight,
+which skips the egress check, and everything in it is pushed to the nodes over
+SSH. This machine still needs the control plane.
+
+--registry-mirror sends a registry's pulls to a mirror the site runs, through
+/etc/rancher/k3s/registries.yaml, written on every node before k3s first
+starts; preflight then probes the mirror instead of the registry. Credentials
+come from KUBENEST_REGISTRY_USERNAME and KUBENEST_REGISTRY_PASSWORD, applied
+to every mirror, or from a --registry-credentials file naming each host, and
+reach the nodes over stdin. Change them later with ` + "`kubenest cluster set-registry`" + `.
+
+--http-proxy and --https-proxy send the nodes' downloads and image pulls
+through a proxy: the k3s installer runs through it, and k3s and containerd
+read it from /etc/rancher/kubenest-proxy.env, written on every node before k3s
+first starts. Preflight tests egress through it. An authenticated proxy's
+credentials come from KUBENEST_PROXY_USERNAME and KUBENEST_PROXY_PASSWORD,
+never the URL. The cluster's pod and service CIDRs and every node address are
+added to NO_PROXY, and add-node and promote-ha add the nodes they join to
+every node's NO_PROXY, restarting k3s one node at a time.
+
+--profile observability adds Prometheus, Alertmanager, Grafana and Loki at
+the bundle's pins, every volume on kubenest-local. Grafana is served through
+the platform Gateway at /kubenest/grafana; its admin password is in secret
+kubenest-grafana in kubenest-observability. Prometheus and Alertmanager stay
+inside the cluster.
+
+--profile replicated-storage adds OpenEBS Mayastor and a second StorageClass,
+kubenest-replicated, keeping every volume on three nodes; kubenest-local stays
+the default. It needs three nodes or more, a second blank device on every node
+(--replicated-device, the same path on each), 2 GiB of hugepages reserved
+before install, and the nvme_tcp kernel module. Preflight checks all of them.
+The install ends by proving a replicated volume is readable from another node
+while the one that wrote it is cordoned.
+
+--output ndjson writes the run to stdout as one JSON object per line, for a
+pipeline rather than a person: every stage transition, every convergence
+observation, every preflight result, and a final result line. The text a
+person would read goes to stderr instead, unchanged.
+
+On a terminal, the thirteen stages are drawn in place with their status and
+elapsed time, under the time left of the install's deadline, with each check
+still converging and what it last saw — during the k3s joins, each node. Piped,
+redirected or under TERM=dumb, the same run is printed a line per transition.
+
+One run at a time: a second install of the same cluster from this machine is
+refused while the first runs, and from stage 3 on so is a run from any other
+machine, by a Lease in kubenest-system that names who holds it. A run that
+died without releasing its lease holds it until the lease expires, 90 seconds
+after its last renewal; --break-lock then takes it over.
+
+--rerun-stage and --from-stage redo stages the journal has completed, when
+something outside the installer undid them: a HelmChart deleted by hand, say.
+--rerun-stage platform-networking runs that stage again; --from-stage
+platform-networking runs it and every stage after it. The instruction is
+recorded in the journal against this run. Nothing is removed first: a rerun
+stage re-applies what it installs, exactly as a resume does. Preflight runs on
+every run regardless.
+
+--hooks names a file of the site's own steps, each run before or after a
+stage, on this machine or on a named node over the install's SSH connection:
+
+  hooks:
+    - name: cmdb-register
+      after: k3s-server
+      node: 10.0.1.10
+      run: /opt/site/bin/cmdb-register --role server
+
+A stage completes only once its hooks have. A failed hook fails its stage, and
+a resume runs the stage and its hooks again, so a hook must be safe to re-run.
+Hook output is journalled, sanitized.
+
+--trace-otlp and --trace-file record where the run's time went, as an
+OpenTelemetry trace whose id is the run's id in the journal: a span per stage,
+and beneath it a span per convergence check, SSH command and control-plane
+request. --trace-otlp sends it to a collector over OTLP/HTTP; --trace-file
+writes it as OTLP JSON, one export per line, which a collector's otlpjsonfile
+receiver can load later. Spans carry names — a command's program, a request's
+path — never arguments, output or credentials.
+
+Webhooks configured with ` + "`kubenest cluster set-notify`" + ` are told when the
+install starts, fails, pauses and completes. A notification that cannot be
+delivered is retried, then reported as a warning; it never fails the install.
+
+When a stage fails because a convergence check ran out of time, the stuck
+object's describe output, its namespace's events, its containers' recent logs
+and any helm-install job's logs are captured at once, redacted, into a
+directory beside the journal; the failure message names it.`,
 		Example: `  kubenest platform install \
     --bundle 1.4 \
     --name prod-1 \
@@ -104,27 +319,52 @@ machine.`,
     --ha single-server \
     --profile observability \
     --ssh-user ubuntu \
-    --ssh-key ~/.ssh/id_ed25519`,
+    --ssh-key ~/.ssh/id_ed25519
+
+  # The same install, declared in a reviewed file.
+  kubenest platform install -f clusters/prod-1.yaml
+
+  # Render it for review without installing anything.
+  kubenest platform install -f clusters/prod-1.yaml --plan --plan-dir plan/prod-1`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applySpecFile(specFile, func(s *ClusterSpec) { s.applyInstall(cmd.Flags().Changed, &f) }); err != nil {
+				return err
+			}
 			if err := f.Validate(); err != nil {
 				return err
 			}
-			return runInstall(cmd.Context(), cmd.OutOrStdout(), f)
+			return runInstall(cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr(), f)
 		},
 	}
 
 	fs := cmd.Flags()
+	fs.StringVarP(&specFile, "file", "f", "", "cluster spec file (kind: Cluster); flags given on the command line override its fields")
 	fs.StringVar(&f.Bundle, "bundle", "", "platform bundle version to install (required)")
 	fs.StringVar(&f.Name, "name", "", "cluster name, recorded against the control plane (required)")
 	fs.StringVar(&f.Org, "org", "", "organization slug or id (only needed when your credential can see more than one)")
 	fs.StringArrayVar(&f.Servers, "server", nil, "control-plane node address (repeat three times for --ha ha)")
 	fs.StringArrayVar(&f.Agents, "agent", nil, "agent node address (repeatable)")
-	fs.StringVar(&f.HATier, "ha", "", "HA tier: single-server or ha (required, permanent)")
+	fs.StringVar(&f.HATier, "ha", "", "HA tier: single-server or ha (required; single-server can later be promoted, ha is permanent)")
 	fs.StringArrayVar(&f.Profiles, "profile", nil, "profile to install on top of core (repeatable)")
 	fs.StringVar(&f.SSHUser, "ssh-user", "", "SSH user on the target nodes")
 	fs.StringVar(&f.SSHKey, "ssh-key", "", "SSH private key file; defaults to ssh-agent or ~/.ssh/config")
 	fs.StringVar(&f.StorageDevice, "storage-device", "", "blank device for the installer to create kubenest-vg on (omit if you created the volume group yourself)")
+	fs.StringVar(&f.ReplicatedDevice, "replicated-device", "", "blank device on every node for the replicated-storage profile's pools, separate from --storage-device")
 	fs.StringVar(&f.BackupTarget, "backup-target", "", "S3-compatible backup target for Velero (optional; unset reports backup: unconfigured)")
+	fs.StringArrayVar(&f.RegistryMirrors, "registry-mirror", nil, "pull REGISTRY's images from a mirror, as REGISTRY=URL, e.g. docker.io=https://mirror.example.com (repeatable; * mirrors every registry)")
+	fs.StringVar(&f.RegistryCredentials, "registry-credentials", "", "YAML file of registry credentials by host: <host>: {username, password}")
+	fs.StringVar(&f.HTTPProxy, "http-proxy", "", "proxy URL for the nodes' plain-HTTP traffic, e.g. http://proxy.example.com:3128 (credentials from KUBENEST_PROXY_USERNAME/PASSWORD)")
+	fs.StringVar(&f.HTTPSProxy, "https-proxy", "", "proxy URL for the nodes' HTTPS traffic: downloads and image pulls")
+	fs.StringSliceVar(&f.NoProxy, "no-proxy", nil, "hosts, domains and CIDRs that bypass the proxy, in addition to the cluster's own (comma-separated or repeatable)")
+	fs.IntVar(&f.Parallelism, "parallel", install.DefaultParallelism, "how many nodes to dial and join at once; agents join concurrently, etcd members one at a time")
+	fs.BoolVar(&f.Plan, "plan", false, "run preflight, then print every stage's commands and manifests instead of applying them")
+	fs.StringVar(&f.PlanDir, "plan-dir", "", "with --plan, write the rendering to this directory, one subdirectory per stage")
+	fs.StringVar(&f.OfflineBundle, "offline-bundle", "", "install from this archive, written by kubenest bundle pack; the nodes need no outbound internet")
+	fs.StringVar(&f.Output, "output", OutputText, "text, or ndjson for one JSON event per line on stdout with the text on stderr")
+	fs.BoolVar(&f.BreakLock, "break-lock", false, "take over the cluster's lock from a run whose lease has expired (a live run is never broken)")
+	rerunFlags(cmd, &f.RerunStages, &f.FromStage)
+	hooksFlag(cmd, &f.Hooks)
+	traceFlags(cmd, &f.TraceOTLP, &f.TraceFile)
 	return cmd
 }
 
@@ -133,6 +373,7 @@ func newPlatformUninstallCommand() *cobra.Command {
 		confirm     bool
 		destroyData bool
 		name        string
+		specFile    string
 		f           InstallFlags
 	)
 	cmd := &cobra.Command{
@@ -154,6 +395,9 @@ is treated as yours.`,
 			if !confirm {
 				return fmt.Errorf("uninstall removes the platform from every node of the cluster: pass --confirm to proceed")
 			}
+			if err := applySpecFile(specFile, func(s *ClusterSpec) { s.applyUninstall(cmd.Flags().Changed, &name, &f) }); err != nil {
+				return err
+			}
 			return runUninstall(cmd.Context(), cmd.OutOrStdout(), name, destroyData, f)
 		},
 	}
@@ -161,6 +405,7 @@ is treated as yours.`,
 	fs.BoolVar(&confirm, "confirm", false, "confirm removal (required)")
 	fs.BoolVar(&destroyData, "destroy-data", false, "also remove persistent volumes (never removes a volume group you created yourself)")
 	fs.StringVar(&name, "name", "", "cluster name; defaults to the only install journal on this machine")
+	fs.StringVarP(&specFile, "file", "f", "", "cluster spec file (kind: Cluster) naming the cluster and its nodes")
 	fs.StringArrayVar(&f.Servers, "server", nil, "control-plane node address (only needed without an install journal)")
 	fs.StringArrayVar(&f.Agents, "agent", nil, "agent node address (only needed without an install journal)")
 	fs.StringVar(&f.SSHUser, "ssh-user", "", "SSH user on the target nodes")
@@ -169,7 +414,10 @@ is treated as yours.`,
 }
 
 func newPlatformUpgradeCommand() *cobra.Command {
-	var f UpgradeFlags
+	var (
+		f        UpgradeFlags
+		specFile string
+	)
 
 	cmd := &cobra.Command{
 		Use:   "upgrade",
@@ -186,30 +434,75 @@ Every pre-flight gate runs before anything is touched, and the one that
 matters most scans your live workloads for APIs the target Kubernetes version
 removes. If it finds any, the upgrade is blocked and the report names them: an
 upgrade that cleanly upgrades the cluster and takes your product down has
-actively harmed you.`,
+actively harmed you.
+
+--output ndjson writes the run to stdout as one JSON object per line: every
+stage transition, every convergence observation, every gate's verdict, and a
+final result line. The text goes to stderr instead, unchanged.
+
+On a terminal, the eight stages are drawn in place with their status and
+elapsed time, under the time left of the upgrade's deadline, with each check
+still converging and what it last saw. Piped, redirected or under TERM=dumb,
+the same run is printed a line per transition.
+
+One run at a time: a second upgrade or rollback of the same cluster, from this
+machine or another, is refused while one runs, naming the run, user and host
+that holds it. A run that died without releasing its lease holds it until the
+lease expires, 90 seconds after its last renewal; --break-lock then takes it
+over. A live run is never broken.
+
+--rerun-stage and --from-stage redo stages the journal has completed, when
+something outside the upgrade undid them. The instruction is recorded in the
+journal against this run; nothing is reverted first.
+
+--hooks names a file of site hooks, each run before or after an upgrade stage,
+as for install. A failed hook fails its stage, and a resume runs both again.
+
+--trace-otlp and --trace-file record the run as an OpenTelemetry trace, as
+for install: a span per stage, convergence check, SSH command and
+control-plane request, sent to a collector or written to a file for a
+support ticket.
+
+Webhooks configured with ` + "`kubenest cluster set-notify`" + ` are notified of the
+upgrade, as for install.`,
 		Example: `  kubenest platform upgrade --cluster prod-1 --to 1.1
 
   # Accept one finding you have judged safe. There is no blanket override.
   kubenest platform upgrade --cluster prod-1 --to 1.1 \
-    --acknowledge payments/Ingress/legacy-gateway`,
+    --acknowledge payments/Ingress/legacy-gateway
+
+  # Upgrade to the bundle a reviewed cluster spec now declares.
+  kubenest platform upgrade -f clusters/prod-1.yaml`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applySpecFile(specFile, func(s *ClusterSpec) { s.applyUpgrade(cmd.Flags().Changed, &f) }); err != nil {
+				return err
+			}
 			if f.Cluster == "" {
 				return fmt.Errorf("--cluster is required: which cluster to upgrade")
 			}
 			if f.To == "" {
 				return fmt.Errorf("--to is required: the bundle version to upgrade to (see `kubenest platform diff`)")
 			}
-			return runUpgrade(cmd.Context(), cmd.OutOrStdout(), f)
+			if err := validateOutput(f.Output); err != nil {
+				return err
+			}
+			return runUpgrade(cmd.Context(), cmd.OutOrStdout(), cmd.ErrOrStderr(), f)
 		},
 	}
 	fs := cmd.Flags()
 	fs.StringVar(&f.Cluster, "cluster", "", "cluster to upgrade (required)")
 	fs.StringVar(&f.To, "to", "", "bundle version to upgrade to (required)")
+	fs.StringVarP(&specFile, "file", "f", "", "cluster spec file (kind: Cluster); its bundle is the version to upgrade to, and flags override it")
 	fs.StringArrayVar(&f.Acknowledge, "acknowledge", nil, "accept one deprecated-API finding by namespace/Kind/name (repeatable; there is deliberately no blanket override)")
 	fs.StringArrayVar(&f.Servers, "server", nil, "control-plane node address (only needed without a local install journal)")
 	fs.StringArrayVar(&f.Agents, "agent", nil, "agent node address (only needed without a local install journal)")
 	fs.StringVar(&f.SSHUser, "ssh-user", "", "SSH user on the target nodes")
 	fs.StringVar(&f.SSHKey, "ssh-key", "", "SSH private key file; defaults to ssh-agent or ~/.ssh/config")
+	fs.StringVar(&f.Output, "output", OutputText, "text, or ndjson for one JSON event per line on stdout with the text on stderr")
+	fs.BoolVar(&f.BreakLock, "break-lock", false, "take over the cluster's lock from a run whose lease has expired (a live run is never broken)")
+	rerunFlags(cmd, &f.RerunStages, &f.FromStage)
+	hooksFlag(cmd, &f.Hooks)
+	traceFlags(cmd, &f.TraceOTLP, &f.TraceFile)
 	return cmd
 }
 
@@ -252,5 +545,6 @@ for confirmation.`,
 	fs.StringArrayVar(&f.Agents, "agent", nil, "agent node address (only needed without a local install journal)")
 	fs.StringVar(&f.SSHUser, "ssh-user", "", "SSH user on the target nodes")
 	fs.StringVar(&f.SSHKey, "ssh-key", "", "SSH private key file; defaults to ssh-agent or ~/.ssh/config")
+	fs.BoolVar(&f.BreakLock, "break-lock", false, "take over the cluster's lock from a run whose lease has expired (a live run is never broken)")
 	return cmd
 }
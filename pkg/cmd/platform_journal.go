@@ -0,0 +1,321 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"kubenest.io/cli/pkg/api"
+	"kubenest.io/cli/pkg/install"
+	"kubenest.io/cli/pkg/register"
+	"kubenest.io/cli/pkg/sshx"
+	"kubenest.io/cli/pkg/stages"
+	"kubenest.io/cli/pkg/upgrade"
+)
+
+// JournalFlags is the flag surface of `kubenest platform journal`.
+type JournalFlags struct {
+	Cluster string
+	// Kind narrows show and export to the install or the upgrade journal
+	// when the cluster has both.
+	Kind string
+}
+
+func newPlatformJournalCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "journal",
+		Short: "Read the install and upgrade journals on this machine",
+		Long: `Read the journals that record where each install and upgrade run from this
+machine got to. A journal is what a resume reads: re-running the identical
+command skips every stage it records as completed.
+
+Nothing here changes a journal. To start over, uninstall; to finish, resume.`,
+	}
+	cmd.AddCommand(newJournalListCommand(), newJournalShowCommand(), newJournalExportCommand())
+	return cmd
+}
+
+func newJournalListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List every install and upgrade journal, with where each one stopped",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runJournalList(cmd.OutOrStdout(), cmd.ErrOrStderr())
+		},
+	}
+}
+
+func newJournalShowCommand() *cobra.Command {
+	var f JournalFlags
+	cmd := &cobra.Command{
+		Use:   "show --cluster NAME",
+		Short: "Show a cluster's journal as a timeline, one block per run",
+		Long: `Show a cluster's journal: the identity a resume must match, then every stage
+transition, grouped by the run that wrote it. A run is one process; each
+resume is a new one. Every stage shows how it ended and how long it took, and
+a stage with no end is one its process never finished.`,
+		Example: `  kubenest platform journal show --cluster prod-1
+  kubenest platform journal show --cluster prod-1 --kind upgrade`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := f.validate("show"); err != nil {
+				return err
+			}
+			journals, err := clusterJournals(f)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			for i, j := range journals {
+				if i > 0 {
+					fmt.Fprintln(out)
+				}
+				writeJournal(out, j)
+			}
+			return nil
+		},
+	}
+	f.flags(cmd)
+	return cmd
+}
+
+func newJournalExportCommand() *cobra.Command {
+	var f JournalFlags
+	cmd := &cobra.Command{
+		Use:   "export --cluster NAME",
+		Short: "Print a cluster's journal as sanitized JSON, for a support ticket",
+		Long: `Print a cluster's journal as JSON with every credential-shaped string
+redacted, ready to attach to a support ticket. The journal holds no secrets by
+construction; the export is sanitized again regardless, because the file on
+disk may have been edited by hand.`,
+		Example: `  kubenest platform journal export --cluster prod-1 > prod-1-journal.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := f.validate("export"); err != nil {
+				return err
+			}
+			journals, err := clusterJournals(f)
+			if err != nil {
+				return err
+			}
+			if len(journals) > 1 {
+				return fmt.Errorf("%s has an install and an upgrade journal: pass --kind install or --kind upgrade to say which to export", f.Cluster)
+			}
+			data, err := journals[0].Export()
+			if err != nil {
+				return err
+			}
+			_, err = cmd.OutOrStdout().Write(data)
+			return err
+		},
+	}
+	f.flags(cmd)
+	return cmd
+}
+
+func (f *JournalFlags) flags(cmd *cobra.Command) {
+	fs := cmd.Flags()
+	fs.StringVar(&f.Cluster, "cluster", "", "cluster whose journal to read (required)")
+	fs.StringVar(&f.Kind, "kind", "", "install or upgrade, when the cluster has both")
+}
+
+func (f JournalFlags) validate(verb string) error {
+	if f.Cluster == "" {
+		return fmt.Errorf("--cluster is required: which cluster's journal to %s (see `kubenest platform journal list`)", verb)
+	}
+	switch f.Kind {
+	case "", install.Kind, upgrade.Kind:
+		return nil
+	}
+	return fmt.Errorf("--kind %q is not a journal kind: the kinds are install and upgrade", f.Kind)
+}
+
+// clusterJournals finds a cluster's journals by the identity recorded in
+// them, not by file name, install first.
+func clusterJournals(f JournalFlags) ([]*stages.Journal, error) {
+	dir, err := stages.JournalDir()
+	if err != nil {
+		return nil, err
+	}
+	all, readErr := stages.ReadJournals(dir)
+	var found []*stages.Journal
+	for _, j := range all {
+		if j.Identity.Cluster == f.Cluster && (f.Kind == "" || j.Identity.Kind == f.Kind) {
+			found = append(found, j)
+		}
+	}
+	if len(found) == 0 {
+		if readErr != nil {
+			return nil, fmt.Errorf("no readable journal for %s: %w", f.Cluster, readErr)
+		}
+		kind := "journal"
+		if f.Kind != "" {
+			kind = f.Kind + " journal"
+		}
+		return nil, fmt.Errorf("no %s for cluster %q in %s (see `kubenest platform journal list`)", kind, f.Cluster, dir)
+	}
+	slices.SortStableFunc(found, func(a, b *stages.Journal) int {
+		return strings.Compare(a.Identity.Kind, b.Identity.Kind)
+	})
+	return found, nil
+}
+
+// runJournalList is `kubenest platform journal list`.
+func runJournalList(out, errOut io.Writer) error {
+	dir, err := stages.JournalDir()
+	if err != nil {
+		return err
+	}
+	journals, readErr := stages.ReadJournals(dir)
+	if readErr != nil {
+		fmt.Fprintf(errOut, "warning: %v\n", readErr)
+	}
+	if len(journals) == 0 {
+		fmt.Fprintf(out, "No journals in %s.\n", dir)
+		return nil
+	}
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLUSTER\tKIND\tBUNDLE\tLAST STAGE\tSTATUS\tAT")
+	for _, j := range journals {
+		stage, status, at := "-", "not started", "-"
+		if last, ok := j.Last(); ok {
+			stage, status, at = last.Stage, string(last.Status), last.At.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", j.Identity.Cluster, j.Identity.Kind, journalBundle(j), stage, status, at)
+	}
+	return tw.Flush()
+}
+
+// journalBundle is the bundle an install journal is for, or the move an
+// upgrade journal records.
+func journalBundle(j *stages.Journal) string {
+	fields := j.Identity.Fields
+	if j.Identity.Kind == upgrade.Kind {
+		return fields["from bundle"] + " → " + fields["to bundle"]
+	}
+	if b := fields["bundle"]; b != "" {
+		return b
+	}
+	return "-"
+}
+
+// writeJournal renders one journal for `journal show`.
+func writeJournal(out io.Writer, j *stages.Journal) {
+	fmt.Fprintf(out, "%s %s journal, %s\n", j.Identity.Cluster, j.Identity.Kind, j.Path())
+	if j.ClusterID != "" {
+		fmt.Fprintf(out, "Control-plane id: %s\n", j.ClusterID)
+	}
+
+	// Every field, empty ones included: an empty --storage-device is as much
+	// a part of what a resume must match as a set one.
+	fmt.Fprintf(out, "\nA resume must match:\n")
+	names := make([]string, 0, len(j.Identity.Fields))
+	for name := range j.Identity.Fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		value := j.Identity.Fields[name]
+		if value == "" {
+			value = "(none)"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", name, value)
+	}
+	_ = tw.Flush()
+
+	runs := j.Runs()
+	if len(runs) == 0 {
+		fmt.Fprintf(out, "\nNo stage has started.\n")
+		return
+	}
+	for i, run := range runs {
+		id := run.ID
+		if id == "" {
+			id = "(unrecorded)"
+		}
+		fmt.Fprintf(out, "\nRun %d of %d, %s\n", i+1, len(runs), id)
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, step := range run.Steps {
+			at := step.Started
+			if at.IsZero() {
+				at = step.Ended
+			}
+			status, took := string(step.Status), "-"
+			if step.Status == stages.StatusStarted {
+				status = "never finished"
+			} else if d := step.Duration(); d > 0 {
+				took = d.Round(time.Second).String()
+			}
+			if step.Hook != "" {
+				status += " " + step.Hook
+			}
+			if step.Attempt > 0 {
+				status += fmt.Sprintf(" (attempt %d)", step.Attempt)
+			}
+			line := fmt.Sprintf("  %s\t%s\t%s\t%s", at.UTC().Format(time.RFC3339), step.Stage, status, took)
+			if step.Component != "" {
+				line += "\t" + step.Component
+			}
+			fmt.Fprintln(tw, line)
+			if step.Detail != "" {
+				fmt.Fprintf(tw, "  \t\t%s\n", step.Detail)
+			}
+		}
+		_ = tw.Flush()
+	}
+}
+
+// recoverInstallJournal brings this machine's install journal for name up to
+// date from its copies before anything reads it: the control plane's, when
+// there is a client and the cluster is registered, and the primary server's,
+// when its address is known. It returns the cluster's control-plane id, ""
+// when there is none, for the caller to keep or remove the copy there.
+//
+// Every copy is optional. A control plane that cannot be asked or a server
+// that cannot be reached is said out loud and passed over: on a first
+// install there are no copies anywhere, and recovery must cost that install
+// nothing but the asking.
+func recoverInstallJournal(ctx context.Context, out io.Writer, client *api.Client, org, name, server string, sshOpts sshx.Options) (string, error) {
+	path, err := install.JournalPath(name)
+	if err != nil {
+		return "", err
+	}
+	warn := func(format string, args ...any) { fmt.Fprintf(out, format+"\n", args...) }
+
+	var copies []stages.Replica
+	clusterID := ""
+	if client != nil {
+		cluster, err := register.FindCluster(ctx, client, org, name)
+		switch {
+		case err != nil:
+			warn("warning: could not look %s up in the control plane for its journal copy: %v", name, err)
+		case cluster != nil:
+			clusterID = cluster.ID
+			copies = append(copies, stages.ControlPlaneReplica{Client: client, ClusterID: func() string { return clusterID }, Kind: install.Kind})
+		}
+	}
+	if server != "" {
+		if ep, err := sshx.Resolve(server, sshOpts); err != nil {
+			warn("warning: could not read the journal copy on %s: %v", server, err)
+		} else if conn, err := sshx.Dial(ctx, ep, sshOpts); err != nil {
+			warn("warning: could not read the journal copy on %s: %v", server, err)
+		} else {
+			defer conn.Close()
+			copies = append(copies, stages.ServerReplica{Runner: conn, Address: server, Kind: install.Kind})
+		}
+	}
+
+	from, err := stages.RecoverJournal(ctx, path, install.Kind, name, copies, warn)
+	if err != nil {
+		return clusterID, err
+	}
+	if from != "" {
+		fmt.Fprintf(out, "Recovered the install journal for %s from %s: another machine has run this install since this one last did.\n", name, from)
+	}
+	return clusterID, nil
+}
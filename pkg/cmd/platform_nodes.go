@@ -0,0 +1,381 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/install"
+	"kubenest.io/cli/pkg/sshx"
+	"kubenest.io/cli/pkg/stages"
+)
+
+// NodeFlags is the flag surface of the commands that change a running
+// cluster's node set.
+type NodeFlags struct {
+	Cluster string
+	// Org is only needed when the credential can see more than one
+	// organization: it is where the journal's control-plane copy is found.
+	Org string
+	// Agents are the nodes add-node joins.
+	Agents []string
+	// Servers are the hosts promote-ha, or add-node on an ha cluster,
+	// joins as etcd members.
+	Servers []string
+	// BackupTarget is --backup-target, for the new members' datastore
+	// snapshots.
+	BackupTarget string
+	// Node is the one node remove-node takes out.
+	Node string
+	// AcknowledgeVolumes accepts stranding individual local volumes by
+	// PersistentVolume name. There is deliberately no blanket form.
+	AcknowledgeVolumes []string
+	SSHUser            string
+	SSHKey             string
+	// Parallelism is --parallel: how many nodes are dialled or joined at
+	// once. Zero is install.DefaultParallelism.
+	Parallelism int
+	// BreakLock takes over the cluster's operation lease from a run that
+	// stopped renewing it, as install's and upgrade's --break-lock do.
+	BreakLock bool
+}
+
+func newPlatformAddNodeCommand() *cobra.Command {
+	var f NodeFlags
+	cmd := &cobra.Command{
+		Use:   "add-node",
+		Short: "Join new agent or server nodes to a running cluster",
+		Long: `Join new agent nodes to an existing cluster, or new servers to an ha one.
+
+The new hosts get the same checks an install gives them — operating system,
+privilege, no existing Kubernetes, resources, the volume group and egress —
+and the node-to-node ports are proven between them and the nodes already in
+the cluster. Nothing is checked or changed on the existing nodes beyond that
+probe.
+
+Each new node joins at the k3s version of the bundle the cluster is on now,
+gets its volume group the way the install made the others', and must report
+Ready. Only then are the install journal and the control-plane record updated,
+so a failed add changes neither, and running the same command again resumes it.
+
+--server joins etcd members to an ha cluster; it is how a failed server is
+replaced, after remove-node has taken it out. Every existing server must be
+Ready first: a member that joins while another is down can cost etcd its
+quorum. If the servers take datastore snapshots, pass --backup-target as
+promote-ha takes it. A single-server cluster gets more servers only through
+promote-ha.
+
+The cluster's nodes, tier and storage device come from the install journal,
+brought up to date first from its copies on the control plane and the primary
+server if another machine has changed the cluster since this one did.`,
+		Example: `  kubenest platform add-node --cluster prod-1 --agent 10.0.1.13
+
+  # Replace a failed server of an ha cluster.
+  kubenest platform remove-node --cluster prod-1 --node 10.0.1.12
+  kubenest platform add-node --cluster prod-1 --server 10.0.1.14`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if f.Cluster == "" {
+				return fmt.Errorf("--cluster is required: which cluster to add nodes to")
+			}
+			switch {
+			case len(f.Agents) == 0 && len(f.Servers) == 0:
+				return fmt.Errorf("at least one --agent or --server is required: the node to add")
+			case len(f.Agents) > 0 && len(f.Servers) > 0:
+				return fmt.Errorf("add servers and agents in separate runs: a server joins etcd, and the agents should join a cluster whose members are settled")
+			}
+			if f.Parallelism < 0 {
+				return fmt.Errorf("--parallel %d: name how many nodes to work on at once, or 0 for the default of %d", f.Parallelism, install.DefaultParallelism)
+			}
+			return runAddNode(cmd.Context(), cmd.OutOrStdout(), f)
+		},
+	}
+	fs := cmd.Flags()
+	fs.StringVar(&f.Cluster, "cluster", "", "cluster to add nodes to (required)")
+	fs.StringVar(&f.Org, "org", "", "organization slug or id (only needed when your credential can see more than one)")
+	fs.StringArrayVar(&f.Agents, "agent", nil, "address of a new agent node (repeatable)")
+	fs.StringArrayVar(&f.Servers, "server", nil, "address of a new server node, for an ha cluster (repeatable)")
+	fs.StringVar(&f.BackupTarget, "backup-target", "", "the cluster's s3:// backup target, required with --server when the servers take datastore snapshots")
+	fs.StringVar(&f.SSHUser, "ssh-user", "", "SSH user on the target nodes")
+	fs.StringVar(&f.SSHKey, "ssh-key", "", "SSH private key file; defaults to ssh-agent or ~/.ssh/config")
+	fs.BoolVar(&f.BreakLock, "break-lock", false, "take over the cluster's lock from a run whose lease has expired (a live run is never broken)")
+	fs.IntVar(&f.Parallelism, "parallel", install.DefaultParallelism, "how many nodes to dial and join at once")
+	return cmd
+}
+
+func newPlatformRemoveNodeCommand() *cobra.Command {
+	var f NodeFlags
+	cmd := &cobra.Command{
+		Use:   "remove-node",
+		Short: "Drain a node and take it out of the cluster",
+		Long: `Take one node out of a cluster, safely, or refuse before touching anything.
+
+The node is cordoned and drained through the eviction API, so every pod
+disruption budget is honoured; a budget that could never let a pod move is a
+refusal up front rather than a drain that hangs. A server is removed from the
+embedded etcd cluster before its Node object is deleted. A server is refused
+if the servers that stay would not have a majority Ready, which would stop
+etcd — as is the only server of a single-server cluster, which is uninstall.
+Removing a server from a three-server ha cluster is allowed, with a warning:
+the two that stay keep quorum but survive no further failure until a third
+joins.
+
+Local persistent volumes live on one node's disk and nowhere else. If the
+node holds any, remove-node names them and refuses; pass --acknowledge-volume
+for each one you accept losing. There is no blanket override.
+
+Finally k3s is removed from the host with the uninstall script, and the node
+leaves the install journal and the control-plane record. Its volume group and
+the data in it are left on the disk.
+
+To replace an agent, add the new one first, then remove the old one. To
+replace a failed server, remove it first, then add its replacement with
+add-node --server: etcd takes a new member safely only while every existing
+one is up.`,
+		Example: `  kubenest platform remove-node --cluster prod-1 --node 10.0.1.12
+
+  # Replace an agent.
+  kubenest platform add-node --cluster prod-1 --agent 10.0.1.13
+  kubenest platform remove-node --cluster prod-1 --node 10.0.1.12
+
+  # Replace a failed server of an ha cluster.
+  kubenest platform remove-node --cluster prod-1 --node 10.0.1.11
+  kubenest platform add-node --cluster prod-1 --server 10.0.1.14`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if f.Cluster == "" {
+				return fmt.Errorf("--cluster is required: which cluster to remove the node from")
+			}
+			if f.Node == "" {
+				return fmt.Errorf("--node is required: the address of the node to remove")
+			}
+			return runRemoveNode(cmd.Context(), cmd.OutOrStdout(), f)
+		},
+	}
+	fs := cmd.Flags()
+	fs.StringVar(&f.Cluster, "cluster", "", "cluster to remove the node from (required)")
+	fs.StringVar(&f.Org, "org", "", "organization slug or id (only needed when your credential can see more than one)")
+	fs.StringVar(&f.Node, "node", "", "address of the node to remove, as given at install (required)")
+	fs.StringArrayVar(&f.AcknowledgeVolumes, "acknowledge-volume", nil, "accept stranding one local PersistentVolume on the node, by name (repeatable; there is deliberately no blanket override)")
+	fs.StringVar(&f.SSHUser, "ssh-user", "", "SSH user on the target nodes")
+	fs.StringVar(&f.SSHKey, "ssh-key", "", "SSH private key file; defaults to ssh-agent or ~/.ssh/config")
+	fs.BoolVar(&f.BreakLock, "break-lock", false, "take over the cluster's lock from a run whose lease has expired (a live run is never broken)")
+	return cmd
+}
+
+func newPlatformPromoteHACommand() *cobra.Command {
+	var f NodeFlags
+	cmd := &cobra.Command{
+		Use:   "promote-ha",
+		Short: "Move a single-server cluster to the ha tier",
+		Long: `Move a single-server cluster to the ha tier by joining two more servers.
+
+Every tier runs embedded etcd from its first server, so this is a join and
+not a rebuild: the new servers get the install's checks, the node-to-node and
+etcd ports are proven against the existing nodes, and each joins the etcd
+cluster in turn. Every node must then report Ready.
+
+If the existing server takes datastore snapshots, the new members are given
+the same schedule and each proves one upload, so pass --backup-target with
+the target already in use; its credentials come from the environment, as at
+install. Only after all of that does the cluster's tier change, in the install
+journal and the control-plane record together.
+
+There is no way back: an ha cluster stays ha.`,
+		Example: `  KUBENEST_BACKUP_ACCESS_KEY_ID=… KUBENEST_BACKUP_SECRET_ACCESS_KEY=… \
+  kubenest platform promote-ha --cluster prod-1 \
+    --server 10.0.1.11 --server 10.0.1.12 \
+    --backup-target "s3://kubenest-backups/prod-1?endpoint=s3.ap-south-1.amazonaws.com&region=ap-south-1"`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if f.Cluster == "" {
+				return fmt.Errorf("--cluster is required: which cluster to promote")
+			}
+			if len(f.Servers) != 2 {
+				return fmt.Errorf("the ha tier is three servers: pass exactly two --server addresses to join, got %d", len(f.Servers))
+			}
+			return runPromoteHA(cmd.Context(), cmd.OutOrStdout(), f)
+		},
+	}
+	fs := cmd.Flags()
+	fs.StringVar(&f.Cluster, "cluster", "", "cluster to promote (required)")
+	fs.StringVar(&f.Org, "org", "", "organization slug or id (only needed when your credential can see more than one)")
+	fs.StringArrayVar(&f.Servers, "server", nil, "address of a new control-plane node (exactly two)")
+	fs.StringVar(&f.BackupTarget, "backup-target", "", "the cluster's s3:// backup target, required when the existing server takes datastore snapshots")
+	fs.StringVar(&f.SSHUser, "ssh-user", "", "SSH user on the target nodes")
+	fs.StringVar(&f.SSHKey, "ssh-key", "", "SSH private key file; defaults to ssh-agent or ~/.ssh/config")
+	fs.BoolVar(&f.BreakLock, "break-lock", false, "take over the cluster's lock from a run whose lease has expired (a live run is never broken)")
+	return cmd
+}
+
+// openNodeSession rebuilds the install session a node change extends: the
+// journal's request, its non-secret record, and the manifest of the bundle
+// the control plane says the cluster is on now.
+//
+// The install journal is required, not a convenience. It is the only record
+// of which hosts are the cluster and who owns their volume groups, and a node
+// change that guessed either would leave upgrade and uninstall working from a
+// list that is no longer true.
+//
+// A node change writes that journal and changes the cluster, so it is locked
+// out exactly as an install or an upgrade is: the journal's lock on this
+// machine is taken here, before the journal is read or recovered, and the
+// cluster's lease once the change has dialled a server.
+func openNodeSession(ctx context.Context, out io.Writer, f NodeFlags) (*install.Session, error) {
+	runID := stages.NewRunID()
+	journalPath, err := install.JournalPath(f.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := stages.LockJournal(journalPath, stages.NewHolder(runID), f.BreakLock,
+		func(format string, args ...any) { fmt.Fprintf(out, format+"\n", args...) })
+	if err != nil {
+		return nil, err
+	}
+	session, err := readNodeSession(ctx, out, f, runID)
+	if err != nil {
+		lock.Release()
+		return nil, err
+	}
+	session.Lock = lock
+	return session, nil
+}
+
+// readNodeSession is openNodeSession under the journal's lock.
+func readNodeSession(ctx context.Context, out io.Writer, f NodeFlags, runID string) (*install.Session, error) {
+	// Another workstation may have changed this cluster's nodes since this
+	// one last did: its journal, on the control plane and the primary
+	// server, is the node set this change has to start from. Without a
+	// control-plane login only the server's copy can be asked, and the
+	// refusal below says what is missing.
+	client, _ := controlPlaneClient()
+	journal, _, err := findJournal(f.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	server := ""
+	if journal != nil {
+		if servers, _ := install.NodesFromJournal(journal); len(servers) > 0 {
+			server = servers[0]
+		}
+	}
+	sshOpts := sshx.Options{User: f.SSHUser, KeyPath: f.SSHKey, DialTimeout: 15 * time.Second}
+	if _, err := recoverInstallJournal(ctx, out, client, f.Org, f.Cluster, server, sshOpts); err != nil {
+		return nil, err
+	}
+	journal, journalPath, err := findJournal(f.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	if journal == nil {
+		return nil, fmt.Errorf("no install journal for cluster %q at %s: node changes read the cluster's nodes and storage from it, and no copy could be recovered from the control plane or the cluster's primary server", f.Cluster, journalPath)
+	}
+	if _, done := journal.Completed(install.StageVerify); !done {
+		return nil, fmt.Errorf("the install of %s has not completed: finish it first (re-run `kubenest platform install`), then change its nodes", f.Cluster)
+	}
+	recorded, err := install.Recorded(journal)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err = controlPlaneClient()
+	if err != nil {
+		return nil, err
+	}
+	record, err := client.BundleRecord(ctx, journal.ClusterID)
+	if err != nil {
+		return nil, fmt.Errorf("reading what this cluster has installed: %w", err)
+	}
+	m, err := fetchManifest(ctx, client, record.BundleVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := install.OptionsFromJournal(journal)
+	opts.SSHUser, opts.SSHKey = f.SSHUser, f.SSHKey
+	opts.Parallelism = f.Parallelism
+	session := &install.Session{
+		ID:       runID,
+		Opts:     opts,
+		Bundle:   m,
+		Jnl:      journal,
+		Reporter: converge.NewTextReporter(out),
+		Out:      out,
+		API:      client,
+		Record:   recorded,
+	}
+	// The change is recorded where the install's own transitions were, so
+	// the next workstation recovers it. The primary server's copy starts
+	// once the change has dialled a server.
+	journal.Replicate(stages.ControlPlaneReplica{Client: client, ClusterID: journal.ClusterIDValue, Kind: install.Kind}, session.Logf)
+	return session, nil
+}
+
+// runAddNode is `kubenest platform add-node`.
+func runAddNode(ctx context.Context, out io.Writer, f NodeFlags) error {
+	session, err := openNodeSession(ctx, out, f)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	ctx, stop := session.Lock.Guard(ctx)
+	defer stop()
+
+	if len(f.Servers) > 0 {
+		fmt.Fprintf(out, "Adding %d server node(s) to %s at bundle %s.\n\n", len(f.Servers), f.Cluster, session.Bundle.Bundle)
+		err = install.AddServers(ctx, session, install.PromoteOptions{Servers: f.Servers, BackupTarget: f.BackupTarget})
+	} else {
+		fmt.Fprintf(out, "Adding %d agent node(s) to %s at bundle %s.\n\n", len(f.Agents), f.Cluster, session.Bundle.Bundle)
+		err = install.AddAgents(ctx, session, f.Agents)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\nDone. %s now has %d node(s); the install journal and the control-plane record both list them.\n",
+		f.Cluster, len(session.Opts.Servers)+len(session.Opts.Agents))
+	return nil
+}
+
+// runRemoveNode is `kubenest platform remove-node`.
+func runRemoveNode(ctx context.Context, out io.Writer, f NodeFlags) error {
+	session, err := openNodeSession(ctx, out, f)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	ctx, stop := session.Lock.Guard(ctx)
+	defer stop()
+
+	fmt.Fprintf(out, "Removing %s from %s.\n\n", f.Node, f.Cluster)
+	if err := install.RemoveNode(ctx, session, install.RemoveNodeOptions{
+		Address:            f.Node,
+		AcknowledgeVolumes: f.AcknowledgeVolumes,
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\nDone. %s has left %s; the install journal and the control-plane record no longer list it.\n", f.Node, f.Cluster)
+	return nil
+}
+
+// runPromoteHA is `kubenest platform promote-ha`.
+func runPromoteHA(ctx context.Context, out io.Writer, f NodeFlags) error {
+	session, err := openNodeSession(ctx, out, f)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	ctx, stop := session.Lock.Guard(ctx)
+	defer stop()
+
+	fmt.Fprintf(out, "Promoting %s to the ha tier: joining %s to the etcd cluster.\n\n", f.Cluster, strings.Join(f.Servers, " and "))
+	if err := install.PromoteHA(ctx, session, install.PromoteOptions{
+		Servers:      f.Servers,
+		BackupTarget: f.BackupTarget,
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\nDone. %s is on the ha tier with servers %s.\n", f.Cluster, strings.Join(session.Opts.Servers, ", "))
+	return nil
+}
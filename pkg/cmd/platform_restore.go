@@ -48,7 +48,7 @@ root-readable k3s config, and are removed before k3s restarts.`,
 			if snapshot == "" {
 				return fmt.Errorf("--snapshot is required: use the exact name reported by `k3s etcd-snapshot ls --s3`")
 			}
-			if err := conn.validate(); err != nil {
+			if err := conn.validate(cmd); err != nil {
 				return err
 			}
 			target.AccessKeyID = envFirst("KUBENEST_BACKUP_ACCESS_KEY_ID", "AWS_ACCESS_KEY_ID")
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -10,13 +11,18 @@ import (
 	"strings"
 	"time"
 
+	"golang.org/x/term"
+
+	"kubenest.io/cli/pkg/airgap"
 	"kubenest.io/cli/pkg/api"
 	"kubenest.io/cli/pkg/config"
 	"kubenest.io/cli/pkg/converge"
 	"kubenest.io/cli/pkg/install"
 	"kubenest.io/cli/pkg/manifest"
 	"kubenest.io/cli/pkg/sshx"
+	"kubenest.io/cli/pkg/stages"
 	"kubenest.io/cli/pkg/storage"
+	"kubenest.io/cli/pkg/trace"
 	"kubenest.io/cli/pkg/uninstall"
 )
 
@@ -44,8 +50,106 @@ func controlPlaneClient() (*api.Client, error) {
 	return api.New(cfg.ControlPlaneURL, api.WithToken(token))
 }
 
+// Options resolves the flag surface into the installer's request. The journal
+// identity is taken from the result, so whatever supplied the values — the
+// command line, a spec file, or both — a resume is checked against what was
+// actually asked for.
+func (f InstallFlags) Options() install.Options {
+	return install.Options{
+		Bundle:              f.Bundle,
+		Name:                f.Name,
+		Org:                 f.Org,
+		Servers:             f.Servers,
+		Agents:              f.Agents,
+		HATier:              f.HATier,
+		Profiles:            f.Profiles,
+		SSHUser:             f.SSHUser,
+		SSHKey:              f.SSHKey,
+		StorageDevice:       f.StorageDevice,
+		ReplicatedDevice:    f.ReplicatedDevice,
+		BackupTarget:        f.BackupTarget,
+		RegistryMirrors:     f.RegistryMirrors,
+		RegistryCredentials: f.RegistryCredentials,
+		HTTPProxy:           f.HTTPProxy,
+		HTTPSProxy:          f.HTTPSProxy,
+		NoProxy:             f.NoProxy,
+		Parallelism:         f.Parallelism,
+	}
+}
+
+// The --output formats of install and upgrade.
+const (
+	OutputText   = "text"
+	OutputNDJSON = "ndjson"
+)
+
+// validateOutput accepts the formats, and empty for text.
+func validateOutput(format string) error {
+	switch format {
+	case "", OutputText, OutputNDJSON:
+		return nil
+	}
+	return fmt.Errorf("--output %q is not a format: use text, or ndjson for one JSON event per line", format)
+}
+
+// outputStreams splits an operation's output for --output. With ndjson, stdout
+// is the event stream and nothing else, so a pipeline can read it line by
+// line; the text a person would have seen moves to stderr, unchanged. With
+// text there is no stream.
+func outputStreams(format string, out, errOut io.Writer) (io.Writer, *stages.NDJSON) {
+	if format != OutputNDJSON {
+		return out, nil
+	}
+	return errOut, stages.NewNDJSON(out)
+}
+
+// liveView is the live progress view for an operation whose text goes to a
+// terminal, titled title; anywhere else — a pipe, a file, CI's log, a dumb
+// terminal, --output ndjson — it is nil, and the text is printed a line per
+// transition as it always was.
+func liveView(format string, out io.Writer, title string) *stages.Live {
+	f, ok := out.(*os.File)
+	if format == OutputNDJSON || !ok || !term.IsTerminal(int(f.Fd())) || os.Getenv("TERM") == "dumb" {
+		return nil
+	}
+	live := stages.NewLive(f, func() int {
+		width, _, err := term.GetSize(int(f.Fd()))
+		if err != nil {
+			return 0
+		}
+		return width
+	})
+	live.Title = title
+	return live
+}
+
+// startLive puts the live view on the screen for sequence, counting down
+// the run's total deadline. A nil view is no view.
+func startLive(live *stages.Live, sequence []stages.Stage, deadline func() (time.Duration, error)) {
+	if live == nil {
+		return
+	}
+	names := make([]string, len(sequence))
+	for i, s := range sequence {
+		names[i] = s.Name
+	}
+	live.Stages(names)
+	if d, err := deadline(); err == nil {
+		live.Deadline = d
+	}
+	live.Start()
+}
+
 // runInstall is `kubenest platform install`.
-func runInstall(ctx context.Context, out io.Writer, f InstallFlags) error {
+func runInstall(ctx context.Context, out, errOut io.Writer, f InstallFlags) (err error) {
+	runID := install.NewRunID()
+	ctx, finishTrace, err := startTrace(ctx, errOut, f.TraceOTLP, f.TraceFile, runID, "kubenest platform install",
+		trace.String("kubenest.cluster", f.Name), trace.String("kubenest.bundle", f.Bundle))
+	if err != nil {
+		return err
+	}
+	defer func() { finishTrace(err) }()
+
 	client, err := controlPlaneClient()
 	if err != nil {
 		return err
@@ -62,58 +166,139 @@ func runInstall(ctx context.Context, out io.Writer, f InstallFlags) error {
 	if err != nil {
 		return fmt.Errorf("bundle %s from the control plane is not a valid manifest: %w", f.Bundle, err)
 	}
-
-	opts := install.Options{
-		Bundle:        f.Bundle,
-		Name:          f.Name,
-		Org:           f.Org,
-		Servers:       f.Servers,
-		Agents:        f.Agents,
-		HATier:        f.HATier,
-		Profiles:      f.Profiles,
-		SSHUser:       f.SSHUser,
-		SSHKey:        f.SSHKey,
-		StorageDevice: f.StorageDevice,
-		BackupTarget:  f.BackupTarget,
+	// The manifest is still the control plane's; the archive only supplies
+	// the artifacts, and preflight proves it carries exactly what this
+	// manifest pins.
+	if f.OfflineBundle != "" {
+		archive, err := airgap.Open(f.OfflineBundle)
+		if err != nil {
+			return err
+		}
+		defer archive.Close()
+		if archive.Bundle() != f.Bundle {
+			return fmt.Errorf("%s was packed for bundle %s, not %s", f.OfflineBundle, archive.Bundle(), f.Bundle)
+		}
+		bundle.Offline = archive
 	}
 
+	opts := f.Options()
+
 	journalPath, err := install.JournalPath(f.Name)
 	if err != nil {
 		return err
 	}
+	// A plan touches nothing, this machine's journal included: it takes no
+	// lock, neither creating the lock file nor refusing while a run holds
+	// it, and is rendered from the journal as it stands.
+	if f.Plan || f.PlanDir != "" {
+		journal, err := install.OpenJournal(journalPath, opts.Identity())
+		if err != nil {
+			return err
+		}
+		return runInstallPlan(ctx, out, errOut, f, &install.Session{
+			ID:       runID,
+			Opts:     opts,
+			Bundle:   bundle,
+			Jnl:      journal,
+			Reporter: converge.NewTextReporter(errOut),
+			Out:      errOut,
+			API:      client,
+		})
+	}
+	// Locked before anything reads or recovers the journal, and held until
+	// the run ends. The session adds the cluster's lease at stage 3.
+	lock, err := stages.LockJournal(journalPath, stages.NewHolder(runID), f.BreakLock,
+		func(format string, args ...any) { fmt.Fprintf(errOut, format+"\n", args...) })
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	// Another workstation may have run this install: the journal it left on
+	// the control plane and the primary server is what lets this one resume
+	// instead of starting over.
+	sshOpts := sshx.Options{User: f.SSHUser, KeyPath: f.SSHKey, DialTimeout: 15 * time.Second}
+	if _, err := recoverInstallJournal(ctx, errOut, client, f.Org, f.Name, f.Servers[0], sshOpts); err != nil {
+		return err
+	}
 	journal, err := install.OpenJournal(journalPath, opts.Identity())
 	if err != nil {
 		return err
 	}
+	out, stream := outputStreams(f.Output, out, errOut)
+	// On a terminal the view replaces the text emitter and reporter, and
+	// everything else written to out is printed above it.
+	var reporter converge.Reporter = converge.NewTextReporter(out)
+	var text install.Emitter = install.TextEmitter{W: out}
+	live := liveView(f.Output, out, "Installing "+f.Name)
+	if live != nil {
+		defer live.Stop()
+		out, reporter, text = live, live, live
+	}
 	if entry, resuming := journal.LastFailure(); resuming {
 		fmt.Fprintf(out, "Resuming: the previous run stopped at stage %s (%s).\nCompleted stages will be skipped.\n\n",
 			entry.Stage, entry.At.Format(time.RFC3339))
 	}
 
 	session := &install.Session{
-		ID:       install.NewRunID(),
+		ID:       runID,
 		Opts:     opts,
 		Bundle:   bundle,
 		Jnl:      journal,
-		Reporter: converge.NewTextReporter(out),
+		Reporter: reporter,
 		Out:      out,
 		API:      client,
+		Lock:     lock,
 	}
 	defer session.Close()
+	// The primary server's copy starts at stage 3, with the first write to
+	// any machine; the control plane's with the cluster id, at stage 2.
+	journal.Replicate(stages.ControlPlaneReplica{Client: client, ClusterID: journal.ClusterIDValue, Kind: install.Kind}, session.Logf)
 
 	// Printed locally AND published to the control plane, from the same
 	// transition: the operator at the terminal and the console watching the
-	// install see the same thirteen stages.
-	session.Emit = install.Emitters{
-		install.TextEmitter{W: out},
-		install.NewControlPlaneEmitter(client, func() string { return journal.ClusterID }),
+	// install see the same thirteen stages. With --output ndjson, streamed
+	// for a pipeline too.
+	emitters := install.Emitters{
+		text,
+		install.NewControlPlaneEmitter(client, journal.ClusterIDValue),
 	}
+	if stream != nil {
+		emitters = append(emitters, stream)
+		session.Reporter = converge.Reporters{session.Reporter, stream}
+		session.Findings = stream
+	}
+	webhooks, err := clusterWebhooks(f.Name, "install", runID)
+	if err != nil {
+		return err
+	}
+	for _, w := range webhooks {
+		emitters = append(emitters, w)
+	}
+	session.Emit = emitters
 
 	fmt.Fprintf(out, "Installing platform bundle %s on %d node(s), %s tier.\n",
 		f.Bundle, len(f.Servers)+len(f.Agents), f.HATier)
 	fmt.Fprintf(out, "Nothing is written to any machine until stage 3.\n\n")
 
-	result, err := install.Execute(ctx, session, install.Plan(session))
+	sequence, err := attachHooks(f.Hooks, install.Plan(session), session.NodeRunner)
+	if err != nil {
+		return err
+	}
+	if err := reopenStages(out, journal, sequence, f.Rerun(), runID); err != nil {
+		return err
+	}
+
+	ctx, stop := lock.Guard(ctx)
+	defer stop()
+	startLive(live, sequence, session.TotalDeadline)
+	result, err := install.Execute(ctx, session, sequence)
+	if live != nil {
+		live.Stop()
+	}
+	if stream != nil {
+		_ = stream.Result(result, err)
+	}
+	notifyResult(ctx, out, webhooks, result, err)
 	if err != nil {
 		return err
 	}
@@ -127,6 +312,130 @@ func runInstall(ctx context.Context, out io.Writer, f InstallFlags) error {
 	return nil
 }
 
+// startTrace starts tracing a run into --trace-otlp, --trace-file or both,
+// and returns the context its spans descend from and the function that ends
+// the trace with the run's outcome. Without either flag there is no tracer:
+// the context is returned as it is, and nothing beneath it records a span.
+func startTrace(ctx context.Context, errOut io.Writer, otlp, file, runID, name string, attrs ...trace.Attr) (context.Context, func(error), error) {
+	var exporters trace.Exporters
+	if otlp != "" {
+		exporter, err := trace.NewOTLP(otlp)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporters = append(exporters, exporter)
+	}
+	if file != "" {
+		exporter, err := trace.NewFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporters = append(exporters, exporter)
+	}
+	if len(exporters) == 0 {
+		return ctx, func(error) {}, nil
+	}
+	logf := func(format string, args ...any) { fmt.Fprintf(errOut, format+"\n", args...) }
+	tracer := trace.New(runID, exporters, logf)
+	ctx, root := tracer.Root(ctx, name, append(attrs, trace.String("kubenest.run_id", runID))...)
+	logf("Tracing this run as trace %s.", tracer.TraceID())
+	return ctx, func(err error) {
+		var paused *stages.PausedError
+		switch {
+		case errors.As(err, &paused):
+			root.SetAttributes(trace.Bool("kubenest.paused", true))
+		case err != nil:
+			root.Fail(stages.Sanitize(err.Error()))
+		}
+		root.End()
+		if err := tracer.Close(); err != nil {
+			logf("warning: could not finish the trace: %v", err)
+		}
+	}, nil
+}
+
+// clusterWebhooks builds the webhooks `kubenest cluster set-notify`
+// configured for a cluster, for one run of operation.
+func clusterWebhooks(cluster, operation, runID string) ([]*stages.Webhook, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	var hooks []*stages.Webhook
+	for _, w := range cfg.WebhooksFor(cluster) {
+		hooks = append(hooks, &stages.Webhook{
+			URL: w.URL, Format: w.Format, On: w.On, Template: w.Template,
+			Operation: operation, Cluster: cluster, RunID: runID,
+		})
+	}
+	return hooks, nil
+}
+
+// notifyResult tells every webhook how the run ended. A notification that
+// cannot be delivered is a warning: the run's outcome is what it was.
+func notifyResult(ctx context.Context, out io.Writer, hooks []*stages.Webhook, result stages.Result, err error) {
+	for _, w := range hooks {
+		if notifyErr := w.Result(ctx, result, err); notifyErr != nil {
+			fmt.Fprintf(out, "warning: %v\n", notifyErr)
+		}
+	}
+}
+
+// attachHooks binds the --hooks file, when one was given, to the sequence.
+func attachHooks(path string, sequence []stages.Stage, node func(address string) (stages.Runner, error)) ([]stages.Stage, error) {
+	if path == "" {
+		return sequence, nil
+	}
+	specs, err := stages.ReadHooks(path)
+	if err != nil {
+		return nil, err
+	}
+	return stages.AttachHooks(sequence, specs, node)
+}
+
+// reopenStages records the operator's --rerun-stage and --from-stage in the
+// journal, and says which completed stages will run again because of them.
+func reopenStages(out io.Writer, journal *stages.Journal, sequence []stages.Stage, rerun stages.Rerun, runID string) error {
+	if rerun.Empty() {
+		return nil
+	}
+	reopened, err := stages.Reopen(journal, sequence, rerun, runID)
+	if err != nil {
+		return err
+	}
+	if len(reopened) == 0 {
+		fmt.Fprintf(out, "No completed stage to run again: every stage named will run anyway.\n\n")
+		return nil
+	}
+	fmt.Fprintf(out, "Running again at your request, although the journal has them completed: %s.\nNothing is removed first; each stage re-applies what it installs.\n\n", strings.Join(reopened, ", "))
+	return nil
+}
+
+// runInstallPlan is `kubenest platform install --plan`.
+//
+// The journal is opened, so a plan against an edited request is refused
+// exactly as the install would be, but it is never written: a plan is not a
+// run, and a journal entry for one would make the next real install think it
+// was resuming. Preflight's narrative goes to stderr so stdout is only the
+// rendering, ready to redirect to a file.
+func runInstallPlan(ctx context.Context, out, errOut io.Writer, f InstallFlags, session *install.Session) error {
+	defer session.Close()
+	fmt.Fprintf(errOut, "Planning platform bundle %s on %d node(s), %s tier. Preflight runs; nothing is written to any machine.\n",
+		f.Bundle, len(f.Servers)+len(f.Agents), f.HATier)
+	plan, err := install.Preview(ctx, session)
+	if err != nil {
+		return err
+	}
+	if f.PlanDir == "" {
+		return install.WritePlan(out, plan)
+	}
+	if err := install.WritePlanDir(f.PlanDir, plan); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Plan for %s written to %s, one directory per stage.\n", f.Name, f.PlanDir)
+	return nil
+}
+
 // runUninstall is `kubenest platform uninstall --confirm`.
 //
 // It reads the journal for the node list and the volume-group ownership. It
@@ -134,6 +443,23 @@ func runInstall(ctx context.Context, out io.Writer, f InstallFlags) error {
 // machine they want back — but then it refuses to remove any volume group,
 // because ownership it cannot establish is treated as the customer's.
 func runUninstall(ctx context.Context, out io.Writer, name string, destroyData bool, f InstallFlags) error {
+	sshOpts := sshx.Options{User: f.SSHUser, KeyPath: f.SSHKey, DialTimeout: 15 * time.Second}
+	// A journal another workstation wrote is recovered from its copies, so
+	// the ownership it recorded is honored here too. Without a control-plane
+	// login only the server's copy can be asked.
+	clusterID := ""
+	client, _ := controlPlaneClient()
+	if name != "" {
+		server := ""
+		if len(f.Servers) > 0 {
+			server = f.Servers[0]
+		}
+		id, err := recoverInstallJournal(ctx, out, client, f.Org, name, server, sshOpts)
+		if err != nil {
+			return err
+		}
+		clusterID = id
+	}
 	journal, journalPath, err := findJournal(name)
 	if err != nil {
 		return err
@@ -164,7 +490,6 @@ func runUninstall(ctx context.Context, out io.Writer, name string, destroyData b
 		return fmt.Errorf("no nodes to uninstall: pass --server (and --agent) for the hosts to clean, or --name for a cluster with an install journal")
 	}
 
-	sshOpts := sshx.Options{User: f.SSHUser, KeyPath: f.SSHKey, DialTimeout: 15 * time.Second}
 	var nodes []uninstall.Node
 	var closers []io.Closer
 	defer func() {
@@ -208,7 +533,12 @@ func runUninstall(ctx context.Context, out io.Writer, name string, destroyData b
 
 	if journal != nil {
 		// The journal outliving its cluster would make the next install on
-		// these hosts refuse to run, for a cluster that no longer exists.
+		// these hosts refuse to run, for a cluster that no longer exists;
+		// its copy in the control plane would make it resume. The servers'
+		// copies went with the rest of the install.
+		if client != nil && clusterID != "" {
+			journal.Replicate(stages.ControlPlaneReplica{Client: client, ClusterID: func() string { return clusterID }, Kind: install.Kind}, nil)
+		}
 		if err := journal.Remove(); err != nil {
 			return err
 		}
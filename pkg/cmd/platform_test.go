@@ -1,8 +1,14 @@
 package cmd
 
 import (
+	"bytes"
+	"os"
 	"strings"
 	"testing"
+
+	"kubenest.io/cli/pkg/install"
+	"kubenest.io/cli/pkg/stages"
+	"kubenest.io/cli/pkg/upgrade"
 )
 
 func TestInstallFlagsValidate(t *testing.T) {
@@ -44,6 +50,40 @@ func TestInstallFlagsValidate(t *testing.T) {
 	if err := f.Validate(); err == nil {
 		t.Error("single-server with two servers must be rejected")
 	}
+
+	f = valid()
+	f.ReplicatedDevice = "/dev/disk/by-id/scsi-0HC_Volume_2"
+	if err := f.Validate(); err == nil || !strings.Contains(err.Error(), "--profile replicated-storage") {
+		t.Errorf("--replicated-device without its profile must be rejected, got %v", err)
+	}
+	f.Profiles = []string{"replicated-storage"}
+	if err := f.Validate(); err != nil {
+		t.Errorf("--replicated-device with its profile rejected: %v", err)
+	}
+
+	f = valid()
+	f.Output = "json"
+	if err := f.Validate(); err == nil || !strings.Contains(err.Error(), "ndjson") {
+		t.Errorf("unknown --output must be rejected naming ndjson, got %v", err)
+	}
+	f.Output = OutputNDJSON
+	if err := f.Validate(); err != nil {
+		t.Errorf("--output ndjson rejected: %v", err)
+	}
+	f.Plan = true
+	if err := f.Validate(); err == nil || !strings.Contains(err.Error(), "--plan") {
+		t.Errorf("--output ndjson with --plan must be rejected, got %v", err)
+	}
+
+	f = valid()
+	f.FromStage = "platform-networking"
+	if err := f.Validate(); err != nil {
+		t.Errorf("--from-stage rejected: %v", err)
+	}
+	f.PlanDir = "plan"
+	if err := f.Validate(); err == nil || !strings.Contains(err.Error(), "--plan") {
+		t.Errorf("--from-stage with --plan must be rejected, got %v", err)
+	}
 }
 
 // Every platform command is implemented now. What is asserted here is that
@@ -57,6 +97,7 @@ func TestUpgradeRefusesWithoutItsRequiredFlags(t *testing.T) {
 	}{
 		{[]string{"platform", "upgrade"}, "--cluster is required"},
 		{[]string{"platform", "upgrade", "--cluster", "prod-1"}, "--to is required"},
+		{[]string{"platform", "upgrade", "--cluster", "prod-1", "--to", "1.1", "--output", "yaml"}, "--output \"yaml\""},
 		{[]string{"platform", "rollback"}, "--cluster is required"},
 		{[]string{"platform", "diff", "--from", "1.0"}, "--from and --to"},
 		{[]string{"cluster", "set-window"}, "--cluster is required"},
@@ -99,3 +140,114 @@ func TestUninstallDemandsConfirm(t *testing.T) {
 		t.Errorf("uninstall without --confirm must refuse, got: %v", err)
 	}
 }
+
+// add-node extends what the install journal records, so without one it
+// refuses before dialling anything rather than guessing at the cluster.
+func TestAddNodeNeedsTheInstallJournal(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := NewRootCommand()
+	root.SetArgs([]string{"platform", "add-node", "--cluster", "prod-1", "--agent", "10.0.1.13"})
+	err := root.Execute()
+	if err == nil || !strings.Contains(err.Error(), "no install journal") {
+		t.Errorf("add-node without a journal must refuse, got: %v", err)
+	}
+
+	root = NewRootCommand()
+	root.SetArgs([]string{"platform", "add-node", "--cluster", "prod-1"})
+	if err := root.Execute(); err == nil || !strings.Contains(err.Error(), "--agent") {
+		t.Errorf("add-node without a node must refuse, got: %v", err)
+	}
+}
+
+// The journal commands read what a resume reads, by the identity recorded in
+// each journal, and refuse to guess which of a cluster's two to export.
+func TestJournalCommandsReadBothKinds(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path, err := install.JournalPath("prod-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := install.OpenJournal(path, install.Options{Name: "prod-1", Bundle: "1.0", HATier: "single-server", Servers: []string{"10.0.1.10"}}.Identity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []install.Entry{
+		{Stage: install.StagePreflight, Status: install.StatusStarted, RunID: "run-a"},
+		{Stage: install.StagePreflight, Status: install.StatusCompleted, RunID: "run-a"},
+		{Stage: install.StageRegister, Status: install.StatusStarted, RunID: "run-a"},
+	} {
+		if err := j.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path, err = upgrade.JournalPath("prod-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := stages.OpenJournal(path, upgrade.Options{Cluster: "prod-1", To: "1.1", Servers: []string{"10.0.1.10"}}.Identity("1.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		root := NewRootCommand()
+		root.SetOut(&out)
+		root.SetArgs(append([]string{"platform", "journal"}, args...))
+		err := root.Execute()
+		return out.String(), err
+	}
+
+	out, err := run("list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"register", "started", "1.0 → 1.1", "not started"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("list is missing %q:\n%s", want, out)
+		}
+	}
+
+	out, err = run("show", "--cluster", "prod-1", "--kind", "install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"A resume must match", "HA tier", "single-server", "--storage-device", "Run 1 of 1, run-a", "never finished"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("show is missing %q:\n%s", want, out)
+		}
+	}
+
+	if _, err := run("export", "--cluster", "prod-1"); err == nil || !strings.Contains(err.Error(), "--kind") {
+		t.Errorf("export of a cluster with both journals must ask which, got %v", err)
+	}
+	out, err = run("export", "--cluster", "prod-1", "--kind", "upgrade")
+	if err != nil || !strings.Contains(out, `"to bundle": "1.1"`) {
+		t.Errorf("export = %s, %v", out, err)
+	}
+	if _, err := run("show", "--cluster", "prod-2"); err == nil || !strings.Contains(err.Error(), "journal list") {
+		t.Errorf("show of an unknown cluster must point at the list, got %v", err)
+	}
+}
+
+// The live view is for a terminal only: a buffer, a pipe, a file or an
+// ndjson stream gets the plain text.
+func TestLiveViewOnlyOnATerminal(t *testing.T) {
+	if liveView(OutputText, &bytes.Buffer{}, "Installing prod-1") != nil {
+		t.Error("a live view for a buffer")
+	}
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if liveView(OutputText, f, "Installing prod-1") != nil {
+		t.Error("a live view for a file")
+	}
+	if liveView(OutputNDJSON, os.Stdout, "Installing prod-1") != nil {
+		t.Error("a live view with --output ndjson")
+	}
+}
@@ -1,7 +1,8 @@
 // Package cmd wires the kubenest command tree.
 //
-// The CLI is the platform installer: login, platform install/uninstall/upgrade
-// and backup operations, per docs.kubenest.io/platform. The pre-2026 app-layer
+// The CLI is the platform installer: login, platform install/uninstall/upgrade,
+// backup operations and offline bundles, per docs.kubenest.io/platform. The
+// pre-2026 app-layer
 // commands (apps, deploy, exec, logs, registry, teams) were removed: they are
 // frozen scope under decision D1 and target a control-plane API surface
 // (teams, X-Team-UUID) that no longer exists. They live on in git history.
@@ -35,6 +36,8 @@ uploaded to the control plane and never written to logs.`,
 		NewPlatformCommand(),
 		NewClusterCommand(),
 		NewBackupCommand(),
+		NewBundleCommand(),
+		NewSecretsCommand(),
 	)
 	return root
 }
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"kubenest.io/cli/pkg/component/secrets"
+	"kubenest.io/cli/pkg/install"
+	"kubenest.io/cli/pkg/sshx"
+)
+
+// NewSecretsCommand groups the secrets profile's day-to-day operations.
+func NewSecretsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Seal secrets for a cluster with the secrets profile",
+	}
+	cmd.AddCommand(newSealCommand())
+	return cmd
+}
+
+// SealFlags is the flag surface of `kubenest secrets seal`.
+type SealFlags struct {
+	Cluster string
+	File    string
+	Scope   string
+	// Cert is a sealing certificate saved from an earlier seal; with it no
+	// connection is made at all.
+	Cert     string
+	SaveCert string
+	Server   string
+	SSHUser  string
+	SSHKey   string
+}
+
+// Validate checks the flags before anything is read or dialled.
+func (f SealFlags) Validate() error {
+	if f.File == "" {
+		return fmt.Errorf("-f is required: name the Secret manifest to seal, or - for standard input")
+	}
+	if f.Cert == "" && f.Cluster == "" && f.Server == "" {
+		return fmt.Errorf("name the cluster whose controller will unseal it: --cluster (with an install journal on this machine), --server, or a saved --cert")
+	}
+	_, err := secrets.ParseScope(f.Scope)
+	return err
+}
+
+func newSealCommand() *cobra.Command {
+	f := SealFlags{Scope: string(secrets.ScopeStrict)}
+	cmd := &cobra.Command{
+		Use:   "seal -f secret.yaml",
+		Short: "Encrypt a Secret into a SealedSecret that only the cluster can open",
+		Long: `Encrypt a Kubernetes Secret manifest into a SealedSecret that is safe to
+commit to git. Only the sealed-secrets controller of the cluster it was sealed
+for can turn it back into the Secret.
+
+Sealing needs the controller's public certificate, nothing else. It is read
+from the cluster's server over SSH — no kubeconfig is needed or created — and
+can be saved with --save-cert, so teams without SSH access seal against the
+file with --cert. The certificate is public; the private keys never leave the
+cluster, and are in the cluster's workload backups.
+
+The Secret's values are encrypted here, on this machine, and written to
+standard output. The Secret must name its namespace. --scope strict (the
+default) binds it to its name and namespace; namespace-wide allows renaming it
+within the namespace, cluster-wide allows any name anywhere.
+
+The cluster's servers come from --server, or from the install journal on this
+machine for --cluster.`,
+		Example: `  kubenest secrets seal --cluster prod-1 -f db-password.yaml > db-password.sealed.yaml
+
+  # Save the certificate once, and seal against it without SSH.
+  kubenest secrets seal --cluster prod-1 -f db-password.yaml --save-cert prod-1.pem
+  kubenest secrets seal --cert prod-1.pem -f api-token.yaml > api-token.sealed.yaml`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := f.Validate(); err != nil {
+				return err
+			}
+			return runSeal(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout(), f)
+		},
+	}
+	fs := cmd.Flags()
+	fs.StringVar(&f.Cluster, "cluster", "", "cluster to seal for; its servers come from the install journal on this machine")
+	fs.StringVarP(&f.File, "file", "f", "", "Secret manifest to seal, or - for standard input (required)")
+	fs.StringVar(&f.Scope, "scope", f.Scope, "where it may be unsealed: strict, namespace-wide or cluster-wide")
+	fs.StringVar(&f.Cert, "cert", "", "seal against a saved certificate instead of reading it from the cluster")
+	fs.StringVar(&f.SaveCert, "save-cert", "", "also write the cluster's sealing certificate to this file")
+	fs.StringVar(&f.Server, "server", "", "server node address, instead of the install journal's")
+	fs.StringVar(&f.SSHUser, "ssh-user", "", "SSH user on the server node")
+	fs.StringVar(&f.SSHKey, "ssh-key", "", "SSH private key file; defaults to ssh-agent or ~/.ssh/config")
+	return cmd
+}
+
+// runSeal is `kubenest secrets seal`.
+func runSeal(ctx context.Context, stdin io.Reader, out io.Writer, f SealFlags) error {
+	var manifest []byte
+	var err error
+	if f.File == "-" {
+		manifest, err = io.ReadAll(stdin)
+	} else {
+		manifest, err = os.ReadFile(f.File)
+	}
+	if err != nil {
+		return err
+	}
+
+	cert, err := sealingCert(ctx, f)
+	if err != nil {
+		return err
+	}
+	key, err := secrets.PublicKey(cert)
+	if err != nil {
+		return err
+	}
+	if f.SaveCert != "" {
+		if err := os.WriteFile(f.SaveCert, cert, 0o644); err != nil {
+			return err
+		}
+	}
+	scope, _ := secrets.ParseScope(f.Scope)
+	sealed, err := secrets.Seal(key, manifest, scope, rand.Reader)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(sealed)
+	return err
+}
+
+// sealingCert is the certificate from --cert, or read from the cluster's
+// first server.
+func sealingCert(ctx context.Context, f SealFlags) ([]byte, error) {
+	if f.Cert != "" {
+		return os.ReadFile(f.Cert)
+	}
+	server := f.Server
+	if server == "" {
+		journal, path, err := findJournal(f.Cluster)
+		if err != nil {
+			return nil, err
+		}
+		if journal == nil {
+			return nil, fmt.Errorf("no install journal for cluster %q at %s: pass --server with a server node's address, or --cert with a saved certificate", f.Cluster, path)
+		}
+		servers := install.OptionsFromJournal(journal).Servers
+		if len(servers) == 0 {
+			return nil, fmt.Errorf("the install journal for %s names no servers: pass --server", f.Cluster)
+		}
+		server = servers[0]
+	}
+	opts := sshx.Options{User: f.SSHUser, KeyPath: f.SSHKey}
+	ep, err := sshx.Resolve(server, opts)
+	if err != nil {
+		return nil, err
+	}
+	client, err := sshx.Dial(ctx, ep, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	cert, err := secrets.Cert(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("reading the sealing certificate from %s: %w (is the secrets profile installed?)", server, err)
+	}
+	if len(cert) == 0 {
+		return nil, fmt.Errorf("the sealed-secrets controller on %s has no active key in %s: install the secrets profile, or wait for the controller to finish starting", server, secrets.Namespace)
+	}
+	return cert, nil
+}
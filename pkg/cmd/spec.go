@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A cluster spec file is the install flag surface written down: the same
+// fields, the same validation, reviewable in a pull request instead of
+// retyped at a terminal. It is an input to the command and nothing more — the
+// journal and the control plane still record the RESOLVED request, so a file
+// edited between a failed run and its resume is the same "different install"
+// refusal an edited command line is.
+//
+// Flags override the file, field by field. A repeatable flag given on the
+// command line replaces the file's whole list rather than appending to it:
+// `--agent 10.0.1.13` against a file naming two agents means one agent, which
+// is what the command line says. Merging would make the effective node set
+// something neither the file nor the command line shows.
+
+const (
+	// ClusterSpecAPIVersion is the only spec schema this CLI reads. A new
+	// schema gets a new version and the old one keeps being read; a file
+	// whose version this build does not know is refused rather than guessed.
+	ClusterSpecAPIVersion = "platform.kubenest.io/v1"
+	// ClusterSpecKind is the only kind a spec file may declare.
+	ClusterSpecKind = "Cluster"
+)
+
+// ClusterSpec is a versioned `kind: Cluster` file.
+//
+//	apiVersion: platform.kubenest.io/v1
+//	kind: Cluster
+//	metadata:
+//	  name: prod-1
+//	spec:
+//	  bundle: "1.4"
+//	  ha: single-server
+//	  servers: [10.0.1.10]
+//	  agents: [10.0.1.11, 10.0.1.12]
+//	  profiles: [observability]
+//	  storageDevice: /dev/nvme1n1
+//	  backupTarget: s3://kubenest-backups/prod-1?endpoint=s3.ap-south-1.amazonaws.com&region=ap-south-1
+//	  registry:
+//	    mirrors: [docker.io=https://mirror.example.com]
+//	    credentials: /etc/kubenest/prod-1-registries.yaml
+//	  proxy:
+//	    https: http://proxy.example.com:3128
+//	    noProxy: [10.0.1.0/24]
+//	  ssh:
+//	    user: ubuntu
+//	    key: ~/.ssh/id_ed25519
+//	  hooks: /etc/kubenest/site-hooks.yaml
+//
+// Its paths — ssh.key, registry.credentials, hooks — are resolved when the
+// file is loaded, as a shell would have resolved them on a command line: a
+// leading ~ is the home directory, and a relative path is relative to the
+// spec file, not to wherever the command happens to be run from. A reviewed
+// file that names ./keys/prod-1 means the key beside it.
+//
+// It carries no credentials and has nowhere to put one: backup keys come from
+// the environment exactly as they do for --backup-target, and registry
+// credentials from the environment or the file registry.credentials names,
+// and proxy credentials from the environment, so the spec is safe to commit.
+type ClusterSpec struct {
+	APIVersion string          `yaml:"apiVersion"`
+	Kind       string          `yaml:"kind"`
+	Metadata   ClusterMetadata `yaml:"metadata"`
+	Spec       ClusterSpecBody `yaml:"spec"`
+}
+
+// ClusterMetadata names the cluster.
+type ClusterMetadata struct {
+	Name string `yaml:"name"`
+	// Org is only needed when the credential can see more than one
+	// organization, as with --org.
+	Org string `yaml:"org,omitempty"`
+}
+
+// ClusterSpecBody mirrors InstallFlags, one field per flag.
+type ClusterSpecBody struct {
+	Bundle        string   `yaml:"bundle"`
+	HATier        string   `yaml:"ha"`
+	Servers       []string `yaml:"servers"`
+	Agents        []string `yaml:"agents,omitempty"`
+	Profiles      []string `yaml:"profiles,omitempty"`
+	StorageDevice string   `yaml:"storageDevice,omitempty"`
+	// ReplicatedDevice is --replicated-device.
+	ReplicatedDevice string       `yaml:"replicatedDevice,omitempty"`
+	BackupTarget     string       `yaml:"backupTarget,omitempty"`
+	Registry         RegistrySpec `yaml:"registry,omitempty"`
+	Proxy            ProxySpec    `yaml:"proxy,omitempty"`
+	SSH              SSHSpec      `yaml:"ssh,omitempty"`
+	// Hooks is --hooks: the path of the site's hooks file, which install
+	// and upgrade both read.
+	Hooks string `yaml:"hooks,omitempty"`
+}
+
+// RegistrySpec is --registry-mirror and --registry-credentials. Credentials
+// is a path, like ssh.key; the passwords stay in the file it names.
+type RegistrySpec struct {
+	Mirrors     []string `yaml:"mirrors,omitempty"`
+	Credentials string   `yaml:"credentials,omitempty"`
+}
+
+// ProxySpec is --http-proxy, --https-proxy and --no-proxy. The URLs carry no
+// credentials, here as on the command line.
+type ProxySpec struct {
+	HTTP    string   `yaml:"http,omitempty"`
+	HTTPS   string   `yaml:"https,omitempty"`
+	NoProxy []string `yaml:"noProxy,omitempty"`
+}
+
+// SSHSpec is how the CLI reaches the nodes. The key is a path; the key
+// material itself never appears in a spec.
+type SSHSpec struct {
+	User string `yaml:"user,omitempty"`
+	Key  string `yaml:"key,omitempty"`
+}
+
+// LoadClusterSpec reads and checks a spec file.
+func LoadClusterSpec(path string) (*ClusterSpec, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading cluster spec: %w", err)
+	}
+	spec, err := ParseClusterSpec(raw)
+	if err != nil {
+		return nil, fmt.Errorf("cluster spec %s: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+	for _, p := range []*string{&spec.Spec.SSH.Key, &spec.Spec.Registry.Credentials, &spec.Spec.Hooks} {
+		if *p, err = specPath(*p, dir); err != nil {
+			return nil, fmt.Errorf("cluster spec %s: %w", path, err)
+		}
+	}
+	return spec, nil
+}
+
+// specPath resolves one of a spec's paths against dir, the file's directory.
+// Nothing else would: the SSH dialer and the hooks loader open the path they
+// are given, and a literal "~/.ssh/id_ed25519" is a file nobody has.
+func specPath(p, dir string) (string, error) {
+	switch {
+	case p == "":
+		return "", nil
+	case p == "~" || strings.HasPrefix(p, "~/"):
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("resolving %s: %w", p, err)
+		}
+		return filepath.Join(home, p[1:]), nil
+	case strings.HasPrefix(p, "~"):
+		return "", fmt.Errorf("%s: only ~ and ~/ are expanded, not another user's home directory", p)
+	case filepath.IsAbs(p):
+		return p, nil
+	}
+	return filepath.Join(dir, p), nil
+}
+
+// ParseClusterSpec decodes a spec strictly. An unknown field is an error,
+// not an ignored line: `agnets:` silently dropping two nodes is exactly the
+// class of mistake a reviewed file exists to prevent.
+func ParseClusterSpec(raw []byte) (*ClusterSpec, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	var spec ClusterSpec
+	if err := dec.Decode(&spec); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("the file is empty")
+		}
+		return nil, err
+	}
+	switch {
+	case spec.APIVersion == "":
+		return nil, fmt.Errorf("apiVersion is required: this CLI reads %s", ClusterSpecAPIVersion)
+	case spec.APIVersion != ClusterSpecAPIVersion:
+		return nil, fmt.Errorf("apiVersion %q is not one this CLI reads (%s): upgrade the CLI or write the file against the version it knows", spec.APIVersion, ClusterSpecAPIVersion)
+	case spec.Kind != ClusterSpecKind:
+		return nil, fmt.Errorf("kind %q is not a cluster spec: want kind: %s", spec.Kind, ClusterSpecKind)
+	}
+	return &spec, nil
+}
+
+// flagChanged reports whether the operator set a flag on the command line.
+// It is cobra's FlagSet.Changed, passed as a function so the overlay rules
+// below read the same for every command that takes a spec.
+type flagChanged func(name string) bool
+
+func overlayString(changed flagChanged, flag string, dst *string, value string) {
+	if !changed(flag) && value != "" {
+		*dst = value
+	}
+}
+
+func overlayList(changed flagChanged, flag string, dst *[]string, value []string) {
+	if !changed(flag) && len(value) > 0 {
+		*dst = append([]string(nil), value...)
+	}
+}
+
+// applyInstall fills every install flag the command line left unset. The
+// result is then validated by InstallFlags.Validate, like any command line.
+func (s *ClusterSpec) applyInstall(changed flagChanged, f *InstallFlags) {
+	overlayString(changed, "name", &f.Name, s.Metadata.Name)
+	overlayString(changed, "org", &f.Org, s.Metadata.Org)
+	overlayString(changed, "bundle", &f.Bundle, s.Spec.Bundle)
+	overlayString(changed, "ha", &f.HATier, s.Spec.HATier)
+	overlayList(changed, "server", &f.Servers, s.Spec.Servers)
+	overlayList(changed, "agent", &f.Agents, s.Spec.Agents)
+	overlayList(changed, "profile", &f.Profiles, s.Spec.Profiles)
+	overlayString(changed, "storage-device", &f.StorageDevice, s.Spec.StorageDevice)
+	overlayString(changed, "replicated-device", &f.ReplicatedDevice, s.Spec.ReplicatedDevice)
+	overlayString(changed, "backup-target", &f.BackupTarget, s.Spec.BackupTarget)
+	overlayList(changed, "registry-mirror", &f.RegistryMirrors, s.Spec.Registry.Mirrors)
+	overlayString(changed, "registry-credentials", &f.RegistryCredentials, s.Spec.Registry.Credentials)
+	overlayString(changed, "http-proxy", &f.HTTPProxy, s.Spec.Proxy.HTTP)
+	overlayString(changed, "https-proxy", &f.HTTPSProxy, s.Spec.Proxy.HTTPS)
+	overlayList(changed, "no-proxy", &f.NoProxy, s.Spec.Proxy.NoProxy)
+	overlayString(changed, "ssh-user", &f.SSHUser, s.Spec.SSH.User)
+	overlayString(changed, "ssh-key", &f.SSHKey, s.Spec.SSH.Key)
+	overlayString(changed, "hooks", &f.Hooks, s.Spec.Hooks)
+}
+
+// applyUpgrade fills the cluster, its nodes and the SSH transport. The
+// spec's bundle is the version the file DECLARES, so it becomes --to: editing
+// `bundle:` in a reviewed file and running upgrade against it is the
+// declarative way to move a cluster. Where it moves FROM is still the
+// cluster's own record, never the file.
+func (s *ClusterSpec) applyUpgrade(changed flagChanged, f *UpgradeFlags) {
+	overlayString(changed, "cluster", &f.Cluster, s.Metadata.Name)
+	overlayString(changed, "to", &f.To, s.Spec.Bundle)
+	overlayList(changed, "server", &f.Servers, s.Spec.Servers)
+	overlayList(changed, "agent", &f.Agents, s.Spec.Agents)
+	overlayString(changed, "ssh-user", &f.SSHUser, s.Spec.SSH.User)
+	overlayString(changed, "ssh-key", &f.SSHKey, s.Spec.SSH.Key)
+	overlayString(changed, "hooks", &f.Hooks, s.Spec.Hooks)
+}
+
+// applyUninstall fills the cluster name, its nodes and the transport. Nodes
+// from the file stand where --server and --agent would, so they are used in
+// place of the journal's list exactly as those flags are.
+func (s *ClusterSpec) applyUninstall(changed flagChanged, name *string, f *InstallFlags) {
+	overlayString(changed, "name", name, s.Metadata.Name)
+	overlayList(changed, "server", &f.Servers, s.Spec.Servers)
+	overlayList(changed, "agent", &f.Agents, s.Spec.Agents)
+	overlayString(changed, "ssh-user", &f.SSHUser, s.Spec.SSH.User)
+	overlayString(changed, "ssh-key", &f.SSHKey, s.Spec.SSH.Key)
+}
+
+// applyBackup fills a backup command's connection.
+func (s *ClusterSpec) applyBackup(changed flagChanged, c *backupConn) {
+	overlayString(changed, "cluster", &c.Cluster, s.Metadata.Name)
+	overlayList(changed, "server", &c.Servers, s.Spec.Servers)
+	overlayString(changed, "ssh-user", &c.SSHUser, s.Spec.SSH.User)
+	overlayString(changed, "ssh-key", &c.SSHKey, s.Spec.SSH.Key)
+}
+
+// applySpecFile loads path, when one was given, and applies it with apply.
+func applySpecFile(path string, apply func(*ClusterSpec)) error {
+	if path == "" {
+		return nil
+	}
+	spec, err := LoadClusterSpec(path)
+	if err != nil {
+		return err
+	}
+	apply(spec)
+	return nil
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const prodSpec = `apiVersion: platform.kubenest.io/v1
+kind: Cluster
+metadata:
+  name: prod-1
+spec:
+  bundle: "1.4"
+  ha: single-server
+  servers: [10.0.1.10]
+  agents: [10.0.1.11, 10.0.1.12]
+  profiles: [ha]
+  storageDevice: /dev/nvme1n1
+  registry:
+    mirrors: [docker.io=https://mirror.example.com]
+    credentials: /etc/kubenest/prod-1-registries.yaml
+  ssh:
+    user: ubuntu
+`
+
+func noFlags(string) bool { return false }
+
+func changedFlags(names ...string) flagChanged {
+	return func(name string) bool {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func TestClusterSpecFillsInstallFlags(t *testing.T) {
+	spec, err := ParseClusterSpec([]byte(prodSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f InstallFlags
+	spec.applyInstall(noFlags, &f)
+	if err := f.Validate(); err != nil {
+		t.Fatalf("a complete spec must validate as flags would: %v", err)
+	}
+	if f.Name != "prod-1" || f.Bundle != "1.4" || f.HATier != "single-server" ||
+		len(f.Agents) != 2 || f.StorageDevice != "/dev/nvme1n1" || f.SSHUser != "ubuntu" ||
+		len(f.RegistryMirrors) != 1 || f.RegistryCredentials != "/etc/kubenest/prod-1-registries.yaml" {
+		t.Errorf("spec not mapped onto the flags: %+v", f)
+	}
+}
+
+// A flag on the command line replaces the file's value outright, lists
+// included: one --agent means one agent, not the file's two plus one.
+func TestFlagsOverrideTheSpec(t *testing.T) {
+	spec, err := ParseClusterSpec([]byte(prodSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := InstallFlags{Agents: []string{"10.0.1.13"}, SSHUser: "admin"}
+	spec.applyInstall(changedFlags("agent", "ssh-user"), &f)
+	if strings.Join(f.Agents, ",") != "10.0.1.13" {
+		t.Errorf("--agent must replace the file's agents, got %v", f.Agents)
+	}
+	if f.SSHUser != "admin" {
+		t.Errorf("--ssh-user must win over the file, got %q", f.SSHUser)
+	}
+	if f.Bundle != "1.4" {
+		t.Errorf("fields without a flag still come from the file, got bundle %q", f.Bundle)
+	}
+}
+
+// The journal identity is the resolved request's, so an edited file is the
+// same "different install" an edited command line is.
+func TestEditedSpecIsADifferentInstall(t *testing.T) {
+	spec, err := ParseClusterSpec([]byte(prodSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first InstallFlags
+	spec.applyInstall(noFlags, &first)
+
+	edited, err := ParseClusterSpec([]byte(strings.Replace(prodSpec, "10.0.1.12]", "10.0.1.14]", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var second InstallFlags
+	edited.applyInstall(noFlags, &second)
+
+	diffs := first.Options().Identity().Differences(second.Options().Identity())
+	if len(diffs) != 1 || !strings.Contains(diffs[0], "agents") {
+		t.Errorf("an edited agent list must be the one difference, got %v", diffs)
+	}
+
+	var fromFlags InstallFlags
+	fromFlags.Name, fromFlags.Bundle, fromFlags.HATier = "prod-1", "1.4", "single-server"
+	fromFlags.Servers = []string{"10.0.1.10"}
+	fromFlags.Agents = []string{"10.0.1.11", "10.0.1.12"}
+	fromFlags.Profiles = []string{"ha"}
+	fromFlags.StorageDevice = "/dev/nvme1n1"
+	fromFlags.RegistryMirrors = []string{"docker.io=https://mirror.example.com"}
+	if diffs := first.Options().Identity().Differences(fromFlags.Options().Identity()); len(diffs) != 0 {
+		t.Errorf("the same request by file or by flags is the same install, got %v", diffs)
+	}
+}
+
+func TestClusterSpecRefusals(t *testing.T) {
+	for _, c := range []struct {
+		name, body, want string
+	}{
+		{"empty", "", "empty"},
+		{"no version", "kind: Cluster\n", "apiVersion is required"},
+		{"future version", "apiVersion: platform.kubenest.io/v2\nkind: Cluster\n", "not one this CLI reads"},
+		{"wrong kind", "apiVersion: platform.kubenest.io/v1\nkind: Bundle\n", "kind"},
+		{"typo", strings.Replace(prodSpec, "agents:", "agnets:", 1), "agnets"},
+	} {
+		if _, err := ParseClusterSpec([]byte(c.body)); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: want an error containing %q, got %v", c.name, c.want, err)
+		}
+	}
+}
+
+// An incomplete file is refused by the same rules as incomplete flags.
+func TestInstallFromSpecFileValidates(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "prod-1.yaml")
+	if err := os.WriteFile(path, []byte(strings.Replace(prodSpec, "  ha: single-server\n", "", 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	root := NewRootCommand()
+	root.SetArgs([]string{"platform", "install", "-f", path})
+	if err := root.Execute(); err == nil || !strings.Contains(err.Error(), "--ha is required") {
+		t.Errorf("a spec without a tier must be refused as the flags would be, got: %v", err)
+	}
+
+	root = NewRootCommand()
+	root.SetArgs([]string{"platform", "install", "-f", path, "--ha", "single-server"})
+	if err := root.Execute(); err == nil || !strings.Contains(err.Error(), "kubenest login") {
+		t.Errorf("the flag must complete the spec and reach the control-plane check, got: %v", err)
+	}
+}
+
+// clusterSpecExample is ClusterSpec's doc comment, as an operator would copy
+// it into a file.
+const clusterSpecExample = `apiVersion: platform.kubenest.io/v1
+kind: Cluster
+metadata:
+  name: prod-1
+spec:
+  bundle: "1.4"
+  ha: single-server
+  servers: [10.0.1.10]
+  agents: [10.0.1.11, 10.0.1.12]
+  profiles: [observability]
+  storageDevice: /dev/nvme1n1
+  backupTarget: s3://kubenest-backups/prod-1?endpoint=s3.ap-south-1.amazonaws.com&region=ap-south-1
+  registry:
+    mirrors: [docker.io=https://mirror.example.com]
+    credentials: /etc/kubenest/prod-1-registries.yaml
+  proxy:
+    https: http://proxy.example.com:3128
+    noProxy: [10.0.1.0/24]
+  ssh:
+    user: ubuntu
+    key: ~/.ssh/id_ed25519
+  hooks: /etc/kubenest/site-hooks.yaml
+`
+
+// The documented example loads, and its ~/.ssh key is the one in the home
+// directory: nothing downstream expands a ~, so the spec must.
+func TestLoadClusterSpecResolvesPaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prod-1.yaml")
+	if err := os.WriteFile(path, []byte(clusterSpecExample), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := LoadClusterSpec(path)
+	if err != nil {
+		t.Fatalf("the documented example must load: %v", err)
+	}
+	var f InstallFlags
+	spec.applyInstall(noFlags, &f)
+	if err := f.Validate(); err != nil {
+		t.Fatalf("the documented example must validate: %v", err)
+	}
+	if want := filepath.Join(home, ".ssh", "id_ed25519"); f.SSHKey != want {
+		t.Errorf("ssh.key = %q, want %q", f.SSHKey, want)
+	}
+	if f.RegistryCredentials != "/etc/kubenest/prod-1-registries.yaml" || f.Hooks != "/etc/kubenest/site-hooks.yaml" {
+		t.Errorf("absolute paths must be kept as written: %q, %q", f.RegistryCredentials, f.Hooks)
+	}
+
+	// Relative paths are the spec file's neighbours, wherever the command
+	// runs from.
+	relative := strings.NewReplacer(
+		"key: ~/.ssh/id_ed25519", "key: keys/prod-1",
+		"hooks: /etc/kubenest/site-hooks.yaml", "hooks: site-hooks.yaml",
+	).Replace(clusterSpecExample)
+	if err := os.WriteFile(path, []byte(relative), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if spec, err = LoadClusterSpec(path); err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "keys", "prod-1"); spec.Spec.SSH.Key != want {
+		t.Errorf("ssh.key = %q, want %q", spec.Spec.SSH.Key, want)
+	}
+	if want := filepath.Join(dir, "site-hooks.yaml"); spec.Spec.Hooks != want {
+		t.Errorf("hooks = %q, want %q", spec.Spec.Hooks, want)
+	}
+
+	if err := os.WriteFile(path, []byte(strings.Replace(clusterSpecExample, "~/.ssh", "~ubuntu/.ssh", 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadClusterSpec(path); err == nil || !strings.Contains(err.Error(), "another user's home") {
+		t.Errorf("~user must be refused rather than opened literally, got: %v", err)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"kubenest.io/cli/pkg/install"
 	"kubenest.io/cli/pkg/manifest"
 	"kubenest.io/cli/pkg/stages"
+	"kubenest.io/cli/pkg/trace"
 	"kubenest.io/cli/pkg/upgrade"
 	"kubenest.io/cli/pkg/window"
 )
@@ -30,12 +31,28 @@ type UpgradeFlags struct {
 	// install.
 	Servers []string
 	Agents  []string
+	// Output is text or ndjson.
+	Output string
+	// BreakLock takes over the cluster's operation lease from a run that
+	// let it expire. A live holder is refused regardless.
+	BreakLock bool
+	// RerunStages and FromStage redo stages the journal has completed.
+	RerunStages []string
+	FromStage   string
+	// Hooks is a file of site hooks to run beside the stages.
+	Hooks string
+	// TraceOTLP and TraceFile are where the run's trace goes: an OTLP/HTTP
+	// collector, a file, both, or neither.
+	TraceOTLP string
+	TraceFile string
 }
 
 // buildUpgradeSession assembles everything an upgrade needs: the cluster's
 // own record, both bundle manifests, the node connections, the maintenance
-// window, and the journal.
-func buildUpgradeSession(ctx context.Context, out io.Writer, f UpgradeFlags) (*upgrade.Session, error) {
+// window, and the journal. With a stream, everything is also written to it
+// as NDJSON; with a live view, stage and check progress is drawn there in
+// place of the text emitter and reporter.
+func buildUpgradeSession(ctx context.Context, out io.Writer, stream *stages.NDJSON, live *stages.Live, runID string, f UpgradeFlags) (*upgrade.Session, error) {
 	client, err := controlPlaneClient()
 	if err != nil {
 		return nil, err
@@ -87,14 +104,22 @@ func buildUpgradeSession(ctx context.Context, out io.Writer, f UpgradeFlags) (*u
 	if err != nil {
 		return nil, err
 	}
+	// Locked before it is read: a journal read while another run writes it
+	// is stale before this run starts.
+	lock, err := stages.LockJournal(journalPath, stages.NewHolder(runID), f.BreakLock,
+		func(format string, args ...any) { fmt.Fprintf(out, format+"\n", args...) })
+	if err != nil {
+		return nil, err
+	}
 	journal, err := stages.OpenJournal(journalPath, opts.Identity(recorded.BundleVersion))
 	if err != nil {
+		lock.Release()
 		return nil, err
 	}
-	journal.ClusterID = clusterID
+	journal.SetClusterID(clusterID)
 
 	session := &upgrade.Session{
-		ID:       stages.NewRunID(),
+		ID:       runID,
 		Opts:     opts,
 		From:     from,
 		To:       to,
@@ -103,12 +128,34 @@ func buildUpgradeSession(ctx context.Context, out io.Writer, f UpgradeFlags) (*u
 		Out:      out,
 		API:      client,
 		Cluster:  recorded,
+		Lock:     lock,
+	}
+	var text stages.Emitter = stages.TextEmitter{W: out}
+	if live != nil {
+		session.Reporter, text = live, live
 	}
-	session.Emit = stages.Emitters{
-		stages.TextEmitter{W: out},
-		stages.NewControlPlaneEmitter(client, func() string { return journal.ClusterID }),
+	emitters := stages.Emitters{
+		text,
+		stages.NewControlPlaneEmitter(client, journal.ClusterIDValue),
 	}
+	if stream != nil {
+		emitters = append(emitters, stream)
+		session.Reporter = converge.Reporters{session.Reporter, stream}
+		session.Findings = stream
+	}
+	session.Emit = emitters
 	if err := session.Connect(ctx); err != nil {
+		session.Close()
+		return nil, err
+	}
+	// The lease is what a run from another machine is refused by. The
+	// cluster exists, so it is taken before anything runs.
+	server, err := session.Server()
+	if err == nil {
+		err = lock.Hold(ctx, stages.KubeLease{Runner: server})
+	}
+	if err != nil {
+		session.Close()
 		return nil, err
 	}
 	return session, nil
@@ -155,17 +202,56 @@ func fetchManifest(ctx context.Context, client *api.Client, version string) (*ma
 }
 
 // runUpgrade is `kubenest platform upgrade`.
-func runUpgrade(ctx context.Context, out io.Writer, f UpgradeFlags) error {
-	session, err := buildUpgradeSession(ctx, out, f)
+func runUpgrade(ctx context.Context, out, errOut io.Writer, f UpgradeFlags) (err error) {
+	runID := stages.NewRunID()
+	ctx, finishTrace, err := startTrace(ctx, errOut, f.TraceOTLP, f.TraceFile, runID, "kubenest platform upgrade",
+		trace.String("kubenest.cluster", f.Cluster), trace.String("kubenest.bundle", f.To))
+	if err != nil {
+		return err
+	}
+	defer func() { finishTrace(err) }()
+
+	out, stream := outputStreams(f.Output, out, errOut)
+	live := liveView(f.Output, out, "Upgrading "+f.Cluster)
+	if live != nil {
+		defer live.Stop()
+		out = live
+	}
+	session, err := buildUpgradeSession(ctx, out, stream, live, runID, f)
 	if err != nil {
 		return err
 	}
 	defer session.Close()
+	webhooks, err := clusterWebhooks(f.Cluster, "upgrade", runID)
+	if err != nil {
+		return err
+	}
+	for _, w := range webhooks {
+		session.Emit = stages.Emitters{session.Emit, w}
+	}
 
 	fmt.Fprintf(out, "Upgrading %s from bundle %s to %s.\n", f.Cluster, session.From.Bundle, session.To.Bundle)
 	fmt.Fprintf(out, "Components first, Kubernetes last: everything before the kubernetes stage reverts\nin seconds. That stage is the point of no return.\n\n")
 
-	result, err := stages.Execute(ctx, session, upgrade.Plan(session))
+	sequence, err := attachHooks(f.Hooks, upgrade.Plan(session), session.NodeRunner)
+	if err != nil {
+		return err
+	}
+	if err := reopenStages(out, session.Jnl, sequence, stages.Rerun{Stages: f.RerunStages, From: f.FromStage}, session.ID); err != nil {
+		return err
+	}
+
+	ctx, stop := session.Lock.Guard(ctx)
+	defer stop()
+	startLive(live, sequence, session.TotalDeadline)
+	result, err := stages.Execute(ctx, session, sequence)
+	if live != nil {
+		live.Stop()
+	}
+	if stream != nil {
+		_ = stream.Result(result, err)
+	}
+	notifyResult(ctx, out, webhooks, result, err)
 	if err != nil {
 		return err
 	}
@@ -183,7 +269,7 @@ func runUpgrade(ctx context.Context, out io.Writer, f UpgradeFlags) error {
 // confirmation when that mechanism is a datastore restore — which is a
 // service interruption, not a revert.
 func runRollback(ctx context.Context, out io.Writer, in io.Reader, f UpgradeFlags, confirmed bool) error {
-	session, err := buildUpgradeSession(ctx, out, f)
+	session, err := buildUpgradeSession(ctx, out, nil, nil, stages.NewRunID(), f)
 	if err != nil {
 		return err
 	}
@@ -30,8 +30,8 @@ import (
 	"kubenest.io/cli/pkg/manifest"
 )
 
-// manifestName is the file the HelmChart resource is written to.
-const manifestName = "kubenest-agent"
+// ManifestName is the file the HelmChart resource is written to.
+const ManifestName = "kubenest-agent"
 
 // releaseName is the Helm release, and it is SHORT for a reason that is not
 // style. The chart names its metrics service
@@ -131,15 +131,15 @@ func Chart(bundle *manifest.Manifest, creds *api.AgentCredentials) (k3s.HelmChar
 	// where the chart lives.
 	ref := chartRepository(creds.Operator.ChartRef)
 
-	return k3s.HelmChart{
+	return k3s.Sourced(bundle, k3s.HelmChart{
 		// The HelmChart resource name IS the Helm release name, which is why
-		// this is the short one and not manifestName.
+		// this is the short one and not ManifestName.
 		Name:            releaseName,
 		Chart:           ref,
 		Version:         version,
 		TargetNamespace: namespace,
 		ValuesYAML:      values,
-	}, nil
+	}), nil
 }
 
 // chartRepository strips any tag from an OCI reference, leaving only where
@@ -187,12 +187,12 @@ func Install(ctx context.Context, r k3s.Runner, bundle *manifest.Manifest, creds
 	if err != nil {
 		return err
 	}
-	if err := k3s.WriteManifest(ctx, r, manifestName, doc); err != nil {
+	if err := k3s.WriteManifest(ctx, r, ManifestName, doc); err != nil {
 		return err
 	}
 	// The values carry the agent JWT and the auto-deploy directory is
 	// world-readable by default.
-	if err := restrict(ctx, r, k3s.ManifestDir+"/"+manifestName+".yaml"); err != nil {
+	if err := restrict(ctx, r, k3s.ManifestDir+"/"+ManifestName+".yaml"); err != nil {
 		return err
 	}
 
@@ -40,14 +40,14 @@ func Chart(bundle *manifest.Manifest) (k3s.HelmChart, error) {
 	if err != nil {
 		return k3s.HelmChart{}, err
 	}
-	return k3s.HelmChart{
+	return k3s.Sourced(bundle, k3s.HelmChart{
 		Name:            "kubenest-cert-manager",
 		Repo:            chartRepo,
 		Chart:           "cert-manager",
 		Version:         version,
 		TargetNamespace: Namespace,
 		ValuesYAML:      Values,
-	}, nil
+	}), nil
 }
 
 // Install applies the chart and converges until every cert-manager pod
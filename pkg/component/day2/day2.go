@@ -73,30 +73,83 @@ func Install(ctx context.Context, r k3s.Runner, bundle *manifest.Manifest, rep c
 	return InstallKured(ctx, r, bundle, rep)
 }
 
-// InstallUpgradeController places system-upgrade-controller and its CRDs.
-func InstallUpgradeController(ctx context.Context, r k3s.Runner, bundle *manifest.Manifest, rep converge.Reporter) error {
+// UpgradeControllerManifests downloads the pinned release documents, named
+// as InstallUpgradeController writes them.
+//
+// The release ships CRDs and controller as separate documents. Both go into
+// the k3s auto-deploy directory, where k3s keeps them applied and retries the
+// ordering itself.
+//
+// From an offline bundle the documents are read from the archive.
+func UpgradeControllerManifests(ctx context.Context, bundle *manifest.Manifest) ([]k3s.Document, error) {
 	version, err := bundle.Core.Version("system-upgrade-controller")
 	if err != nil {
-		return err
+		return nil, err
+	}
+	var docs []k3s.Document
+	for _, part := range upgradeControllerParts {
+		if bundle.Offline != nil {
+			data, err := manifest.ReadArtifact(bundle.Offline, upgradeControllerArtifact(version, part.asset))
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, k3s.Document{Name: part.name, Content: data})
+			continue
+		}
+		url := fmt.Sprintf("%s/%s/%s", ReleaseBaseURL, version, part.asset)
+		data, err := fetch(ctx, url)
+		if err != nil {
+			return nil, fmt.Errorf("download system-upgrade-controller %s (%s): %w", version, part.asset, err)
+		}
+		docs = append(docs, k3s.Document{Name: part.name, Content: data})
 	}
+	return docs, nil
+}
+
+// upgradeControllerParts are the release's two documents and the names they
+// are written under.
+var upgradeControllerParts = []struct{ name, asset string }{
+	{"kubenest-system-upgrade-crd", "crd.yaml"},
+	{"kubenest-system-upgrade-controller", "system-upgrade-controller.yaml"},
+}
+
+// UpgradeControllerArtifacts are the release documents an offline bundle
+// carries.
+func UpgradeControllerArtifacts(bundle *manifest.Manifest) ([]manifest.Artifact, error) {
+	version, err := bundle.Core.Version("system-upgrade-controller")
+	if err != nil {
+		return nil, err
+	}
+	var out []manifest.Artifact
+	for _, part := range upgradeControllerParts {
+		out = append(out, manifest.Artifact{
+			Name: upgradeControllerArtifact(version, part.asset),
+			URL:  fmt.Sprintf("%s/%s/%s", ReleaseBaseURL, version, part.asset),
+		})
+	}
+	return out, nil
+}
+
+func upgradeControllerArtifact(version, asset string) string {
+	return "manifests/system-upgrade-controller/" + version + "/" + asset
+}
+
+// KuredManifestName is the file kured's HelmChart is written to.
+const KuredManifestName = "kubenest-kured"
+
+// InstallUpgradeController places system-upgrade-controller and its CRDs.
+func InstallUpgradeController(ctx context.Context, r k3s.Runner, bundle *manifest.Manifest, rep converge.Reporter) error {
 	deadline, err := bundle.Limits.Timeouts.For("component-ready")
 	if err != nil {
 		return err
 	}
 
-	// The release ships CRDs and controller as separate documents. Both go
-	// into the k3s auto-deploy directory, where k3s keeps them applied and
-	// retries the ordering itself.
-	for _, part := range []struct{ name, asset string }{
-		{"kubenest-system-upgrade-crd", "crd.yaml"},
-		{"kubenest-system-upgrade-controller", "system-upgrade-controller.yaml"},
-	} {
-		url := fmt.Sprintf("%s/%s/%s", ReleaseBaseURL, version, part.asset)
-		data, err := fetch(ctx, url)
-		if err != nil {
-			return fmt.Errorf("download system-upgrade-controller %s (%s): %w", version, part.asset, err)
-		}
-		if err := writeStreamed(ctx, r, part.name, data); err != nil {
+	docs, err := UpgradeControllerManifests(ctx, bundle)
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if err := writeStreamed(ctx, r, doc.Name, doc.Content); err != nil {
 			return err
 		}
 	}
@@ -131,7 +184,7 @@ func Chart(bundle *manifest.Manifest) (k3s.HelmChart, error) {
 	if err != nil {
 		return k3s.HelmChart{}, err
 	}
-	return k3s.HelmChart{
+	return k3s.Sourced(bundle, k3s.HelmChart{
 		Name:            "kured",
 		Repo:            "https://kubereboot.github.io/charts",
 		Chart:           "kured",
@@ -139,7 +192,7 @@ func Chart(bundle *manifest.Manifest) (k3s.HelmChart, error) {
 		TargetNamespace: KuredNamespace,
 		ValuesYAML: "configuration:\n" +
 			"  rebootSentinel: " + RebootSentinel + "\n",
-	}, nil
+	}), nil
 }
 
 // InstallKured places kured.
@@ -156,7 +209,7 @@ func InstallKured(ctx context.Context, r k3s.Runner, bundle *manifest.Manifest,
 	if err != nil {
 		return err
 	}
-	if err := k3s.WriteManifest(ctx, r, "kubenest-kured", doc); err != nil {
+	if err := k3s.WriteManifest(ctx, r, KuredManifestName, doc); err != nil {
 		return err
 	}
 
@@ -40,25 +40,56 @@ func URL(version string) string {
 	return fmt.Sprintf("%s/%s/standard-install.yaml", ReleaseBaseURL, version)
 }
 
+// ManifestName is the file the release manifest is written to in the k3s
+// auto-deploy directory.
+const ManifestName = "kubenest-gateway-api"
+
+// Artifacts is the release manifest an offline bundle carries.
+func Artifacts(bundle *manifest.Manifest) ([]manifest.Artifact, error) {
+	version, err := bundle.Core.Version("gateway-api")
+	if err != nil {
+		return nil, err
+	}
+	return []manifest.Artifact{{Name: artifactName(version), URL: URL(version)}}, nil
+}
+
+func artifactName(version string) string {
+	return "manifests/gateway-api/" + version + "/standard-install.yaml"
+}
+
+// Manifest downloads the pinned standard-channel release manifest — the
+// document Install places, byte for byte. From an offline bundle it is read
+// from the archive instead, which carries the same bytes.
+func Manifest(ctx context.Context, bundle *manifest.Manifest) ([]byte, error) {
+	version, err := bundle.Core.Version("gateway-api")
+	if err != nil {
+		return nil, err
+	}
+	if bundle.Offline != nil {
+		return manifest.ReadArtifact(bundle.Offline, artifactName(version))
+	}
+	data, err := fetch(ctx, URL(version))
+	if err != nil {
+		return nil, fmt.Errorf("download Gateway API %s release manifest: %w", version, err)
+	}
+	return data, nil
+}
+
 // Install fetches the pinned standard-channel manifest, places it in the k3s
 // auto-deploy directory, and converges until every CRD is Established. The
 // download happens on the installer machine — target nodes need no GitHub
 // access for this.
 func Install(ctx context.Context, r k3s.Runner, bundle *manifest.Manifest, rep converge.Reporter) error {
-	version, err := bundle.Core.Version("gateway-api")
-	if err != nil {
-		return err
-	}
 	deadline, err := bundle.Limits.Timeouts.For("component-ready")
 	if err != nil {
 		return err
 	}
 
-	data, err := fetch(ctx, URL(version))
+	data, err := Manifest(ctx, bundle)
 	if err != nil {
-		return fmt.Errorf("download Gateway API %s release manifest: %w", version, err)
+		return err
 	}
-	if err := writeStreamed(ctx, r, "kubenest-gateway-api", data); err != nil {
+	if err := writeStreamed(ctx, r, ManifestName, data); err != nil {
 		return err
 	}
 
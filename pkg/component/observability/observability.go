@@ -0,0 +1,542 @@
+// Package observability installs the observability profile: Prometheus and
+// Alertmanager (kube-prometheus-stack), Loki as the log store with Alloy
+// shipping every pod's logs to it, and Grafana in front of all three.
+//
+// It is the pkg/component shape with one difference: a profile is several
+// charts, and which ones is the bundle's decision. The components installed
+// are exactly those profiles.observability.components pins, each at its
+// pinned chart version, so moving between bundles (pkg/upgrade) is a matter
+// of comparing two pin sets — a component the new bundle drops is removed
+// with Uninstall, one it adds is installed.
+//
+// Everything that persists does so on kubenest-local, the platform's
+// LVM-backed StorageClass: metrics, alert silences, logs and Grafana's own
+// database survive a pod moving or a node rebooting, and survive the profile
+// being removed (Uninstall keeps the volumes, for the reason platform
+// uninstall does).
+//
+// Grafana alone is exposed, through the platform Gateway at GrafanaPath.
+// Prometheus and Alertmanager have no authentication of their own, so they
+// stay reachable only inside the cluster, where Grafana reaches them.
+package observability
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"kubenest.io/cli/pkg/component/traefik"
+	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/k3s"
+	"kubenest.io/cli/pkg/manifest"
+	"kubenest.io/cli/pkg/storage"
+)
+
+const (
+	// Profile is the profile name in the bundle manifest and on --profile.
+	Profile = "observability"
+	// Namespace is where every observability component runs.
+	Namespace = "kubenest-observability"
+
+	// GrafanaPath is where Grafana is served on the platform Gateway. The
+	// Gateway's listeners carry no hostname filter (the app-layer contract),
+	// so this path is claimed on every hostname the cluster serves; it sits
+	// under /kubenest so no customer route is likely to want it.
+	GrafanaPath = "/kubenest/grafana"
+	// RouteManifestName is the file the Grafana HTTPRoute is written to.
+	RouteManifestName = "kubenest-observability-route"
+
+	grafanaService = "kubenest-grafana"
+)
+
+// Component is one chart of the profile.
+type Component struct {
+	// Key is the component's name under profiles.observability.components.
+	Key   string
+	Repo  string
+	Chart string
+	// Values is the chart values document, declared as one literal per
+	// component so a reviewer reads the whole posture in one place.
+	Values string
+	// StatefulSets are the StatefulSets the component runs. The workload
+	// readiness check covers Deployments and DaemonSets only, and most of
+	// what persists here is a StatefulSet, several made by an operator
+	// rather than by the chart.
+	StatefulSets []string
+}
+
+// Components is every component this CLI can install, in install order:
+// Prometheus first, because its chart brings the CRDs the others'
+// ServiceMonitors use; the log store before the collector that ships to it;
+// Grafana last, with its data sources all there to connect to.
+var Components = []Component{
+	{
+		Key:          "kube-prometheus-stack",
+		Repo:         "https://prometheus-community.github.io/helm-charts",
+		Chart:        "kube-prometheus-stack",
+		Values:       prometheusValues,
+		StatefulSets: []string{"prometheus-kubenest-prometheus", "alertmanager-kubenest-alertmanager"},
+	},
+	{
+		Key:          "loki",
+		Repo:         "https://grafana.github.io/helm-charts",
+		Chart:        "loki",
+		Values:       lokiValues,
+		StatefulSets: []string{"kubenest-loki"},
+	},
+	{
+		Key:    "alloy",
+		Repo:   "https://grafana.github.io/helm-charts",
+		Chart:  "alloy",
+		Values: alloyValues,
+	},
+	{
+		Key:    "grafana",
+		Repo:   "https://grafana.github.io/helm-charts",
+		Chart:  "grafana",
+		Values: grafanaValues,
+	},
+}
+
+// required is what the profile promises: metrics and alerting, a log store,
+// and a UI over both. A bundle that pins less is not the observability
+// profile, and installing the part it does pin would be a cluster that does
+// not match its record.
+var required = []string{"kube-prometheus-stack", "loki", "grafana"}
+
+// prometheusValues: Prometheus and Alertmanager on kubenest-local, scraping
+// every ServiceMonitor in the cluster rather than only the chart's own, and
+// without the control-plane scrapes k3s has no endpoints for (the
+// scheduler, controller-manager, proxy and etcd run inside the k3s process).
+// The chart's Grafana is off: Grafana is its own pinned component, which
+// still receives the chart's dashboards through its sidecar.
+const prometheusValues = `fullnameOverride: kubenest
+grafana:
+  enabled: false
+  forceDeployDashboards: true
+prometheus:
+  prometheusSpec:
+    retention: 15d
+    retentionSize: 18GB
+    serviceMonitorSelectorNilUsesHelmValues: false
+    podMonitorSelectorNilUsesHelmValues: false
+    ruleSelectorNilUsesHelmValues: false
+    storageSpec:
+      volumeClaimTemplate:
+        spec:
+          storageClassName: ` + storage.StorageClassName + `
+          accessModes: [ReadWriteOnce]
+          resources:
+            requests:
+              storage: 20Gi
+alertmanager:
+  alertmanagerSpec:
+    storage:
+      volumeClaimTemplate:
+        spec:
+          storageClassName: ` + storage.StorageClassName + `
+          accessModes: [ReadWriteOnce]
+          resources:
+            requests:
+              storage: 2Gi
+kubeControllerManager:
+  enabled: false
+kubeScheduler:
+  enabled: false
+kubeProxy:
+  enabled: false
+kubeEtcd:
+  enabled: false
+`
+
+// lokiValues: one single-binary Loki on a filesystem volume, seven days of
+// retention enforced by the compactor. The scalable modes' object store and
+// caches are what a platform node does not have spare; a cluster that
+// outgrows this is past what the profile sizes for.
+const lokiValues = `fullnameOverride: kubenest-loki
+deploymentMode: SingleBinary
+loki:
+  auth_enabled: false
+  commonConfig:
+    replication_factor: 1
+  storage:
+    type: filesystem
+  schemaConfig:
+    configs:
+      - from: "2024-04-01"
+        store: tsdb
+        object_store: filesystem
+        schema: v13
+        index:
+          prefix: index_
+          period: 24h
+  limits_config:
+    retention_period: 168h
+  compactor:
+    retention_enabled: true
+    delete_request_store: filesystem
+singleBinary:
+  replicas: 1
+  persistence:
+    enabled: true
+    storageClass: ` + storage.StorageClassName + `
+    size: 20Gi
+backend:
+  replicas: 0
+read:
+  replicas: 0
+write:
+  replicas: 0
+gateway:
+  enabled: false
+chunksCache:
+  enabled: false
+resultsCache:
+  enabled: false
+lokiCanary:
+  enabled: false
+test:
+  enabled: false
+`
+
+// alloyValues: a DaemonSet tailing every pod's logs through the API server
+// and pushing them to Loki. It keeps no state worth persisting — a restart
+// resumes from the API server, not from a position file.
+const alloyValues = `fullnameOverride: kubenest-alloy
+alloy:
+  configMap:
+    content: |
+      discovery.kubernetes "pods" {
+        role = "pod"
+      }
+      discovery.relabel "pods" {
+        targets = discovery.kubernetes.pods.targets
+        rule {
+          source_labels = ["__meta_kubernetes_namespace"]
+          target_label  = "namespace"
+        }
+        rule {
+          source_labels = ["__meta_kubernetes_pod_name"]
+          target_label  = "pod"
+        }
+        rule {
+          source_labels = ["__meta_kubernetes_pod_container_name"]
+          target_label  = "container"
+        }
+      }
+      loki.source.kubernetes "pods" {
+        targets    = discovery.relabel.pods.output
+        forward_to = [loki.write.kubenest.receiver]
+      }
+      loki.write "kubenest" {
+        endpoint {
+          url = "http://kubenest-loki:3100/loki/api/v1/push"
+        }
+      }
+`
+
+// grafanaValues: served from GrafanaPath, its database on kubenest-local,
+// with Prometheus, Alertmanager and Loki as data sources and the
+// kube-prometheus-stack dashboards picked up by the sidecar. The admin
+// password is the chart's generated one, in the kubenest-grafana secret;
+// nothing here or in the plan carries it.
+const grafanaValues = `fullnameOverride: ` + grafanaService + `
+persistence:
+  enabled: true
+  storageClassName: ` + storage.StorageClassName + `
+  size: 5Gi
+  annotations:
+    helm.sh/resource-policy: keep
+grafana.ini:
+  server:
+    root_url: "%(protocol)s://%(domain)s` + GrafanaPath + `/"
+    serve_from_sub_path: true
+sidecar:
+  dashboards:
+    enabled: true
+    label: grafana_dashboard
+datasources:
+  datasources.yaml:
+    apiVersion: 1
+    datasources:
+      - name: Prometheus
+        type: prometheus
+        url: http://kubenest-prometheus:9090
+        isDefault: true
+      - name: Alertmanager
+        type: alertmanager
+        url: http://kubenest-alertmanager:9093
+        jsonData:
+          implementation: prometheus
+      - name: Loki
+        type: loki
+        url: http://kubenest-loki:3100
+`
+
+// RouteManifest is Grafana's HTTPRoute on the platform Gateway, attached the
+// way every app-layer route is (kn-e7qy): parentRefs → kubenest-gateway in
+// kubenest-system, no sectionName, no TLS — the Gateway terminates TLS and
+// redirects plain HTTP. It carries no Namespace document: removing the route
+// must never take the namespace, and the volumes in it, with it.
+const RouteManifest = `apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: ` + grafanaService + `
+  namespace: ` + Namespace + `
+spec:
+  parentRefs:
+    - name: ` + traefik.GatewayName + `
+      namespace: ` + traefik.Namespace + `
+  rules:
+    - matches:
+        - path:
+            type: PathPrefix
+            value: ` + GrafanaPath + `
+      backendRefs:
+        - name: ` + grafanaService + `
+          port: 80
+`
+
+// Lookup returns the component with the given key.
+func Lookup(key string) (Component, bool) {
+	for _, c := range Components {
+		if c.Key == key {
+			return c, true
+		}
+	}
+	return Component{}, false
+}
+
+// Pinned returns the components the bundle's observability profile pins, in
+// install order. A pin this CLI does not know is refused rather than
+// skipped, and so is a profile missing one of the required components.
+func Pinned(bundle *manifest.Manifest) ([]Component, error) {
+	prof, err := bundle.Profiles.Get(Profile)
+	if err != nil {
+		return nil, err
+	}
+	var unknown []string
+	for key := range prof.Components {
+		if _, ok := Lookup(key); !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("bundle %s pins profiles.%s.components %s, which this build of the CLI does not know how to install: use the CLI released with the bundle",
+			bundle.Bundle, Profile, strings.Join(unknown, ", "))
+	}
+	for _, key := range required {
+		if _, ok := prof.Components[key]; !ok {
+			return nil, fmt.Errorf("bundle %s does not pin profiles.%s.components.%s: the profile is Prometheus, a log store and Grafana together, and the bundle decides every version",
+				bundle.Bundle, Profile, key)
+		}
+	}
+	var pinned []Component
+	for _, c := range Components {
+		if _, ok := prof.Components[c.Key]; ok {
+			pinned = append(pinned, c)
+		}
+	}
+	return pinned, nil
+}
+
+// Chart renders the pinned HelmChart custom resource for one component.
+func Chart(bundle *manifest.Manifest, c Component) (k3s.HelmChart, error) {
+	prof, err := bundle.Profiles.Get(Profile)
+	if err != nil {
+		return k3s.HelmChart{}, err
+	}
+	version, ok := prof.Components[c.Key]
+	if !ok || version == "" {
+		return k3s.HelmChart{}, fmt.Errorf("bundle %s does not pin profiles.%s.components.%s: the bundle decides every version, add the pin to the manifest rather than defaulting in code",
+			bundle.Bundle, Profile, c.Key)
+	}
+	return k3s.Sourced(bundle, k3s.HelmChart{
+		Name:            ChartName(c.Key),
+		Repo:            c.Repo,
+		Chart:           c.Chart,
+		Version:         version,
+		TargetNamespace: Namespace,
+		ValuesYAML:      c.Values,
+	}), nil
+}
+
+// ChartName is the HelmChart resource, and manifest file, of a component.
+func ChartName(key string) string { return "kubenest-" + key }
+
+// Charts renders every pinned component's chart, in install order.
+func Charts(bundle *manifest.Manifest) ([]k3s.HelmChart, error) {
+	pinned, err := Pinned(bundle)
+	if err != nil {
+		return nil, err
+	}
+	charts := make([]k3s.HelmChart, 0, len(pinned))
+	for _, c := range pinned {
+		chart, err := Chart(bundle, c)
+		if err != nil {
+			return nil, err
+		}
+		charts = append(charts, chart)
+	}
+	return charts, nil
+}
+
+// Install applies every pinned component and converges until the profile is
+// up: every workload Ready, every volume Bound on kubenest-local, and
+// Grafana's route Accepted by the Gateway. Re-running it is how an upgrade
+// moves the profile, since the HelmCharts carry the new pins.
+func Install(ctx context.Context, r k3s.Runner, bundle *manifest.Manifest, rep converge.Reporter) error {
+	pinned, err := Pinned(bundle)
+	if err != nil {
+		return err
+	}
+	deadline, err := bundle.Limits.Timeouts.For("component-ready")
+	if err != nil {
+		return err
+	}
+	var statefulSets []string
+	for _, c := range pinned {
+		chart, err := Chart(bundle, c)
+		if err != nil {
+			return err
+		}
+		doc, err := chart.Manifest()
+		if err != nil {
+			return err
+		}
+		if err := k3s.WriteManifest(ctx, r, chart.Name, doc); err != nil {
+			return err
+		}
+		statefulSets = append(statefulSets, c.StatefulSets...)
+	}
+
+	checks := []struct {
+		name  string
+		probe converge.Probe
+	}{
+		{"observability-ready", readyProbe(r, statefulSets)},
+		{"observability-volumes-bound", VolumesBoundProbe(r)},
+	}
+	for _, check := range checks {
+		if err := wait(ctx, check.name, check.probe, deadline, rep); err != nil {
+			return err
+		}
+	}
+
+	// The route last: its backend exists now, so Accepted and ResolvedRefs
+	// are both a verdict on the route rather than on the install's progress.
+	if err := k3s.WriteManifest(ctx, r, RouteManifestName, []byte(RouteManifest)); err != nil {
+		return err
+	}
+	if err := wait(ctx, "grafana-route-accepted", RouteAcceptedProbe(r), deadline, rep); err != nil {
+		return fmt.Errorf("%w (is the platform Gateway %s in %s up?)", err, traefik.GatewayName, traefik.Namespace)
+	}
+	return nil
+}
+
+// Uninstall removes components from the cluster: their HelmCharts, which
+// runs each release's helm uninstall, and — when Grafana is among them — its
+// route. It waits until every release is gone, so a component removed and
+// then installed again does not race its own uninstall job.
+//
+// The volumes stay. Helm does not delete a StatefulSet's claims, and
+// Grafana's claim carries helm's keep policy (see grafanaValues); three weeks of
+// metrics and logs are not something to lose to a profile change.
+func Uninstall(ctx context.Context, r k3s.Runner, bundle *manifest.Manifest, components []Component, rep converge.Reporter) error {
+	deadline, err := bundle.Limits.Timeouts.For("component-ready")
+	if err != nil {
+		return err
+	}
+	var names []string
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		if c.Key == "grafana" {
+			if err := k3s.RemoveManifest(ctx, r, RouteManifestName, []byte(RouteManifest)); err != nil {
+				return err
+			}
+		}
+		// The resource is named by kind and name alone: the version and
+		// source do not matter to a delete, and the bundle that pinned them
+		// may no longer be the one in hand.
+		doc := fmt.Appendf(nil, "apiVersion: helm.cattle.io/v1\nkind: HelmChart\nmetadata:\n  name: %s\n  namespace: kube-system\n", ChartName(c.Key))
+		if err := k3s.RemoveManifest(ctx, r, ChartName(c.Key), doc); err != nil {
+			return err
+		}
+		names = append(names, ChartName(c.Key))
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return wait(ctx, "observability-removed", chartsGoneProbe(r, names), deadline, rep)
+}
+
+func wait(ctx context.Context, name string, probe converge.Probe, deadline time.Duration, rep converge.Reporter) error {
+	res, err := converge.Wait(ctx, probe, converge.Options{Name: name, Deadline: deadline, Reporter: rep})
+	if err != nil {
+		return err
+	}
+	return res.Err()
+}
+
+// statefulSetList is the slice of `kubectl get statefulsets -o json` the
+// readiness check reads.
+type statefulSetList struct {
+	Items []statefulSet `json:"items"`
+}
+
+type statefulSet struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Replicas *int32 `json:"replicas"`
+	} `json:"spec"`
+	Status struct {
+		ReadyReplicas int32 `json:"readyReplicas"`
+	} `json:"status"`
+}
+
+// readyProbe requires every Deployment and DaemonSet Ready and every named
+// StatefulSet to exist with all its replicas Ready. The names matter because
+// Prometheus and Alertmanager are made by the operator some time after the
+// chart's install job succeeds: a namespace with no StatefulSets yet is not
+// one whose StatefulSets are all Ready.
+func readyProbe(r k3s.Runner, statefulSets []string) converge.Probe {
+	return func(ctx context.Context) (bool, converge.State, error) {
+		done, state, err := k3s.CheckWorkloadsReady(ctx, r, Namespace)
+		if err != nil || !done {
+			return false, state, err
+		}
+		out, err := k3s.Kubectl(ctx, r, "get statefulsets -n "+Namespace+" -o json")
+		if err != nil {
+			return false, converge.State{Object: "statefulsets in " + Namespace, Status: "unobservable"}, err
+		}
+		var list statefulSetList
+		if err := json.Unmarshal([]byte(out), &list); err != nil {
+			return false, converge.State{Object: "statefulsets in " + Namespace, Status: "unparsable"}, err
+		}
+		for _, name := range statefulSets {
+			object := "statefulset " + name + " in " + Namespace
+			i := slices.IndexFunc(list.Items, func(s statefulSet) bool { return s.Metadata.Name == name })
+			if i < 0 {
+				return false, converge.State{Object: object, Status: "not created yet"}, nil
+			}
+			want := int32(1)
+			if list.Items[i].Spec.Replicas != nil {
+				want = *list.Items[i].Spec.Replicas
+			}
+			if got := list.Items[i].Status.ReadyReplicas; got < want {
+				return false, converge.State{Object: object, Status: fmt.Sprintf("%d/%d Ready", got, want)}, nil
+			}
+		}
+		return true, converge.State{
+			Object: "observability in " + Namespace,
+			Status: fmt.Sprintf("workloads and %d statefulset(s) Ready", len(statefulSets)),
+		}, nil
+	}
+}
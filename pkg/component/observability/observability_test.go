@@ -0,0 +1,247 @@
+package observability
+
+import (
+	"context"
+	"encoding/base64"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"kubenest.io/cli/pkg/component/componenttest"
+	"kubenest.io/cli/pkg/component/traefik"
+	"kubenest.io/cli/pkg/manifest"
+	"kubenest.io/cli/pkg/sshx"
+	"kubenest.io/cli/pkg/storage"
+)
+
+const pins = `
+bundle: "1.0"
+core:
+  k3s: v1.35.7+k3s1
+limits:
+  timeouts:
+    component-ready: 2s
+profiles:
+  observability:
+    components:
+      grafana: 10.5.15
+      loki: 6.46.0
+      kube-prometheus-stack: 79.5.0
+      alloy: 1.4.0
+`
+
+func bundle(t *testing.T, doc string) *manifest.Manifest {
+	t.Helper()
+	m, err := manifest.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// The charts are the profile's pins, in dependency order whatever order the
+// manifest lists them in, every one into the profile's namespace.
+func TestChartsThreadTheProfilePinsInInstallOrder(t *testing.T) {
+	charts, err := Charts(bundle(t, pins))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range charts {
+		got = append(got, c.Chart+"@"+c.Version)
+		if c.TargetNamespace != Namespace {
+			t.Errorf("%s lands in %s, want %s", c.Name, c.TargetNamespace, Namespace)
+		}
+	}
+	want := []string{"kube-prometheus-stack@79.5.0", "loki@6.46.0", "alloy@1.4.0", "grafana@10.5.15"}
+	if !slices.Equal(got, want) {
+		t.Errorf("charts = %v, want %v", got, want)
+	}
+}
+
+func TestAProfileMissingAPartOrPinningAnUnknownOneIsRefused(t *testing.T) {
+	missing := strings.Replace(pins, "      loki: 6.46.0\n", "", 1)
+	if _, err := Charts(bundle(t, missing)); err == nil || !strings.Contains(err.Error(), "profiles.observability.components.loki") {
+		t.Errorf("a profile without its log store must be refused naming the pin, got %v", err)
+	}
+	unknown := strings.Replace(pins, "      alloy: 1.4.0\n", "      tempo: 1.24.0\n", 1)
+	if _, err := Charts(bundle(t, unknown)); err == nil || !strings.Contains(err.Error(), "tempo") {
+		t.Errorf("a component this CLI cannot install must be refused, not skipped, got %v", err)
+	}
+}
+
+// Every volume the profile asks for is on kubenest-local. The values are
+// walked rather than grepped, so a claim template added later without a
+// class is caught too.
+func TestEveryVolumeIsOnKubenestLocal(t *testing.T) {
+	classes := 0
+	for _, c := range Components {
+		var values map[string]any
+		if err := yaml.Unmarshal([]byte(c.Values), &values); err != nil {
+			t.Fatalf("%s values are not valid YAML: %v", c.Key, err)
+		}
+		var walk func(path string, v any)
+		walk = func(path string, v any) {
+			switch v := v.(type) {
+			case map[string]any:
+				for k, child := range v {
+					if k == "storageClassName" || k == "storageClass" {
+						classes++
+						if child != storage.StorageClassName {
+							t.Errorf("%s %s.%s = %v, want %s", c.Key, path, k, child, storage.StorageClassName)
+						}
+					}
+					walk(path+"."+k, child)
+				}
+			case []any:
+				for _, child := range v {
+					walk(path, child)
+				}
+			}
+		}
+		walk(c.Key, values)
+	}
+	// Prometheus, Alertmanager, Loki and Grafana.
+	if classes != 4 {
+		t.Errorf("found %d storage classes in the values, want one per persisted component (4)", classes)
+	}
+}
+
+// Grafana attaches to the platform Gateway the way every app-layer route
+// does, and is told it is served from the route's path.
+func TestGrafanaRouteMatchesTheGatewayContract(t *testing.T) {
+	var route struct {
+		Metadata struct {
+			Namespace string `yaml:"namespace"`
+		} `yaml:"metadata"`
+		Spec struct {
+			ParentRefs []map[string]string `yaml:"parentRefs"`
+			Rules      []struct {
+				Matches []struct {
+					Path struct {
+						Value string `yaml:"value"`
+					} `yaml:"path"`
+				} `yaml:"matches"`
+				BackendRefs []struct {
+					Name string `yaml:"name"`
+				} `yaml:"backendRefs"`
+			} `yaml:"rules"`
+		} `yaml:"spec"`
+	}
+	if err := yaml.Unmarshal([]byte(RouteManifest), &route); err != nil {
+		t.Fatal(err)
+	}
+	if len(route.Spec.ParentRefs) != 1 {
+		t.Fatalf("want one parentRef, got %v", route.Spec.ParentRefs)
+	}
+	ref := route.Spec.ParentRefs[0]
+	if ref["name"] != traefik.GatewayName || ref["namespace"] != traefik.Namespace || ref["sectionName"] != "" {
+		t.Errorf("parentRef = %v, want %s in %s with no sectionName", ref, traefik.GatewayName, traefik.Namespace)
+	}
+	if route.Metadata.Namespace != Namespace || len(route.Spec.Rules) != 1 ||
+		route.Spec.Rules[0].Matches[0].Path.Value != GrafanaPath || route.Spec.Rules[0].BackendRefs[0].Name != grafanaService {
+		t.Errorf("the route must send %s to %s in %s: %+v", GrafanaPath, grafanaService, Namespace, route)
+	}
+	if !strings.Contains(grafanaValues, GrafanaPath+"/") || !strings.Contains(grafanaValues, "serve_from_sub_path: true") {
+		t.Error("Grafana must be told it is served from the route's path")
+	}
+	if strings.Contains(RouteManifest, "kind: Namespace") {
+		t.Error("the route file must not carry the namespace: removing it would take the volumes too")
+	}
+}
+
+// cluster answers the observability probes as a healthy cluster would.
+func cluster(cmd string) (sshx.Result, error) {
+	switch {
+	case strings.Contains(cmd, "get deployments,daemonsets"):
+		return sshx.Result{Stdout: `{"items": [{"kind": "Deployment", "metadata": {"name": "kubenest-grafana"}, "status": {"conditions": [{"type": "Available", "status": "True"}]}}]}`}, nil
+	case strings.Contains(cmd, "get statefulsets"):
+		return sshx.Result{Stdout: `{"items": [
+			{"metadata": {"name": "prometheus-kubenest-prometheus"}, "spec": {"replicas": 1}, "status": {"readyReplicas": 1}},
+			{"metadata": {"name": "alertmanager-kubenest-alertmanager"}, "spec": {"replicas": 1}, "status": {"readyReplicas": 1}},
+			{"metadata": {"name": "kubenest-loki"}, "spec": {"replicas": 1}, "status": {"readyReplicas": 1}}]}`}, nil
+	case strings.Contains(cmd, "get pvc"):
+		return sshx.Result{Stdout: `{"items": [{"metadata": {"name": "kubenest-grafana"}, "spec": {"storageClassName": "kubenest-local"}, "status": {"phase": "Bound"}}]}`}, nil
+	case strings.Contains(cmd, "get httproute"):
+		return sshx.Result{Stdout: `{"status": {"parents": [{"parentRef": {"name": "kubenest-gateway"}, "conditions": [{"type": "Accepted", "status": "True"}, {"type": "ResolvedRefs", "status": "True"}]}]}}`}, nil
+	}
+	return sshx.Result{}, nil
+}
+
+// Every chart is written before anything is waited on, and the route only
+// once the profile is up, so its acceptance is a verdict on the route.
+func TestInstallWritesEveryChartThenTheRoute(t *testing.T) {
+	r := &componenttest.FakeRunner{Respond: cluster}
+	if err := Install(context.Background(), r, bundle(t, pins), nil); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	var written []string
+	for _, cmd := range r.Commands() {
+		if i := strings.Index(cmd, "/manifests/"); i >= 0 && strings.Contains(cmd, "tee") {
+			written = append(written, strings.TrimSuffix(strings.Fields(cmd[i+len("/manifests/"):])[0], ".yaml"))
+		}
+	}
+	want := []string{"kubenest-kube-prometheus-stack", "kubenest-loki", "kubenest-alloy", "kubenest-grafana", RouteManifestName}
+	if !slices.Equal(written, want) {
+		t.Errorf("wrote %v, want %v", written, want)
+	}
+}
+
+// A StatefulSet the operator has not made yet is not a Ready one.
+func TestInstallWaitsForTheOperatorsStatefulSets(t *testing.T) {
+	r := &componenttest.FakeRunner{Respond: func(cmd string) (sshx.Result, error) {
+		if strings.Contains(cmd, "get statefulsets") {
+			return sshx.Result{Stdout: `{"items": [{"metadata": {"name": "kubenest-loki"}, "spec": {"replicas": 1}, "status": {"readyReplicas": 1}}]}`}, nil
+		}
+		return cluster(cmd)
+	}}
+	err := Install(context.Background(), r, bundle(t, pins), nil)
+	if err == nil || !strings.Contains(err.Error(), "prometheus-kubenest-prometheus") {
+		t.Errorf("want the missing Prometheus named at the deadline, got %v", err)
+	}
+}
+
+// Uninstall takes the files out first, so k3s cannot re-apply what it then
+// deletes, waits for the releases to go, and never touches a volume.
+func TestUninstallRemovesTheChartsAndKeepsTheVolumes(t *testing.T) {
+	r := &componenttest.FakeRunner{Respond: cluster}
+	pinned, err := Pinned(bundle(t, pins))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Uninstall(context.Background(), r, bundle(t, pins), pinned, nil); err != nil {
+		t.Fatalf("uninstall: %v", err)
+	}
+	cmds := r.Commands()
+	removed := map[string]int{}
+	for i, cmd := range cmds {
+		if name, ok := strings.CutPrefix(cmd, "sudo -n rm -f /var/lib/rancher/k3s/server/manifests/"); ok {
+			removed[strings.TrimSuffix(name, ".yaml")] = i
+		}
+		if strings.Contains(cmd, "kubectl delete") {
+			payload, err := base64.StdEncoding.DecodeString(strings.Fields(cmd)[2])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(payload), "PersistentVolumeClaim") || strings.Contains(string(payload), "kind: Namespace") {
+				t.Errorf("uninstall deleted a volume or its namespace:\n%s", payload)
+			}
+		}
+		if strings.Contains(cmd, "delete pvc") {
+			t.Errorf("uninstall deleted a volume: %s", cmd)
+		}
+	}
+	for _, name := range []string{"kubenest-grafana", "kubenest-loki", RouteManifestName} {
+		if _, ok := removed[name]; !ok {
+			t.Errorf("%s was not removed from the auto-deploy directory", name)
+		}
+	}
+	if removed[RouteManifestName] > removed["kubenest-grafana"] {
+		t.Error("the route must go before the service it routes to")
+	}
+	if !strings.Contains(cmds[len(cmds)-1], "get helmcharts") {
+		t.Error("uninstall must wait for the releases to be gone")
+	}
+}
@@ -0,0 +1,142 @@
+package observability
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/k3s"
+	"kubenest.io/cli/pkg/storage"
+)
+
+// claimList is the slice of `kubectl get pvc -o json` the volume check reads.
+type claimList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Spec struct {
+			StorageClassName string `json:"storageClassName"`
+		} `json:"spec"`
+		Status struct {
+			Phase string `json:"phase"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// VolumesBoundProbe requires every claim in the namespace Bound, and on
+// kubenest-local. A claim on any other class never converges — it is data
+// the platform's backups and uninstall do not know about — so the deadline's
+// report names it and the class it is on.
+func VolumesBoundProbe(r k3s.Runner) converge.Probe {
+	return func(ctx context.Context) (bool, converge.State, error) {
+		object := "volume claims in " + Namespace
+		out, err := k3s.Kubectl(ctx, r, "get pvc -n "+Namespace+" -o json")
+		if err != nil {
+			return false, converge.State{Object: object, Status: "unobservable"}, err
+		}
+		var claims claimList
+		if err := json.Unmarshal([]byte(out), &claims); err != nil {
+			return false, converge.State{Object: object, Status: "unparsable"}, err
+		}
+		if len(claims.Items) == 0 {
+			return false, converge.State{Object: object, Status: "none yet"}, nil
+		}
+		for _, c := range claims.Items {
+			claim := "pvc " + c.Metadata.Name + " in " + Namespace
+			if c.Spec.StorageClassName != storage.StorageClassName {
+				return false, converge.State{
+					Object: claim,
+					Status: "on storage class " + c.Spec.StorageClassName,
+					Detail: "every observability volume belongs on " + storage.StorageClassName,
+				}, nil
+			}
+			if c.Status.Phase != "Bound" {
+				return false, converge.State{Object: claim, Status: c.Status.Phase}, nil
+			}
+		}
+		return true, converge.State{
+			Object: object,
+			Status: fmt.Sprintf("%d Bound on %s", len(claims.Items), storage.StorageClassName),
+		}, nil
+	}
+}
+
+// routeStatus is the part of an HTTPRoute the acceptance check reads. A
+// route's conditions are per parent, under status.parents, not at
+// status.conditions where component.CheckCondition looks.
+type routeStatus struct {
+	Status struct {
+		Parents []struct {
+			ParentRef struct {
+				Name string `json:"name"`
+			} `json:"parentRef"`
+			Conditions []struct {
+				Type    string `json:"type"`
+				Status  string `json:"status"`
+				Reason  string `json:"reason"`
+				Message string `json:"message"`
+			} `json:"conditions"`
+		} `json:"parents"`
+	} `json:"status"`
+}
+
+// RouteAcceptedProbe requires Grafana's route Accepted by the platform
+// Gateway with its backend resolved — the two together are what make
+// GrafanaPath answer.
+func RouteAcceptedProbe(r k3s.Runner) converge.Probe {
+	return func(ctx context.Context) (bool, converge.State, error) {
+		object := "httproute " + grafanaService + " in " + Namespace
+		out, err := k3s.Kubectl(ctx, r, "get httproute "+grafanaService+" -n "+Namespace+" -o json")
+		if err != nil {
+			return false, converge.State{Object: object, Status: "not found yet"}, err
+		}
+		var route routeStatus
+		if err := json.Unmarshal([]byte(out), &route); err != nil {
+			return false, converge.State{Object: object, Status: "unparsable"}, err
+		}
+		for _, parent := range route.Status.Parents {
+			met := map[string]bool{}
+			for _, c := range parent.Conditions {
+				if c.Status == "True" {
+					met[c.Type] = true
+					continue
+				}
+				if c.Type == "Accepted" || c.Type == "ResolvedRefs" {
+					return false, converge.State{
+						Object: object,
+						Status: c.Type + "=" + c.Status + " (" + c.Reason + ")",
+						Detail: c.Message,
+					}, nil
+				}
+			}
+			if met["Accepted"] && met["ResolvedRefs"] {
+				return true, converge.State{Object: object, Status: "Accepted by " + parent.ParentRef.Name}, nil
+			}
+		}
+		return false, converge.State{Object: object, Status: "not accepted by a Gateway yet"}, nil
+	}
+}
+
+// chartsGoneProbe waits for the named HelmCharts to be deleted. The delete
+// returns at once; helm-controller's uninstall job runs under a finalizer,
+// and the resource is gone only when the release is.
+func chartsGoneProbe(r k3s.Runner, names []string) converge.Probe {
+	return func(ctx context.Context) (bool, converge.State, error) {
+		out, err := k3s.Kubectl(ctx, r, "get helmcharts -n kube-system -o jsonpath='{.items[*].metadata.name}'")
+		if err != nil {
+			return false, converge.State{Object: "helmcharts", Status: "unobservable"}, err
+		}
+		present := strings.Fields(strings.Trim(out, "'"))
+		for _, name := range names {
+			for _, p := range present {
+				if p == name {
+					return false, converge.State{Object: "helmchart " + name, Status: "uninstalling"}, nil
+				}
+			}
+		}
+		return true, converge.State{Object: "observability charts", Status: fmt.Sprintf("%d removed", len(names))}, nil
+	}
+}
@@ -0,0 +1,209 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scope is where a SealedSecret may be unsealed, and it is part of what is
+// encrypted: a secret sealed for one name and namespace cannot be renamed or
+// moved by editing the YAML, because the controller's decryption then fails.
+type Scope string
+
+const (
+	// ScopeStrict binds the secret to its name and namespace. The default,
+	// as in kubeseal.
+	ScopeStrict Scope = "strict"
+	// ScopeNamespaceWide lets it be renamed within its namespace.
+	ScopeNamespaceWide Scope = "namespace-wide"
+	// ScopeClusterWide lets it be unsealed under any name, anywhere.
+	ScopeClusterWide Scope = "cluster-wide"
+)
+
+// ParseScope reads --scope.
+func ParseScope(s string) (Scope, error) {
+	switch scope := Scope(s); scope {
+	case ScopeStrict, ScopeNamespaceWide, ScopeClusterWide:
+		return scope, nil
+	}
+	return "", fmt.Errorf("--scope %q is not a scope: name strict (bound to its name and namespace), namespace-wide or cluster-wide", s)
+}
+
+// label is the OAEP label the scope seals under, exactly as the controller
+// computes it when it unseals.
+func (s Scope) label(namespace, name string) []byte {
+	switch s {
+	case ScopeClusterWide:
+		return nil
+	case ScopeNamespaceWide:
+		return []byte(namespace)
+	default:
+		return []byte(namespace + "/" + name)
+	}
+}
+
+// annotation is what tells the controller the scope; strict carries none.
+func (s Scope) annotation() string {
+	switch s {
+	case ScopeClusterWide:
+		return "sealedsecrets.bitnami.com/cluster-wide"
+	case ScopeNamespaceWide:
+		return "sealedsecrets.bitnami.com/namespace-wide"
+	}
+	return ""
+}
+
+// inputSecret is the part of a Secret manifest sealing reads.
+type inputSecret struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name        string            `yaml:"name"`
+		Namespace   string            `yaml:"namespace"`
+		Labels      map[string]string `yaml:"labels"`
+		Annotations map[string]string `yaml:"annotations"`
+	} `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	Data       map[string]string `yaml:"data"`
+	StringData map[string]string `yaml:"stringData"`
+}
+
+// PublicKey reads the controller's certificate as Cert returns it.
+func PublicKey(certPEM []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("the sealing certificate is not a PEM certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("the sealing certificate does not parse: %w", err)
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("the sealing certificate's key is %T, not RSA: it is not a sealed-secrets certificate", cert.PublicKey)
+	}
+	return key, nil
+}
+
+// Seal encrypts a Secret manifest into the SealedSecret the controller will
+// turn back into it. Each value is encrypted separately, so a reviewer sees
+// which keys changed in a diff without seeing any value.
+//
+// The Secret must name its namespace: there is no kubeconfig to take a
+// default from, and a strict or namespace-wide seal is bound to it.
+func Seal(key *rsa.PublicKey, manifest []byte, scope Scope, rand io.Reader) ([]byte, error) {
+	var in inputSecret
+	if err := yaml.Unmarshal(manifest, &in); err != nil {
+		return nil, fmt.Errorf("the input is not a Secret manifest: %w", err)
+	}
+	if in.Kind != "Secret" || in.APIVersion != "v1" {
+		return nil, fmt.Errorf("the input is a %s %s, not a v1 Secret", in.APIVersion, in.Kind)
+	}
+	if in.Metadata.Name == "" {
+		return nil, fmt.Errorf("the Secret has no metadata.name")
+	}
+	if in.Metadata.Namespace == "" && scope != ScopeClusterWide {
+		return nil, fmt.Errorf("secret %s has no metadata.namespace: a %s seal is bound to its namespace, so name it in the manifest", in.Metadata.Name, scope)
+	}
+
+	// data is base64 and stringData plain; stringData wins on a clash, as it
+	// does when the API server merges them.
+	values := map[string][]byte{}
+	for k, v := range in.Data {
+		raw, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: data.%s is not base64: %w", in.Metadata.Name, k, err)
+		}
+		values[k] = raw
+	}
+	for k, v := range in.StringData {
+		values[k] = []byte(v)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("secret %s has no data or stringData to seal", in.Metadata.Name)
+	}
+
+	label := scope.label(in.Metadata.Namespace, in.Metadata.Name)
+	encrypted := map[string]string{}
+	for _, k := range slices.Sorted(maps.Keys(values)) {
+		ciphertext, err := hybridEncrypt(rand, key, values[k], label)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: sealing %s: %w", in.Metadata.Name, k, err)
+		}
+		encrypted[k] = base64.StdEncoding.EncodeToString(ciphertext)
+	}
+
+	annotations := map[string]string{}
+	if a := scope.annotation(); a != "" {
+		annotations[a] = "true"
+	}
+	metadata := map[string]any{"name": in.Metadata.Name}
+	if in.Metadata.Namespace != "" {
+		metadata["namespace"] = in.Metadata.Namespace
+	}
+	if len(annotations) > 0 {
+		metadata["annotations"] = annotations
+	}
+	// The Secret the controller writes carries the input's own labels and
+	// annotations; only the values are sealed.
+	templateMetadata := maps.Clone(metadata)
+	delete(templateMetadata, "annotations")
+	if len(in.Metadata.Labels) > 0 {
+		templateMetadata["labels"] = in.Metadata.Labels
+	}
+	if len(in.Metadata.Annotations) > 0 {
+		templateMetadata["annotations"] = in.Metadata.Annotations
+	}
+	template := map[string]any{"metadata": templateMetadata}
+	if in.Type != "" {
+		template["type"] = in.Type
+	}
+	return yaml.Marshal(map[string]any{
+		"apiVersion": "bitnami.com/v1alpha1",
+		"kind":       "SealedSecret",
+		"metadata":   metadata,
+		"spec": map[string]any{
+			"encryptedData": encrypted,
+			"template":      template,
+		},
+	})
+}
+
+// hybridEncrypt is the controller's wire format: a fresh AES-256 session key
+// sealed to the controller with RSA-OAEP (SHA-256, the scope as label),
+// its length as two big-endian bytes, then the value under AES-GCM. The
+// nonce is all zeroes, which is safe only because no session key is ever
+// used twice.
+func hybridEncrypt(rand io.Reader, key *rsa.PublicKey, plaintext, label []byte) ([]byte, error) {
+	sessionKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand, sessionKey); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sealedKey, err := rsa.EncryptOAEP(sha256.New(), rand, key, sessionKey, label)
+	if err != nil {
+		return nil, err
+	}
+	out := binary.BigEndian.AppendUint16(nil, uint16(len(sealedKey)))
+	out = append(out, sealedKey...)
+	return gcm.Seal(out, make([]byte, gcm.NonceSize()), plaintext, nil), nil
+}
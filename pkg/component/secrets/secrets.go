@@ -0,0 +1,178 @@
+// Package secrets installs the secrets profile (kn-ynaq): the sealed-secrets
+// controller, which decrypts SealedSecrets committed to git into the Secrets
+// they describe, inside the cluster and nowhere else.
+//
+// The choice of sealed-secrets over an external store is the profile's
+// premise: a platform cluster may have no secrets manager to talk to, and an
+// app team should be able to commit a secret without holding a kubeconfig.
+// Sealing needs only the controller's public certificate, which Cert fetches
+// over the same SSH transport the installer uses, and Seal encrypts against
+// locally (`kubenest secrets seal`).
+//
+// The controller's private keys ARE the secrets: lose them and every
+// SealedSecret in every repository is ciphertext nobody can open. They live
+// in Namespace, which the platform's Velero schedule backs up (see
+// TestKeysAreInTheWorkloadBackup); after a restore, restart the controller so
+// it reads the restored keys.
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"kubenest.io/cli/pkg/component"
+	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/k3s"
+	"kubenest.io/cli/pkg/manifest"
+)
+
+const (
+	// Profile is the profile name in the bundle manifest and on --profile.
+	Profile = "secrets"
+	// Namespace is where the controller and its keys live. It is the
+	// platform's own rather than kube-system, which the workload backups
+	// exclude.
+	Namespace = "kubenest-secrets"
+	// ControllerName is the controller's Deployment and Service. It is
+	// kubeseal's default name, so a team that prefers kubeseal needs only
+	// --controller-namespace.
+	ControllerName = "sealed-secrets-controller"
+	// KeyLabel marks the controller's key Secrets; the value "active" marks
+	// the ones it seals against.
+	KeyLabel = "sealedsecrets.bitnami.com/sealed-secrets-key"
+
+	// ChartName is the HelmChart resource, and manifest file, of the
+	// controller.
+	ChartName = "kubenest-sealed-secrets"
+
+	// componentKey is the profile's pin, under profiles.secrets.components.
+	componentKey = "sealed-secrets"
+	chartRepo    = "https://bitnami-labs.github.io/sealed-secrets"
+	crdName      = "sealedsecrets.bitnami.com"
+)
+
+// Values: the controller under kubeseal's default name, renewing its key
+// every 30 days. Renewal adds a key and keeps the old ones, so everything
+// sealed before a renewal still opens; only new seals use the new key.
+const Values = `fullnameOverride: ` + ControllerName + `
+keyrenewperiod: "720h"
+`
+
+// Chart renders the pinned HelmChart custom resource for the controller.
+func Chart(bundle *manifest.Manifest) (k3s.HelmChart, error) {
+	version, err := Version(bundle)
+	if err != nil {
+		return k3s.HelmChart{}, err
+	}
+	return k3s.Sourced(bundle, k3s.HelmChart{
+		Name:            ChartName,
+		Repo:            chartRepo,
+		Chart:           "sealed-secrets",
+		Version:         version,
+		TargetNamespace: Namespace,
+		ValuesYAML:      Values,
+	}), nil
+}
+
+// Version is the bundle's pin for the controller's chart.
+func Version(bundle *manifest.Manifest) (string, error) {
+	prof, err := bundle.Profiles.Get(Profile)
+	if err != nil {
+		return "", err
+	}
+	version, ok := prof.Components[componentKey]
+	if !ok || version == "" {
+		return "", fmt.Errorf("bundle %s does not pin profiles.%s.components.%s: the bundle decides every version, add the pin to the manifest rather than defaulting in code",
+			bundle.Bundle, Profile, componentKey)
+	}
+	return version, nil
+}
+
+// Install applies the chart and converges until the controller can both
+// seal and unseal: its Deployment Ready, the SealedSecret CRD Established,
+// and an active key published. Re-running it moves the controller to the
+// bundle's pin; its keys are untouched.
+func Install(ctx context.Context, r k3s.Runner, bundle *manifest.Manifest, rep converge.Reporter) error {
+	chart, err := Chart(bundle)
+	if err != nil {
+		return err
+	}
+	deadline, err := bundle.Limits.Timeouts.For("component-ready")
+	if err != nil {
+		return err
+	}
+	doc, err := chart.Manifest()
+	if err != nil {
+		return err
+	}
+	if err := k3s.WriteManifest(ctx, r, chart.Name, doc); err != nil {
+		return err
+	}
+
+	checks := []struct {
+		name  string
+		probe converge.Probe
+	}{
+		{"sealed-secrets-ready", k3s.WorkloadsReadyProbe(r, Namespace)},
+		{"sealed-secrets-crd", component.CRDsEstablishedProbe(r, []string{crdName})},
+		{"sealed-secrets-key", KeyReadyProbe(r)},
+	}
+	for _, check := range checks {
+		res, err := converge.Wait(ctx, check.probe, converge.Options{Name: check.name, Deadline: deadline, Reporter: rep})
+		if err != nil {
+			return err
+		}
+		if err := res.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// KeyReadyProbe requires an active key: the controller generates its first
+// at start-up, and until it has, nothing can be sealed.
+func KeyReadyProbe(r k3s.Runner) converge.Probe {
+	return func(ctx context.Context) (bool, converge.State, error) {
+		object := "sealing key in " + Namespace
+		cert, err := Cert(ctx, r)
+		if err != nil {
+			return false, converge.State{Object: object, Status: "not published yet"}, err
+		}
+		if len(cert) == 0 {
+			return false, converge.State{Object: object, Status: "not generated yet"}, nil
+		}
+		return true, converge.State{Object: object, Status: "active"}, nil
+	}
+}
+
+// Cert returns the PEM certificate of the controller's newest active key,
+// which is the one it seals against, or nil when it has none yet.
+//
+// kubectl is asked for the certificate column alone, so the private keys in
+// the same Secrets never cross the SSH connection.
+func Cert(ctx context.Context, r k3s.Runner) ([]byte, error) {
+	out, err := k3s.Kubectl(ctx, r, "get secrets -n "+Namespace+" -l "+KeyLabel+"=active --no-headers"+
+		` -o 'custom-columns=CREATED:.metadata.creationTimestamp,CERT:.data.tls\.crt'`)
+	if err != nil {
+		return nil, err
+	}
+	newest, at := "", ""
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		// RFC 3339 in UTC sorts as text.
+		if len(fields) != 2 || fields[1] == "<none>" || fields[0] < at {
+			continue
+		}
+		newest, at = fields[1], fields[0]
+	}
+	if newest == "" {
+		return nil, nil
+	}
+	pem, err := base64.StdEncoding.DecodeString(newest)
+	if err != nil {
+		return nil, fmt.Errorf("the sealing certificate in %s is not base64: %w", Namespace, err)
+	}
+	return pem, nil
+}
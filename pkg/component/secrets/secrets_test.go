@@ -0,0 +1,254 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"kubenest.io/cli/pkg/backup"
+	"kubenest.io/cli/pkg/component/componenttest"
+	"kubenest.io/cli/pkg/manifest"
+	"kubenest.io/cli/pkg/sshx"
+)
+
+const pins = `
+bundle: "1.0"
+core:
+  k3s: v1.35.7+k3s1
+limits:
+  timeouts:
+    component-ready: 2s
+profiles:
+  secrets:
+    components:
+      sealed-secrets: 2.17.9
+`
+
+func bundle(t *testing.T, doc string) *manifest.Manifest {
+	t.Helper()
+	m, err := manifest.Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// controllerKey is a key pair and certificate as the controller generates
+// them, for the test to unseal with.
+func controllerKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sealed-secret"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// unseal is the controller's side of hybridEncrypt.
+func unseal(t *testing.T, key *rsa.PrivateKey, ciphertext, label []byte) string {
+	t.Helper()
+	n := binary.BigEndian.Uint16(ciphertext)
+	sessionKey, err := rsa.DecryptOAEP(sha256.New(), nil, key, ciphertext[2:2+n], label)
+	if err != nil {
+		t.Fatalf("unsealing the session key: %v", err)
+	}
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := gcm.Open(nil, make([]byte, gcm.NonceSize()), ciphertext[2+n:], nil)
+	if err != nil {
+		t.Fatalf("unsealing the value: %v", err)
+	}
+	return string(plain)
+}
+
+const secret = `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: shop
+  labels:
+    app: shop
+type: Opaque
+data:
+  user: YWRtaW4=
+stringData:
+  password: hunter2
+`
+
+type sealedSecret struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name        string            `yaml:"name"`
+		Namespace   string            `yaml:"namespace"`
+		Annotations map[string]string `yaml:"annotations"`
+	} `yaml:"metadata"`
+	Spec struct {
+		EncryptedData map[string]string `yaml:"encryptedData"`
+		Template      struct {
+			Metadata struct {
+				Labels map[string]string `yaml:"labels"`
+			} `yaml:"metadata"`
+			Type string `yaml:"type"`
+		} `yaml:"template"`
+	} `yaml:"spec"`
+}
+
+// Every value opens with the controller's private key under the label of the
+// scope it was sealed for, and under no other: a strict seal moved to another
+// name does not open.
+func TestSealOpensOnlyUnderItsScope(t *testing.T) {
+	key, cert := controllerKey(t)
+	pub, err := PublicKey(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		scope      Scope
+		label      string
+		annotation string
+	}{
+		{ScopeStrict, "shop/db", ""},
+		{ScopeNamespaceWide, "shop", "sealedsecrets.bitnami.com/namespace-wide"},
+		{ScopeClusterWide, "", "sealedsecrets.bitnami.com/cluster-wide"},
+	} {
+		t.Run(string(tc.scope), func(t *testing.T) {
+			out, err := Seal(pub, []byte(secret), tc.scope, rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got sealedSecret
+			if err := yaml.Unmarshal(out, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Kind != "SealedSecret" || got.Metadata.Name != "db" || got.Metadata.Namespace != "shop" {
+				t.Errorf("sealed %s %s/%s, want SealedSecret shop/db", got.Kind, got.Metadata.Namespace, got.Metadata.Name)
+			}
+			if tc.annotation != "" && got.Metadata.Annotations[tc.annotation] != "true" {
+				t.Errorf("annotations = %v, want %s", got.Metadata.Annotations, tc.annotation)
+			}
+			if got.Spec.Template.Metadata.Labels["app"] != "shop" || got.Spec.Template.Type != "Opaque" {
+				t.Errorf("template lost the Secret's labels or type: %+v", got.Spec.Template)
+			}
+			want := map[string]string{"user": "admin", "password": "hunter2"}
+			for k, v := range want {
+				raw, err := base64.StdEncoding.DecodeString(got.Spec.EncryptedData[k])
+				if err != nil {
+					t.Fatalf("%s: %v", k, err)
+				}
+				if plain := unseal(t, key, raw, []byte(tc.label)); plain != v {
+					t.Errorf("%s unsealed to %q, want %q", k, plain, v)
+				}
+				if strings.Contains(string(out), v) {
+					t.Errorf("%s appears in the clear", k)
+				}
+			}
+			if tc.scope == ScopeStrict {
+				raw, _ := base64.StdEncoding.DecodeString(got.Spec.EncryptedData["password"])
+				n := binary.BigEndian.Uint16(raw)
+				if _, err := rsa.DecryptOAEP(sha256.New(), nil, key, raw[2:2+n], []byte("shop/other")); err == nil {
+					t.Error("a strict seal opened under another name")
+				}
+			}
+		})
+	}
+}
+
+// There is no kubeconfig to default a namespace from, so a scope bound to one
+// requires the manifest to name it.
+func TestSealRefusesASecretWithoutItsNamespace(t *testing.T) {
+	_, cert := controllerKey(t)
+	pub, err := PublicKey(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := strings.Replace(secret, "  namespace: shop\n", "", 1)
+	_, err = Seal(pub, []byte(doc), ScopeStrict, rand.Reader)
+	if err == nil || !strings.Contains(err.Error(), "metadata.namespace") {
+		t.Fatalf("err = %v, want it to name metadata.namespace", err)
+	}
+	if _, err := Seal(pub, []byte(doc), ScopeClusterWide, rand.Reader); err != nil {
+		t.Errorf("cluster-wide needs no namespace: %v", err)
+	}
+	if _, err := ParseScope("global"); err == nil || !strings.Contains(err.Error(), "namespace-wide") {
+		t.Errorf("ParseScope(global) = %v, want it to name the scopes", err)
+	}
+}
+
+// Cert seals against the newest active key, and asks kubectl for the
+// certificate column only.
+func TestCertIsTheNewestActiveKeysCertificate(t *testing.T) {
+	enc := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	r := &componenttest.FakeRunner{Respond: func(cmd string) (sshx.Result, error) {
+		return sshx.Result{Stdout: "2026-09-01T00:00:00Z   " + enc("new") + "\n" +
+			"2026-08-01T00:00:00Z   " + enc("old") + "\n"}, nil
+	}}
+	cert, err := Cert(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cert) != "new" {
+		t.Errorf("cert = %q, want the newest", cert)
+	}
+	cmd := r.Commands()[0]
+	if !strings.Contains(cmd, KeyLabel+"=active") || strings.Contains(cmd, "tls.key") {
+		t.Errorf("command %q must select active keys and read only the certificate", cmd)
+	}
+
+	none := &componenttest.FakeRunner{}
+	if cert, err := Cert(context.Background(), none); err != nil || cert != nil {
+		t.Errorf("no keys: cert = %q, err = %v; want nil, nil", cert, err)
+	}
+}
+
+func TestChartIsThePinnedController(t *testing.T) {
+	chart, err := Chart(bundle(t, pins))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chart.Version != "2.17.9" || chart.TargetNamespace != Namespace {
+		t.Errorf("chart = %s@%s into %s", chart.Chart, chart.Version, chart.TargetNamespace)
+	}
+	unpinned := strings.Replace(pins, "      sealed-secrets: 2.17.9\n", "      other: 1.0.0\n", 1)
+	if _, err := Chart(bundle(t, unpinned)); err == nil || !strings.Contains(err.Error(), "profiles.secrets.components.sealed-secrets") {
+		t.Errorf("unpinned: err = %v, want it to name the pin", err)
+	}
+}
+
+// The controller's private keys are every SealedSecret's only way back to
+// plaintext: its namespace must be in the workload backups.
+func TestKeysAreInTheWorkloadBackup(t *testing.T) {
+	if !backup.Covers(Namespace) {
+		t.Fatalf("%s is excluded from the workload backups: the sealing keys would not survive losing the cluster", Namespace)
+	}
+}
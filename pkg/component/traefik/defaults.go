@@ -28,6 +28,9 @@ const (
 	// certManagerNamespace is where the CA material lives: a CA ClusterIssuer
 	// reads its secret from cert-manager's own cluster-resource namespace.
 	certManagerNamespace = "cert-manager"
+
+	// DefaultsManifestName is the file DefaultsManifest is written to.
+	DefaultsManifestName = "kubenest-gateway-defaults"
 )
 
 // DefaultsManifest renders the platform's ingress defaults: the namespace,
@@ -146,7 +149,7 @@ func InstallGatewayDefaults(ctx context.Context, r k3s.Runner, bundle *manifest.
 	if err != nil {
 		return err
 	}
-	if err := k3s.WriteManifest(ctx, r, "kubenest-gateway-defaults", []byte(DefaultsManifest)); err != nil {
+	if err := k3s.WriteManifest(ctx, r, DefaultsManifestName, []byte(DefaultsManifest)); err != nil {
 		return err
 	}
 
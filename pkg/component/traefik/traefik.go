@@ -95,14 +95,14 @@ func Chart(bundle *manifest.Manifest) (k3s.HelmChart, error) {
 	if err != nil {
 		return k3s.HelmChart{}, err
 	}
-	return k3s.HelmChart{
+	return k3s.Sourced(bundle, k3s.HelmChart{
 		Name:            "kubenest-traefik",
 		Repo:            chartRepo,
 		Chart:           "traefik",
 		Version:         version,
 		TargetNamespace: Namespace,
 		ValuesYAML:      Values,
-	}, nil
+	}), nil
 }
 
 // Install applies the Traefik chart and converges until its pods are Ready
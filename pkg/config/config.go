@@ -27,6 +27,32 @@ type Config struct {
 	// LegacyAPIURL is read (never written) so a config written by the
 	// pre-platform CLI still logs in against the same control plane.
 	LegacyAPIURL string `json:"api_url,omitempty"`
+
+	// Notifications are the webhooks told about each cluster's installs and
+	// upgrades, by cluster name. They live here, 0600, because a chat
+	// webhook's URL is a credential for the channel it posts to.
+	Notifications map[string][]Webhook `json:"notifications,omitempty"`
+}
+
+// Webhook is one endpoint notified of a cluster's runs.
+type Webhook struct {
+	URL string `json:"url"`
+	// Format is json, slack or teams.
+	Format string `json:"format"`
+	// On is the notifications to send — started, failed, paused,
+	// completed — or empty for all of them.
+	On []string `json:"on,omitempty"`
+	// Template is a text/template for the message line, empty for the
+	// default.
+	Template string `json:"template,omitempty"`
+}
+
+// WebhooksFor returns the webhooks configured for a cluster.
+func (c *Config) WebhooksFor(cluster string) []Webhook {
+	if c == nil {
+		return nil
+	}
+	return c.Notifications[cluster]
 }
 
 const (
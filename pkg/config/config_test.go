@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"testing"
 )
@@ -14,6 +15,9 @@ func TestRoundTrip(t *testing.T) {
 		ControlPlaneURL: "https://api.example.com",
 		Token:           "tok-123",
 		UserEmail:       "op@example.com",
+		Notifications: map[string][]Webhook{
+			"prod-1": {{URL: "https://hooks.slack.com/services/T0/B0/x", Format: "slack", On: []string{"failed", "completed"}}},
+		},
 	}
 	if err := saveTo(path, want); err != nil {
 		t.Fatalf("save: %v", err)
@@ -22,7 +26,7 @@ func TestRoundTrip(t *testing.T) {
 	if err != nil {
 		t.Fatalf("load: %v", err)
 	}
-	if *got != *want {
+	if !reflect.DeepEqual(got, want) {
 		t.Errorf("round-trip mismatch:\n got %+v\nwant %+v", got, want)
 	}
 }
@@ -32,7 +36,7 @@ func TestLoadMissingFileIsEmptyConfig(t *testing.T) {
 	if err != nil {
 		t.Fatalf("load: %v", err)
 	}
-	if *got != (Config{}) {
+	if !reflect.DeepEqual(*got, Config{}) {
 		t.Errorf("expected empty config, got %+v", got)
 	}
 }
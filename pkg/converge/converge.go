@@ -16,6 +16,10 @@
 // the API server while it restarts — are just observations until the
 // deadline. Only the deadline fails a check.
 //
+// A check may be driven by a watch instead of a fixed interval (Watcher): the
+// probe runs whenever what it observes changes, and still on a resync. That
+// changes how soon a check notices, never what it concludes.
+//
 // Deadlines come from the bundle manifest's limits.timeouts (pkg/manifest),
 // never from constants in this package. There is no default deadline.
 package converge
@@ -25,6 +29,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"kubenest.io/cli/pkg/trace"
 )
 
 // Outcome is one of exactly three check results. There is no fourth.
@@ -85,6 +91,15 @@ func (s State) String() string {
 // try to classify their own failures as fatal — the deadline does that.
 type Probe func(ctx context.Context) (done bool, state State, err error)
 
+// Watcher tells Wait when what a probe observes may have changed. It holds a
+// stream open — a `kubectl get --watch` — and calls changed on every update
+// until ctx is done or the stream ends, and returns why it ended.
+//
+// A watcher never decides anything: it only says when to look again, and the
+// probe does the looking. So a check that watches has exactly the verdicts a
+// check that polls has, and a watch that drops costs only promptness.
+type Watcher func(ctx context.Context, changed func()) error
+
 // Event is one progress report while a check converges, and the final report
 // when it settles. Progress is printed, not silence: printing what is still
 // converging is what stops an operator killing an install that was going to
@@ -119,6 +134,15 @@ type Options struct {
 	Deadline time.Duration
 	// Interval between observations. Default 5s.
 	Interval time.Duration
+	// Watch, when set, drives observations from a stream of changes
+	// instead of the Interval: the probe runs when the watch reports one,
+	// and every Resync regardless, in case the stream missed something.
+	// While the watch is down the check polls at Interval, and it gives up
+	// on a watch that keeps dropping without reporting anything.
+	Watch Watcher
+	// Resync is the longest a watched check goes without an observation.
+	// Default 30s.
+	Resync time.Duration
 	// Reporter receives progress. Nil means events are dropped (tests);
 	// the CLI passes a printer.
 	Reporter Reporter
@@ -142,7 +166,19 @@ func (r Result) Err() error {
 	if r.Outcome == Pass {
 		return nil
 	}
-	return fmt.Errorf("%s: fail after %s: %s", r.Check, r.Elapsed.Round(time.Second), r.Last)
+	return &FailError{Result: r}
+}
+
+// FailError is a check whose deadline passed. It is a type, not a string, so
+// a caller can tell "the cluster did not get there in time" — an image pull
+// that outlived its window, a chart download the repo answered with a 502 —
+// from a refusal that no amount of waiting would change.
+type FailError struct {
+	Result Result
+}
+
+func (e *FailError) Error() string {
+	return fmt.Sprintf("%s: fail after %s: %s", e.Result.Check, e.Result.Elapsed.Round(time.Second), e.Result.Last)
 }
 
 // Wait polls probe until it holds or the deadline passes.
@@ -151,7 +187,36 @@ func (r Result) Err() error {
 // in which case it returns the context's error and a Result carrying the last
 // observation with no verdict pretended. Everything else, including probe
 // errors, waits for the deadline.
+//
+// In a traced run each check is a span, with its verdict and how many
+// observations it took: a check that passed on its hundredth observation is
+// where a slow stage's time went, even though it passed. The probe's own
+// commands are spans beneath it.
 func Wait(ctx context.Context, probe Probe, opts Options) (Result, error) {
+	mode := "poll"
+	if opts.Watch != nil {
+		mode = "watch"
+	}
+	ctx, span := trace.Start(ctx, "converge "+opts.Name, trace.String("kubenest.check", opts.Name), trace.String("kubenest.check.mode", mode))
+	res, err := wait(ctx, probe, opts)
+	span.SetAttributes(
+		trace.String("kubenest.check.outcome", string(res.Outcome)),
+		trace.Int("kubenest.check.observations", res.Observations),
+		trace.Int("kubenest.check.deadline_ms", int(opts.Deadline.Milliseconds())),
+	)
+	switch {
+	case err != nil:
+		span.Fail(err.Error())
+	case res.Outcome == Fail:
+		// The object and its status, not the detail: the detail is the
+		// cluster's own words, and a span leaves the site.
+		span.Fail(fmt.Sprintf("fail after %s: %s is %s", res.Elapsed.Round(time.Second), res.Last.Object, res.Last.Status))
+	}
+	span.End()
+	return res, err
+}
+
+func wait(ctx context.Context, probe Probe, opts Options) (Result, error) {
 	if opts.Deadline <= 0 {
 		return Result{}, fmt.Errorf("check %q has no deadline: deadlines come from the bundle manifest's limits.timeouts, pass one via manifest.Timeouts.For", opts.Name)
 	}
@@ -163,6 +228,8 @@ func Wait(ctx context.Context, probe Probe, opts Options) (Result, error) {
 	start := time.Now()
 	deadline := start.Add(opts.Deadline)
 	res := Result{Check: opts.Name}
+	w := newWatch(ctx, opts)
+	defer w.stop()
 
 	for {
 		done, state, err := probe(ctx)
@@ -200,15 +267,116 @@ func Wait(ctx context.Context, probe Probe, opts Options) (Result, error) {
 
 		report(opts, Event{Check: opts.Name, Outcome: Converging, State: state, Elapsed: res.Elapsed, Deadline: opts.Deadline})
 
+		if err := w.next(ctx, interval, deadline); err != nil {
+			res.Elapsed = time.Since(start)
+			return res, err
+		}
+	}
+}
+
+// watchAttempts is how many watches in a row may end without reporting a
+// change before a check stops starting them and only polls: a node whose
+// kubectl cannot watch should cost one failed stream, not one per poll.
+const watchAttempts = 3
+
+// watchFloor is the least time between a watched check's observations, or
+// its Interval if that is shorter. A watch reports every update to what it
+// watches, and a namespace of pods rolling out updates many times a second;
+// each observation is a round of kubectl calls over SSH, so without a floor
+// a watched check would observe far more often than it would ever poll.
+const watchFloor = time.Second
+
+// watch is Wait's side of a Watcher: the stream it is holding open, if any,
+// and whether it is still worth opening one.
+type watch struct {
+	opts     Options
+	cancel   context.CancelFunc
+	ctx      context.Context
+	changes  chan struct{}
+	ended    chan error
+	running  bool
+	failures int
+}
+
+func newWatch(ctx context.Context, opts Options) *watch {
+	ctx, cancel := context.WithCancel(ctx)
+	return &watch{opts: opts, ctx: ctx, cancel: cancel}
+}
+
+// next waits until the next observation is due. Polling, that is the
+// interval. Watching, it is the first change, or the resync, or the
+// deadline — so a watched check fails at its deadline, not a resync later —
+// but never sooner than the floor after the last observation, so a burst of
+// changes is one observation at its end.
+func (w *watch) next(ctx context.Context, interval time.Duration, deadline time.Time) error {
+	last := time.Now()
+	wait := interval
+	if w.start() {
+		wait = w.opts.Resync
+		if wait <= 0 {
+			wait = 30 * time.Second
+		}
+		wait = min(wait, max(time.Until(deadline), 0))
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
 		select {
 		case <-ctx.Done():
-			res.Elapsed = time.Since(start)
-			return res, ctx.Err()
-		case <-time.After(interval):
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		case <-w.changes:
+			w.failures = 0
+			gap := min(interval, watchFloor) - time.Since(last)
+			if gap <= 0 {
+				return nil
+			}
+			timer.Reset(min(gap, max(time.Until(deadline), 0)))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+				return nil
+			}
+		case <-w.ended:
+			// The stream dropped — an API server restarting does that.
+			// Poll until the next observation, which opens another.
+			w.running, w.changes, w.ended = false, nil, nil
+			w.failures++
+			timer.Reset(interval)
 		}
 	}
 }
 
+// start opens the stream when the check watches, none is open and the last
+// few did not all fail, and reports whether one is open.
+func (w *watch) start() bool {
+	if w.opts.Watch == nil || w.running || w.failures >= watchAttempts {
+		return w.running
+	}
+	changes, ended := make(chan struct{}, 1), make(chan error, 1)
+	w.running, w.changes, w.ended = true, changes, ended
+	go func() {
+		ended <- w.opts.Watch(w.ctx, func() {
+			// A burst of updates is one more observation, not one each.
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		})
+	}()
+	return true
+}
+
+// stop ends the stream and waits for its watcher to return.
+func (w *watch) stop() {
+	w.cancel()
+	if w.running {
+		<-w.ended
+	}
+}
+
 func report(opts Options, e Event) {
 	if opts.Reporter != nil {
 		opts.Reporter.Report(e)
@@ -7,6 +7,8 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"kubenest.io/cli/pkg/trace"
 )
 
 // A condition that settles late — transient Error states first, exactly the
@@ -188,3 +190,163 @@ func TestMissingDeadlineRefusesToRun(t *testing.T) {
 		t.Errorf("running without a deadline must refuse and point at the manifest, got: %v", err)
 	}
 }
+
+type spans []trace.SpanData
+
+func (s *spans) Export(_ context.Context, batch []trace.SpanData) error {
+	*s = append(*s, batch...)
+	return nil
+}
+
+func (s *spans) Close() error { return nil }
+
+// In a traced run a check is a span that says how many observations it took,
+// with the probe's own work beneath it — and a failed check names the stuck
+// object without the cluster's detail.
+func TestAWaitIsASpanWithItsObservations(t *testing.T) {
+	var got spans
+	tracer := trace.New("run-1", &got, nil)
+	ctx, root := tracer.Root(context.Background(), "run")
+	probe := func(ctx context.Context) (bool, State, error) {
+		_, span := trace.Start(ctx, "ssh k3s kubectl")
+		span.End()
+		return false, State{Object: "pod traefik-7c9f", Status: "Pending", Detail: "token=abc"}, nil
+	}
+	if _, err := Wait(ctx, probe, Options{Name: "traefik-ready", Deadline: 30 * time.Millisecond, Interval: 10 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	root.End()
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var check trace.SpanData
+	probes := 0
+	for _, s := range got {
+		switch s.Name {
+		case "converge traefik-ready":
+			check = s
+		case "ssh k3s kubectl":
+			probes++
+		}
+	}
+	var observations any
+	for _, a := range check.Attrs {
+		if a.Key == "kubenest.check.observations" {
+			observations = a.Value
+		}
+	}
+	if observations != int64(probes) || probes < 2 {
+		t.Errorf("observations = %v, probe spans = %d", observations, probes)
+	}
+	if !check.Failed || !strings.Contains(check.Message, "pod traefik-7c9f is Pending") || strings.Contains(check.Message, "token") {
+		t.Errorf("failed check span: %v %q", check.Failed, check.Message)
+	}
+}
+
+// A watched check observes when the watch reports a change — here the only
+// thing that could wake it, with the interval and resync an hour away — and
+// the verdict is the probe's, as it would be polling.
+func TestAWatchedCheckObservesOnEachChange(t *testing.T) {
+	var mu sync.Mutex
+	ready := false
+	changes := make(chan func(), 1)
+	watch := func(ctx context.Context, changed func()) error {
+		changes <- changed
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	probe := func(ctx context.Context) (bool, State, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return ready, State{Object: "nodes", Status: "2/3 Ready"}, nil
+	}
+	go func() {
+		changed := <-changes
+		mu.Lock()
+		ready = true
+		mu.Unlock()
+		changed()
+	}()
+
+	start := time.Now()
+	res, err := Wait(context.Background(), probe, Options{
+		Name: "nodes-ready", Deadline: time.Hour, Interval: time.Hour, Resync: time.Hour, Watch: watch,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Outcome != Pass || res.Observations != 2 || time.Since(start) > 5*time.Second {
+		t.Errorf("outcome %s after %d observations in %s, want pass on the change", res.Outcome, res.Observations, time.Since(start))
+	}
+}
+
+// A watch on a busy namespace reports changes back to back; the check still
+// observes no more often than the floor — here its Interval — allows.
+func TestAWatchedCheckKeepsAFloorBetweenObservations(t *testing.T) {
+	watch := func(ctx context.Context, changed func()) error {
+		for ctx.Err() == nil {
+			changed()
+			time.Sleep(time.Millisecond)
+		}
+		return ctx.Err()
+	}
+	probe := func(ctx context.Context) (bool, State, error) {
+		return false, State{Object: "pods", Status: "rolling out"}, nil
+	}
+	res, err := Wait(context.Background(), probe, Options{
+		Name: "core-components", Deadline: 500 * time.Millisecond, Interval: 100 * time.Millisecond, Resync: time.Hour, Watch: watch,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Outcome != Fail || res.Observations > 7 {
+		t.Errorf("outcome %s after %d observations in 500ms, want at most one per 100ms", res.Outcome, res.Observations)
+	}
+}
+
+// A watched check that never holds fails at its deadline, not a resync
+// later: the watch changes when a check looks, not when it gives up.
+func TestAWatchedCheckFailsAtItsDeadline(t *testing.T) {
+	watch := func(ctx context.Context, changed func()) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	probe := func(ctx context.Context) (bool, State, error) {
+		return false, State{Object: "pod traefik-7c9f", Status: "Pending"}, nil
+	}
+	start := time.Now()
+	res, err := Wait(context.Background(), probe, Options{
+		Name: "traefik-ready", Deadline: 100 * time.Millisecond, Resync: time.Hour, Watch: watch,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Outcome != Fail || time.Since(start) > 5*time.Second {
+		t.Errorf("outcome %s after %s, want fail at the deadline", res.Outcome, time.Since(start))
+	}
+}
+
+// A watch that cannot be held open costs a few attempts, and the check polls
+// on to its verdict.
+func TestADroppingWatchFallsBackToPolling(t *testing.T) {
+	watches := 0
+	watch := func(ctx context.Context, changed func()) error {
+		watches++
+		return errors.New("kubectl exited 1: the server doesn't have a resource type")
+	}
+	observations := 0
+	probe := func(ctx context.Context) (bool, State, error) {
+		observations++
+		return observations == 8, State{}, nil
+	}
+	res, err := Wait(context.Background(), probe, Options{
+		Name: "nodes-ready", Deadline: 5 * time.Second, Interval: time.Millisecond, Resync: time.Hour, Watch: watch,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Outcome != Pass || watches != 3 {
+		t.Errorf("outcome %s, %d watches opened, want pass by polling after 3", res.Outcome, watches)
+	}
+}
@@ -58,3 +58,16 @@ func (r *TextReporter) Report(e Event) {
 	}
 	r.lastState[e.Check] = e.State
 }
+
+// Reporters fans one event out to several reporters, so a check's progress
+// can be printed for a person and streamed for a machine from the same poll.
+type Reporters []Reporter
+
+// Report calls every reporter.
+func (rs Reporters) Report(e Event) {
+	for _, r := range rs {
+		if r != nil {
+			r.Report(e)
+		}
+	}
+}
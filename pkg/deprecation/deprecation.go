@@ -134,7 +134,7 @@ func Scan(ctx context.Context, r Runner, bundle *manifest.Manifest, acknowledged
 		return Report{}, fmt.Errorf("cannot scan without a target Kubernetes version")
 	}
 
-	binary, err := ensurePluto(ctx, r, scanner)
+	binary, err := ensurePluto(ctx, r, scanner, bundle.Offline)
 	if err != nil {
 		// Fail closed, loudly. Not being able to scan is not the same as
 		// finding nothing, and must never be reported as if it were.
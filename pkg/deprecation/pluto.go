@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	"kubenest.io/cli/pkg/k3s"
 	"kubenest.io/cli/pkg/manifest"
 )
 
@@ -26,7 +27,11 @@ var ReleaseBaseURL = "https://github.com/FairwindsOps/pluto/releases/download"
 // therefore a different download. There is no "latest", and an already
 // present binary at the pinned path is reused rather than re-fetched, which
 // makes a resumed upgrade cheap.
-func ensurePluto(ctx context.Context, r Runner, scanner manifest.DeprecationScanner) (string, error) {
+//
+// From an offline bundle the release tarball is pushed from the archive
+// instead of downloaded; everything after that — the versioned path, the
+// version proof — is the same.
+func ensurePluto(ctx context.Context, r Runner, scanner manifest.DeprecationScanner, offline manifest.Artifacts) (string, error) {
 	path := fmt.Sprintf("%s/pluto-%s", installDir, scanner.Version)
 
 	present, err := run(ctx, r, fmt.Sprintf("test -x %s && echo present || echo absent", shellQuote(path)))
@@ -46,14 +51,24 @@ func ensurePluto(ctx context.Context, r Runner, scanner manifest.DeprecationScan
 		return "", err
 	}
 
-	// pluto's release assets are pluto_<version-without-v>_linux_<arch>.tar.gz.
 	version := strings.TrimPrefix(scanner.Version, "v")
-	url := fmt.Sprintf("%s/%s/pluto_%s_linux_%s.tar.gz", ReleaseBaseURL, scanner.Version, version, goarch)
+	release := releaseArtifact(scanner, goarch)
+	url := release.URL
+
+	fetch := k3s.Fetch(url, "\"$tmp/pluto.tar.gz\"")
+	if offline != nil {
+		pushed := fmt.Sprintf("%s/pluto-%s-%s.tar.gz", installDir, scanner.Version, goarch)
+		if err := k3s.PushArtifact(ctx, r, offline, release.Name, pushed, "0644"); err != nil {
+			return "", err
+		}
+		url = "the offline bundle"
+		fetch = fmt.Sprintf("cp %s \"$tmp/pluto.tar.gz\"", shellQuote(pushed))
+	}
 
 	script := strings.Join([]string{
 		fmt.Sprintf("sudo -n install -d -m 0755 %s", installDir),
 		"tmp=$(mktemp -d)",
-		fmt.Sprintf("curl -sfL %s -o \"$tmp/pluto.tar.gz\"", shellQuote(url)),
+		fetch,
 		"tar -xzf \"$tmp/pluto.tar.gz\" -C \"$tmp\" pluto",
 		fmt.Sprintf("sudo -n install -m 0755 \"$tmp/pluto\" %s", shellQuote(path)),
 		"rm -rf \"$tmp\"",
@@ -76,6 +91,47 @@ func ensurePluto(ctx context.Context, r Runner, scanner manifest.DeprecationScan
 	return path, nil
 }
 
+// Artifacts is the scanner release an offline bundle carries for one
+// architecture.
+func Artifacts(bundle *manifest.Manifest, arch string) ([]manifest.Artifact, error) {
+	scanner, err := bundle.Upgrade.Scanner()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := archOf(arch); err != nil {
+		return nil, err
+	}
+	return []manifest.Artifact{releaseArtifact(scanner, arch)}, nil
+}
+
+// releaseArtifact is pluto's release tarball: the assets are
+// pluto_<version-without-v>_linux_<arch>.tar.gz.
+func releaseArtifact(scanner manifest.DeprecationScanner, goarch string) manifest.Artifact {
+	version := strings.TrimPrefix(scanner.Version, "v")
+	asset := fmt.Sprintf("pluto_%s_linux_%s.tar.gz", version, goarch)
+	return manifest.Artifact{
+		Name: "bin/pluto/" + scanner.Version + "/" + asset,
+		URL:  fmt.Sprintf("%s/%s/%s", ReleaseBaseURL, scanner.Version, asset),
+	}
+}
+
+// StageScanner places the pinned scanner on a server from an offline bundle,
+// at the install. The nodes have no egress to fetch it at the first upgrade's
+// scan, and a scan that cannot run refuses the upgrade — correctly, but it
+// should not be the first anyone hears of it. A bundle with no scanner pin has
+// nothing to stage; the upgrade that needs one says so.
+func StageScanner(ctx context.Context, r Runner, bundle *manifest.Manifest) error {
+	if bundle.Offline == nil {
+		return nil
+	}
+	scanner, err := bundle.Upgrade.Scanner()
+	if err != nil {
+		return nil
+	}
+	_, err = ensurePluto(ctx, r, scanner, bundle.Offline)
+	return err
+}
+
 // archOf maps uname -m to the release asset's architecture. An architecture
 // with no pinned asset is an error rather than a guess: downloading the wrong
 // binary would fail later and less clearly.
@@ -0,0 +1,225 @@
+// Package diagnose captures what an engineer would look at first when a
+// convergence check fails: the stuck object's describe output, its
+// namespace's events, its containers' recent logs, and — for a component k3s
+// installs from a HelmChart — the helm-install job's logs.
+//
+// A failed check already names the object and its state ("pod traefik-7c9f
+// in kube-system is Pending"). What it cannot carry is the evidence, and by
+// the time someone has SSHed in to run kubectl describe, the pod has been
+// rescheduled and its previous container's logs are gone. So the evidence is
+// taken at the moment of failure, from the server node the run is already
+// connected to, and written to a local directory the failure message names.
+//
+// Everything written is redacted as the journal is (stages.Redact), but kept
+// whole: a describe cut at two thousand characters answers nothing. The
+// directory is 0700 like the journal's, because logs are the site's.
+//
+// A capture is best-effort throughout. A command that fails is recorded in
+// its file in place of the output — "could not capture: …" is itself
+// evidence, an API server that is not answering — and only a directory that
+// cannot be written fails the capture.
+package diagnose
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/k3s"
+	"kubenest.io/cli/pkg/stages"
+)
+
+// LogLines is how much of each container's log is kept: enough to hold the
+// crash and what led to it, not the hour of healthy output before.
+const LogLines = 200
+
+// Object is a Kubernetes object parsed from a convergence State.Object.
+// Kind is empty when the state names only a namespace — "pods in openebs" —
+// and Namespace is empty for a cluster-scoped object.
+type Object struct {
+	Kind      string
+	Name      string
+	Namespace string
+}
+
+func (o Object) String() string {
+	s := o.Kind + " " + o.Name
+	if o.Kind == "" {
+		s = "namespace " + o.Namespace
+	} else if o.Namespace != "" {
+		s += " in " + o.Namespace
+	}
+	return s
+}
+
+// kinds are the words the checks use for an object that kubectl can describe.
+// A word not here — "workloads", "core components", "the agent" — names no
+// one object, and only its namespace, if any, is captured.
+var kinds = map[string]string{
+	"pod": "pod", "deployment": "deployment", "deploy": "deployment",
+	"daemonset": "daemonset", "statefulset": "statefulset", "job": "job",
+	"node": "node", "helmchart": "helmchart", "certificate": "certificate",
+	"gateway": "gateway", "httproute": "httproute", "pvc": "pvc",
+	"storageclass": "storageclass", "csidriver": "csidriver", "csinode": "csinode",
+	"backupstoragelocation": "backupstoragelocation", "schedule": "schedule",
+	"backup": "backup", "diskpool": "diskpool", "namespace": "namespace",
+}
+
+// dnsName is a Kubernetes object or namespace name. Nothing else is put on a
+// command line: State.Object is text, and text reaches a shell.
+var dnsName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+
+// Parse reads the object a check names. The checks write it in a few shapes —
+// "pod x in ns", "gateway/x in ns", "pod ns/x", "node x", "pods in ns" — and
+// anything else is not an object, reported false.
+func Parse(text string) (Object, bool) {
+	var o Object
+	head, ns, scoped := strings.Cut(strings.TrimSpace(text), " in ")
+	if scoped {
+		if !dnsName.MatchString(ns) {
+			return Object{}, false
+		}
+		o.Namespace = ns
+	}
+	kind, name, ok := strings.Cut(head, " ")
+	if !ok {
+		kind, name, ok = strings.Cut(head, "/")
+	}
+	if ok && !strings.Contains(name, " ") {
+		if k, known := kinds[kind]; known {
+			if n, inNS, qualified := strings.Cut(name, "/"); qualified && o.Namespace == "" && dnsName.MatchString(n) {
+				name, o.Namespace = inNS, n
+			}
+			if dnsName.MatchString(name) {
+				o.Kind, o.Name = k, name
+				if k == "namespace" {
+					return Object{Namespace: name}, true
+				}
+				return o, true
+			}
+		}
+	}
+	if o.Namespace != "" {
+		return Object{Namespace: o.Namespace}, true
+	}
+	return Object{}, false
+}
+
+// Dir is where a run's capture for one stage is written: beside the journal
+// it explains, one directory per run and stage.
+func Dir(journalPath, runID, stage string) string {
+	base := strings.TrimSuffix(filepath.Base(journalPath), filepath.Ext(journalPath))
+	return filepath.Join(filepath.Dir(journalPath), "diagnostics", base, runID+"-"+stage)
+}
+
+// Capture gathers the evidence for the object state names, through the server
+// node r, into dir. It returns an error only when dir cannot be written, or
+// when state names nothing there is evidence for.
+func Capture(ctx context.Context, r k3s.Runner, state converge.State, dir string) error {
+	obj, ok := Parse(state.Object)
+	if !ok {
+		return fmt.Errorf("%q names no Kubernetes object to capture", state.Object)
+	}
+	if obj.Kind == "helmchart" && obj.Namespace == "" {
+		// Where k3s's auto-deploy directory puts every chart it applies.
+		obj.Namespace = "kube-system"
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("diagnostics directory: %w", err)
+	}
+
+	// Each file is one kubectl command's output.
+	type file struct{ name, args string }
+	var files []file
+	add := func(name, args string) { files = append(files, file{name, args}) }
+	ns := ""
+	if obj.Namespace != "" {
+		ns = " -n " + obj.Namespace
+	}
+	tail := " --all-containers --prefix --tail=" + strconv.Itoa(LogLines)
+	if obj.Kind != "" {
+		add("describe.txt", "describe "+obj.Kind+" "+obj.Name+ns)
+	}
+	switch {
+	case obj.Namespace != "":
+		add("events.txt", "get events"+ns+" --sort-by=.lastTimestamp")
+		add("pods.txt", "get pods"+ns+" -o wide")
+	case obj.Kind != "":
+		add("events.txt", "get events -A --field-selector involvedObject.name="+obj.Name+" --sort-by=.lastTimestamp")
+	}
+	switch obj.Kind {
+	case "pod":
+		add("logs.txt", "logs pod/"+obj.Name+ns+tail)
+		// The container that crashed is the previous one; the current one
+		// has only just started.
+		add("logs-previous.txt", "logs pod/"+obj.Name+ns+tail+" --previous")
+	case "deployment", "daemonset", "statefulset", "job":
+		add("logs.txt", "logs "+obj.Kind+"/"+obj.Name+ns+tail)
+	}
+	for _, chart := range helmCharts(ctx, r, obj) {
+		add("helm-install-"+chart.Name+".txt", "logs job/helm-install-"+chart.Name+" -n "+chart.Namespace+" --tail="+strconv.Itoa(LogLines))
+	}
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "Check failed on %s at %s.\nLast state: %s\n\n", obj, time.Now().UTC().Format(time.RFC3339), state)
+	for _, f := range files {
+		out, err := k3s.Kubectl(ctx, r, f.args)
+		if err != nil {
+			out = "could not capture: " + err.Error() + "\n"
+		}
+		if err := os.WriteFile(filepath.Join(dir, f.name), []byte(stages.Redact(out)), 0o600); err != nil {
+			return fmt.Errorf("diagnostics directory: %w", err)
+		}
+		fmt.Fprintf(&summary, "%-28s kubectl %s\n", f.name, f.args)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "summary.txt"), []byte(stages.Redact(summary.String())), 0o600); err != nil {
+		return fmt.Errorf("diagnostics directory: %w", err)
+	}
+	return nil
+}
+
+// helmCharts are the HelmCharts behind obj: the one it is, or those that
+// install into its namespace. A component that never became Ready is as
+// often a chart that never installed, and the helm-install job's log is
+// where that says why.
+func helmCharts(ctx context.Context, r k3s.Runner, obj Object) []Object {
+	if obj.Namespace == "" && obj.Kind != "helmchart" {
+		return nil
+	}
+	out, err := k3s.Kubectl(ctx, r, "get helmcharts -A -o json")
+	if err != nil {
+		return nil
+	}
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+			Spec struct {
+				TargetNamespace string `json:"targetNamespace"`
+			} `json:"spec"`
+		} `json:"items"`
+	}
+	if json.Unmarshal([]byte(out), &list) != nil {
+		return nil
+	}
+	var charts []Object
+	for _, c := range list.Items {
+		name, ns := c.Metadata.Name, c.Metadata.Namespace
+		if !dnsName.MatchString(name) || !dnsName.MatchString(ns) {
+			continue
+		}
+		if (obj.Kind == "helmchart" && name == obj.Name) || (obj.Kind != "helmchart" && c.Spec.TargetNamespace == obj.Namespace) {
+			charts = append(charts, Object{Kind: "helmchart", Name: name, Namespace: ns})
+		}
+	}
+	return charts
+}
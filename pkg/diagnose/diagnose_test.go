@@ -0,0 +1,124 @@
+package diagnose
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kubenest.io/cli/pkg/component/componenttest"
+	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/sshx"
+)
+
+func TestParseReadsTheShapesChecksWrite(t *testing.T) {
+	for text, want := range map[string]Object{
+		"pod traefik-7c9f in kube-system":     {Kind: "pod", Name: "traefik-7c9f", Namespace: "kube-system"},
+		"gateway/kubenest-gateway in traefik": {Kind: "gateway", Name: "kubenest-gateway", Namespace: "traefik"},
+		"pod mayastor/io-engine-x":            {Kind: "pod", Name: "io-engine-x", Namespace: "mayastor"},
+		"deploy cert-manager":                 {Kind: "deployment", Name: "cert-manager"},
+		"node worker-1":                       {Kind: "node", Name: "worker-1"},
+		"pods in openebs":                     {Namespace: "openebs"},
+		"sealing key in sealed-secrets":       {Namespace: "sealed-secrets"},
+		"namespace velero":                    {Namespace: "velero"},
+		// A name that is not one never reaches kubectl; its namespace can.
+		"pod x;reboot in kube-system": {Namespace: "kube-system"},
+	} {
+		got, ok := Parse(text)
+		if !ok || got != want {
+			t.Errorf("Parse(%q) = %+v, %v; want %+v", text, got, ok, want)
+		}
+	}
+	// Nothing to describe, and nothing that is not a name reaches kubectl.
+	for _, text := range []string{"nodes", "core components", "the agent", "pod $(id)"} {
+		if got, ok := Parse(text); ok {
+			t.Errorf("Parse(%q) = %+v, want nothing", text, got)
+		}
+	}
+}
+
+// A stuck pod gets its describe, its namespace's events, its current and
+// previous logs, and the logs of the chart that installs into its namespace
+// — redacted, in a directory only its owner can read.
+func TestCaptureWritesTheEvidenceForAStuckPod(t *testing.T) {
+	r := &componenttest.FakeRunner{Respond: func(command string) (sshx.Result, error) {
+		switch {
+		case strings.Contains(command, "get helmcharts"):
+			return sshx.Result{Stdout: `{"items":[
+				{"metadata":{"name":"traefik","namespace":"kube-system"},"spec":{"targetNamespace":"traefik"}},
+				{"metadata":{"name":"cert-manager","namespace":"kube-system"},"spec":{"targetNamespace":"cert-manager"}}]}`}, nil
+		case strings.Contains(command, "describe pod traefik-7c9f -n traefik"):
+			return sshx.Result{Stdout: "Name: traefik-7c9f\nStatus: Pending\n"}, nil
+		case strings.Contains(command, "--previous"):
+			return sshx.Result{ExitCode: 1, Stderr: "previous terminated container not found"}, nil
+		case strings.Contains(command, "logs pod/traefik-7c9f"):
+			return sshx.Result{Stdout: "level=error msg=\"auth\" token=knp_abcdefghijklmnop\n"}, nil
+		case strings.Contains(command, "logs job/helm-install-traefik -n kube-system"):
+			return sshx.Result{Stdout: "Error: chart requires kubeVersion >= 1.30\n"}, nil
+		}
+		return sshx.Result{Stdout: "ok\n"}, nil
+	}}
+	dir := filepath.Join(t.TempDir(), "diagnostics", "prod-1", "run-1-platform-networking")
+	state := converge.State{Object: "pod traefik-7c9f in traefik", Status: "Pending", Detail: "0/3 nodes are available"}
+	if err := Capture(context.Background(), r, state, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(name string) string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return string(b)
+	}
+	if !strings.Contains(read("describe.txt"), "Status: Pending") {
+		t.Error("describe output missing")
+	}
+	if logs := read("logs.txt"); strings.Contains(logs, "knp_abcdefghijklmnop") || !strings.Contains(logs, "[redacted token]") {
+		t.Errorf("logs were not redacted: %q", logs)
+	}
+	if !strings.Contains(read("logs-previous.txt"), "could not capture: kubectl") {
+		t.Error("a command that failed should say so in its file")
+	}
+	if !strings.Contains(read("helm-install-traefik.txt"), "kubeVersion") {
+		t.Error("the helm-install job's logs are missing")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "helm-install-cert-manager.txt")); err == nil {
+		t.Error("captured a chart that installs into another namespace")
+	}
+	summary := read("summary.txt")
+	for _, want := range []string{"pod traefik-7c9f in traefik", "0/3 nodes are available", "events.txt"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary lacks %q:\n%s", want, summary)
+		}
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0o700 {
+		t.Errorf("directory mode = %v, want 0700", info.Mode().Perm())
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "logs.txt")); info.Mode().Perm() != 0o600 {
+		t.Errorf("file mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestCaptureRefusesAStateThatNamesNoObject(t *testing.T) {
+	r := &componenttest.FakeRunner{}
+	dir := filepath.Join(t.TempDir(), "d")
+	if err := Capture(context.Background(), r, converge.State{Object: "core components"}, dir); err == nil {
+		t.Fatal("captured evidence for nothing")
+	}
+	if len(r.Commands()) != 0 {
+		t.Errorf("ran %v", r.Commands())
+	}
+	if _, err := os.Stat(dir); err == nil {
+		t.Error("created a directory with nothing to put in it")
+	}
+}
+
+func TestDirSitsBesideTheJournal(t *testing.T) {
+	got := Dir("/home/op/.kubenest/journal/prod-1-upgrade.json", "abc123", "verify")
+	if want := "/home/op/.kubenest/journal/diagnostics/prod-1-upgrade/abc123-verify"; got != want {
+		t.Errorf("Dir = %s, want %s", got, want)
+	}
+}
@@ -22,20 +22,11 @@ import (
 // credential on a command line lands in shell history, in `ps`, and in the
 // install transcript someone pastes into a support ticket.
 func parseBackupTarget(raw string) (backup.Target, error) {
-	u, err := url.Parse(raw)
-	if err != nil || u.Scheme != "s3" || u.Host == "" {
-		return backup.Target{}, fmt.Errorf(
-			"--backup-target %q is not an S3 target: use s3://<bucket>[/<prefix>]?endpoint=<host>&region=<region> (credentials come from KUBENEST_BACKUP_ACCESS_KEY_ID and KUBENEST_BACKUP_SECRET_ACCESS_KEY, never from a flag)", raw)
-	}
-	query := u.Query()
-	target := backup.Target{
-		Bucket:          u.Host,
-		Prefix:          strings.Trim(u.Path, "/"),
-		Endpoint:        query.Get("endpoint"),
-		Region:          query.Get("region"),
-		AccessKeyID:     envFirst("KUBENEST_BACKUP_ACCESS_KEY_ID", "AWS_ACCESS_KEY_ID"),
-		SecretAccessKey: envFirst("KUBENEST_BACKUP_SECRET_ACCESS_KEY", "AWS_SECRET_ACCESS_KEY"),
+	target, err := backupTargetLocation(raw)
+	if err != nil {
+		return backup.Target{}, err
 	}
+	target.AccessKeyID, target.SecretAccessKey = backupCredentials()
 	if target.AccessKeyID == "" || target.SecretAccessKey == "" {
 		return backup.Target{}, fmt.Errorf(
 			"--backup-target was given but no credentials are in the environment: set KUBENEST_BACKUP_ACCESS_KEY_ID and KUBENEST_BACKUP_SECRET_ACCESS_KEY (or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY). Omit --backup-target to install Velero unconfigured and set a target later")
@@ -46,6 +37,28 @@ func parseBackupTarget(raw string) (backup.Target, error) {
 	return target, nil
 }
 
+// backupTargetLocation reads only the coordinates: where the backups go,
+// without the credentials that open the bucket. A plan renders this much.
+func backupTargetLocation(raw string) (backup.Target, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return backup.Target{}, fmt.Errorf(
+			"--backup-target %q is not an S3 target: use s3://<bucket>[/<prefix>]?endpoint=<host>&region=<region> (credentials come from KUBENEST_BACKUP_ACCESS_KEY_ID and KUBENEST_BACKUP_SECRET_ACCESS_KEY, never from a flag)", raw)
+	}
+	query := u.Query()
+	return backup.Target{
+		Bucket:   u.Host,
+		Prefix:   strings.Trim(u.Path, "/"),
+		Endpoint: query.Get("endpoint"),
+		Region:   query.Get("region"),
+	}, nil
+}
+
+func backupCredentials() (accessKeyID, secretAccessKey string) {
+	return envFirst("KUBENEST_BACKUP_ACCESS_KEY_ID", "AWS_ACCESS_KEY_ID"),
+		envFirst("KUBENEST_BACKUP_SECRET_ACCESS_KEY", "AWS_SECRET_ACCESS_KEY")
+}
+
 func envFirst(names ...string) string {
 	for _, name := range names {
 		if value := os.Getenv(name); value != "" {
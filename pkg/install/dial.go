@@ -2,6 +2,7 @@ package install
 
 import (
 	"context"
+	"slices"
 	"time"
 
 	"kubenest.io/cli/pkg/preflight"
@@ -20,43 +21,55 @@ const dialTimeout = 15 * time.Second
 // re-run can fix everything the operator can see. Returning early on the
 // first unreachable node would make a three-node install a three-run install.
 //
+// The dials run --parallel at a time: a fleet with a few nodes down would
+// otherwise spend dialTimeout on each of them in turn before preflight could
+// say so. The nodes come back in the order they were given, servers first,
+// whatever order the dials finish in.
+//
 // Key material comes from --ssh-key, ssh-agent or ~/.ssh/config and never
 // leaves this machine.
 func (s *Session) dialAll(ctx context.Context) []preflight.Node {
+	addresses := append(slices.Clone(s.Opts.Servers), s.Opts.Agents...)
+	nodes := make([]preflight.Node, len(addresses))
+	// Never an error: dialNode records a failure on the node.
+	_ = eachNode(ctx, s.parallelism(), "dialling nodes", "dial", addresses, nil, func(ctx context.Context, i int) error {
+		role := RoleAgent
+		if i < len(s.Opts.Servers) {
+			role = RoleServer
+		}
+		nodes[i] = s.dialNode(ctx, addresses[i], role)
+		return nil
+	})
+	return nodes
+}
+
+// dialNode opens one node's connection, recording a failure on the node
+// rather than returning it, for the reason dialAll gives.
+func (s *Session) dialNode(ctx context.Context, address string, role NodeRole) preflight.Node {
 	opts := sshx.Options{
 		User:        s.Opts.SSHUser,
 		KeyPath:     s.Opts.SSHKey,
 		DialTimeout: dialTimeout,
 	}
-	var nodes []preflight.Node
-	add := func(address string, role NodeRole) {
-		node := preflight.Node{Address: address, Role: string(role)}
-		endpoint, err := sshx.Resolve(address, opts)
-		if err != nil {
-			node.DialErr = err
-			nodes = append(nodes, node)
-			return
-		}
-		client, err := sshx.Dial(ctx, endpoint, opts)
-		if err != nil {
-			node.DialErr = err
-			nodes = append(nodes, node)
-			return
-		}
-		// Wrapped so a dropped connection is redialled rather than turning
-		// every later observation into the same dead-socket error. See
-		// reconnect.go — this was a real failure on the host gate, not a
-		// hypothetical.
-		runner := newReconnectingRunner(address, opts, client)
-		s.closers = append(s.closers, runner)
-		node.Runner = runner
-		nodes = append(nodes, node)
+	node := preflight.Node{Address: address, Role: string(role)}
+	endpoint, err := sshx.Resolve(address, opts)
+	if err != nil {
+		node.DialErr = err
+		return node
 	}
-	for _, address := range s.Opts.Servers {
-		add(address, RoleServer)
+	client, err := sshx.Dial(ctx, endpoint, opts)
+	if err != nil {
+		node.DialErr = err
+		return node
 	}
-	for _, address := range s.Opts.Agents {
-		add(address, RoleAgent)
-	}
-	return nodes
+	// Wrapped so a dropped connection is redialled rather than turning
+	// every later observation into the same dead-socket error. See
+	// reconnect.go — this was a real failure on the host gate, not a
+	// hypothetical.
+	runner := newReconnectingRunner(address, opts, client)
+	s.mu.Lock()
+	s.closers = append(s.closers, runner)
+	s.mu.Unlock()
+	node.Runner = runner
+	return node
 }
@@ -36,6 +36,8 @@ type (
 	Emitters = stages.Emitters
 	// TextEmitter prints transitions for a human.
 	TextEmitter = stages.TextEmitter
+	// Findings receives preflight results one at a time.
+	Findings = stages.Findings
 	// NopEmitter drops them.
 	NopEmitter = stages.NopEmitter
 	// ControlPlaneEmitter publishes to the control plane.
@@ -50,6 +52,9 @@ const (
 	StatusStarted   = stages.StatusStarted
 	StatusCompleted = stages.StatusCompleted
 	StatusFailed    = stages.StatusFailed
+	StatusReopened  = stages.StatusReopened
+	StatusRetrying  = stages.StatusRetrying
+	StatusHook      = stages.StatusHook
 )
 
 // Kind names this operation in the journal, so an install journal and an
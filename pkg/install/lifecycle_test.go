@@ -169,5 +169,10 @@ func TestPlanMatchesTheStageContract(t *testing.T) {
 		if stage.AlwaysRun != alwaysRun[stage.Name] {
 			t.Errorf("stage %s AlwaysRun = %v, want %v", stage.Name, stage.AlwaysRun, alwaysRun[stage.Name])
 		}
+		// Only the platform layer runs side by side: what comes after it
+		// must still wait for all of it.
+		if concurrent := i >= 4 && i <= 8; concurrent != (stage.After != nil) {
+			t.Errorf("stage %s After = %v", stage.Name, stage.After)
+		}
 	}
 }
@@ -0,0 +1,42 @@
+package install
+
+import (
+	"strings"
+	"testing"
+)
+
+// Replacing a failed ha server starts by removing it, which must be allowed;
+// removing a healthy one while another is down must not.
+func TestCheckMembersRefusesOnlyQuorumLoss(t *testing.T) {
+	ha := Options{Name: "prod-1", HATier: "ha", Servers: []string{"10.0.1.10", "10.0.1.11", "10.0.1.12"}}
+	for _, c := range []struct {
+		name    string
+		address string
+		ready   map[string]bool
+		refused string
+		warned  string
+	}{
+		{"the failed member", "10.0.1.12", map[string]bool{"10.0.1.10": true, "10.0.1.11": true}, "", "survives no further server failure"},
+		{"a healthy member while another is down", "10.0.1.10", map[string]bool{"10.0.1.10": true, "10.0.1.11": true}, "only 1 of them Ready (not Ready: 10.0.1.12)", ""},
+	} {
+		warning, err := checkMembers(ha, c.address, c.ready)
+		switch {
+		case c.refused != "":
+			if err == nil || !strings.Contains(err.Error(), c.refused) {
+				t.Errorf("%s: want a refusal containing %q, got %v", c.name, c.refused, err)
+			}
+		case err != nil:
+			t.Errorf("%s: refused: %v", c.name, err)
+		case !strings.Contains(warning, c.warned):
+			t.Errorf("%s: want a warning containing %q, got %q", c.name, c.warned, warning)
+		}
+	}
+
+	// Four members, one of them leaving: three remain and nothing is lost.
+	four := ha
+	four.Servers = append(four.Servers, "10.0.1.13")
+	ready := map[string]bool{"10.0.1.10": true, "10.0.1.11": true, "10.0.1.12": true, "10.0.1.13": true}
+	if warning, err := checkMembers(four, "10.0.1.13", ready); err != nil || warning != "" {
+		t.Errorf("four to three: warning %q, err %v", warning, err)
+	}
+}
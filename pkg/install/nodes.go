@@ -0,0 +1,675 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"kubenest.io/cli/pkg/api"
+	"kubenest.io/cli/pkg/backup"
+	"kubenest.io/cli/pkg/k3s"
+	"kubenest.io/cli/pkg/preflight"
+	"kubenest.io/cli/pkg/stages"
+	"kubenest.io/cli/pkg/storage"
+	"kubenest.io/cli/pkg/uninstall"
+)
+
+// AddAgents joins worker nodes to a cluster this machine installed
+// (`kubenest platform add-node`). It is the agent half of the install, run
+// again for new hosts only: preflight's node-level checks and a port probe
+// against the existing peers, k3s.InstallAgent, the volume group, and the
+// same node-ready wait stage 4 ends on; then the existing nodes' NO_PROXY
+// gains the new addresses (refreshProxy).
+//
+// The session is the INSTALL's, rebuilt from its journal: the node lists, the
+// tier and the storage device are what the install recorded, and Bundle is
+// the manifest the cluster is on NOW — an upgraded cluster joins new nodes at
+// its current k3s version, never the one it was installed at.
+//
+// Nothing is recorded until every new node is Ready. A failed add leaves the
+// journal and the control-plane record naming the cluster as it was, and
+// re-running the same command is the resume: a node that already joined is
+// recognised by the server's node list, not refused as "existing Kubernetes".
+func AddAgents(ctx context.Context, s *Session, addresses []string) error {
+	if err := checkNewNodes(s.Opts, addresses); err != nil {
+		return err
+	}
+	if s.Jnl == nil || s.Jnl.ClusterID == "" {
+		return fmt.Errorf("the install journal for %s has no registered cluster id: add-node extends a cluster whose install reached stage 2 (register)", s.Opts.Name)
+	}
+
+	peers := s.dialAll(ctx)
+	var server k3s.Runner
+	var serverAddress string
+	for i := range peers {
+		peers[i].ExistingK3sIsOurs = true
+		if server == nil && peers[i].Role == string(RoleServer) && peers[i].Runner != nil {
+			server, serverAddress = peers[i].Runner, peers[i].Address
+		}
+	}
+	if server == nil {
+		return fmt.Errorf("no server of %s could be reached over SSH: a new node joins through the first reachable server, and reads the cluster token from it", s.Opts.Name)
+	}
+	if err := s.adoptServer(ctx, serverAddress, server); err != nil {
+		return err
+	}
+	joined, err := k3s.NodeNames(ctx, server)
+	if err != nil {
+		return fmt.Errorf("reading the cluster's node list from %s: %w", serverAddress, err)
+	}
+
+	var joining []preflight.Node
+	for _, address := range addresses {
+		node := s.dialNode(ctx, address, RoleAgent)
+		// A node already in the server's list joined on an earlier,
+		// failed add-node run; its k3s and volume group are this
+		// command's own work, exactly as on an install resume.
+		_, already := joined[address]
+		node.ExistingK3sIsOurs = already
+		node.StorageIsOurs = already && s.Record.Ownership == storage.InstallerCreated
+		joining = append(joining, node)
+	}
+
+	proxy, err := joiningProxy(s.Opts, addresses, RoleAgent)
+	if err != nil {
+		return err
+	}
+	report, err := preflight.RunJoin(ctx, preflight.Options{
+		Proxy:         proxy,
+		Bundle:        s.Bundle,
+		BundleVersion: s.Bundle.Bundle,
+		HATier:        s.Opts.HATier,
+		StorageDevice: s.Record.Device,
+		Nodes:         joining,
+		Egress:        EgressTargets(s),
+	}, peers)
+	s.publish(report)
+	for _, warning := range report.Warnings() {
+		s.Logf("  warning: %s", warning)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := configureJoiningProxy(ctx, s, proxy, joining); err != nil {
+		return err
+	}
+	if err := inheritRegistries(ctx, s, server, serverAddress, joining); err != nil {
+		return err
+	}
+	// The token is read for immediate use and never stored, as in stage 4.
+	token, err := k3s.NodeToken(ctx, server)
+	if err != nil {
+		return err
+	}
+	joinURL := serverURL(serverAddress)
+	s.Logf("Joining %s to %s.", strings.Join(addresses, ", "), s.Opts.Name)
+	err = eachNode(ctx, s.parallelism(), "joining agents", "k3s-agent", addresses, s.Reporter, func(ctx context.Context, i int) error {
+		node := joining[i]
+		if err := stages.NewComponentError("k3s", k3s.InstallAgent(ctx, node.Runner, s.Bundle, joinURL, token, s.Reporter)); err != nil {
+			return err
+		}
+		// The install's storage path, not a new decision: the device it
+		// recorded, or the customer-created volume group preflight just
+		// verified.
+		if err := storage.EnsureVolumeGroup(ctx, node.Runner, s.Record.Device); err != nil {
+			return fmt.Errorf("volume group: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	total := len(s.Opts.Servers) + len(s.Opts.Agents) + len(addresses)
+	if err := k3s.WaitNodesReady(ctx, server, s.Bundle, total, s.Reporter); err != nil {
+		return err
+	}
+	if err := refreshProxy(ctx, s, server, peers, proxy); err != nil {
+		return err
+	}
+	s.Opts.Agents = append(s.Opts.Agents, addresses...)
+	return s.recordChange(ctx, StageK3sAgents, "add-node joined "+strings.Join(addresses, ", "))
+}
+
+// checkNewNodes refuses an add that names a node twice or one the cluster
+// already has. Joining a server's address as an agent would not fail
+// cleanly: the k3s agent installer replaces the server's systemd unit.
+func checkNewNodes(opts Options, addresses []string) error {
+	if len(addresses) == 0 {
+		return fmt.Errorf("name at least one node to add")
+	}
+	seen := map[string]bool{}
+	for _, a := range addresses {
+		switch {
+		case seen[a]:
+			return fmt.Errorf("%s is named twice", a)
+		case slices.Contains(opts.Servers, a):
+			return fmt.Errorf("%s is already a server of %s", a, opts.Name)
+		case slices.Contains(opts.Agents, a):
+			return fmt.Errorf("%s is already an agent of %s", a, opts.Name)
+		}
+		seen[a] = true
+	}
+	return nil
+}
+
+// recordChange writes the cluster's new node set, its tier and its registry
+// mirrors down in both places that describe it. The local journal's node
+// lists are what upgrade and uninstall read, so a node missing from them is a
+// node neither would ever touch; the control-plane record carries the change
+// as an entry for the stage that joins that kind of node, the vocabulary
+// every reader of the install journal already knows.
+//
+// The journal's identity changes here on purpose. `platform install` against
+// the same cluster now has to name the new node set, which is true: the old
+// command line no longer describes this cluster.
+func (s *Session) recordChange(ctx context.Context, stage, detail string) error {
+	entry, err := s.Jnl.Amend(map[string]string{
+		"HA tier":           s.Opts.HATier,
+		"servers":           stages.List(s.Opts.Servers),
+		"agents":            stages.List(s.Opts.Agents),
+		"--registry-mirror": s.Opts.Identity().Fields["--registry-mirror"],
+	}, Entry{
+		Stage:     stage,
+		Status:    StatusCompleted,
+		Component: "k3s",
+		Detail:    detail,
+		RunID:     s.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.API == nil {
+		return fmt.Errorf("no control plane configured: run `kubenest login` first")
+	}
+	current, err := s.API.BundleRecord(ctx, s.Jnl.ClusterID)
+	if err != nil {
+		return fmt.Errorf("reading the cluster's bundle record: %w", err)
+	}
+	at := entry.At
+	return s.API.PutBundleRecord(ctx, s.Jnl.ClusterID, api.BundleRecord{
+		BundleVersion:        current.BundleVersion,
+		Profiles:             current.Profiles,
+		HATier:               s.Opts.HATier,
+		VolumeGroupOwnership: current.VolumeGroupOwnership,
+		InstallJournal: append(current.InstallJournal, api.InstallJournalEntry{
+			Stage:     entry.Stage,
+			Component: entry.Component,
+			Status:    api.StageCompleted,
+			At:        &at,
+			Detail:    entry.Detail,
+		}),
+	})
+}
+
+// adoptServer makes the server a node change works through the session's
+// node, starts the journal's copy on it and takes the cluster's lease there.
+// A node change dials only what it needs, so it has no stage 1 to fill
+// s.Nodes, and without a server there the change would be recorded on this
+// machine and the control plane but not on the host another workstation
+// recovers from first — and would run alongside an upgrade from that
+// workstation. It is called before the change writes anything.
+func (s *Session) adoptServer(ctx context.Context, address string, runner k3s.Runner) error {
+	s.Nodes = append(s.Nodes, Node{Address: address, Role: RoleServer, Runner: runner})
+	s.replicateToServer()
+	return s.holdLease(ctx)
+}
+
+// OptionsFromJournal rebuilds the install request a journal recorded, for
+// the commands that extend a cluster rather than create it. SSH transport is
+// not part of the identity and is left for the caller to set.
+func OptionsFromJournal(j *Journal) Options {
+	servers, agents := NodesFromJournal(j)
+	return Options{
+		Bundle:           j.Identity.Fields["bundle"],
+		Name:             j.Identity.Cluster,
+		Servers:          servers,
+		Agents:           agents,
+		HATier:           j.Identity.Fields["HA tier"],
+		Profiles:         strings.Fields(j.Identity.Fields["profiles"]),
+		StorageDevice:    j.Identity.Fields["--storage-device"],
+		ReplicatedDevice: j.Identity.Fields["--replicated-device"],
+		// Order-preserving, as Identity wrote it.
+		RegistryMirrors: strings.Fields(j.Identity.Fields["--registry-mirror"]),
+		HTTPProxy:       j.Identity.Fields["--http-proxy"],
+		HTTPSProxy:      j.Identity.Fields["--https-proxy"],
+		NoProxy:         strings.Fields(j.Identity.Fields["--no-proxy"]),
+	}
+}
+
+// RemoveNodeOptions is `platform remove-node`'s request.
+type RemoveNodeOptions struct {
+	Address string
+	// AcknowledgeVolumes accepts stranding individual local volumes, by
+	// PersistentVolume name. As with the upgrade's --acknowledge there is no
+	// blanket form: each volume is somebody's data.
+	AcknowledgeVolumes []string
+}
+
+// RemoveNode takes one node out of the cluster: cordon and drain through the
+// eviction API, the etcd member for a server, the Node object, and then k3s
+// itself on that host with pkg/uninstall's script. Its persistent volumes and
+// its volume group are left on the disk, as uninstall leaves them by default.
+//
+// Every refusal comes before the cordon. A drain that cannot finish, a local
+// volume that would be stranded without the operator saying so, or a server
+// whose loss would take etcd below quorum all stop the command while the
+// cluster is still exactly as it was.
+//
+// Re-running after a failure is safe: a node Kubernetes no longer lists
+// skips straight to the host clean-up and the record.
+func RemoveNode(ctx context.Context, s *Session, opts RemoveNodeOptions) error {
+	if s.Jnl == nil || s.Jnl.ClusterID == "" {
+		return fmt.Errorf("the install journal for %s has no registered cluster id: remove-node changes a cluster whose install reached stage 2 (register)", s.Opts.Name)
+	}
+	role, err := roleOf(s.Opts, opts.Address)
+	if err != nil {
+		return err
+	}
+	if role == RoleServer {
+		if err := checkQuorum(s.Opts, opts.Address); err != nil {
+			return err
+		}
+	}
+
+	var server preflight.Node
+	for _, address := range s.Opts.Servers {
+		if address == opts.Address {
+			continue
+		}
+		if server = s.dialNode(ctx, address, RoleServer); server.Runner != nil {
+			break
+		}
+	}
+	if server.Runner == nil {
+		return fmt.Errorf("no other server of %s could be reached over SSH: the drain and the Node deletion run through a server that stays", s.Opts.Name)
+	}
+	if err := s.adoptServer(ctx, server.Address, server.Runner); err != nil {
+		return err
+	}
+	target := s.dialNode(ctx, opts.Address, role)
+	if target.Runner == nil {
+		return fmt.Errorf("%s could not be reached over SSH (%v): remove-node runs the k3s uninstall script on the host itself, so it must be reachable", opts.Address, target.DialErr)
+	}
+
+	names, err := k3s.NodeNames(ctx, server.Runner)
+	if err != nil {
+		return fmt.Errorf("reading the cluster's node list from %s: %w", server.Address, err)
+	}
+	if name, listed := names[opts.Address]; listed {
+		if role == RoleServer {
+			ready, err := k3s.ReadyNodes(ctx, server.Runner)
+			if err != nil {
+				return fmt.Errorf("reading the cluster's node list from %s: %w", server.Address, err)
+			}
+			warning, err := checkMembers(s.Opts, opts.Address, ready)
+			if err != nil {
+				return err
+			}
+			if warning != "" {
+				s.Logf("  warning: %s", warning)
+			}
+		}
+		if err := checkRemovable(ctx, server.Runner, name, opts.AcknowledgeVolumes); err != nil {
+			return err
+		}
+		s.Logf("Draining %s (node %s).", opts.Address, name)
+		if err := k3s.Drain(ctx, server.Runner, s.Bundle, name); err != nil {
+			return err
+		}
+		if role == RoleServer {
+			s.Logf("Removing %s from etcd.", name)
+			if err := k3s.RemoveEtcdMember(ctx, server.Runner, s.Bundle, name, s.Reporter); err != nil {
+				return err
+			}
+		}
+		if err := k3s.DeleteNode(ctx, server.Runner, name); err != nil {
+			return err
+		}
+	} else {
+		s.Logf("Kubernetes no longer lists %s: an earlier run already removed it, so only the host is left to clean.", opts.Address)
+	}
+
+	if err := uninstall.Run(ctx, uninstall.Options{
+		Nodes: []uninstall.Node{{Address: opts.Address, Role: uninstall.Role(role), Runner: target.Runner}},
+		Out:   s.Out,
+	}); err != nil {
+		return err
+	}
+
+	stage := StageK3sAgents
+	if role == RoleServer {
+		stage = StageK3sServer
+		s.Opts.Servers = slices.DeleteFunc(s.Opts.Servers, func(a string) bool { return a == opts.Address })
+	} else {
+		s.Opts.Agents = slices.DeleteFunc(s.Opts.Agents, func(a string) bool { return a == opts.Address })
+	}
+	return s.recordChange(ctx, stage, "remove-node removed "+opts.Address)
+}
+
+func roleOf(opts Options, address string) (NodeRole, error) {
+	switch {
+	case slices.Contains(opts.Servers, address):
+		return RoleServer, nil
+	case slices.Contains(opts.Agents, address):
+		return RoleAgent, nil
+	}
+	return "", fmt.Errorf("%s is not a node of %s: its servers are %s and its agents %s",
+		address, opts.Name, strings.Join(opts.Servers, ", "), orNone(opts.Agents))
+}
+
+// checkQuorum refuses the server removal no cluster survives: a
+// single-server cluster's server IS the cluster, and taking it away is
+// uninstall. Whether the others keep quorum depends on which of them are up,
+// which is checkMembers' question, asked of the cluster.
+func checkQuorum(opts Options, address string) error {
+	if len(opts.Servers) == 1 {
+		return fmt.Errorf("%s is the only server of %s: removing it is `kubenest platform uninstall`, not remove-node", address, opts.Name)
+	}
+	return nil
+}
+
+// checkMembers refuses a server removal that would cost etcd its quorum: the
+// members that stay must have a majority of themselves Ready. Removing a
+// FAILED member of three leaves two that are both up, which is exactly how a
+// failed server is replaced; removing a healthy one while another is down
+// leaves one of two, and the cluster stops. ready is k3s.ReadyNodes.
+//
+// A removal that keeps quorum but leaves the ha tier below three members is
+// not refused: it is the first half of a replacement. The warning it returns
+// says what the cluster can no longer survive.
+func checkMembers(opts Options, address string, ready map[string]bool) (warning string, err error) {
+	remaining, up := 0, 0
+	var down []string
+	for _, a := range opts.Servers {
+		if a == address {
+			continue
+		}
+		remaining++
+		if ready[a] {
+			up++
+		} else {
+			down = append(down, a)
+		}
+	}
+	if up*2 <= remaining {
+		return "", fmt.Errorf("removing %s would leave %s with %d etcd member(s), only %d of them Ready (not Ready: %s): etcd needs a majority up, so the cluster would stop. Bring the others back first",
+			address, opts.Name, remaining, up, strings.Join(down, ", "))
+	}
+	if opts.HATier == "ha" && remaining < 3 {
+		return fmt.Sprintf("%s will have %d etcd members: they keep quorum only while every one of them is up, so it survives no further server failure until a replacement joins with `kubenest platform add-node --cluster %s --server <address>`",
+			opts.Name, remaining, opts.Name), nil
+	}
+	return "", nil
+}
+
+// checkRemovable is the two refusals that need the cluster: a budget no
+// drain can get past, and local volumes the operator has not agreed to
+// strand.
+func checkRemovable(ctx context.Context, server k3s.Runner, node string, acknowledged []string) error {
+	var problems []string
+	blocking, _, err := k3s.BlockingDisruptionBudgets(ctx, server)
+	if err != nil {
+		return fmt.Errorf("reading pod disruption budgets: %w", err)
+	}
+	if len(blocking) > 0 {
+		problems = append(problems, "a drain could never finish: "+strings.Join(blocking, "; ")+
+			"\n    fix: relax the budget or add a replica — remove-node never force-deletes a pod")
+	}
+
+	volumes, err := storage.VolumesOn(ctx, server, node)
+	if err != nil {
+		return fmt.Errorf("listing local volumes on %s: %w", node, err)
+	}
+	var stranded []string
+	for _, v := range volumes {
+		if !slices.Contains(acknowledged, v.Name) {
+			stranded = append(stranded, v.String())
+		}
+	}
+	if len(stranded) > 0 {
+		problems = append(problems, fmt.Sprintf("%d local volume(s) live only on this node and would be stranded: %s", len(stranded), strings.Join(stranded, ", "))+
+			"\n    fix: move that data off the node first (back it up and restore it elsewhere), or accept losing each volume with --acknowledge-volume <name>")
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("remove-node refused %s before changing anything:\n  %s", node, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func orNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
+
+// joinServers joins addresses to the cluster's embedded etcd, one at a time,
+// through the first server that answers: preflight for the new hosts and the
+// ports between them and the nodes already there, the proxy and the
+// registries the cluster already uses, k3s.InstallServer, the volume group,
+// every node Ready, and the new addresses in the existing nodes' NO_PROXY
+// (refreshProxy). New members get the datastore snapshot schedule the
+// existing server has, because a member without it is a member whose loss
+// the backups do not cover. It records nothing; the caller does.
+func (s *Session) joinServers(ctx context.Context, addresses []string, backupTarget string) error {
+	peers := s.dialAll(ctx)
+	// Through the first server that answers: a server being replaced may be
+	// the one that does not.
+	var first preflight.Node
+	for _, p := range peers {
+		if p.Role == string(RoleServer) && p.Runner != nil {
+			first = p
+			break
+		}
+	}
+	if first.Runner == nil {
+		return fmt.Errorf("no server of %s could be reached over SSH (%v): new servers join through one and read the cluster token from it", s.Opts.Name, peers[0].DialErr)
+	}
+	if err := s.adoptServer(ctx, first.Address, first.Runner); err != nil {
+		return err
+	}
+	for i := range peers {
+		peers[i].ExistingK3sIsOurs = true
+	}
+
+	// Decided before anything is written: a join that could not finish
+	// configuring snapshots would leave unprotected members behind.
+	var target *backup.Target
+	snapshots, err := backup.DatastoreSnapshotsConfigured(ctx, first.Runner)
+	if err != nil {
+		return fmt.Errorf("on %s: %w", first.Address, err)
+	}
+	switch {
+	case snapshots && backupTarget == "":
+		return fmt.Errorf("%s takes datastore snapshots, and the new members must too: pass --backup-target with the same s3:// target, with its credentials in KUBENEST_BACKUP_ACCESS_KEY_ID and KUBENEST_BACKUP_SECRET_ACCESS_KEY", first.Address)
+	case backupTarget != "":
+		t, err := parseBackupTarget(backupTarget)
+		if err != nil {
+			return err
+		}
+		target = &t
+	}
+
+	joined, err := k3s.NodeNames(ctx, first.Runner)
+	if err != nil {
+		return fmt.Errorf("reading the cluster's node list from %s: %w", first.Address, err)
+	}
+	// A member joins as one more vote etcd needs before it can vote itself:
+	// with a server down, three members become four that need three, and
+	// only two are answering until the new one is. A failed server is
+	// removed first, and its replacement joins a cluster that is whole.
+	ready, err := k3s.ReadyNodes(ctx, first.Runner)
+	if err != nil {
+		return fmt.Errorf("reading the cluster's node list from %s: %w", first.Address, err)
+	}
+	var down []string
+	for _, address := range s.Opts.Servers {
+		if !ready[address] {
+			down = append(down, address)
+		}
+	}
+	if len(down) > 0 {
+		return fmt.Errorf("server(s) %s of %s are not Ready, and an etcd member joining while another is down can cost the cluster its quorum: `kubenest platform remove-node` the failed server first, then add its replacement", strings.Join(down, ", "), s.Opts.Name)
+	}
+	// The wait below counts the nodes that are Ready now, not every node the
+	// journal lists: an agent that is down will not be Ready by the end of
+	// it either.
+	want := len(addresses)
+	for _, address := range append(slices.Clone(s.Opts.Servers), s.Opts.Agents...) {
+		if ready[address] {
+			want++
+		}
+	}
+	var joining []preflight.Node
+	for _, address := range addresses {
+		node := s.dialNode(ctx, address, RoleServer)
+		_, already := joined[address]
+		node.ExistingK3sIsOurs = already
+		node.StorageIsOurs = already && s.Record.Ownership == storage.InstallerCreated
+		joining = append(joining, node)
+	}
+	proxy, err := joiningProxy(s.Opts, addresses, RoleServer)
+	if err != nil {
+		return err
+	}
+	report, err := preflight.RunJoin(ctx, preflight.Options{
+		Proxy:         proxy,
+		Bundle:        s.Bundle,
+		BundleVersion: s.Bundle.Bundle,
+		HATier:        "ha",
+		StorageDevice: s.Record.Device,
+		Nodes:         joining,
+		Egress:        EgressTargets(s),
+	}, peers)
+	s.publish(report)
+	for _, warning := range report.Warnings() {
+		s.Logf("  warning: %s", warning)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := configureJoiningProxy(ctx, s, proxy, joining); err != nil {
+		return err
+	}
+	if err := inheritRegistries(ctx, s, first.Runner, first.Address, joining); err != nil {
+		return err
+	}
+	// The token is read for immediate use and never stored, as in stage 3.
+	token, err := k3s.NodeToken(ctx, first.Runner)
+	if err != nil {
+		return err
+	}
+	joinURL := serverURL(first.Address)
+	for _, node := range joining {
+		s.Logf("Joining %s to the etcd cluster on %s.", node.Address, first.Address)
+		if err := stages.NewComponentError("k3s", k3s.InstallServer(ctx, node.Runner, s.Bundle,
+			k3s.ServerOptions{JoinURL: joinURL, Token: token}, s.Reporter)); err != nil {
+			return fmt.Errorf("joining %s to the etcd cluster: %w", node.Address, err)
+		}
+		if err := storage.EnsureVolumeGroup(ctx, node.Runner, s.Record.Device); err != nil {
+			return fmt.Errorf("volume group on %s: %w", node.Address, err)
+		}
+	}
+	if err := k3s.WaitNodesReady(ctx, first.Runner, s.Bundle, want, s.Reporter); err != nil {
+		return err
+	}
+	if err := refreshProxy(ctx, s, first.Runner, peers, proxy); err != nil {
+		return err
+	}
+
+	if target != nil {
+		// Serially, as stage 8 does: each restart is proven Ready and each
+		// member proves one upload before the next is touched.
+		for _, node := range joining {
+			if err := backup.ConfigureDatastoreSnapshots(ctx, node.Runner, s.Bundle, *target, s.Reporter); err != nil {
+				return stages.NewComponentError("k3s", fmt.Errorf("datastore snapshots on %s: %w", node.Address, err))
+			}
+		}
+	} else {
+		s.Logf("  no datastore snapshots are configured on %s, so none are configured on the new members; `kubenest backup set-target` with every --server address configures every member", first.Address)
+	}
+	return nil
+}
+
+// AddServers joins etcd members to an ha cluster (`kubenest platform add-node
+// --server`). It is the second half of replacing a server: remove-node takes
+// the failed one out, leaving two members that keep quorum but survive no
+// further failure, and this joins its replacement to make three again. The
+// join is PromoteHA's, against a cluster that is already ha, and as with
+// AddAgents nothing is recorded until every new member is Ready, so running
+// the same command again resumes it.
+func AddServers(ctx context.Context, s *Session, opts PromoteOptions) error {
+	if err := checkNewNodes(s.Opts, opts.Servers); err != nil {
+		return err
+	}
+	if s.Jnl == nil || s.Jnl.ClusterID == "" {
+		return fmt.Errorf("the install journal for %s has no registered cluster id: add-node extends a cluster whose install reached stage 2 (register)", s.Opts.Name)
+	}
+	if s.Opts.HATier != "ha" {
+		return fmt.Errorf("%s is on the %s tier, whose one server is the cluster: `kubenest platform promote-ha` joins the two that make it ha", s.Opts.Name, s.Opts.HATier)
+	}
+	if err := s.joinServers(ctx, opts.Servers, opts.BackupTarget); err != nil {
+		return err
+	}
+	s.Opts.Servers = append(s.Opts.Servers, opts.Servers...)
+	return s.recordChange(ctx, StageK3sServer, "add-node joined server(s) "+strings.Join(opts.Servers, ", "))
+}
+
+// PromoteOptions is `platform promote-ha`'s request.
+type PromoteOptions struct {
+	// Servers are the two hosts that join the existing server as etcd
+	// members.
+	Servers []string
+	// BackupTarget is --backup-target's s3:// coordinates. It is required
+	// only when the existing server already takes datastore snapshots, and
+	// its credentials come from the environment exactly as at install.
+	BackupTarget string
+}
+
+// PromoteHA moves a single-server cluster to the ha tier by joining two more
+// servers to its embedded etcd. That this is a join and not a rebuild is the
+// point of decision A: every tier's first server runs with --cluster-init,
+// so a single-server cluster is already a one-member etcd cluster waiting
+// for peers (see k3s.ServerFlags).
+//
+// The order follows what can be undone. Preflight and the snapshot question
+// come first and change nothing. Each new server then joins and the cluster
+// must report every node Ready; the new members get the datastore snapshot
+// schedule the first server already has, because an ha cluster with one
+// protected member is not protected; and only then does the tier change, in
+// the journal and in the control-plane record together.
+//
+// There is no way back: an ha cluster stays ha. A member that fails is
+// replaced with AddServers, not by promoting again.
+func PromoteHA(ctx context.Context, s *Session, opts PromoteOptions) error {
+	if s.Jnl == nil || s.Jnl.ClusterID == "" {
+		return fmt.Errorf("the install journal for %s has no registered cluster id: promote-ha changes a cluster whose install reached stage 2 (register)", s.Opts.Name)
+	}
+	if s.Opts.HATier != "single-server" {
+		return fmt.Errorf("%s is on the %s tier: only a single-server cluster can be promoted; `kubenest platform add-node --server` joins a server to an ha cluster", s.Opts.Name, s.Opts.HATier)
+	}
+	if len(s.Opts.Servers) != 1 {
+		return fmt.Errorf("the journal lists %d servers for single-server cluster %s: refusing to promote a cluster whose record does not match its tier", len(s.Opts.Servers), s.Opts.Name)
+	}
+	if len(opts.Servers) != 2 {
+		return fmt.Errorf("the ha tier is three servers: name exactly two new --server hosts to join %s, got %d", s.Opts.Servers[0], len(opts.Servers))
+	}
+	if err := checkNewNodes(s.Opts, opts.Servers); err != nil {
+		return err
+	}
+	if err := s.Bundle.OffersTier("ha"); err != nil {
+		return err
+	}
+
+	if err := s.joinServers(ctx, opts.Servers, opts.BackupTarget); err != nil {
+		return err
+	}
+
+	s.Opts.Servers = append(s.Opts.Servers, opts.Servers...)
+	s.Opts.HATier = "ha"
+	return s.recordChange(ctx, StageK3sServer, "promote-ha joined "+strings.Join(opts.Servers, ", ")+"; tier is now ha")
+}
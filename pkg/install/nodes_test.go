@@ -0,0 +1,131 @@
+package install_test
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kubenest.io/cli/pkg/install"
+	"kubenest.io/cli/pkg/manifest"
+)
+
+func nodeSession(t *testing.T, opts install.Options) *install.Session {
+	t.Helper()
+	j, err := install.OpenJournal(filepath.Join(t.TempDir(), "journal.json"), opts.Identity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.ClusterID = "cl-1"
+	return &install.Session{ID: "run-1", Opts: opts, Jnl: j, Out: io.Discard}
+}
+
+// The refusals that need nothing but the journal come before any node is
+// dialled: the cluster must be exactly as it was when one fires.
+func TestRemoveNodeRefusesWhatTheTierCannotSurvive(t *testing.T) {
+	ha := install.Options{Name: "prod-1", HATier: "ha",
+		Servers: []string{"10.0.1.10", "10.0.1.11", "10.0.1.12"}, Agents: []string{"10.0.1.20"}}
+	single := install.Options{Name: "dev-1", HATier: "single-server", Servers: []string{"10.0.1.10"}}
+	for _, c := range []struct {
+		name    string
+		opts    install.Options
+		address string
+		want    string
+	}{
+		{"the only server", single, "10.0.1.10", "platform uninstall"},
+		{"not a node", ha, "10.0.1.99", "not a node of prod-1"},
+	} {
+		s := nodeSession(t, c.opts)
+		err := install.RemoveNode(context.Background(), s, install.RemoveNodeOptions{Address: c.address})
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: want a refusal containing %q, got %v", c.name, c.want, err)
+		}
+		if len(s.Jnl.Entries) != 0 {
+			t.Errorf("%s: a refusal wrote to the journal", c.name)
+		}
+	}
+}
+
+// A server joins only an ha cluster: a single-server cluster's second and
+// third servers are promote-ha, which changes the tier too.
+func TestAddServersRefusesWhatIsNotAnHACluster(t *testing.T) {
+	single := nodeSession(t, install.Options{Name: "dev-1", HATier: "single-server", Servers: []string{"10.0.1.10"}})
+	ha := nodeSession(t, install.Options{Name: "prod-1", HATier: "ha", Servers: []string{"10.0.1.10", "10.0.1.11"}})
+	for _, c := range []struct {
+		name    string
+		s       *install.Session
+		servers []string
+		want    string
+	}{
+		{"single-server", single, []string{"10.0.1.11"}, "promote-ha"},
+		{"an existing server", ha, []string{"10.0.1.11"}, "already a server"},
+		{"none", ha, nil, "at least one node"},
+	} {
+		err := install.AddServers(context.Background(), c.s, install.PromoteOptions{Servers: c.servers})
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: want a refusal containing %q, got %v", c.name, c.want, err)
+		}
+		if len(c.s.Jnl.Entries) != 0 {
+			t.Errorf("%s: a refusal wrote to the journal", c.name)
+		}
+	}
+}
+
+func TestAddNodeRefusesAnExistingNode(t *testing.T) {
+	s := nodeSession(t, install.Options{Name: "prod-1", HATier: "single-server",
+		Servers: []string{"10.0.1.10"}, Agents: []string{"10.0.1.20"}})
+	for address, want := range map[string]string{
+		"10.0.1.10": "already a server",
+		"10.0.1.20": "already an agent",
+	} {
+		err := install.AddAgents(context.Background(), s, []string{address})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: want %q, got %v", address, want, err)
+		}
+	}
+}
+
+// A journal read back rebuilds the request it recorded, which is what a node
+// change extends.
+func TestOptionsFromJournalRoundTrips(t *testing.T) {
+	opts := install.Options{Bundle: "1.0", Name: "prod-1", HATier: "ha",
+		Servers: []string{"10.0.1.10", "10.0.1.11", "10.0.1.12"}, Agents: []string{"10.0.1.20"},
+		Profiles: []string{"observability"}, StorageDevice: "/dev/nvme1n1",
+		RegistryMirrors: []string{"docker.io=https://b.example.com", "docker.io=https://a.example.com"},
+		HTTPSProxy:      "http://proxy.example.com:3128", NoProxy: []string{"10.0.1.0/24", ".corp.example.com"}}
+	s := nodeSession(t, opts)
+	if diffs := install.OptionsFromJournal(s.Jnl).Identity().Differences(opts.Identity()); len(diffs) != 0 {
+		t.Errorf("the rebuilt request differs: %v", diffs)
+	}
+}
+
+func TestPromoteHARefusesWhatIsNotASingleServerToThree(t *testing.T) {
+	single := install.Options{Name: "dev-1", HATier: "single-server", Servers: []string{"10.0.1.10"}}
+	ha := install.Options{Name: "prod-1", HATier: "ha", Servers: []string{"10.0.1.10", "10.0.1.11", "10.0.1.12"}}
+	noHA, err := manifest.Parse([]byte("bundle: \"1.0\"\nha-tiers: [single-server]\nlimits:\n  timeouts:\n    node-ready: 5m\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name    string
+		opts    install.Options
+		servers []string
+		want    string
+	}{
+		{"already ha", ha, []string{"10.0.1.13", "10.0.1.14"}, "only a single-server cluster"},
+		{"one new server", single, []string{"10.0.1.11"}, "exactly two"},
+		{"the existing server again", single, []string{"10.0.1.10", "10.0.1.11"}, "already a server"},
+		{"a bundle without ha", single, []string{"10.0.1.11", "10.0.1.12"}, `does not offer the "ha" tier`},
+	} {
+		s := nodeSession(t, c.opts)
+		s.Bundle = noHA
+		err := install.PromoteHA(context.Background(), s, install.PromoteOptions{Servers: c.servers})
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: want a refusal containing %q, got %v", c.name, c.want, err)
+		}
+		if s.Opts.HATier != c.opts.HATier {
+			t.Errorf("%s: a refusal changed the tier", c.name)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"kubenest.io/cli/pkg/converge"
+)
+
+// DefaultParallelism is how many nodes are dialled or joined at once when
+// --parallel is not given. Each join is an SSH session running the k3s
+// installer, which downloads a ~70 MB binary: ten at once keeps a 20-agent
+// fleet inside the install budget without every node pulling through one
+// site uplink at the same moment.
+const DefaultParallelism = 10
+
+// parallelism is the session's bound on concurrent per-node work.
+func (s *Session) parallelism() int {
+	if s.Opts.Parallelism > 0 {
+		return s.Opts.Parallelism
+	}
+	return DefaultParallelism
+}
+
+// NodeErrors is every node a concurrent step failed on, in the order the
+// nodes were given. One stage error names them all: an operator who fixes
+// the first agent, re-runs and finds the second has been failed by the
+// installer, for the reason preflight runs every check.
+type NodeErrors struct {
+	// Step is what was being done, e.g. "joining agents".
+	Step string
+	// Total is how many nodes the step ran on.
+	Total  int
+	Failed []NodeError
+}
+
+// NodeError is one node's failure.
+type NodeError struct {
+	Address string
+	Err     error
+}
+
+func (e *NodeErrors) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d of %d node(s) failed", e.Step, len(e.Failed), e.Total)
+	for _, f := range e.Failed {
+		fmt.Fprintf(&b, "\n  %s: %v", f.Address, f.Err)
+	}
+	return b.String()
+}
+
+// Unwrap exposes every node's error, so a ComponentError inside one still
+// names the component the stage failed in.
+func (e *NodeErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, f := range e.Failed {
+		errs = append(errs, f.Err)
+	}
+	return errs
+}
+
+// eachNode runs fn for every address, at most limit at once, and waits for
+// all of them: a failure does not cancel the nodes already running, because
+// a k3s installer killed part-way leaves a node worse than one left to
+// finish. Each node's start and verdict go to rep under check, so a 20-agent
+// join is visibly 20 pieces of progress rather than one long silence.
+func eachNode(ctx context.Context, limit int, step, check string, addresses []string, rep converge.Reporter, fn func(ctx context.Context, i int) error) error {
+	if limit < 1 {
+		limit = 1
+	}
+	errs := make([]error, len(addresses))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, address := range addresses {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			name := check + " " + address
+			started := time.Now()
+			report(rep, converge.Event{Check: name, Outcome: converge.Converging, State: converge.State{Object: address, Status: "started"}})
+			errs[i] = fn(ctx, i)
+			event := converge.Event{Check: name, Outcome: converge.Pass, Elapsed: time.Since(started)}
+			if errs[i] != nil {
+				event.Outcome, event.State = converge.Fail, converge.State{Object: address, Detail: errs[i].Error()}
+			}
+			report(rep, event)
+		}()
+	}
+	wg.Wait()
+
+	failed := &NodeErrors{Step: step, Total: len(addresses)}
+	for i, err := range errs {
+		if err != nil {
+			failed.Failed = append(failed.Failed, NodeError{Address: addresses[i], Err: err})
+		}
+	}
+	if len(failed.Failed) == 0 {
+		return nil
+	}
+	return failed
+}
+
+func report(rep converge.Reporter, e converge.Event) {
+	if rep != nil {
+		rep.Report(e)
+	}
+}
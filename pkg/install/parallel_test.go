@@ -0,0 +1,108 @@
+package install_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"kubenest.io/cli/pkg/component/componenttest"
+	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/install"
+	"kubenest.io/cli/pkg/manifest"
+	"kubenest.io/cli/pkg/sshx"
+)
+
+// Agents join --parallel at a time, never more; a failed agent does not stop
+// the others, and the stage's one error names every agent that failed. Each
+// agent's start and verdict reach the reporter.
+func TestAgentsJoinInParallelAndEveryFailureIsNamed(t *testing.T) {
+	m, err := manifest.Parse([]byte("bundle: \"1.0\"\ncore:\n  k3s: v1.35.7+k3s1\nlimits:\n  timeouts:\n    node-ready: 2s\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inFlight, most atomic.Int32
+	agent := func(fails bool) *componenttest.FakeRunner {
+		return &componenttest.FakeRunner{Respond: func(cmd string) (sshx.Result, error) {
+			if !strings.Contains(cmd, "get.k3s.io") {
+				return sshx.Result{}, nil
+			}
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				m := most.Load()
+				if n <= m || most.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			if fails {
+				return sshx.Result{ExitCode: 1, Stderr: "curl: (6) Could not resolve host: get.k3s.io"}, nil
+			}
+			return sshx.Result{}, nil
+		}}
+	}
+	server := &componenttest.FakeRunner{Respond: func(cmd string) (sshx.Result, error) {
+		if strings.Contains(cmd, "node-token") {
+			return sshx.Result{Stdout: "K10secret\n"}, nil
+		}
+		return sshx.Result{}, nil
+	}}
+
+	opts := install.Options{Bundle: "1.0", Name: "prod-1", HATier: "single-server", Servers: []string{"10.0.1.10"}, Parallelism: 2}
+	nodes := []install.Node{{Address: "10.0.1.10", Role: install.RoleServer, Runner: server}}
+	agents := map[string]*componenttest.FakeRunner{}
+	for i, address := range []string{"10.0.1.20", "10.0.1.21", "10.0.1.22", "10.0.1.23", "10.0.1.24"} {
+		agents[address] = agent(i == 1 || i == 3)
+		opts.Agents = append(opts.Agents, address)
+		nodes = append(nodes, install.Node{Address: address, Role: install.RoleAgent, Runner: agents[address]})
+	}
+	var mu sync.Mutex
+	verdicts := map[string]converge.Outcome{}
+	rep := converge.ReporterFunc(func(e converge.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if e.Outcome != converge.Converging {
+			verdicts[e.Check] = e.Outcome
+		}
+	})
+	s := &install.Session{ID: "run-1", Opts: opts, Bundle: m, Nodes: nodes, Reporter: rep, Out: io.Discard}
+
+	var join install.Stage
+	for _, stage := range install.Plan(s) {
+		if stage.Name == install.StageK3sAgents {
+			join = stage
+		}
+	}
+	err = join.Run(context.Background())
+
+	var failed *install.NodeErrors
+	if !errors.As(err, &failed) {
+		t.Fatalf("want *NodeErrors, got %v", err)
+	}
+	if len(failed.Failed) != 2 || failed.Failed[0].Address != "10.0.1.21" || failed.Failed[1].Address != "10.0.1.23" {
+		t.Errorf("want both failed agents named in order, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "2 of 5") {
+		t.Errorf("the error must say how many failed of how many: %v", err)
+	}
+	if got := most.Load(); got != 2 {
+		t.Errorf("at most --parallel 2 agents may install at once, and 2 should have; saw %d", got)
+	}
+	for address, r := range agents {
+		ran := false
+		for _, cmd := range r.Commands() {
+			ran = ran || strings.Contains(cmd, "get.k3s.io")
+		}
+		if !ran {
+			t.Errorf("%s never ran the installer: one agent's failure stopped another's join", address)
+		}
+	}
+	if verdicts["k3s-agent 10.0.1.20"] != converge.Pass || verdicts["k3s-agent 10.0.1.21"] != converge.Fail {
+		t.Errorf("every agent's verdict must be reported, got %v", verdicts)
+	}
+}
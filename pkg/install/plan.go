@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"kubenest.io/cli/pkg/api"
@@ -16,6 +17,8 @@ import (
 	"kubenest.io/cli/pkg/component/gatewayapi"
 	"kubenest.io/cli/pkg/component/traefik"
 	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/deprecation"
+	"kubenest.io/cli/pkg/diagnose"
 	"kubenest.io/cli/pkg/k3s"
 	"kubenest.io/cli/pkg/manifest"
 	"kubenest.io/cli/pkg/preflight"
@@ -37,7 +40,27 @@ type Options struct {
 	SSHUser       string
 	SSHKey        string
 	StorageDevice string
-	BackupTarget  string
+	// ReplicatedDevice is the blank device every node gives the
+	// replicated-storage profile's pools.
+	ReplicatedDevice string
+	BackupTarget     string
+	// RegistryMirrors are --registry-mirror's REGISTRY=URL values, in the
+	// order they are tried.
+	RegistryMirrors []string
+	// RegistryCredentials is the path of a per-host credentials file. The
+	// path is not a secret; what it holds is, and is read only when a node's
+	// registries.yaml is written.
+	RegistryCredentials string
+	// HTTPProxy and HTTPSProxy are proxy URLs without credentials, which come
+	// from the environment. NoProxy is the operator's own additions; the
+	// cluster's CIDRs and node addresses are added to it by parseProxy.
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    []string
+	// Parallelism bounds how many nodes are dialled or joined at once; zero
+	// means DefaultParallelism. It is how the install runs, not what it
+	// installs, so it is not part of the identity: a resume may change it.
+	Parallelism int
 }
 
 // Identity is the part of the request a resume must match exactly.
@@ -46,12 +69,19 @@ func (o Options) Identity() Identity {
 		Kind:    Kind,
 		Cluster: o.Name,
 		Fields: map[string]string{
-			"bundle":           o.Bundle,
-			"HA tier":          o.HATier,
-			"--storage-device": o.StorageDevice,
-			"servers":          stages.List(o.Servers),
-			"agents":           stages.List(o.Agents),
-			"profiles":         stages.List(o.Profiles),
+			"bundle":              o.Bundle,
+			"HA tier":             o.HATier,
+			"--storage-device":    o.StorageDevice,
+			"--replicated-device": o.ReplicatedDevice,
+			// In the operator's order, not sorted: the order is the order
+			// containerd tries them.
+			"--registry-mirror": strings.Join(o.RegistryMirrors, " "),
+			"--http-proxy":      o.HTTPProxy,
+			"--https-proxy":     o.HTTPSProxy,
+			"--no-proxy":        stages.List(o.NoProxy),
+			"servers":           stages.List(o.Servers),
+			"agents":            stages.List(o.Agents),
+			"profiles":          stages.List(o.Profiles),
 		},
 	}
 }
@@ -88,6 +118,10 @@ type Record struct {
 	Adopted      bool              `json:"adopted,omitempty"`
 	Device       string            `json:"storage_device,omitempty"`
 	Ownership    storage.Ownership `json:"volume_group_ownership,omitempty"`
+	// ReplicatedDevice is recorded before the replicated-storage pools are
+	// created on it, so a resumed preflight knows a non-blank device may be
+	// this install's own work.
+	ReplicatedDevice string `json:"replicated_device,omitempty"`
 }
 
 // Session is one install run's state.
@@ -101,6 +135,9 @@ type Session struct {
 	Jnl      *Journal
 	Emit     Emitter
 	Reporter converge.Reporter
+	// Findings receives every preflight result, not only the warnings
+	// and failures the text prints. Nil drops them.
+	Findings Findings
 	Out      io.Writer
 	// API is the control plane. Stages 2, 12 and 13 need it; nothing else
 	// does, and no stage may hold a credential in it beyond the CLI token
@@ -115,8 +152,19 @@ type Session struct {
 	Creds Credentials
 	// Record is the journalled non-secret record.
 	Record Record
-
+	// Lock is this run's hold on the journal, from before it was opened.
+	// The session adds the cluster's lease to it at stage 3, when there is
+	// a cluster to hold one on, and Close releases both. Nil runs unlocked
+	// (tests, --plan).
+	Lock *stages.Lock
+
+	// mu guards closers and Out against the per-node goroutines of a
+	// parallel dial or join.
+	mu      sync.Mutex
 	closers []io.Closer
+	// onServer is set once the journal is being copied to the primary
+	// server.
+	onServer bool
 }
 
 // The engine's Controller, implemented by this session.
@@ -146,17 +194,74 @@ func (s *Session) Logf(format string, args ...any) {
 	if s.Out == nil {
 		return
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	fmt.Fprintf(s.Out, format+"\n", args...)
 }
 
-// Close releases every connection stage 1 opened. Safe to call twice.
+// Close waits for the journal's copies to land, then releases the lock and
+// every connection stage 1 opened — in that order, because the copies are
+// written and the lease released over those connections. Safe to call twice.
 func (s *Session) Close() {
+	if s.Jnl != nil {
+		s.Jnl.Flush()
+	}
+	if s.Lock != nil {
+		s.Lock.Release()
+		s.Lock = nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	for _, c := range s.closers {
 		_ = c.Close()
 	}
 	s.closers = nil
 }
 
+// replicateToServer copies the journal to the primary server from the next
+// transition on, so another workstation can resume or uninstall this one's
+// install with the volume-group ownership it recorded. It starts no earlier
+// than stage 3: nothing is written to any machine before then, the journal
+// included.
+func (s *Session) replicateToServer() {
+	if s.onServer || s.Jnl == nil {
+		return
+	}
+	for _, n := range s.Nodes {
+		if n.Role == RoleServer {
+			s.Jnl.Replicate(stages.ServerReplica{Runner: n.Runner, Address: n.Address, Kind: Kind}, s.Logf)
+			s.onServer = true
+			return
+		}
+	}
+}
+
+// holdLease takes the cluster's operation lease on the primary server, so a
+// run of this install or an upgrade from another machine is refused while
+// this one runs. Before stage 3 there is no cluster to hold it on; until
+// then only the journal's lock on this machine applies.
+func (s *Session) holdLease(ctx context.Context) error {
+	if s.Lock == nil {
+		return nil
+	}
+	for _, n := range s.Nodes {
+		if n.Role == RoleServer {
+			return s.Lock.Hold(ctx, stages.KubeLease{Runner: n.Runner})
+		}
+	}
+	return nil
+}
+
+// publish hands every result of a preflight run to Findings.
+func (s *Session) publish(report preflight.Report) {
+	if s.Findings == nil {
+		return
+	}
+	for _, r := range report.Results {
+		s.Findings.Finding(stages.FindingPreflight, r)
+	}
+}
+
 // saveRecord persists the non-secret record to the journal.
 func (s *Session) saveRecord() error { return s.Jnl.SetState(s.Record) }
 
@@ -192,6 +297,28 @@ func (s *Session) Server() (k3s.Runner, error) {
 	return nil, errors.New("no server node connection: stage 1 (preflight) opens these, so this is an engine bug, not a host problem")
 }
 
+// Diagnose captures the evidence for a check that failed at stage, from the
+// server node, into a directory beside the journal.
+func (s *Session) Diagnose(ctx context.Context, stage string, state converge.State) (string, error) {
+	server, err := s.Server()
+	if err != nil {
+		return "", err
+	}
+	dir := diagnose.Dir(s.Jnl.Path(), s.ID, stage)
+	return dir, diagnose.Capture(ctx, server, state, dir)
+}
+
+// NodeRunner returns the connection to the node at address, for a hook that
+// runs there.
+func (s *Session) NodeRunner(address string) (stages.Runner, error) {
+	for _, n := range s.Nodes {
+		if n.Address == address {
+			return n.Runner, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a node this install is connected to: a hook runs on a --server or --agent address, and not before preflight, which opens the connections", address)
+}
+
 // NodesWithRole returns every node of one role, in the order given on the
 // command line.
 func (s *Session) NodesWithRole(role NodeRole) []Node {
@@ -210,23 +337,49 @@ func (s *Session) NodesWithRole(role NodeRole) []Node {
 // file owns which function each stage calls. They are separate so the
 // sequencing is readable without the plumbing, and so a test can exercise
 // resume against a table of fakes.
+//
+// Stages 3 to 12 retry a transient failure under stages.DefaultRetry. They
+// can: each is already re-run by a resume after it fails, so running it again
+// in the same process asks nothing of it a resume does not. The three that
+// always run do not retry. Preflight and verify report what they see, and a
+// check retried until it passes has stopped being one; register mints
+// credentials, and every mint rotates.
+//
+// Stages 5 to 9 need a running cluster and little of each other, so they say
+// what they are after and run side by side instead of queueing:
+//
+//	platform-networking  after k3s-agents
+//	platform-certs       after platform-networking: the Gateway defaults
+//	                     attach to Traefik's Gateway API
+//	platform-storage     after k3s-agents
+//	platform-backup      after platform-storage: its datastore snapshots
+//	                     restart k3s on every server, which the storage
+//	                     stage's volume-group work should not share hosts with
+//	platform-day2        after k3s-agents
+//
+// Every other stage declares nothing and waits for everything before it, so
+// the agent still starts only once the whole platform layer has completed.
+// Each of the five installs by writing a manifest and converging on it,
+// which is why they tolerate one another — and the API server restarting
+// under backup — where a kubectl apply in flight would not.
 func Plan(s *Session) []Stage {
 	bind := func(f func(context.Context, *Session) error) stages.StageFunc {
 		return func(ctx context.Context) error { return f(ctx, s) }
 	}
+	retry := stages.DefaultRetry
 	return []Stage{
 		{Name: StagePreflight, AlwaysRun: true, Run: bind(stagePreflight)},
 		{Name: StageRegister, AlwaysRun: true, Run: bind(stageRegister)},
-		{Name: StageK3sServer, Component: "k3s", Run: bind(stageK3sServer)},
-		{Name: StageK3sAgents, Component: "k3s", Run: bind(stageK3sAgents)},
-		{Name: StageNetworking, Component: "traefik", Run: bind(stageNetworking)},
-		{Name: StageCerts, Component: "cert-manager", Run: bind(stageCerts)},
-		{Name: StageStorage, Component: "openebs-lvm-localpv", Run: bind(stageStorage)},
-		{Name: StageBackup, Component: "velero", Run: bind(stageBackup)},
-		{Name: StageDay2, Component: "system-upgrade-controller", Run: bind(stageDay2)},
-		{Name: StageAgent, Component: "kubenest-agent", Run: bind(stageAgent)},
-		{Name: StageProfiles, Run: bind(stageProfiles)},
-		{Name: StageRecord, Run: bind(stageRecord)},
+		{Name: StageK3sServer, Component: "k3s", Retry: retry, Run: bind(stageK3sServer)},
+		{Name: StageK3sAgents, Component: "k3s", Retry: retry, Run: bind(stageK3sAgents)},
+		{Name: StageNetworking, Component: "traefik", After: []string{StageK3sAgents}, Retry: retry, Run: bind(stageNetworking)},
+		{Name: StageCerts, Component: "cert-manager", After: []string{StageNetworking}, Retry: retry, Run: bind(stageCerts)},
+		{Name: StageStorage, Component: "openebs-lvm-localpv", After: []string{StageK3sAgents}, Retry: retry, Run: bind(stageStorage)},
+		{Name: StageBackup, Component: "velero", After: []string{StageStorage}, Retry: retry, Run: bind(stageBackup)},
+		{Name: StageDay2, Component: "system-upgrade-controller", After: []string{StageK3sAgents}, Retry: retry, Run: bind(stageDay2)},
+		{Name: StageAgent, Component: "kubenest-agent", Retry: retry, Run: bind(stageAgent)},
+		{Name: StageProfiles, Retry: retry, Run: bind(stageProfiles)},
+		{Name: StageRecord, Retry: retry, Run: bind(stageRecord)},
 		{Name: StageVerify, AlwaysRun: true, Run: bind(Verify)},
 	}
 }
@@ -239,10 +392,11 @@ func stagePreflight(ctx context.Context, s *Session) error {
 
 	// A resumed install re-runs preflight after earlier stages already
 	// installed k3s and possibly created the volume group. Two checks would
-	// otherwise refuse the installer's own work.
-	_, serversDone := s.Jnl.Completed(StageK3sServer)
-	_, agentsDone := s.Jnl.Completed(StageK3sAgents)
-	_, storageDone := s.Jnl.Completed(StageStorage)
+	// otherwise refuse the installer's own work — work it did even when an
+	// operator has since asked for the stage to run again.
+	serversDone := s.Jnl.EverCompleted(StageK3sServer)
+	agentsDone := s.Jnl.EverCompleted(StageK3sAgents)
+	storageDone := s.Jnl.EverCompleted(StageStorage)
 	for i := range nodes {
 		if nodes[i].Role == string(RoleServer) {
 			nodes[i].ExistingK3sIsOurs = serversDone
@@ -250,18 +404,32 @@ func stagePreflight(ctx context.Context, s *Session) error {
 			nodes[i].ExistingK3sIsOurs = agentsDone
 		}
 		nodes[i].StorageIsOurs = storageDone && s.Record.Ownership == storage.InstallerCreated
+		nodes[i].ReplicatedIsOurs = s.Record.ReplicatedDevice != ""
 	}
 
-	report, err := preflight.Run(ctx, preflight.Options{
-		Bundle:        s.Bundle,
-		BundleVersion: s.Opts.Bundle,
-		HATier:        s.Opts.HATier,
-		Profiles:      s.Opts.Profiles,
-		StorageDevice: s.Opts.StorageDevice,
-		Nodes:         nodes,
-		Egress:        EgressTargets(s),
-		Catalog:       bundleCatalog{s.API},
-	})
+	proxy, err := parseProxy(s.Opts)
+	if err != nil {
+		return err
+	}
+	opts := preflight.Options{
+		Proxy:            proxy,
+		Bundle:           s.Bundle,
+		BundleVersion:    s.Opts.Bundle,
+		HATier:           s.Opts.HATier,
+		Profiles:         s.Opts.Profiles,
+		StorageDevice:    s.Opts.StorageDevice,
+		ReplicatedDevice: s.Opts.ReplicatedDevice,
+		Nodes:            nodes,
+		Egress:           EgressTargets(s),
+		Catalog:          bundleCatalog{s.API},
+	}
+	// The manifest's artifact source IS the offline bundle when there is
+	// one; preflight verifies it there rather than through a second handle.
+	if offline, ok := s.Bundle.Offline.(preflight.OfflineBundle); ok {
+		opts.Offline = offline
+	}
+	report, err := preflight.Run(ctx, opts)
+	s.publish(report)
 	for _, warning := range report.Warnings() {
 		s.Logf("  warning: %s", warning)
 	}
@@ -274,20 +442,56 @@ func stagePreflight(ctx context.Context, s *Session) error {
 	for _, n := range nodes {
 		s.Nodes = append(s.Nodes, Node{Address: n.Address, Role: NodeRole(n.Role), Runner: n.Runner})
 	}
+	// A resume past stage 3 skips it, so the copy on the server starts here:
+	// that server has been written to already, by this install.
+	// The lease likewise: a refusal here, from preflight, has written
+	// nothing anywhere.
+	if serversDone {
+		s.replicateToServer()
+		if err := s.holdLease(ctx); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // EgressTargets is what the nodes must be able to reach, assembled from the
 // component installers' OWN chart repositories rather than a list copied here.
 // A bundle that moves a repository cannot leave preflight checking the old one.
+//
+// A mirrored registry is probed at its mirrors instead: a site that mirrors
+// docker.io because Docker Hub is rate-limited or unreachable from it must
+// not be refused for not reaching Docker Hub, and a mirror the nodes cannot
+// reach is exactly what preflight should find.
 func EgressTargets(s *Session) []preflight.EgressTarget {
 	targets := []preflight.EgressTarget{
 		{Name: "k3s installer", URL: "https://get.k3s.io"},
-		{Name: "container registry (docker.io)", URL: "https://registry-1.docker.io/v2/"},
-		{Name: "container registry (ghcr.io)", URL: "https://ghcr.io/v2/"},
-		{Name: "Gateway API release", URL: gatewayapi.ReleaseBaseURL},
-		{Name: "system-upgrade-controller release", URL: day2.ReleaseBaseURL},
 	}
+	// A malformed --registry-mirror is refused by flag validation before a
+	// session exists.
+	regs, _ := registryMirrors(s.Opts.RegistryMirrors)
+	probed := map[string]bool{}
+	for _, registry := range []struct{ name, url string }{
+		{"docker.io", "https://registry-1.docker.io/v2/"},
+		{"ghcr.io", "https://ghcr.io/v2/"},
+	} {
+		mirrors := regs.MirrorsOf(registry.name)
+		if len(mirrors) == 0 {
+			targets = append(targets, preflight.EgressTarget{Name: "container registry (" + registry.name + ")", URL: registry.url})
+			continue
+		}
+		for _, mirror := range mirrors {
+			if probed[mirror] {
+				continue
+			}
+			probed[mirror] = true
+			targets = append(targets, preflight.EgressTarget{Name: registry.name + " mirror", URL: mirror + "/v2/"})
+		}
+	}
+	targets = append(targets,
+		preflight.EgressTarget{Name: "Gateway API release", URL: gatewayapi.ReleaseBaseURL},
+		preflight.EgressTarget{Name: "system-upgrade-controller release", URL: day2.ReleaseBaseURL},
+	)
 	charts := []struct {
 		name  string
 		chart func() (k3s.HelmChart, error)
@@ -309,6 +513,25 @@ func EgressTargets(s *Session) []preflight.EgressTarget {
 			URL:  strings.TrimRight(chart.Repo, "/") + "/index.yaml",
 		})
 	}
+	// Several of a profile's charts may share a repository; each is probed once.
+	repos := map[string]bool{}
+	for _, name := range s.Opts.Profiles {
+		profile, ok := componentProfiles[name]
+		if !ok {
+			continue
+		}
+		profileCharts, _ := profile.charts(s.Bundle)
+		for _, chart := range profileCharts {
+			if chart.Repo == "" || repos[chart.Repo] {
+				continue
+			}
+			repos[chart.Repo] = true
+			targets = append(targets, preflight.EgressTarget{
+				Name: name + " charts (" + chart.Chart + ")",
+				URL:  strings.TrimRight(chart.Repo, "/") + "/index.yaml",
+			})
+		}
+	}
 	return targets
 }
 
@@ -352,7 +575,7 @@ func stageRegister(ctx context.Context, s *Session) error {
 	if err != nil {
 		return err
 	}
-	s.Jnl.ClusterID = cluster.ID
+	s.Jnl.SetClusterID(cluster.ID)
 	s.Record.Adopted = adopted
 
 	if _, agentInstalled := s.Jnl.Completed(StageAgent); agentInstalled {
@@ -376,17 +599,25 @@ func stageRegister(ctx context.Context, s *Session) error {
 
 // stageK3sServer installs k3s on the control-plane node, or all three for the
 // ha tier: the first initialises the embedded-etcd cluster and the other two
-// join it.
+// join it. Each gets the cluster's proxy and registries.yaml first, so k3s's
+// first pull already goes through the proxy to the site's mirror.
 func stageK3sServer(ctx context.Context, s *Session) error {
 	servers := s.NodesWithRole(RoleServer)
 	if len(servers) == 0 {
 		return fmt.Errorf("no server node")
 	}
+	s.replicateToServer()
+	if err := configureNodes(ctx, s, servers); err != nil {
+		return err
+	}
 	if err := stages.NewComponentError("k3s", k3s.InstallServer(ctx, servers[0].Runner, s.Bundle, k3s.ServerOptions{}, s.Reporter)); err != nil {
 		return err
 	}
+	if err := s.holdLease(ctx); err != nil {
+		return err
+	}
 	if len(servers) == 1 {
-		return nil
+		return stageOfflineScanner(ctx, s, servers)
 	}
 
 	// The token is read for immediate use and never stored: not in the
@@ -402,10 +633,30 @@ func stageK3sServer(ctx context.Context, s *Session) error {
 			return fmt.Errorf("joining %s to the etcd cluster: %w", server.Address, err)
 		}
 	}
-	return k3s.WaitNodesReady(ctx, servers[0].Runner, s.Bundle, len(servers), s.Reporter)
+	if err := k3s.WaitNodesReady(ctx, servers[0].Runner, s.Bundle, len(servers), s.Reporter); err != nil {
+		return err
+	}
+	return stageOfflineScanner(ctx, s, servers)
+}
+
+// stageOfflineScanner places the pinned deprecation scanner on every server
+// when installing from an offline bundle, so the first upgrade's scan does
+// not need the egress the site does not have. Online it does nothing; the
+// scan downloads its own.
+func stageOfflineScanner(ctx context.Context, s *Session, servers []Node) error {
+	for _, server := range servers {
+		if err := deprecation.StageScanner(ctx, server.Runner, s.Bundle); err != nil {
+			return fmt.Errorf("placing the deprecation scanner on %s: %w", server.Address, err)
+		}
+	}
+	return nil
 }
 
-// stageK3sAgents joins the worker nodes.
+// stageK3sAgents joins the worker nodes, each with the proxy and
+// registries.yaml the servers got, --parallel at a time. Agents join nothing
+// but the API server, so unlike the etcd members they can all join at once;
+// on a 20-agent fleet this stage is most of the install if they do not. Every
+// agent that failed is named in the one error.
 func stageK3sAgents(ctx context.Context, s *Session) error {
 	agents := s.NodesWithRole(RoleAgent)
 	if len(agents) == 0 {
@@ -420,14 +671,26 @@ func stageK3sAgents(ctx context.Context, s *Session) error {
 		return err
 	}
 	joinURL := serverURL(servers[0].Address)
-	for _, node := range agents {
-		if err := k3s.InstallAgent(ctx, node.Runner, s.Bundle, joinURL, token, s.Reporter); err != nil {
-			return fmt.Errorf("joining agent %s: %w", node.Address, err)
+	err = eachNode(ctx, s.parallelism(), "joining agents", "k3s-agent", nodeAddresses(agents), s.Reporter, func(ctx context.Context, i int) error {
+		if err := configureNodes(ctx, s, agents[i:i+1]); err != nil {
+			return err
 		}
+		return k3s.InstallAgent(ctx, agents[i].Runner, s.Bundle, joinURL, token, s.Reporter)
+	})
+	if err != nil {
+		return err
 	}
 	return k3s.WaitNodesReady(ctx, servers[0].Runner, s.Bundle, len(s.Nodes), s.Reporter)
 }
 
+func nodeAddresses(nodes []Node) []string {
+	addresses := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		addresses = append(addresses, n.Address)
+	}
+	return addresses
+}
+
 // serverURL is the address other nodes join through. It is the address the
 // operator gave on the command line — the installer does not guess at a
 // different interface, because on a private network it would guess wrong.
@@ -568,28 +831,45 @@ func stageAgent(ctx context.Context, s *Session) error {
 // stageProfiles installs each selected profile, in the order given.
 //
 // Every requested profile has already been checked against the bundle by
-// preflight, so anything reaching here is offered by the bundle — but the
-// component profiles are not built yet (kn-sev5, kn-ynaq, kn-54ni, wave 4).
-// Saying so and failing is the only honest option: silently installing core
-// when someone asked for observability produces a cluster that does not match
-// its own record.
+// preflight, so anything reaching here is offered by the bundle. A profile
+// this build cannot install — one a newer bundle offers — is refused before
+// anything is applied: silently installing core when someone asked for it
+// produces a cluster that does not match its own record.
 func stageProfiles(ctx context.Context, s *Session) error {
-	var unbuilt []string
+	var unbuilt, installing []string
 	for _, name := range s.Opts.Profiles {
-		if name == "ha" {
+		switch name {
+		case "ha":
 			// The ha profile is a topology, not components: two more servers
 			// joining the embedded-etcd cluster single-server already runs.
 			// Stage 3 did that.
-			continue
+		default:
+			if _, ok := componentProfiles[name]; ok {
+				installing = append(installing, name)
+			} else {
+				unbuilt = append(unbuilt, name)
+			}
 		}
-		unbuilt = append(unbuilt, name)
 	}
-	if len(unbuilt) == 0 {
+	if len(unbuilt) > 0 {
+		return fmt.Errorf("bundle %s offers %s, but this build of the CLI cannot install %s: upgrade the CLI to one that knows the profile, or install without it",
+			s.Bundle.Bundle, strings.Join(s.Bundle.Profiles.Names(), ", "), strings.Join(unbuilt, ", "))
+	}
+	if len(installing) == 0 {
 		s.Logf("  core only, no component profiles requested")
 		return nil
 	}
-	return fmt.Errorf("bundle %s offers %s, but this build of the CLI cannot install %s yet — the component profiles land after core (kn-sev5 observability, kn-ynaq secrets, kn-54ni replicated-storage). Install core now and add the profile when it ships",
-		s.Bundle.Bundle, strings.Join(s.Bundle.Profiles.Names(), ", "), strings.Join(unbuilt, ", "))
+	server, err := s.Server()
+	if err != nil {
+		return err
+	}
+	for _, name := range installing {
+		s.Logf("  profile %s", name)
+		if err := stages.NewComponentError(name, componentProfiles[name].install(ctx, s, server)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // stageRecord writes what was installed against the cluster: bundle version,
@@ -622,11 +902,14 @@ func stageRecord(ctx context.Context, s *Session) error {
 // terminalEntries is the journal in the control plane's shape. Only terminal
 // transitions are persisted server-side; `started` exists to make a killed
 // run legible locally and to drive live progress, not to fill a permanent
-// record with noise.
+// record with noise. An operator's reopened entry is not a transition at all,
+// and the wire has no word for it; the rerun itself is recorded by the
+// entries it produces. Nor has it one for an attempt that was retried — the
+// attempt that ended the stage is its record there — or for a site hook.
 func terminalEntries(j *Journal) []api.InstallJournalEntry {
 	var out []api.InstallJournalEntry
 	for _, e := range j.Entries {
-		if e.Status == StatusStarted {
+		if e.Status == StatusStarted || e.Status == StatusReopened || e.Status == StatusRetrying || e.Status == StatusHook {
 			continue
 		}
 		at := e.At
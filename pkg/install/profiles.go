@@ -0,0 +1,95 @@
+package install
+
+import (
+	"context"
+	"fmt"
+
+	"kubenest.io/cli/pkg/component/observability"
+	"kubenest.io/cli/pkg/component/secrets"
+	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/k3s"
+	"kubenest.io/cli/pkg/manifest"
+	"kubenest.io/cli/pkg/storage"
+)
+
+// componentProfile is a profile this build can install: its installer, the
+// charts --plan renders and preflight probes the repositories of, and
+// whatever else the plan should show for it.
+type componentProfile struct {
+	install func(ctx context.Context, s *Session, server k3s.Runner) error
+	charts  func(bundle *manifest.Manifest) ([]k3s.HelmChart, error)
+	render  func(s *Session, p *PlannedStage)
+}
+
+// componentProfiles are the profiles with components. A profile the bundle
+// offers that is not here is refused by stage 11 before anything is applied.
+var componentProfiles = map[string]componentProfile{
+	observability.Profile: {
+		install: chartsOnly(observability.Install),
+		charts:  observability.Charts,
+		render: func(_ *Session, p *PlannedStage) {
+			p.manifest(k3s.Document{Name: observability.RouteManifestName, Content: []byte(observability.RouteManifest)})
+			p.note("Grafana is served at %s on the platform Gateway; its admin password is generated in-cluster, in secret kubenest-grafana in %s", observability.GrafanaPath, observability.Namespace)
+		},
+	},
+	secrets.Profile: {
+		install: chartsOnly(secrets.Install),
+		charts:  oneChart(secrets.Chart),
+		render: func(_ *Session, p *PlannedStage) {
+			p.note("the sealing key is generated in-cluster, in %s, and is in the workload backups; seal secrets against it with `kubenest secrets seal`", secrets.Namespace)
+		},
+	},
+	storage.ReplicatedProfile: {
+		install: installReplicated,
+		charts:  oneChart(storage.ReplicatedChart),
+		render: func(s *Session, p *PlannedStage) {
+			p.note("load the engine's kernel modules on every node, and label every node for the io-engine")
+			p.note("pool %s on every node, %d replicas per volume", s.Opts.ReplicatedDevice, storage.Replicas)
+			p.manifest(k3s.Document{Name: storage.ReplicatedStorageClassManifestName, Content: []byte(storage.ReplicatedStorageClassManifest)})
+			p.note("prove a replicated volume outlives its node: write on one, cordon it, read back on another; %s stays the default", storage.StorageClassName)
+		},
+	},
+}
+
+// chartsOnly adapts a profile that needs nothing but the server and the
+// bundle.
+func chartsOnly(install func(ctx context.Context, r k3s.Runner, bundle *manifest.Manifest, rep converge.Reporter) error) func(context.Context, *Session, k3s.Runner) error {
+	return func(ctx context.Context, s *Session, server k3s.Runner) error {
+		return install(ctx, server, s.Bundle, s.Reporter)
+	}
+}
+
+func oneChart(chart func(bundle *manifest.Manifest) (k3s.HelmChart, error)) func(*manifest.Manifest) ([]k3s.HelmChart, error) {
+	return func(bundle *manifest.Manifest) ([]k3s.HelmChart, error) {
+		h, err := chart(bundle)
+		if err != nil {
+			return nil, err
+		}
+		return []k3s.HelmChart{h}, nil
+	}
+}
+
+// installReplicated prepares every node's kernel, records the device, then
+// installs and verifies the engine. The device is recorded BEFORE it is
+// pooled: a resumed install re-runs preflight, which must not refuse the
+// device for carrying this install's own pool.
+func installReplicated(ctx context.Context, s *Session, server k3s.Runner) error {
+	prof, err := s.Bundle.Profiles.Get(storage.ReplicatedProfile)
+	if err != nil {
+		return err
+	}
+	req := storage.ProfileRequirement(prof)
+	for _, node := range s.Nodes {
+		if err := storage.PrepareReplicatedHost(ctx, node.Runner, req); err != nil {
+			return fmt.Errorf("replicated storage on %s: %w", node.Address, err)
+		}
+	}
+	s.Record.ReplicatedDevice = s.Opts.ReplicatedDevice
+	if err := s.saveRecord(); err != nil {
+		return err
+	}
+	if err := storage.InstallReplicated(ctx, server, s.Bundle, s.Opts.ReplicatedDevice, s.Reporter); err != nil {
+		return err
+	}
+	return storage.VerifyReplicated(ctx, server, s.Bundle, s.Reporter)
+}
@@ -0,0 +1,164 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"kubenest.io/cli/pkg/k3s"
+	"kubenest.io/cli/pkg/preflight"
+	"kubenest.io/cli/pkg/stages"
+)
+
+// parseProxy reads --http-proxy, --https-proxy and --no-proxy into the proxy
+// every node is given before k3s first starts.
+//
+// The flags carry the proxy's address only. An authenticated proxy's
+// credentials come from KUBENEST_PROXY_USERNAME and KUBENEST_PROXY_PASSWORD,
+// for the reason the backup and registry credentials do, and are joined to
+// the URLs here, in memory, on their way to the node's root-only file.
+//
+// NO_PROXY is the operator's list plus everything node-to-node: every node
+// address of the cluster, k3s's pod and service CIDRs, loopback and the
+// in-cluster DNS suffixes. A proxy between a kubelet and its API server is
+// never what anyone meant, and leaving it to the operator to remember all of
+// that is how a cluster ends up half-working through a proxy that logs it.
+func parseProxy(opts Options) (k3s.Proxy, error) {
+	if opts.HTTPProxy == "" && opts.HTTPSProxy == "" {
+		if len(opts.NoProxy) > 0 {
+			return k3s.Proxy{}, fmt.Errorf("--no-proxy was given without --http-proxy or --https-proxy: there is no proxy for it to bypass")
+		}
+		return k3s.Proxy{}, nil
+	}
+	user, password := proxyCredentials()
+	if (user == "") != (password == "") {
+		return k3s.Proxy{}, fmt.Errorf("KUBENEST_PROXY_USERNAME and KUBENEST_PROXY_PASSWORD go together: set both, or neither")
+	}
+	withCredentials := func(flag, raw string) (string, error) {
+		if raw == "" {
+			return "", nil
+		}
+		u, err := k3s.ParseProxyURL(flag, raw)
+		if err != nil {
+			return "", err
+		}
+		if user != "" {
+			u.User = url.UserPassword(user, password)
+		}
+		return u.String(), nil
+	}
+	var p k3s.Proxy
+	var err error
+	if p.HTTP, err = withCredentials("http-proxy", opts.HTTPProxy); err != nil {
+		return k3s.Proxy{}, err
+	}
+	if p.HTTPS, err = withCredentials("https-proxy", opts.HTTPSProxy); err != nil {
+		return k3s.Proxy{}, err
+	}
+	for _, entries := range [][]string{k3s.ClusterNoProxy, opts.Servers, opts.Agents, opts.NoProxy} {
+		for _, entry := range entries {
+			if entry != "" && !slices.Contains(p.NoProxy, entry) {
+				p.NoProxy = append(p.NoProxy, entry)
+			}
+		}
+	}
+	return p, nil
+}
+
+func proxyCredentials() (username, password string) {
+	return envFirst("KUBENEST_PROXY_USERNAME"), envFirst("KUBENEST_PROXY_PASSWORD")
+}
+
+// configureNodes writes what a node needs before its k3s first starts: the
+// proxy, then registries.yaml. The order matters only on a resume, where a
+// restart onto the new registries.yaml must already go through the proxy.
+func configureNodes(ctx context.Context, s *Session, nodes []Node) error {
+	proxy, err := parseProxy(s.Opts)
+	if err != nil {
+		return err
+	}
+	if err := configureProxy(ctx, s, proxy, nodes); err != nil {
+		return err
+	}
+	regs, err := parseRegistries(s.Opts)
+	if err != nil {
+		return err
+	}
+	return configureRegistries(ctx, s, regs, nodes)
+}
+
+// joiningProxy is the proxy of a cluster that nodes are joining. It is
+// rebuilt from the journalled flags and this machine's KUBENEST_PROXY_*,
+// with the joining addresses in NO_PROXY, and is what the joining nodes get
+// first and the existing nodes get once the join is done (refreshProxy).
+func joiningProxy(opts Options, addresses []string, role NodeRole) (k3s.Proxy, error) {
+	if role == RoleServer {
+		opts.Servers = append(slices.Clone(opts.Servers), addresses...)
+	} else {
+		opts.Agents = append(slices.Clone(opts.Agents), addresses...)
+	}
+	return parseProxy(opts)
+}
+
+// configureJoiningProxy gives the nodes preflight admitted to a running
+// cluster the proxy, before they are joined.
+func configureJoiningProxy(ctx context.Context, s *Session, proxy k3s.Proxy, nodes []preflight.Node) error {
+	joining := make([]Node, 0, len(nodes))
+	for _, n := range nodes {
+		joining = append(joining, Node{Address: n.Address, Role: NodeRole(n.Role), Runner: n.Runner})
+	}
+	return configureProxy(ctx, s, proxy, joining)
+}
+
+// configureProxy gives each node the proxy, before its k3s starts so the
+// first image pull already goes through it. Without a proxy it does nothing.
+func configureProxy(ctx context.Context, s *Session, proxy k3s.Proxy, nodes []Node) error {
+	if proxy.Empty() {
+		return nil
+	}
+	for _, node := range nodes {
+		if _, err := k3s.ConfigureProxy(ctx, node.Runner, s.Bundle, proxy, s.Reporter); err != nil {
+			return fmt.Errorf("proxy configuration on %s: %w", node.Address, err)
+		}
+	}
+	return nil
+}
+
+// refreshProxy gives the nodes that were already in the cluster the proxy a
+// join changed. Their NO_PROXY lacks the addresses that just joined, so
+// without this a kubelet, containerd or etcd talking to a new node would go
+// through the proxy, which either cannot reach it or can and sees the
+// traffic. It runs after the join, so a restart onto the new file is a
+// cluster that already has the new node, and one node at a time as
+// SetRegistry does: a node whose file is unchanged is not restarted, one
+// whose file changed is back (waitRestarted) before the next is touched.
+//
+// A node that cannot be reached is warned about rather than refused: a
+// failed agent must not keep a server from being replaced, and the next
+// join that reaches it writes it the whole node set. A node that leaves
+// is not taken out of anyone's NO_PROXY; bypassing the proxy for an
+// address nobody uses costs nothing.
+func refreshProxy(ctx context.Context, s *Session, server k3s.Runner, peers []preflight.Node, proxy k3s.Proxy) error {
+	if proxy.Empty() {
+		return nil
+	}
+	for _, node := range peers {
+		if node.Runner == nil {
+			s.Logf("  warning: %s could not be reached (%v), so its NO_PROXY does not name the new node(s); the next add-node that reaches it rewrites it", node.Address, node.DialErr)
+			continue
+		}
+		changed, err := k3s.ConfigureProxy(ctx, node.Runner, s.Bundle, proxy, s.Reporter)
+		if err != nil {
+			return stages.NewComponentError("k3s", fmt.Errorf("proxy configuration on %s: %w", node.Address, err))
+		}
+		if !changed {
+			continue
+		}
+		s.Logf("  %s: restarted onto the new NO_PROXY", node.Address)
+		if err := waitRestarted(ctx, s, server, node); err != nil {
+			return fmt.Errorf("after restarting %s: %w", node.Address, err)
+		}
+	}
+	return nil
+}
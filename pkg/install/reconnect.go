@@ -77,9 +77,25 @@ func (r *reconnectingRunner) Run(ctx context.Context, command string) (sshx.Resu
 // inlined into one exec command, which blows the SSH packet cap on a real
 // host.
 func (r *reconnectingRunner) RunInput(ctx context.Context, command string, stdin io.Reader) (sshx.Result, error) {
-	// A retried stream must send the same bytes again, so the payload is
-	// buffered. Everything streamed during an install is a manifest — the
-	// largest is the Gateway API CRD bundle at a few hundred KB.
+	// An offline bundle's files are image tarballs of hundreds of megabytes
+	// and arrive seekable; a retry rewinds them instead of holding them in
+	// memory.
+	if seeker, ok := stdin.(io.ReadSeeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return sshx.Result{}, err
+		}
+		return r.attempt(ctx, func(c runConn) (sshx.Result, error) {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return sshx.Result{}, err
+			}
+			return c.RunInput(ctx, command, seeker)
+		})
+	}
+	// Otherwise a retried stream must send the same bytes again, so the
+	// payload is buffered. Everything else streamed during an install is a
+	// manifest — the largest is the Gateway API CRD bundle at a few hundred
+	// KB.
 	payload, err := io.ReadAll(stdin)
 	if err != nil {
 		return sshx.Result{}, err
@@ -0,0 +1,66 @@
+package install
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"kubenest.io/cli/pkg/component/componenttest"
+	"kubenest.io/cli/pkg/preflight"
+	"kubenest.io/cli/pkg/sshx"
+)
+
+// envRunner is a FakeRunner that also takes stdin, keeping what it was given.
+type envRunner struct {
+	componenttest.FakeRunner
+	stdin string
+}
+
+func (r *envRunner) RunInput(ctx context.Context, command string, stdin io.Reader) (sshx.Result, error) {
+	body, err := io.ReadAll(stdin)
+	if err != nil {
+		return sshx.Result{}, err
+	}
+	r.stdin = string(body)
+	return r.Run(ctx, command)
+}
+
+// After a join, the nodes already there are given a NO_PROXY naming the new
+// node; one whose file already did is not restarted, and one that cannot be
+// reached is named rather than failing a join that has already happened.
+func TestRefreshProxyGivesExistingNodesTheJoinedAddresses(t *testing.T) {
+	opts := Options{Name: "prod-1", HATier: "ha",
+		Servers:    []string{"10.0.1.10", "10.0.1.11", "10.0.1.12"},
+		Agents:     []string{"10.0.1.20"},
+		HTTPSProxy: "http://proxy.example.com:3128"}
+	proxy, err := joiningProxy(opts, []string{"10.0.1.30"}, RoleAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &envRunner{}
+	server.Respond = func(string) (sshx.Result, error) { return sshx.Result{Stdout: "unchanged"}, nil }
+	var out bytes.Buffer
+	s := &Session{Opts: opts, Out: &out}
+	peers := []preflight.Node{
+		{Address: "10.0.1.10", Role: string(RoleServer), Runner: server},
+		{Address: "10.0.1.20", Role: string(RoleAgent), DialErr: errors.New("connection refused")},
+	}
+
+	if err := refreshProxy(context.Background(), s, server, peers, proxy); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(server.stdin, "10.0.1.30") {
+		t.Errorf("the existing server's proxy file does not name the joined node:\n%s", server.stdin)
+	}
+	for _, cmd := range server.Commands() {
+		if strings.Contains(cmd, "restart") {
+			t.Errorf("restarted a node whose file was unchanged: %s", cmd)
+		}
+	}
+	if !strings.Contains(out.String(), "10.0.1.20 could not be reached") {
+		t.Errorf("the unreachable agent is not named:\n%s", out.String())
+	}
+}
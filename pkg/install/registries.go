@@ -0,0 +1,260 @@
+package install
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"kubenest.io/cli/pkg/k3s"
+	"kubenest.io/cli/pkg/preflight"
+	"kubenest.io/cli/pkg/stages"
+)
+
+// parseRegistries reads --registry-mirror and the registry credentials into
+// the registries.yaml every node gets before k3s first starts.
+//
+// The flags carry only where pulls go. Credentials come from the environment
+// (KUBENEST_REGISTRY_USERNAME / KUBENEST_REGISTRY_PASSWORD, applied to every
+// mirror) or from --registry-credentials, a file of per-host credentials —
+// the same rule as the backup keys, for the same reason: a password on a
+// command line lands in shell history, in `ps`, and in the transcript someone
+// pastes into a support ticket.
+//
+// The file names registry hosts:
+//
+//	mirror.example.com:5000:
+//	  username: kubenest
+//	  password: …
+//	registry-1.docker.io:
+//	  username: acme
+//	  password: …
+//
+// A host the file names keeps the file's credentials; the environment's fill
+// in the mirrors it does not.
+func parseRegistries(opts Options) (k3s.Registries, error) {
+	regs, err := registryMirrors(opts.RegistryMirrors)
+	if err != nil {
+		return k3s.Registries{}, err
+	}
+	if opts.RegistryCredentials != "" {
+		if regs.Auth, err = readRegistryCredentials(opts.RegistryCredentials); err != nil {
+			return k3s.Registries{}, err
+		}
+	}
+	user, password := registryCredentials()
+	if (user == "") != (password == "") {
+		return k3s.Registries{}, fmt.Errorf("KUBENEST_REGISTRY_USERNAME and KUBENEST_REGISTRY_PASSWORD go together: set both, or neither")
+	}
+	if user != "" {
+		if len(regs.Mirrors) == 0 {
+			return k3s.Registries{}, fmt.Errorf("KUBENEST_REGISTRY_USERNAME is set but there is no --registry-mirror for it to log in to: name the mirror, or put upstream registry credentials in a --registry-credentials file")
+		}
+		if regs.Auth == nil {
+			regs.Auth = map[string]k3s.RegistryAuth{}
+		}
+		for _, m := range regs.Mirrors {
+			if _, named := regs.Auth[m.Host()]; !named {
+				regs.Auth[m.Host()] = k3s.RegistryAuth{Username: user, Password: password}
+			}
+		}
+	}
+	return regs, nil
+}
+
+// registryMirrors reads only the mirrors: where pulls go, without the
+// credentials that open them. A plan and the egress check need this much.
+func registryMirrors(raw []string) (k3s.Registries, error) {
+	var regs k3s.Registries
+	for _, r := range raw {
+		m, err := k3s.ParseMirror(r)
+		if err != nil {
+			return k3s.Registries{}, err
+		}
+		regs.Mirrors = append(regs.Mirrors, m)
+	}
+	return regs, nil
+}
+
+// readRegistryCredentials decodes a --registry-credentials file strictly: a
+// misspelt `pasword:` is an error here, not a 401 from every node.
+func readRegistryCredentials(path string) (map[string]k3s.RegistryAuth, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading --registry-credentials: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	var auth map[string]k3s.RegistryAuth
+	if err := dec.Decode(&auth); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("--registry-credentials %s: %w", path, err)
+	}
+	for host, a := range auth {
+		if a.Username == "" || a.Password == "" {
+			return nil, fmt.Errorf("--registry-credentials %s: %s needs both a username and a password", path, host)
+		}
+	}
+	return auth, nil
+}
+
+func registryCredentials() (username, password string) {
+	return envFirst("KUBENEST_REGISTRY_USERNAME"), envFirst("KUBENEST_REGISTRY_PASSWORD")
+}
+
+// configureRegistries gives each node the cluster's registries.yaml. During
+// an install it runs on each node before that node's k3s does, so the first
+// image pull already goes to the mirror; a node that already runs k3s — a
+// resume after the credentials were rotated — is restarted onto the new file.
+// With no mirrors and no credentials it does nothing: k3s then pulls from the
+// registries themselves, as it always has.
+func configureRegistries(ctx context.Context, s *Session, regs k3s.Registries, nodes []Node) error {
+	if regs.Empty() {
+		return nil
+	}
+	content, err := regs.YAML()
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if _, err := k3s.ConfigureRegistries(ctx, node.Runner, s.Bundle, content, s.Reporter); err != nil {
+			return fmt.Errorf("registry configuration on %s: %w", node.Address, err)
+		}
+	}
+	return nil
+}
+
+// inheritRegistries gives nodes joining a running cluster the registries.yaml
+// an existing node has. It is copied rather than rebuilt from flags because
+// the credentials in it are whatever `cluster set-registry` last rolled out,
+// which this machine may never have held; a new node pulling from Docker Hub
+// while the rest of the cluster uses the mirror is the drift this avoids.
+func inheritRegistries(ctx context.Context, s *Session, from k3s.Runner, fromAddress string, nodes []preflight.Node) error {
+	content, err := k3s.ReadRegistries(ctx, from)
+	if err != nil {
+		return fmt.Errorf("on %s: %w", fromAddress, err)
+	}
+	if content == nil {
+		return nil
+	}
+	for _, node := range nodes {
+		if _, err := k3s.ConfigureRegistries(ctx, node.Runner, s.Bundle, content, s.Reporter); err != nil {
+			return fmt.Errorf("registry configuration on %s: %w", node.Address, err)
+		}
+	}
+	return nil
+}
+
+// SetRegistryOptions is `cluster set-registry`'s request.
+type SetRegistryOptions struct {
+	Mirrors     []string
+	Credentials string
+	// Clear removes registries.yaml from every node: pulls go to the
+	// registries themselves again.
+	Clear bool
+}
+
+// SetRegistry rolls a new registry configuration out to a running cluster,
+// one node at a time: servers first, then agents. A node whose file is
+// unchanged is not restarted, so re-running after a failure resumes where
+// the roll-out stopped; a node whose file changed restarts k3s, and the next
+// node is not touched until that one is back (waitRestarted) and every node
+// of the cluster reports Ready again. That is what keeps the ha tier's etcd
+// quorum through the roll-out, and what keeps a mirror that turns out to be
+// unreachable from taking down more than the one node that found out.
+//
+// Every node must be reachable before any is changed. A roll-out that skipped
+// an unreachable node would leave it pulling from somewhere the rest of the
+// cluster no longer does, and nothing would say so.
+func SetRegistry(ctx context.Context, s *Session, opts SetRegistryOptions) error {
+	if s.Jnl == nil || s.Jnl.ClusterID == "" {
+		return fmt.Errorf("the install journal for %s has no registered cluster id: set-registry changes a cluster whose install reached stage 2 (register)", s.Opts.Name)
+	}
+	request := s.Opts
+	request.RegistryMirrors, request.RegistryCredentials = opts.Mirrors, opts.Credentials
+	regs, err := parseRegistries(request)
+	if err != nil {
+		return err
+	}
+	switch {
+	case opts.Clear && !regs.Empty():
+		return fmt.Errorf("--clear removes the registry configuration: it takes no --registry-mirror, --registry-credentials or KUBENEST_REGISTRY_* credentials")
+	case !opts.Clear && regs.Empty():
+		return fmt.Errorf("nothing to set: name at least one --registry-mirror or a --registry-credentials file, or pass --clear to remove the configuration")
+	}
+	content, err := regs.YAML()
+	if err != nil {
+		return err
+	}
+
+	peers := s.dialAll(ctx)
+	var unreachable []string
+	for _, p := range peers {
+		if p.Runner == nil {
+			unreachable = append(unreachable, fmt.Sprintf("%s (%v)", p.Address, p.DialErr))
+		}
+	}
+	if len(unreachable) > 0 {
+		return fmt.Errorf("every node must be reachable before the registry configuration changes on any of them; no SSH to %s", strings.Join(unreachable, ", "))
+	}
+	server := peers[0].Runner
+	if err := s.adoptServer(ctx, peers[0].Address, server); err != nil {
+		return err
+	}
+
+	for _, node := range peers {
+		var changed bool
+		if opts.Clear {
+			changed, err = k3s.RemoveRegistries(ctx, node.Runner, s.Bundle, s.Reporter)
+		} else {
+			changed, err = k3s.ConfigureRegistries(ctx, node.Runner, s.Bundle, content, s.Reporter)
+		}
+		if err != nil {
+			return stages.NewComponentError("k3s", fmt.Errorf("registry configuration on %s: %w", node.Address, err))
+		}
+		if !changed {
+			s.Logf("  %s: unchanged", node.Address)
+			continue
+		}
+		s.Logf("  %s: restarted onto the new registry configuration", node.Address)
+		if err := waitRestarted(ctx, s, server, node); err != nil {
+			return fmt.Errorf("after restarting %s: %w", node.Address, err)
+		}
+	}
+
+	s.Opts.RegistryMirrors = opts.Mirrors
+	detail := "set-registry: pulls go to " + strings.Join(opts.Mirrors, ", ")
+	switch {
+	case opts.Clear:
+		detail = "set-registry: registry configuration removed"
+	case len(opts.Mirrors) == 0:
+		detail = "set-registry: registry credentials only, no mirrors"
+	}
+	return s.recordChange(ctx, StageK3sServer, detail)
+}
+
+// waitRestarted waits until a node SetRegistry restarted is back: heard from
+// since the restart and Ready, and, for a server, its etcd member healthy.
+// Every node Ready is not enough on its own straight after a restart — the
+// restarted node's Ready condition is its old kubelet's until the node
+// controller doubts it, and by then the next node may have been restarted
+// too.
+func waitRestarted(ctx context.Context, s *Session, server k3s.Runner, node preflight.Node) error {
+	since, err := k3s.Started(ctx, node.Runner)
+	if err != nil {
+		return err
+	}
+	if err := k3s.WaitRejoined(ctx, server, s.Bundle, node.Address, since, s.Reporter); err != nil {
+		return err
+	}
+	if node.Role == string(RoleServer) {
+		if err := k3s.WaitEtcdHealthy(ctx, node.Runner, s.Bundle, s.Reporter); err != nil {
+			return err
+		}
+	}
+	return k3s.WaitNodesReady(ctx, server, s.Bundle, len(s.Opts.Servers)+len(s.Opts.Agents), s.Reporter)
+}
@@ -0,0 +1,95 @@
+package install_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kubenest.io/cli/pkg/install"
+)
+
+// A site that mirrors docker.io is not refused for not reaching Docker Hub:
+// the mirror is what its nodes must reach, so the mirror is what is probed.
+func TestEgressProbesTheMirrorInsteadOfTheRegistry(t *testing.T) {
+	s := renderSession(t)
+	s.Opts.RegistryMirrors = []string{"docker.io=https://mirror.example.com", "*=https://all.example.com"}
+	urls := map[string]bool{}
+	for _, target := range install.EgressTargets(s) {
+		urls[target.URL] = true
+	}
+	if urls["https://registry-1.docker.io/v2/"] || urls["https://ghcr.io/v2/"] {
+		t.Errorf("a mirrored registry was probed directly: %v", urls)
+	}
+	for _, want := range []string{"https://mirror.example.com/v2/", "https://all.example.com/v2/"} {
+		if !urls[want] {
+			t.Errorf("the mirror %s was not probed: %v", want, urls)
+		}
+	}
+}
+
+// The plan shows registries.yaml as every node gets it, but not a password
+// from either source.
+func TestRenderShowsRegistriesWithoutPasswords(t *testing.T) {
+	t.Setenv("KUBENEST_REGISTRY_USERNAME", "kubenest")
+	t.Setenv("KUBENEST_REGISTRY_PASSWORD", "env-registry-password")
+	credentials := filepath.Join(t.TempDir(), "registries.yaml")
+	if err := os.WriteFile(credentials, []byte("registry-1.docker.io:\n  username: acme\n  password: file-registry-password\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s := renderSession(t)
+	s.Opts.RegistryMirrors = []string{"docker.io=https://mirror.example.com"}
+	s.Opts.RegistryCredentials = credentials
+
+	plan, err := install.Render(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := renderedDocument(t, plan, install.StageK3sServer, "registries.yaml")
+	for _, want := range []string{"https://mirror.example.com", "mirror.example.com:", "registry-1.docker.io:", "acme"} {
+		if !strings.Contains(string(doc.Content), want) {
+			t.Errorf("registries.yaml is missing %q:\n%s", want, doc.Content)
+		}
+	}
+	var all strings.Builder
+	if err := install.WritePlan(&all, plan); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"env-registry-password", "file-registry-password"} {
+		if strings.Contains(all.String(), secret) {
+			t.Errorf("the plan contains the registry password %q", secret)
+		}
+	}
+}
+
+// The refusals come before any node is dialled, and write nothing.
+func TestSetRegistryRefusesAnEmptyOrContradictoryRequest(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		opts install.SetRegistryOptions
+		want string
+	}{
+		{"nothing", install.SetRegistryOptions{}, "nothing to set"},
+		{"clear and set", install.SetRegistryOptions{Clear: true, Mirrors: []string{"docker.io=https://mirror.example.com"}}, "--clear removes"},
+		{"bad mirror", install.SetRegistryOptions{Mirrors: []string{"docker.io"}}, "not REGISTRY=URL"},
+	} {
+		s := nodeSession(t, install.Options{Name: "prod-1", HATier: "single-server", Servers: []string{"10.0.1.10"}})
+		err := install.SetRegistry(context.Background(), s, c.opts)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: want a refusal containing %q, got %v", c.name, c.want, err)
+		}
+		if len(s.Jnl.Entries) != 0 {
+			t.Errorf("%s: a refusal wrote to the journal", c.name)
+		}
+	}
+}
+
+func TestRegistryPasswordWithoutAUserIsRefused(t *testing.T) {
+	t.Setenv("KUBENEST_REGISTRY_PASSWORD", "half-a-credential")
+	s := renderSession(t)
+	s.Opts.RegistryMirrors = []string{"docker.io=https://mirror.example.com"}
+	if _, err := install.Render(context.Background(), s); err == nil || !strings.Contains(err.Error(), "go together") {
+		t.Errorf("want a refusal naming both variables, got %v", err)
+	}
+}
@@ -0,0 +1,432 @@
+package install
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"kubenest.io/cli/pkg/api"
+	"kubenest.io/cli/pkg/backup"
+	"kubenest.io/cli/pkg/component/agent"
+	"kubenest.io/cli/pkg/component/certmanager"
+	"kubenest.io/cli/pkg/component/day2"
+	"kubenest.io/cli/pkg/component/gatewayapi"
+	"kubenest.io/cli/pkg/component/traefik"
+	"kubenest.io/cli/pkg/k3s"
+	"kubenest.io/cli/pkg/storage"
+)
+
+// `platform install --plan` renders what the thirteen stages WOULD do, for a
+// change board to review before anything touches a host.
+//
+// What it shows is the literal output, not a description of it: the same
+// k3s command line stage 3 runs, the same HelmChart documents the component
+// packages write to k3s.ManifestDir, byte for byte, because every one is
+// produced by the function the installer itself calls. A plan that
+// re-described the install in its own words would be a second source of
+// truth, and the first time the two disagreed the board would have approved
+// something that was not installed.
+//
+// Five things are deliberately NOT rendered: the cluster token, the agent
+// JWT, the backup credentials, the registry passwords and the proxy's
+// credentials. Those do not exist until the install creates or reads them,
+// and a plan is a document meant to be attached to a ticket. Where one would
+// appear, the plan says where it comes from instead.
+
+// PlannedStage is one stage's rendered output.
+type PlannedStage struct {
+	Name string
+	// Notes is what the stage does that is not a file: an API call, a
+	// command, a wait. One sentence each.
+	Notes     []string
+	Documents []PlannedDocument
+}
+
+// PlannedDocument is one file the stage would place, or one command it
+// would run.
+type PlannedDocument struct {
+	// Name is the file name the plan writes it under.
+	Name string
+	// Target is where it goes: the path on the server node, or the node a
+	// command runs on.
+	Target  string
+	Content []byte
+}
+
+// planPlaceholder stands in for a value only a real install produces.
+const planPlaceholder = "<issued by the control plane at stage 2; never rendered>"
+
+// Preview is the plan's whole run: preflight, read-only as it always is,
+// then every later stage rendered instead of applied. Preflight runs for
+// real because a plan for hosts that cannot take the install is not a plan.
+func Preview(ctx context.Context, s *Session) ([]PlannedStage, error) {
+	if err := stagePreflight(ctx, s); err != nil {
+		return nil, err
+	}
+	return Render(ctx, s)
+}
+
+// Render produces every stage's output without a connection to anything but
+// the release downloads the installer itself makes from this machine.
+func Render(ctx context.Context, s *Session) ([]PlannedStage, error) {
+	renderers := []struct {
+		stage  string
+		render func(context.Context, *Session, *PlannedStage) error
+	}{
+		{StagePreflight, renderPreflight},
+		{StageRegister, renderRegister},
+		{StageK3sServer, renderK3sServer},
+		{StageK3sAgents, renderK3sAgents},
+		{StageNetworking, renderNetworking},
+		{StageCerts, renderCerts},
+		{StageStorage, renderStorage},
+		{StageBackup, renderBackup},
+		{StageDay2, renderDay2},
+		{StageAgent, renderAgent},
+		{StageProfiles, renderProfiles},
+		{StageRecord, renderRecord},
+		{StageVerify, renderVerify},
+	}
+	var out []PlannedStage
+	for _, r := range renderers {
+		planned := PlannedStage{Name: r.stage}
+		if err := r.render(ctx, s, &planned); err != nil {
+			return nil, fmt.Errorf("rendering %s: %w", r.stage, err)
+		}
+		out = append(out, planned)
+	}
+	return out, nil
+}
+
+func (p *PlannedStage) note(format string, args ...any) {
+	p.Notes = append(p.Notes, fmt.Sprintf(format, args...))
+}
+
+func (p *PlannedStage) manifest(doc k3s.Document) {
+	p.Documents = append(p.Documents, PlannedDocument{Name: doc.Name + ".yaml", Target: doc.Path(), Content: doc.Content})
+}
+
+func (p *PlannedStage) chart(name string, chart k3s.HelmChart) error {
+	doc, err := chart.Manifest()
+	if err != nil {
+		return err
+	}
+	p.manifest(k3s.Document{Name: name, Content: doc})
+	return nil
+}
+
+func renderPreflight(_ context.Context, s *Session, p *PlannedStage) error {
+	p.note("read-only checks on %d node(s): nothing is written anywhere", len(s.Opts.Servers)+len(s.Opts.Agents))
+	return nil
+}
+
+func renderRegister(_ context.Context, s *Session, p *PlannedStage) error {
+	org := s.Opts.Org
+	if org == "" {
+		org = "the only organization this credential can see"
+	}
+	p.note("register (or adopt) cluster %q under %s with the control plane", s.Opts.Name, org)
+	p.note("mint the agent's credentials; they are held in memory for stage 10 and written nowhere else")
+	return nil
+}
+
+func renderK3sServer(_ context.Context, s *Session, p *PlannedStage) error {
+	version, err := s.Bundle.Core.Version("k3s")
+	if err != nil {
+		return err
+	}
+	if s.Bundle.Offline != nil {
+		p.note("from the offline bundle, pushed over SSH before each node's installer runs: the script, the k3s binary and system images, every platform image, and on servers every chart; nothing is downloaded")
+	}
+	proxy, err := parseProxy(s.Opts)
+	if err != nil {
+		return err
+	}
+	if !proxy.Empty() {
+		p.Documents = append(p.Documents, PlannedDocument{
+			Name:    "kubenest-proxy.env",
+			Target:  k3s.ProxyEnvFile + " on every node, before k3s starts (root, 0600, written over stdin; read by k3s and k3s-agent through a systemd drop-in)",
+			Content: proxy.Redacted(),
+		})
+	}
+	regs, err := parseRegistries(s.Opts)
+	if err != nil {
+		return err
+	}
+	if !regs.Empty() {
+		content, err := regs.Redacted()
+		if err != nil {
+			return err
+		}
+		p.Documents = append(p.Documents, PlannedDocument{
+			Name:    "registries.yaml",
+			Target:  k3s.RegistriesPath + " on every node, before k3s starts (root, 0600, written over stdin)",
+			Content: content,
+		})
+	}
+	for i, address := range s.Opts.Servers {
+		opts := k3s.ServerOptions{}
+		comment := "# initialises the embedded-etcd cluster\n"
+		if i > 0 {
+			opts.JoinURL = serverURL(s.Opts.Servers[0])
+			comment = fmt.Sprintf("# joins the etcd cluster; the token is read from %s (root, 0600)\n", k3s.TokenFile())
+		}
+		p.Documents = append(p.Documents, PlannedDocument{
+			Name:    "k3s-server-" + address + ".sh",
+			Target:  address,
+			Content: []byte(comment + installerCommand(s, version, k3s.ServerArgs(opts)) + "\n"),
+		})
+	}
+	return nil
+}
+
+// installerCommand is the command the node runs, from the offline bundle's
+// pushed script when there is one and through the proxy when there is one.
+func installerCommand(s *Session, version string, args []string) string {
+	if s.Bundle.Offline != nil {
+		return k3s.OfflineInstallerCommand(version, args)
+	}
+	if s.Opts.HTTPProxy != "" || s.Opts.HTTPSProxy != "" {
+		return k3s.ProxiedInstallerCommand(version, args)
+	}
+	return k3s.InstallerCommand(version, args)
+}
+
+func renderK3sAgents(_ context.Context, s *Session, p *PlannedStage) error {
+	if len(s.Opts.Agents) == 0 {
+		p.note("no agents")
+		return nil
+	}
+	version, err := s.Bundle.Core.Version("k3s")
+	if err != nil {
+		return err
+	}
+	joinURL := serverURL(s.Opts.Servers[0])
+	for _, address := range s.Opts.Agents {
+		p.Documents = append(p.Documents, PlannedDocument{
+			Name:   "k3s-agent-" + address + ".sh",
+			Target: address,
+			Content: []byte(fmt.Sprintf("# the token is read from %s (root, 0600)\n", k3s.TokenFile()) +
+				installerCommand(s, version, k3s.AgentArgs(joinURL)) + "\n"),
+		})
+	}
+	return nil
+}
+
+func renderNetworking(ctx context.Context, s *Session, p *PlannedStage) error {
+	crds, err := gatewayapi.Manifest(ctx, s.Bundle)
+	if err != nil {
+		return err
+	}
+	p.manifest(k3s.Document{Name: gatewayapi.ManifestName, Content: crds})
+	chart, err := traefik.Chart(s.Bundle)
+	if err != nil {
+		return err
+	}
+	return p.chart(chart.Name, chart)
+}
+
+func renderCerts(_ context.Context, s *Session, p *PlannedStage) error {
+	chart, err := certmanager.Chart(s.Bundle)
+	if err != nil {
+		return err
+	}
+	if err := p.chart(chart.Name, chart); err != nil {
+		return err
+	}
+	p.manifest(k3s.Document{Name: traefik.DefaultsManifestName, Content: []byte(traefik.DefaultsManifest)})
+	return nil
+}
+
+func renderStorage(_ context.Context, s *Session, p *PlannedStage) error {
+	if s.Opts.StorageDevice != "" {
+		p.note("create volume group %s on %s on every node (recorded as installer-created)", storage.VolumeGroup, s.Opts.StorageDevice)
+	} else {
+		p.note("verify the volume group %s you created exists on every node (recorded as customer-created, never removed)", storage.VolumeGroup)
+	}
+	chart, err := storage.Chart(s.Bundle)
+	if err != nil {
+		return err
+	}
+	if err := p.chart(chart.Name, chart); err != nil {
+		return err
+	}
+	p.manifest(k3s.Document{Name: storage.StorageClassManifestName, Content: []byte(storage.StorageClassManifest)})
+	return nil
+}
+
+func renderBackup(_ context.Context, s *Session, p *PlannedStage) error {
+	chart, err := backup.Chart(s.Bundle)
+	if err != nil {
+		return err
+	}
+	if err := p.chart(chart.Name, chart); err != nil {
+		return err
+	}
+	if s.Opts.BackupTarget == "" {
+		p.note("no --backup-target: Velero is installed unconfigured and the cluster reports backup: unconfigured")
+		return nil
+	}
+	target, err := backupTargetLocation(s.Opts.BackupTarget)
+	if err != nil {
+		return err
+	}
+	p.Notes = append(p.Notes, target.Locations()...)
+	if key, secret := backupCredentials(); key == "" || secret == "" {
+		p.note("credentials are NOT in this environment: the install would refuse at this stage until KUBENEST_BACKUP_ACCESS_KEY_ID and KUBENEST_BACKUP_SECRET_ACCESS_KEY are set")
+	} else {
+		p.note("credentials come from the environment and are applied as a Secret, which is not rendered")
+	}
+	location, err := target.StorageLocationManifest()
+	if err != nil {
+		return err
+	}
+	p.Documents = append(p.Documents, PlannedDocument{
+		Name:    "backup-storage-location.yaml",
+		Target:  "kubectl apply (not the auto-deploy directory: the target is per-cluster configuration)",
+		Content: location,
+	})
+	p.note("configure embedded-etcd snapshots to the same target on each of %d server(s), restarting them one at a time", len(s.Opts.Servers))
+	return nil
+}
+
+func renderDay2(ctx context.Context, s *Session, p *PlannedStage) error {
+	docs, err := day2.UpgradeControllerManifests(ctx, s.Bundle)
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		p.manifest(doc)
+	}
+	chart, err := day2.Chart(s.Bundle)
+	if err != nil {
+		return err
+	}
+	return p.chart(day2.KuredManifestName, chart)
+}
+
+// renderAgent renders the agent's HelmChart with placeholders where the
+// minted credentials go. The shape is the real one; the values that make it
+// THIS cluster's agent only exist once stage 2 has run.
+func renderAgent(_ context.Context, s *Session, p *PlannedStage) error {
+	placeholder := &api.AgentCredentials{
+		ClusterID: planPlaceholder,
+		AgentJWT:  api.AgentJWT{Token: api.NewSecret(planPlaceholder), HubURL: planPlaceholder},
+		Operator:  api.OperatorInstallInfo{Namespace: "kubenest-operator", ChartRef: "oci://" + planPlaceholder},
+	}
+	chart, err := agent.Chart(s.Bundle, placeholder)
+	if err != nil {
+		return err
+	}
+	p.note("the chart reference, namespace, cluster id and JWT come from the stage 2 mint; the file is made 0600 on the server")
+	return p.chart(agent.ManifestName, chart)
+}
+
+func renderProfiles(_ context.Context, s *Session, p *PlannedStage) error {
+	if len(s.Opts.Profiles) == 0 {
+		p.note("core only, no profiles requested")
+		return nil
+	}
+	p.note("profiles in order: %s", strings.Join(s.Opts.Profiles, ", "))
+	for _, name := range s.Opts.Profiles {
+		profile, ok := componentProfiles[name]
+		if !ok {
+			continue
+		}
+		charts, err := profile.charts(s.Bundle)
+		if err != nil {
+			return err
+		}
+		for _, chart := range charts {
+			if err := p.chart(chart.Name, chart); err != nil {
+				return err
+			}
+		}
+		profile.render(s, p)
+	}
+	return nil
+}
+
+func renderRecord(_ context.Context, s *Session, p *PlannedStage) error {
+	ownership := storage.CustomerCreated
+	if s.Opts.StorageDevice != "" {
+		ownership = storage.InstallerCreated
+	}
+	profiles := s.Opts.Profiles
+	if profiles == nil {
+		profiles = []string{}
+	}
+	record, err := json.MarshalIndent(api.BundleRecord{
+		BundleVersion:        s.Opts.Bundle,
+		Profiles:             profiles,
+		HATier:               s.Opts.HATier,
+		VolumeGroupOwnership: string(ownership),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	p.Documents = append(p.Documents, PlannedDocument{
+		Name:    "bundle-record.json",
+		Target:  "the control plane's bundle record for " + s.Opts.Name + " (with the install journal's terminal entries)",
+		Content: append(record, '\n'),
+	})
+	return nil
+}
+
+func renderVerify(_ context.Context, _ *Session, p *PlannedStage) error {
+	p.note("the five acceptance checks, each a convergence wait with a deadline from the bundle")
+	return nil
+}
+
+// WritePlan prints a plan as one stream: YAML documents separated by ---,
+// each preceded by a comment naming its stage and where it lands, so the
+// whole thing can be read top to bottom or split by a YAML tool.
+func WritePlan(w io.Writer, plan []PlannedStage) error {
+	var b strings.Builder
+	for i, stage := range plan {
+		fmt.Fprintf(&b, "# ==== [%2d/%d] %s\n", i+1, len(plan), stage.Name)
+		for _, n := range stage.Notes {
+			fmt.Fprintf(&b, "#   %s\n", n)
+		}
+		for _, doc := range stage.Documents {
+			fmt.Fprintf(&b, "---\n# %s -> %s\n", doc.Name, doc.Target)
+			b.Write(doc.Content)
+			if len(doc.Content) > 0 && doc.Content[len(doc.Content)-1] != '\n' {
+				b.WriteByte('\n')
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WritePlanDir writes a plan as one directory per stage, numbered in order,
+// with each document verbatim under its own name — the layout a change board
+// diffs between two plans. NOTES.txt in each carries the notes and where
+// every document lands, so the documents themselves stay byte-identical to
+// what the installer writes.
+func WritePlanDir(dir string, plan []PlannedStage) error {
+	for i, stage := range plan {
+		stageDir := filepath.Join(dir, fmt.Sprintf("%02d-%s", i+1, stage.Name))
+		if err := os.MkdirAll(stageDir, 0o755); err != nil {
+			return err
+		}
+		notes := append([]string(nil), stage.Notes...)
+		for _, doc := range stage.Documents {
+			notes = append(notes, doc.Name+" -> "+doc.Target)
+			if err := os.WriteFile(filepath.Join(stageDir, doc.Name), doc.Content, 0o644); err != nil {
+				return err
+			}
+		}
+		if len(notes) > 0 {
+			if err := os.WriteFile(filepath.Join(stageDir, "NOTES.txt"), []byte(strings.Join(notes, "\n")+"\n"), 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
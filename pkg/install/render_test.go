@@ -0,0 +1,193 @@
+package install_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kubenest.io/cli/pkg/component/day2"
+	"kubenest.io/cli/pkg/component/gatewayapi"
+	"kubenest.io/cli/pkg/install"
+	"kubenest.io/cli/pkg/k3s"
+	"kubenest.io/cli/pkg/manifest"
+)
+
+const renderBundle = `bundle: "1.0"
+core:
+  k3s: v1.33.1+k3s1
+  gateway-api: v1.3.0
+  traefik: 34.0.0
+  cert-manager: v1.17.2
+  openebs-lvm-localpv: 1.10.0
+  velero: 10.0.1
+  system-upgrade-controller: v0.15.2
+  kured: 5.6.1
+  kubenest-agent: 0.4.0
+backup:
+  object-store-plugin:
+    provider: aws
+    version: v1.14.2
+limits:
+  timeouts:
+    install-total: 30m
+    component-ready: 10m
+`
+
+// renderSession is a three-server install with a backup target, against a
+// release server that answers every download with a marker naming the path.
+func renderSession(t *testing.T) *install.Session {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "# release asset "+r.URL.Path+"\n")
+	}))
+	t.Cleanup(srv.Close)
+	oldGateway, oldDay2 := gatewayapi.ReleaseBaseURL, day2.ReleaseBaseURL
+	gatewayapi.ReleaseBaseURL, day2.ReleaseBaseURL = srv.URL, srv.URL
+	t.Cleanup(func() { gatewayapi.ReleaseBaseURL, day2.ReleaseBaseURL = oldGateway, oldDay2 })
+
+	m, err := manifest.Parse([]byte(renderBundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := install.Options{
+		Bundle: "1.0", Name: "prod-1", HATier: "ha",
+		Servers:       []string{"10.0.1.10", "10.0.1.11", "10.0.1.12"},
+		Agents:        []string{"10.0.1.20"},
+		StorageDevice: "/dev/nvme1n1",
+		BackupTarget:  "s3://kubenest-backups/prod-1?endpoint=s3.ap-south-1.amazonaws.com&region=ap-south-1",
+	}
+	return &install.Session{ID: "run-1", Opts: opts, Bundle: m, Out: io.Discard}
+}
+
+func renderedDocument(t *testing.T, plan []install.PlannedStage, stage, name string) install.PlannedDocument {
+	t.Helper()
+	for _, s := range plan {
+		if s.Name != stage {
+			continue
+		}
+		for _, d := range s.Documents {
+			if d.Name == name {
+				return d
+			}
+		}
+	}
+	t.Fatalf("stage %s renders no %s", stage, name)
+	return install.PlannedDocument{}
+}
+
+// Every stage appears, in order, and the documents are the ones the
+// installer writes — the same names under k3s.ManifestDir.
+func TestRenderCoversEveryStage(t *testing.T) {
+	t.Setenv("KUBENEST_BACKUP_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	plan, err := install.Render(context.Background(), renderSession(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != len(install.StageNames) {
+		t.Fatalf("plan renders %d stages, want %d", len(plan), len(install.StageNames))
+	}
+	for i, s := range plan {
+		if s.Name != install.StageNames[i] {
+			t.Errorf("stage %d is %q, want %q", i+1, s.Name, install.StageNames[i])
+		}
+		if len(s.Notes) == 0 && len(s.Documents) == 0 {
+			t.Errorf("stage %s renders nothing at all", s.Name)
+		}
+	}
+
+	for _, c := range []struct{ stage, name string }{
+		{install.StageNetworking, "kubenest-gateway-api.yaml"},
+		{install.StageNetworking, "kubenest-traefik.yaml"},
+		{install.StageCerts, "kubenest-cert-manager.yaml"},
+		{install.StageCerts, "kubenest-gateway-defaults.yaml"},
+		{install.StageStorage, "openebs-lvm-localpv.yaml"},
+		{install.StageStorage, "kubenest-storageclass.yaml"},
+		{install.StageDay2, "kubenest-system-upgrade-crd.yaml"},
+		{install.StageDay2, "kubenest-kured.yaml"},
+		{install.StageAgent, "kubenest-agent.yaml"},
+	} {
+		doc := renderedDocument(t, plan, c.stage, c.name)
+		if doc.Target != k3s.ManifestDir+"/"+c.name {
+			t.Errorf("%s lands at %q, want the auto-deploy directory", c.name, doc.Target)
+		}
+	}
+
+	joiner := renderedDocument(t, plan, install.StageK3sServer, "k3s-server-10.0.1.11.sh")
+	if !strings.Contains(string(joiner.Content), "--server https://10.0.1.10:6443 --token-file") ||
+		!strings.Contains(string(joiner.Content), "INSTALL_K3S_VERSION='v1.33.1+k3s1'") {
+		t.Errorf("a joining server must render the real installer command:\n%s", joiner.Content)
+	}
+	for _, flag := range k3s.ServerFlags() {
+		if !strings.Contains(string(joiner.Content), flag) {
+			t.Errorf("server command is missing canonical flag %s", flag)
+		}
+	}
+
+	location := renderedDocument(t, plan, install.StageBackup, "backup-storage-location.yaml")
+	if !strings.Contains(string(location.Content), "prod-1/workload") {
+		t.Errorf("the storage location must carry the workload prefix:\n%s", location.Content)
+	}
+	var backupNotes string
+	for _, s := range plan {
+		if s.Name == install.StageBackup {
+			backupNotes = strings.Join(s.Notes, "\n")
+		}
+	}
+	if !strings.Contains(backupNotes, "s3://kubenest-backups/prod-1/datastore") {
+		t.Errorf("the datastore snapshot location must be named:\n%s", backupNotes)
+	}
+	if !strings.Contains(backupNotes, "NOT in this environment") {
+		t.Errorf("missing credentials must be called out, because the install would refuse:\n%s", backupNotes)
+	}
+}
+
+// Nothing a plan renders may carry a credential, even when the environment
+// holds one: the plan is a document for a ticket.
+func TestRenderNeverCarriesCredentials(t *testing.T) {
+	t.Setenv("KUBENEST_BACKUP_ACCESS_KEY_ID", "AKIAPLANTESTKEY")
+	t.Setenv("KUBENEST_BACKUP_SECRET_ACCESS_KEY", "plan-test-secret-value")
+	plan, err := install.Render(context.Background(), renderSession(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all strings.Builder
+	if err := install.WritePlan(&all, plan); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"AKIAPLANTESTKEY", "plan-test-secret-value"} {
+		if strings.Contains(all.String(), secret) {
+			t.Errorf("the plan contains the credential %q", secret)
+		}
+	}
+	if !strings.Contains(all.String(), "never rendered") {
+		t.Error("the agent's credentials must be shown as placeholders")
+	}
+}
+
+func TestWritePlanDirIsOneDirectoryPerStage(t *testing.T) {
+	plan, err := install.Render(context.Background(), renderSession(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := install.WritePlanDir(dir, plan); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "05-platform-networking", "kubenest-traefik.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := renderedDocument(t, plan, install.StageNetworking, "kubenest-traefik.yaml")
+	if string(got) != string(want.Content) {
+		t.Error("a document on disk must be byte-identical to what the installer writes")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "13-verify", "NOTES.txt")); err != nil {
+		t.Errorf("every stage gets its directory, verify included: %v", err)
+	}
+}
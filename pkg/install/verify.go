@@ -115,8 +115,11 @@ func verifyComponentsRunning(ctx context.Context, s *Session) error {
 		// things that are not ours.
 		return day2KuredReady(ctx, server)
 	}
+	// Workloads become Ready when their pods do, so the pods are what is
+	// watched: those of the namespaces the probe reads, kured's included.
 	res, err := converge.Wait(ctx, probe, converge.Options{
 		Name: "core-components-running", Deadline: deadline, Reporter: s.Reporter,
+		Watch: k3s.WatchNamespaces(server, "pods", append(namespaces, day2.KuredNamespace)),
 	})
 	if err != nil {
 		return err
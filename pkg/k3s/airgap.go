@@ -0,0 +1,209 @@
+package k3s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"kubenest.io/cli/pkg/manifest"
+	"kubenest.io/cli/pkg/sshx"
+)
+
+// An offline install (`platform install --offline-bundle`) runs the same
+// installer against nodes with no egress. Everything the online path has a
+// node download — the installer script, the k3s binary, k3s's own system
+// images, the charts the helm-controller would fetch, the platform's images —
+// is pushed to the node over the SSH connection the installer already holds,
+// into the places k3s itself reads them from. Nothing else about the install
+// changes, which is the point: one install, two sources.
+
+// ReleaseBaseURL is k3s's release download base, for packing an offline
+// bundle. A variable so tests can point it at a local server.
+var ReleaseBaseURL = "https://github.com/k3s-io/k3s/releases/download"
+
+// InstallScriptURL is the installer script the online path pipes to sh.
+var InstallScriptURL = "https://get.k3s.io"
+
+// The on-node locations an offline install fills. ImageDir and ChartDir are
+// k3s's own: it imports every tarball in ImageDir into containerd when it
+// starts, and serves ChartDir to the helm-controller at
+// https://%{KUBERNETES_API}%/static/charts/ — which is how a HelmChart
+// installs with no chart repository reachable.
+const (
+	ImageDir         = "/var/lib/rancher/k3s/agent/images"
+	ChartDir         = "/var/lib/rancher/k3s/server/static/charts"
+	binaryPath       = "/usr/local/bin/k3s"
+	offlineScriptDir = "/var/lib/rancher/kubenest/airgap"
+)
+
+// The archive prefixes the k3s stages push wholesale: every chart to every
+// server, every image tarball to every node.
+const (
+	ChartsPrefix = "charts/"
+	ImagesPrefix = "images/"
+)
+
+// Artifacts are the k3s files an offline bundle carries for one
+// architecture, named by the pinned version so an archive for another bundle
+// cannot satisfy this one.
+func Artifacts(bundle *manifest.Manifest, arch string) ([]manifest.Artifact, error) {
+	version, err := bundle.Core.Version("k3s")
+	if err != nil {
+		return nil, err
+	}
+	binary, images, err := releaseAssets(arch)
+	if err != nil {
+		return nil, err
+	}
+	base := ReleaseBaseURL + "/" + url.PathEscape(version) + "/"
+	return []manifest.Artifact{
+		{Name: scriptArtifact(version), URL: InstallScriptURL},
+		{Name: binaryArtifact(version, arch), URL: base + binary},
+		{Name: imagesArtifact(version, arch), URL: base + images},
+	}, nil
+}
+
+func scriptArtifact(version string) string { return "k3s/" + version + "/install.sh" }
+
+func binaryArtifact(version, arch string) string { return "k3s/" + version + "/k3s-" + arch }
+
+func imagesArtifact(version, arch string) string {
+	return "k3s/" + version + "/k3s-airgap-images-" + arch + ".tar.zst"
+}
+
+// releaseAssets names k3s's release files for an architecture. amd64's binary
+// is the bare "k3s" — k3s's naming, not ours.
+func releaseAssets(arch string) (binary, images string, err error) {
+	switch arch {
+	case "amd64":
+		return "k3s", "k3s-airgap-images-amd64.tar.zst", nil
+	case "arm64":
+		return "k3s-arm64", "k3s-airgap-images-arm64.tar.zst", nil
+	default:
+		return "", "", fmt.Errorf("k3s publishes no offline build for architecture %q: the architectures are amd64 and arm64", arch)
+	}
+}
+
+// ChartArtifact is where an offline bundle carries a chart: Helm's own
+// packaged name, <chart>-<version>.tgz, which is what `helm pull` writes and
+// what the chart's last path element plus the pin produces for both a
+// repository chart and an oci:// one.
+func ChartArtifact(h HelmChart) string {
+	return ChartsPrefix + path.Base(h.Chart) + "-" + h.Version + ".tgz"
+}
+
+// Sourced is the chart as this bundle supplies it. Online that is the chart
+// unchanged. From an offline bundle it is the packaged chart the k3s-server
+// stage placed in ChartDir, served by the API server itself: no repository is
+// named, so the helm-controller has nothing to reach.
+//
+// Every component's Chart goes through here, which is also what keeps
+// --plan and the egress list honest: an offline chart has no Repo, and so
+// no repository to render or to probe.
+func Sourced(bundle *manifest.Manifest, h HelmChart) HelmChart {
+	if bundle == nil || bundle.Offline == nil {
+		return h
+	}
+	h.Chart = "https://%{KUBERNETES_API}%/static/charts/" + strings.TrimPrefix(ChartArtifact(h), ChartsPrefix)
+	h.Repo = ""
+	return h
+}
+
+// InputRunner is a Runner that can stream stdin. *sshx.Client implements it,
+// and an offline install needs it: the files it pushes are far past what fits
+// in one command line.
+type InputRunner interface {
+	Runner
+	RunInput(ctx context.Context, command string, stdin io.Reader) (sshx.Result, error)
+}
+
+// OfflineInstallerCommand is the installer command from an offline bundle:
+// the script pushed beside the binary, told not to download either.
+func OfflineInstallerCommand(version string, args []string) string {
+	return fmt.Sprintf("sudo -n INSTALL_K3S_SKIP_DOWNLOAD=true INSTALL_K3S_VERSION=%s sh %s/install.sh %s",
+		shellQuote(version), offlineScriptDir, strings.Join(args, " "))
+}
+
+// stageOffline pushes what this node needs from the offline bundle before the
+// installer runs: the script and binary, k3s's system images and the
+// platform's, and on a server every chart. The images must land before k3s
+// first starts, because that is when it imports them.
+func stageOffline(ctx context.Context, r Runner, bundle *manifest.Manifest, version string, server bool) error {
+	arch, err := NodeArch(ctx, r)
+	if err != nil {
+		return err
+	}
+	pushes := []struct {
+		name, dest, mode string
+	}{
+		{scriptArtifact(version), offlineScriptDir + "/install.sh", "0755"},
+		{binaryArtifact(version, arch), binaryPath, "0755"},
+		{imagesArtifact(version, arch), ImageDir + "/" + path.Base(imagesArtifact(version, arch)), "0644"},
+	}
+	for _, name := range bundle.Offline.Names() {
+		switch {
+		case strings.HasPrefix(name, ImagesPrefix):
+			pushes = append(pushes, struct{ name, dest, mode string }{name, ImageDir + "/" + path.Base(name), "0644"})
+		case server && strings.HasPrefix(name, ChartsPrefix):
+			pushes = append(pushes, struct{ name, dest, mode string }{name, ChartDir + "/" + path.Base(name), "0644"})
+		}
+	}
+	for _, p := range pushes {
+		if err := PushArtifact(ctx, r, bundle.Offline, p.name, p.dest, p.mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PushArtifact streams one archive member to dest on the node, root-owned
+// with the given mode. A file already there at the member's size is left
+// alone, so a resumed install does not send a gigabyte of images twice; a
+// push that died part-way leaves a file of the wrong size, which is sent
+// again.
+func PushArtifact(ctx context.Context, r Runner, from manifest.Artifacts, name, dest, mode string) error {
+	member, err := from.Open(name)
+	if err != nil {
+		return fmt.Errorf("the offline bundle has no %s: %w", name, err)
+	}
+	res, err := r.Run(ctx, "sudo -n stat -c %s "+shellQuote(dest)+" 2>/dev/null || true")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(res.Stdout) == strconv.FormatInt(member.Size(), 10) {
+		return nil
+	}
+	ir, ok := r.(InputRunner)
+	if !ok {
+		return fmt.Errorf("pushing %s needs a connection that streams, and this one does not", name)
+	}
+	res, err = ir.RunInput(ctx, "sudo -n install -D -m "+mode+" /dev/stdin "+shellQuote(dest), member)
+	if err != nil {
+		return fmt.Errorf("pushing %s to %s: %w", name, dest, err)
+	}
+	if res.ExitCode != 0 {
+		return fmt.Errorf("pushing %s to %s: exit %d: %s", name, dest, res.ExitCode, firstLine(res.Stderr))
+	}
+	return nil
+}
+
+// NodeArch is the node's architecture in release-asset terms. An
+// architecture with no k3s build is an error rather than a guess.
+func NodeArch(ctx context.Context, r Runner) (string, error) {
+	res, err := r.Run(ctx, "uname -m")
+	if err != nil {
+		return "", err
+	}
+	switch uname := strings.TrimSpace(res.Stdout); uname {
+	case "x86_64", "amd64":
+		return "amd64", nil
+	case "aarch64", "arm64":
+		return "arm64", nil
+	default:
+		return "", fmt.Errorf("no k3s build for architecture %q", uname)
+	}
+}
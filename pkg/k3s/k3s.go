@@ -21,6 +21,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"regexp"
 	"strings"
 
@@ -61,6 +62,49 @@ func WriteManifest(ctx context.Context, r Runner, name string, content []byte) e
 	return nil
 }
 
+// RemoveManifest takes <name>.yaml out of the auto-deploy directory and
+// deletes what it applied. Removing the file alone is not enough: k3s's
+// deploy controller stops reconciling a file that is gone but does not delete
+// the objects it made, so a HelmChart left behind keeps its release running.
+// Deleting the HelmChart is what runs helm-controller's uninstall job. The
+// objects are named by the same content the file was written with, so
+// whatever kind it held goes; absent objects are not an error, and neither
+// is a file that is already gone, so a re-run after a partial removal
+// finishes it.
+func RemoveManifest(ctx context.Context, r Runner, name string, content []byte) error {
+	if !manifestName.MatchString(name) {
+		return fmt.Errorf("manifest name %q must be lowercase alphanumerics and hyphens", name)
+	}
+	path := ManifestDir + "/" + name + ".yaml"
+	// The file first: a file that outlives its objects is re-applied by k3s
+	// within the minute, undoing the delete.
+	res, err := r.Run(ctx, "sudo -n rm -f "+path)
+	if err != nil {
+		return fmt.Errorf("remove %s: %w", path, err)
+	}
+	if res.ExitCode != 0 {
+		return fmt.Errorf("remove %s: exit %d: %s", path, res.ExitCode, firstLine(res.Stderr))
+	}
+	encoded := base64.StdEncoding.EncodeToString(content)
+	cmd := fmt.Sprintf("printf '%%s' %s | base64 -d | sudo -n k3s kubectl delete --ignore-not-found --wait=false -f -", encoded)
+	if res, err = r.Run(ctx, cmd); err != nil {
+		return fmt.Errorf("delete the objects of %s: %w", path, err)
+	}
+	if res.ExitCode != 0 {
+		return fmt.Errorf("delete the objects of %s: exit %d: %s", path, res.ExitCode, firstLine(res.Stderr))
+	}
+	return nil
+}
+
+// Document is one file the installer places in ManifestDir, as <Name>.yaml.
+type Document struct {
+	Name    string
+	Content []byte
+}
+
+// Path is where the document lands on a server node.
+func (d Document) Path() string { return ManifestDir + "/" + d.Name + ".yaml" }
+
 // HelmChart describes one chart install expressed as a k3s HelmChart custom
 // resource. Version is REQUIRED and comes from the bundle manifest's core
 // pins — there is no "latest" here.
@@ -131,6 +175,80 @@ func Kubectl(ctx context.Context, r Runner, args string) (string, error) {
 	return res.Stdout, nil
 }
 
+// Streamer is a Runner that can also hold a command open and stream its
+// output. *sshx.Client implements it; a runner that does not is polled.
+type Streamer interface {
+	Stream(ctx context.Context, command string, w io.Writer) error
+}
+
+// Watch is a converge.Watcher over `k3s kubectl get <args> --watch -o json`
+// on the server node: one SSH channel, held open, reporting a change for
+// every object kubectl writes. kubectl watches one resource type at a time,
+// so args names one, with whatever scope — -n, -A — the probe looks at.
+//
+// It returns nil when r cannot stream, and a nil Watcher is a check that
+// polls: the opt-in costs a caller nothing when it is handed a fake.
+func Watch(r Runner, args string) converge.Watcher {
+	return watch(r, args, nil)
+}
+
+// WatchNamespaces is Watch over one resource in several namespaces: a change
+// is reported only for an object in one of them. kubectl watches a single
+// namespace or all of them, and a stream per namespace would be an SSH
+// channel each, so this is the all-namespaces stream with everything else
+// dropped here. A check that reads the platform's namespaces is not woken by
+// every pod the workloads beside it churn.
+func WatchNamespaces(r Runner, resource string, namespaces []string) converge.Watcher {
+	keep := map[string]bool{}
+	for _, ns := range namespaces {
+		keep[ns] = true
+	}
+	return watch(r, resource+" -A", func(object json.RawMessage) bool {
+		var o struct {
+			Metadata struct {
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		// An object that cannot be read is reported: a missed change costs
+		// a check its promptness, an extra one only an observation.
+		return json.Unmarshal(object, &o) != nil || keep[o.Metadata.Namespace]
+	})
+}
+
+// watch is Watch, reporting only the objects keep accepts; nil keeps all.
+func watch(r Runner, args string, keep func(json.RawMessage) bool) converge.Watcher {
+	streamer, ok := r.(Streamer)
+	if !ok {
+		return nil
+	}
+	return func(ctx context.Context, changed func()) error {
+		pr, pw := io.Pipe()
+		streamed := make(chan error, 1)
+		go func() {
+			err := streamer.Stream(ctx, "sudo -n k3s kubectl get "+args+" --watch -o json", pw)
+			pw.CloseWithError(err)
+			streamed <- err
+		}()
+		// kubectl writes one JSON object per update, back to back. That
+		// one arrived is all the watcher reports: the probe reads the
+		// state itself.
+		dec := json.NewDecoder(pr)
+		for {
+			var object json.RawMessage
+			if err := dec.Decode(&object); err != nil {
+				pr.CloseWithError(err)
+				if streamErr := <-streamed; streamErr != nil {
+					return fmt.Errorf("watch %s: %w", args, streamErr)
+				}
+				return fmt.Errorf("watch %s: %w", args, err)
+			}
+			if keep == nil || keep(object) {
+				changed()
+			}
+		}
+	}
+}
+
 // podList is the slice of `kubectl get pods -o json` the ready check reads.
 type podList struct {
 	Items []struct {
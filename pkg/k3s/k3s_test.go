@@ -3,6 +3,8 @@ package k3s
 import (
 	"context"
 	"encoding/base64"
+	"errors"
+	"io"
 	"regexp"
 	"strings"
 	"testing"
@@ -204,3 +206,64 @@ func TestKubectlErrorCarriesStderr(t *testing.T) {
 		t.Fatalf("err = %v, want stderr's first line in it", err)
 	}
 }
+
+// streamingRunner writes out to the stream and ends it with err.
+type streamingRunner struct {
+	fakeRunner
+	command string
+	out     string
+	err     error
+}
+
+func (s *streamingRunner) Stream(_ context.Context, command string, w io.Writer) error {
+	s.command = command
+	if _, err := io.WriteString(w, s.out); err != nil {
+		return err
+	}
+	return s.err
+}
+
+// kubectl's watch output is pretty-printed objects back to back; each is one
+// change, and the stream ending is the watch ending.
+func TestWatchReportsEachObjectAsAChange(t *testing.T) {
+	r := &streamingRunner{
+		out: "{\n  \"kind\": \"Node\",\n  \"metadata\": {\"name\": \"a\"}\n}\n{\"kind\": \"Node\"}\n",
+		err: errors.New("k3s kubectl exited 1: connection refused"),
+	}
+	changes := 0
+	err := Watch(r, "nodes")(context.Background(), func() { changes++ })
+	if changes != 2 {
+		t.Errorf("changes = %d, want one per object", changes)
+	}
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("err = %v, want why the stream ended", err)
+	}
+	if r.command != "sudo -n k3s kubectl get nodes --watch -o json" {
+		t.Errorf("command = %q", r.command)
+	}
+
+	if Watch(&fakeRunner{t: t}, "nodes") != nil {
+		t.Error("a runner that cannot stream was given a watch: it must poll")
+	}
+}
+
+// A namespaced watch reports the namespaces it was given and nothing else,
+// from the one all-namespaces stream.
+func TestWatchNamespacesReportsOnlyItsNamespaces(t *testing.T) {
+	r := &streamingRunner{
+		out: `{"kind":"Pod","metadata":{"name":"traefik-1","namespace":"traefik"}}
+{"kind":"Pod","metadata":{"name":"shop-1","namespace":"shop"}}
+{"kind":"Pod","metadata":{"name":"shop-2","namespace":"shop"}}
+{"kind":"Pod","metadata":{"name":"longhorn-1","namespace":"longhorn-system"}}
+`,
+		err: errors.New("k3s kubectl exited 1: connection refused"),
+	}
+	changes := 0
+	_ = WatchNamespaces(r, "pods", []string{"traefik", "longhorn-system"})(context.Background(), func() { changes++ })
+	if changes != 2 {
+		t.Errorf("changes = %d, want the two platform pods only", changes)
+	}
+	if r.command != "sudo -n k3s kubectl get pods -A --watch -o json" {
+		t.Errorf("command = %q", r.command)
+	}
+}
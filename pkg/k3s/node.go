@@ -0,0 +1,182 @@
+package k3s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/manifest"
+)
+
+// The operations below change a running cluster's membership one node at a
+// time: `platform remove-node` and the upgrade's disruption gate. Each one is
+// a kubectl call on a server that is NOT the node being changed, so a caller
+// must never pass the departing node's own connection.
+
+// NodeNames maps every node's InternalIP to its Node object's name. The
+// operator names hosts by the address they gave the installer; Kubernetes
+// names them by hostname, and the two are only joined here.
+func NodeNames(ctx context.Context, r Runner) (map[string]string, error) {
+	out, err := Kubectl(ctx, r, "get nodes -o json")
+	if err != nil {
+		return nil, err
+	}
+	var nodes nodeList
+	if err := json.Unmarshal([]byte(out), &nodes); err != nil {
+		return nil, fmt.Errorf("parsing the node list: %w", err)
+	}
+	names := map[string]string{}
+	for _, n := range nodes.Items {
+		for _, a := range n.Status.Addresses {
+			if a.Type == "InternalIP" {
+				names[a.Address] = n.Metadata.Name
+			}
+		}
+	}
+	return names, nil
+}
+
+// ReadyNodes maps every node's InternalIP to whether its Ready condition is
+// True now: one sample, for a refusal that must be decided before anything
+// changes, never for a wait.
+func ReadyNodes(ctx context.Context, r Runner) (map[string]bool, error) {
+	out, err := Kubectl(ctx, r, "get nodes -o json")
+	if err != nil {
+		return nil, err
+	}
+	var nodes nodeList
+	if err := json.Unmarshal([]byte(out), &nodes); err != nil {
+		return nil, fmt.Errorf("parsing the node list: %w", err)
+	}
+	ready := map[string]bool{}
+	for _, n := range nodes.Items {
+		isReady := false
+		for _, c := range n.Status.Conditions {
+			if c.Type == "Ready" {
+				isReady = c.Status == "True"
+			}
+		}
+		for _, a := range n.Status.Addresses {
+			if a.Type == "InternalIP" {
+				ready[a.Address] = isReady
+			}
+		}
+	}
+	return ready, nil
+}
+
+// pdbList is what the disruption check reads.
+type pdbList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Status struct {
+			DisruptionsAllowed int32 `json:"disruptionsAllowed"`
+			CurrentHealthy     int32 `json:"currentHealthy"`
+			DesiredHealthy     int32 `json:"desiredHealthy"`
+			ExpectedPods       int32 `json:"expectedPods"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// BlockingDisruptionBudgets names every PodDisruptionBudget that would stop a
+// drain from ever finishing, and counts the budgets it read.
+//
+// The test is deliberately narrow: a budget currently allowing no
+// disruptions AND with no slack (every expected pod is needed) can never let
+// a pod move. Zero allowed right now is normal while something reschedules,
+// and is not a reason to refuse anything.
+func BlockingDisruptionBudgets(ctx context.Context, r Runner) (blocking []string, total int, err error) {
+	out, err := Kubectl(ctx, r, "get poddisruptionbudgets -A -o json")
+	if err != nil {
+		return nil, 0, err
+	}
+	var pdbs pdbList
+	if err := json.Unmarshal([]byte(out), &pdbs); err != nil {
+		return nil, 0, fmt.Errorf("unparsable pod disruption budgets: %w", err)
+	}
+	for _, p := range pdbs.Items {
+		if p.Status.DisruptionsAllowed > 0 {
+			continue
+		}
+		if p.Status.DesiredHealthy >= p.Status.ExpectedPods && p.Status.ExpectedPods > 0 {
+			blocking = append(blocking, fmt.Sprintf("%s/%s requires %d of %d pods, so no pod may ever be evicted",
+				p.Metadata.Namespace, p.Metadata.Name, p.Status.DesiredHealthy, p.Status.ExpectedPods))
+		}
+	}
+	return blocking, len(pdbs.Items), nil
+}
+
+// Drain cordons a node and evicts its pods through the eviction API, so every
+// PodDisruptionBudget is honoured exactly as system-upgrade-controller honours
+// it during an upgrade. Nothing is force-deleted: a pod that will not leave
+// is an operator's decision, and the timeout error says which pods stayed.
+//
+// The deadline is the bundle's upgrade-per-node budget, which is a drain plus
+// a restart; a drain alone that overruns it is stuck, not slow.
+func Drain(ctx context.Context, r Runner, bundle *manifest.Manifest, node string) error {
+	deadline, err := bundle.Limits.Timeouts.For("upgrade-per-node")
+	if err != nil {
+		return err
+	}
+	if _, err := Kubectl(ctx, r, "cordon "+shellQuote(node)); err != nil {
+		return fmt.Errorf("cordoning %s: %w", node, err)
+	}
+	_, err = Kubectl(ctx, r, "drain "+shellQuote(node)+
+		" --ignore-daemonsets --delete-emptydir-data --timeout="+strconv.Itoa(int(deadline.Seconds()))+"s")
+	if err != nil {
+		return fmt.Errorf("draining %s (it stays cordoned; `k3s kubectl uncordon %s` returns it to service): %w", node, node, err)
+	}
+	return nil
+}
+
+// The annotations k3s's etcd controller acts on. Setting the first on a
+// server's Node asks k3s to remove that member from the embedded etcd
+// cluster; k3s sets the second once it has.
+const (
+	etcdRemoveAnnotation  = "etcd.k3s.cattle.io/remove"
+	etcdRemovedAnnotation = "etcd.k3s.cattle.io/removed-node-name"
+)
+
+// RemoveEtcdMember takes a server out of the embedded etcd cluster and waits
+// until k3s confirms it. Deleting the Node first would leave a member that
+// still counts towards quorum and will never vote again — the one way to turn
+// a healthy three-member cluster into one a single further failure stops.
+func RemoveEtcdMember(ctx context.Context, r Runner, bundle *manifest.Manifest, node string, rep converge.Reporter) error {
+	deadline, err := bundle.Limits.Timeouts.For("component-ready")
+	if err != nil {
+		return err
+	}
+	if _, err := Kubectl(ctx, r, "annotate node "+shellQuote(node)+" "+etcdRemoveAnnotation+"=true --overwrite"); err != nil {
+		return fmt.Errorf("asking k3s to remove %s from etcd: %w", node, err)
+	}
+	jsonpath := "{.metadata.annotations." + strings.ReplaceAll(etcdRemovedAnnotation, ".", `\.`) + "}"
+	res, err := converge.Wait(ctx, func(ctx context.Context) (bool, converge.State, error) {
+		out, err := Kubectl(ctx, r, "get node "+shellQuote(node)+" -o jsonpath="+shellQuote(jsonpath))
+		if err != nil {
+			return false, converge.State{Object: "etcd member " + node, Status: "the API server is not answering"}, err
+		}
+		if strings.TrimSpace(out) != "" {
+			return true, converge.State{Object: "etcd member " + node, Status: "removed"}, nil
+		}
+		return false, converge.State{Object: "etcd member " + node, Status: "still a member"}, nil
+	}, converge.Options{Name: "etcd-member-removed", Deadline: deadline, Reporter: rep})
+	if err != nil {
+		return err
+	}
+	return res.Err()
+}
+
+// DeleteNode removes the Node object. A node already gone is not an error, so
+// an interrupted removal can be run again.
+func DeleteNode(ctx context.Context, r Runner, node string) error {
+	if _, err := Kubectl(ctx, r, "delete node "+shellQuote(node)+" --ignore-not-found"); err != nil {
+		return fmt.Errorf("deleting node %s: %w", node, err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package k3s_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"kubenest.io/cli/pkg/component/componenttest"
+	"kubenest.io/cli/pkg/k3s"
+	"kubenest.io/cli/pkg/sshx"
+)
+
+func TestNodeNamesJoinsAddressToName(t *testing.T) {
+	fake := &componenttest.FakeRunner{Respond: func(string) (sshx.Result, error) {
+		return sshx.Result{Stdout: `{"items":[{"metadata":{"name":"worker-3"},"status":{"addresses":[
+			{"type":"InternalIP","address":"10.0.1.13"},{"type":"Hostname","address":"worker-3"}]}}]}`}, nil
+	}}
+	names, err := k3s.NodeNames(context.Background(), fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names["10.0.1.13"] != "worker-3" || len(names) != 1 {
+		t.Errorf("want 10.0.1.13 -> worker-3 only, got %v", names)
+	}
+}
+
+func TestReadyNodesReadsEachNodesCondition(t *testing.T) {
+	fake := &componenttest.FakeRunner{Respond: func(string) (sshx.Result, error) {
+		return sshx.Result{Stdout: `{"items":[
+			{"metadata":{"name":"cp-1"},"status":{"conditions":[{"type":"Ready","status":"True"}],"addresses":[{"type":"InternalIP","address":"10.0.1.10"}]}},
+			{"metadata":{"name":"cp-2"},"status":{"conditions":[{"type":"Ready","status":"Unknown","reason":"NodeStatusUnknown"}],"addresses":[{"type":"InternalIP","address":"10.0.1.11"}]}}]}`}, nil
+	}}
+	ready, err := k3s.ReadyNodes(context.Background(), fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ready["10.0.1.10"] || ready["10.0.1.11"] || len(ready) != 2 {
+		t.Errorf("want 10.0.1.10 Ready and 10.0.1.11 not, got %v", ready)
+	}
+}
+
+// Only a budget that can NEVER allow an eviction blocks; one at zero while
+// a pod reschedules is ordinary.
+func TestOnlyAnImpossibleBudgetBlocksADrain(t *testing.T) {
+	fake := &componenttest.FakeRunner{Respond: func(string) (sshx.Result, error) {
+		return sshx.Result{Stdout: `{"items":[
+			{"metadata":{"namespace":"payments","name":"db"},"status":{"disruptionsAllowed":0,"desiredHealthy":3,"expectedPods":3}},
+			{"metadata":{"namespace":"web","name":"api"},"status":{"disruptionsAllowed":0,"desiredHealthy":2,"expectedPods":3}},
+			{"metadata":{"namespace":"web","name":"ui"},"status":{"disruptionsAllowed":1,"desiredHealthy":1,"expectedPods":2}}]}`}, nil
+	}}
+	blocking, total, err := k3s.BlockingDisruptionBudgets(context.Background(), fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(blocking) != 1 || !strings.Contains(blocking[0], "payments/db") {
+		t.Errorf("want payments/db alone out of 3, got %v of %d", blocking, total)
+	}
+}
+
+// The etcd member goes before the Node object, and the wait is on k3s's own
+// confirmation rather than on the annotation having been set.
+func TestRemoveEtcdMemberWaitsForK3s(t *testing.T) {
+	var polls int
+	fake := &componenttest.FakeRunner{Respond: func(cmd string) (sshx.Result, error) {
+		if strings.Contains(cmd, "jsonpath") {
+			polls++
+			if polls < 2 {
+				return sshx.Result{}, nil
+			}
+			return sshx.Result{Stdout: "server-2-1a2b3c"}, nil
+		}
+		return sshx.Result{}, nil
+	}}
+	m := bundle(t)
+	m.Limits.Timeouts["component-ready"] = m.Limits.Timeouts["node-ready"]
+	if err := k3s.RemoveEtcdMember(context.Background(), fake, m, "server-2", nil); err != nil {
+		t.Fatal(err)
+	}
+	cmds := fake.Commands()
+	if !strings.Contains(cmds[0], "annotate node 'server-2' etcd.k3s.cattle.io/remove=true") {
+		t.Errorf("the removal must be asked of k3s first, got %q", cmds[0])
+	}
+	if polls < 2 {
+		t.Errorf("returned after %d observation(s), before k3s confirmed", polls)
+	}
+}
@@ -0,0 +1,174 @@
+package k3s
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/manifest"
+)
+
+// Some sites reach the internet only through an HTTP proxy, usually an
+// authenticated one. Three things on a node then need it: the installer's
+// downloads, containerd's image pulls, and preflight's egress probe. The
+// first two read it from ProxyEnvFile — the installer by sourcing it, k3s
+// and the containerd it runs through a systemd drop-in — so the proxy is a
+// property of the node, written before k3s first starts, and every later
+// command that installs or restarts k3s finds it there rather than having to
+// be told again.
+
+// ProxyEnvFile holds HTTP_PROXY, HTTPS_PROXY and NO_PROXY for a node. Root
+// and 0600: the proxy URLs may carry credentials.
+const ProxyEnvFile = "/etc/rancher/kubenest-proxy.env"
+
+// proxyDropIns point both k3s units at ProxyEnvFile. Both are written on
+// every node: which one a node runs is decided by the installer afterwards,
+// and a drop-in for a unit that does not exist is inert.
+var proxyDropIns = []string{
+	"/etc/systemd/system/k3s.service.d/kubenest-proxy.conf",
+	"/etc/systemd/system/k3s-agent.service.d/kubenest-proxy.conf",
+}
+
+// ClusterNoProxy is what no cluster traffic may be sent through a proxy for:
+// loopback, k3s's default pod and service CIDRs, and in-cluster DNS names.
+// Node addresses are added per cluster.
+var ClusterNoProxy = []string{
+	"127.0.0.1", "localhost", "::1",
+	"10.42.0.0/16", "10.43.0.0/16",
+	".svc", ".cluster.local",
+}
+
+// Proxy is one node's proxy configuration.
+type Proxy struct {
+	// HTTP and HTTPS are proxy URLs, credentials included when the proxy
+	// needs them. Either may be empty.
+	HTTP, HTTPS string
+	// NoProxy is everything that bypasses the proxy, ClusterNoProxy and the
+	// node addresses included.
+	NoProxy []string
+}
+
+// Empty reports whether there is a proxy at all.
+func (p Proxy) Empty() bool { return p.HTTP == "" && p.HTTPS == "" }
+
+// ParseProxyURL reads --http-proxy or --https-proxy. Credentials in the URL
+// are refused: they would be on a command line, in shell history and in the
+// journal. They come from the environment instead.
+func ParseProxyURL(flag, raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("--%s %q is not a proxy URL: use http://proxy.example.com:3128", flag, raw)
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("--%s %q carries credentials: put them in KUBENEST_PROXY_USERNAME and KUBENEST_PROXY_PASSWORD instead, never on a command line", flag, u.Redacted())
+	}
+	return u, nil
+}
+
+// Env renders ProxyEnvFile. Each value is single-quoted, which both systemd's
+// EnvironmentFile and a POSIX shell read the same way; a quote inside a
+// credential is percent-encoded, as a URL allows, so it cannot end the value.
+// Both cases are written because curl reads only the lower-case http_proxy.
+func (p Proxy) Env() []byte {
+	var b strings.Builder
+	write := func(name, value string) {
+		if value == "" {
+			return
+		}
+		value = strings.ReplaceAll(value, "'", "%27")
+		fmt.Fprintf(&b, "%s='%s'\n%s='%s'\n", name, value, strings.ToLower(name), value)
+	}
+	write("HTTP_PROXY", p.HTTP)
+	write("HTTPS_PROXY", p.HTTPS)
+	write("NO_PROXY", strings.Join(p.NoProxy, ","))
+	return []byte(b.String())
+}
+
+// Redacted is Env with credentials removed, for `platform install --plan`.
+func (p Proxy) Redacted() []byte {
+	redact := func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return raw
+		}
+		return u.Redacted()
+	}
+	return Proxy{HTTP: redact(p.HTTP), HTTPS: redact(p.HTTPS), NoProxy: p.NoProxy}.Env()
+}
+
+// ExportScript is the same settings as shell exports, for a script streamed
+// on stdin — preflight's egress probe, which runs before anything is written
+// to the node.
+func (p Proxy) ExportScript() string {
+	env := strings.TrimSpace(string(p.Env()))
+	if env == "" {
+		return ""
+	}
+	return "set -a\n" + env + "\nset +a\n"
+}
+
+// ConfigureProxy writes ProxyEnvFile and the drop-ins that hand it to k3s,
+// reporting whether anything changed. The file crosses SSH on stdin, never
+// in a command line. A node already running k3s is restarted onto a changed
+// file, as for registries.yaml.
+func ConfigureProxy(ctx context.Context, r Runner, bundle *manifest.Manifest, p Proxy, rep converge.Reporter) (bool, error) {
+	ir, ok := r.(InputRunner)
+	if !ok {
+		return false, fmt.Errorf("writing %s refuses to put proxy credentials in a command line: the SSH runner must support stdin", ProxyEnvFile)
+	}
+	const tmp = "/run/kubenest/proxy.env"
+	dropIn := "[Service]\nEnvironmentFile=" + ProxyEnvFile + "\n"
+	var dropIns []string
+	for _, path := range proxyDropIns {
+		dropIns = append(dropIns, fmt.Sprintf("printf '%%s' %s | sudo -n install -D -m 0644 /dev/stdin %s",
+			shellQuote(dropIn), path))
+	}
+	command := fmt.Sprintf(
+		"sudo -n install -d -m 0700 /run/kubenest && sudo -n install -m 0600 /dev/stdin %[1]s && "+
+			"sudo -n install -d -m 0755 /etc/rancher && %[3]s && "+
+			"if sudo -n test -f %[2]s && sudo -n cmp -s %[1]s %[2]s; then printf unchanged; "+
+			"else sudo -n install -m 0600 %[1]s %[2]s && sudo -n systemctl daemon-reload && printf changed; fi; "+
+			"status=$?; sudo -n rm -f %[1]s; exit $status",
+		tmp, ProxyEnvFile, strings.Join(dropIns, " && "))
+	res, err := ir.RunInput(ctx, command, strings.NewReader(string(p.Env())))
+	if err != nil {
+		return false, fmt.Errorf("writing %s: %w", ProxyEnvFile, err)
+	}
+	if res.ExitCode != 0 {
+		return false, fmt.Errorf("writing %s: exit %d: %s", ProxyEnvFile, res.ExitCode, firstLine(res.Stderr))
+	}
+	if strings.TrimSpace(res.Stdout) != "changed" {
+		return false, nil
+	}
+	return true, restartIfRunning(ctx, r, bundle, ProxyEnvFile, rep)
+}
+
+// ProxiedInstallerCommand is InstallerCommand on a node behind a proxy. It
+// runs as root throughout, because sudo's env_reset would drop the proxy
+// between the download of the script and the script's own download of the
+// binary; the k3s installer then copies the proxy settings into the service
+// environment itself as well.
+func ProxiedInstallerCommand(version string, args []string) string {
+	inner := fmt.Sprintf("set -a && . %s && set +a && curl -sfL https://get.k3s.io | INSTALL_K3S_VERSION=%s sh -s - %s",
+		ProxyEnvFile, shellQuote(version), strings.Join(args, " "))
+	return "sudo -n sh -c " + shellQuote(inner)
+}
+
+// Fetch is a shell fragment that downloads url to dest on a node, through the
+// node's proxy when it has one. dest is inserted unquoted so the caller can
+// pass a shell expression such as "$tmp/file".
+func Fetch(url, dest string) string {
+	return fmt.Sprintf("if sudo -n test -f %[1]s; then sudo -n sh -c 'set -a && . %[1]s && set +a && curl -sfL \"$0\" -o \"$1\"' %[2]s %[3]s; else curl -sfL %[2]s -o %[3]s; fi",
+		ProxyEnvFile, shellQuote(url), dest)
+}
+
+// proxied reports whether the node has a proxy configured.
+func proxied(ctx context.Context, r Runner) (bool, error) {
+	res, err := r.Run(ctx, "sudo -n test -f "+ProxyEnvFile+" && echo proxied || true")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(res.Stdout) == "proxied", nil
+}
@@ -0,0 +1,367 @@
+package k3s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/manifest"
+)
+
+// RegistriesPath is where k3s reads containerd's registry configuration:
+// which endpoints to pull a registry's images from, and with what
+// credentials. containerd reads it once, when k3s starts, so a node must have
+// the file before its first start and must restart to see a change.
+//
+// It exists here because Docker Hub's anonymous pull limit has failed
+// installs part-way through a stage: a site with a pull-through mirror sends
+// every docker.io pull there instead, and a site with an authenticated
+// registry gets its pulls counted against an account rather than an address.
+const RegistriesPath = "/etc/rancher/k3s/registries.yaml"
+
+// Mirror sends one registry's pulls to an endpoint the site runs.
+type Mirror struct {
+	// Registry is the registry being mirrored as images name it: docker.io,
+	// ghcr.io, quay.io. "*" is every registry without a mirror of its own.
+	Registry string
+	// Endpoint is the mirror's URL, with its scheme.
+	Endpoint string
+}
+
+// ParseMirror reads --registry-mirror's REGISTRY=URL.
+func ParseMirror(raw string) (Mirror, error) {
+	registry, endpoint, ok := strings.Cut(raw, "=")
+	if !ok || registry == "" || endpoint == "" {
+		return Mirror{}, fmt.Errorf("--registry-mirror %q is not REGISTRY=URL: for example docker.io=https://mirror.example.com", raw)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return Mirror{}, fmt.Errorf("--registry-mirror %q: the mirror must be an http:// or https:// URL", raw)
+	}
+	if strings.Contains(registry, "/") {
+		return Mirror{}, fmt.Errorf("--registry-mirror %q: mirror a registry host such as docker.io, not a repository", raw)
+	}
+	return Mirror{Registry: registry, Endpoint: strings.TrimRight(endpoint, "/")}, nil
+}
+
+// String is the flag form, which is also how the journal records a mirror.
+func (m Mirror) String() string { return m.Registry + "=" + m.Endpoint }
+
+// Host is the mirror's host and port, which is what registries.yaml keys a
+// mirror's credentials by.
+func (m Mirror) Host() string {
+	u, err := url.Parse(m.Endpoint)
+	if err != nil {
+		return m.Endpoint
+	}
+	return u.Host
+}
+
+// RegistryAuth is one registry host's credentials. Neither field is ever
+// logged, journalled or rendered.
+type RegistryAuth struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// Registries is the whole of registries.yaml as the platform writes it.
+type Registries struct {
+	// Mirrors in the operator's order: several for one registry are tried in
+	// that order.
+	Mirrors []Mirror
+	// Auth is keyed by registry host: a mirror's, or an upstream registry's
+	// when the site pulls from it with an account.
+	Auth map[string]RegistryAuth
+}
+
+// Empty reports whether there is anything to write.
+func (r Registries) Empty() bool { return len(r.Mirrors) == 0 && len(r.Auth) == 0 }
+
+// MirrorsOf is the endpoints pulls for registry go to, in order: its own
+// mirrors, or the "*" mirrors when it has none.
+func (r Registries) MirrorsOf(registry string) []string {
+	var own, wildcard []string
+	for _, m := range r.Mirrors {
+		switch m.Registry {
+		case registry:
+			own = append(own, m.Endpoint)
+		case "*":
+			wildcard = append(wildcard, m.Endpoint)
+		}
+	}
+	if len(own) > 0 {
+		return own
+	}
+	return wildcard
+}
+
+// YAML renders the file k3s reads, credentials included. The result is a
+// secret and only ever travels on stdin.
+func (r Registries) YAML() ([]byte, error) { return r.render(false) }
+
+// Redacted renders the same file with every password replaced, for
+// `platform install --plan`.
+func (r Registries) Redacted() ([]byte, error) { return r.render(true) }
+
+type registriesFile struct {
+	Mirrors map[string]registryMirror `yaml:"mirrors,omitempty"`
+	Configs map[string]registryConfig `yaml:"configs,omitempty"`
+}
+
+type registryMirror struct {
+	Endpoint []string `yaml:"endpoint"`
+}
+
+type registryConfig struct {
+	Auth RegistryAuth `yaml:"auth"`
+}
+
+func (r Registries) render(redact bool) ([]byte, error) {
+	file := registriesFile{Mirrors: map[string]registryMirror{}, Configs: map[string]registryConfig{}}
+	for _, m := range r.Mirrors {
+		entry := file.Mirrors[m.Registry]
+		entry.Endpoint = append(entry.Endpoint, m.Endpoint)
+		file.Mirrors[m.Registry] = entry
+	}
+	hosts := make([]string, 0, len(r.Auth))
+	for host := range r.Auth {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		auth := r.Auth[host]
+		if redact {
+			auth.Password = "<from the environment or --registry-credentials; never rendered>"
+		}
+		file.Configs[host] = registryConfig{Auth: auth}
+	}
+	return yaml.Marshal(file)
+}
+
+// ConfigureRegistries writes registries.yaml to a node, root-only, and
+// reports whether it changed. The content crosses SSH on stdin, never in a
+// command line, because it carries the registry passwords.
+//
+// A node already running k3s is restarted when the file changed, and this
+// waits for the service to come back; whether the node is Ready again is the
+// caller's wait, through a server's API. A node without k3s yet needs
+// nothing more: the installer's first start reads the file.
+func ConfigureRegistries(ctx context.Context, r Runner, bundle *manifest.Manifest, content []byte, rep converge.Reporter) (bool, error) {
+	ir, ok := r.(InputRunner)
+	if !ok {
+		return false, fmt.Errorf("writing %s refuses to put registry credentials in a command line: the SSH runner must support stdin", RegistriesPath)
+	}
+	const tmp = "/run/kubenest/registries.yaml"
+	command := fmt.Sprintf(
+		"sudo -n install -d -m 0700 /run/kubenest && sudo -n install -m 0600 /dev/stdin %s && "+
+			"sudo -n install -d -m 0755 /etc/rancher/k3s && "+
+			"if sudo -n test -f %s && sudo -n cmp -s %s %s; then printf unchanged; "+
+			"else sudo -n install -m 0600 %s %s && printf changed; fi; "+
+			"status=$?; sudo -n rm -f %s; exit $status",
+		tmp, RegistriesPath, tmp, RegistriesPath, tmp, RegistriesPath, tmp)
+	res, err := ir.RunInput(ctx, command, strings.NewReader(string(content)))
+	if err != nil {
+		return false, fmt.Errorf("writing %s: %w", RegistriesPath, err)
+	}
+	if res.ExitCode != 0 {
+		return false, fmt.Errorf("writing %s: exit %d: %s", RegistriesPath, res.ExitCode, firstLine(res.Stderr))
+	}
+	if strings.TrimSpace(res.Stdout) != "changed" {
+		return false, nil
+	}
+	return true, restartIfRunning(ctx, r, bundle, RegistriesPath, rep)
+}
+
+// RemoveRegistries deletes a node's registries.yaml, restarting k3s if it was
+// there, so pulls go to the registries themselves again.
+func RemoveRegistries(ctx context.Context, r Runner, bundle *manifest.Manifest, rep converge.Reporter) (bool, error) {
+	res, err := r.Run(ctx, "if sudo -n test -f "+RegistriesPath+"; then sudo -n rm -f "+RegistriesPath+" && printf removed; fi")
+	if err != nil {
+		return false, fmt.Errorf("removing %s: %w", RegistriesPath, err)
+	}
+	if res.ExitCode != 0 {
+		return false, fmt.Errorf("removing %s: exit %d: %s", RegistriesPath, res.ExitCode, firstLine(res.Stderr))
+	}
+	if strings.TrimSpace(res.Stdout) != "removed" {
+		return false, nil
+	}
+	return true, restartIfRunning(ctx, r, bundle, RegistriesPath, rep)
+}
+
+// ReadRegistries returns a node's registries.yaml, or nil when it has none.
+// The content is a credential: it is read to be written to another node of
+// the same cluster and for nothing else.
+func ReadRegistries(ctx context.Context, r Runner) ([]byte, error) {
+	res, err := r.Run(ctx, "if sudo -n test -f "+RegistriesPath+"; then sudo -n cat "+RegistriesPath+"; fi")
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", RegistriesPath, err)
+	}
+	if res.ExitCode != 0 {
+		return nil, fmt.Errorf("reading %s: exit %d: %s", RegistriesPath, res.ExitCode, firstLine(res.Stderr))
+	}
+	if res.Stdout == "" {
+		return nil, nil
+	}
+	return []byte(res.Stdout), nil
+}
+
+// restartIfRunning restarts whichever k3s service the node runs — k3s on a
+// server, k3s-agent on an agent — and waits, within the node-ready deadline,
+// for systemd to report it active again. A node with neither is not yet
+// installed and is left alone. file is what changed, and what a failed
+// restart's error names.
+func restartIfRunning(ctx context.Context, r Runner, bundle *manifest.Manifest, file string, rep converge.Reporter) error {
+	service, err := installedService(ctx, r)
+	if err != nil || service == "" {
+		return err
+	}
+	res, err := r.Run(ctx, "sudo -n systemctl restart "+service)
+	if err != nil {
+		return fmt.Errorf("restarting %s for %s: %w", service, file, err)
+	}
+	if res.ExitCode != 0 {
+		return fmt.Errorf("restarting %s for %s: exit %d: %s", service, file, res.ExitCode, firstLine(res.Stderr))
+	}
+	deadline, err := bundle.Limits.Timeouts.For("node-ready")
+	if err != nil {
+		return err
+	}
+	active, err := converge.Wait(ctx, func(ctx context.Context) (bool, converge.State, error) {
+		res, err := r.Run(ctx, "sudo -n systemctl is-active "+service)
+		if err != nil {
+			return false, converge.State{Object: service, Status: "unobservable"}, err
+		}
+		status := strings.TrimSpace(res.Stdout)
+		return status == "active", converge.State{Object: service, Status: status}, nil
+	}, converge.Options{Name: service + "-restart", Deadline: deadline, Reporter: rep})
+	if err != nil {
+		return err
+	}
+	return active.Err()
+}
+
+// installedService is the k3s service the node runs: k3s on a server,
+// k3s-agent on an agent, and "" on a node with neither.
+func installedService(ctx context.Context, r Runner) (string, error) {
+	res, err := r.Run(ctx, "for s in k3s k3s-agent; do if sudo -n systemctl is-enabled $s >/dev/null 2>&1; then echo $s; break; fi; done")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(res.Stdout), nil
+}
+
+// Started is when the node's k3s service last started, by the node's own
+// clock — the clock its kubelet stamps heartbeats with, so the two compare
+// whatever this machine's clock says. It is rounded up to the next second,
+// systemd's precision: a heartbeat after it is the running process's, never
+// the one before the restart. A node without k3s has the zero time.
+func Started(ctx context.Context, r Runner) (time.Time, error) {
+	service, err := installedService(ctx, r)
+	if err != nil || service == "" {
+		return time.Time{}, err
+	}
+	res, err := r.Run(ctx, `date -u -d "$(systemctl show -p ExecMainStartTimestamp --value `+service+`)" +%s`)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reading when %s started: %w", service, err)
+	}
+	if res.ExitCode != 0 {
+		return time.Time{}, fmt.Errorf("reading when %s started: exit %d: %s", service, res.ExitCode, firstLine(res.Stderr))
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(res.Stdout), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reading when %s started: %q is not a time", service, strings.TrimSpace(res.Stdout))
+	}
+	return time.Unix(seconds+1, 0).UTC(), nil
+}
+
+// WaitRejoined waits, through server, until the node at address is back
+// from a restart at since (Started): Ready, with its kubelet's lease renewed
+// after since. Ready alone proves nothing straight after a restart — the
+// condition is the old kubelet's until the node controller doubts it, forty
+// seconds on — while a renewal after since can only be the new kubelet's,
+// and it renews every ten seconds.
+func WaitRejoined(ctx context.Context, server Runner, bundle *manifest.Manifest, address string, since time.Time, rep converge.Reporter) error {
+	deadline, err := bundle.Limits.Timeouts.For("node-ready")
+	if err != nil {
+		return err
+	}
+	res, err := converge.Wait(ctx, func(ctx context.Context) (bool, converge.State, error) {
+		object := "node " + address
+		out, err := Kubectl(ctx, server, "get nodes -o json")
+		if err != nil {
+			return false, converge.State{Object: object, Status: "the API server is not answering"}, err
+		}
+		var nodes nodeList
+		if err := json.Unmarshal([]byte(out), &nodes); err != nil {
+			return false, converge.State{Object: object, Status: "unparsable"}, err
+		}
+		name, ready := "", false
+		for _, n := range nodes.Items {
+			if !slices.ContainsFunc(n.Status.Addresses, func(a nodeAddress) bool {
+				return a.Type == "InternalIP" && a.Address == address
+			}) {
+				continue
+			}
+			name = n.Metadata.Name
+			for _, c := range n.Status.Conditions {
+				if c.Type == "Ready" {
+					ready = c.Status == "True"
+				}
+			}
+			break
+		}
+		if name == "" {
+			return false, converge.State{Object: object, Status: "not listed"}, nil
+		}
+		object = "node " + name
+		out, err = Kubectl(ctx, server, "get lease -n kube-node-lease "+shellQuote(name)+" -o jsonpath={.spec.renewTime}")
+		if err != nil {
+			return false, converge.State{Object: object, Status: "no lease yet"}, err
+		}
+		renewed, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(out))
+		switch {
+		case err != nil || !renewed.After(since):
+			return false, converge.State{Object: object, Status: "not heard from since its restart",
+				Detail: "last heartbeat " + strings.TrimSpace(out) + ", restarted " + since.Format(time.RFC3339)}, nil
+		case !ready:
+			return false, converge.State{Object: object, Status: "not Ready since its restart"}, nil
+		}
+		return true, converge.State{Object: object, Status: "Ready since its restart"}, nil
+	}, converge.Options{Name: "node-rejoined " + address, Deadline: deadline, Reporter: rep})
+	if err != nil {
+		return err
+	}
+	return res.Err()
+}
+
+// WaitEtcdHealthy waits until a server's own API server reports its etcd
+// member healthy. Run on the server itself, `/readyz/etcd` is that server's
+// member: a restarted server whose member has not rejoined would make the
+// next server's restart the one that loses quorum.
+func WaitEtcdHealthy(ctx context.Context, r Runner, bundle *manifest.Manifest, rep converge.Reporter) error {
+	deadline, err := bundle.Limits.Timeouts.For("node-ready")
+	if err != nil {
+		return err
+	}
+	res, err := converge.Wait(ctx, func(ctx context.Context) (bool, converge.State, error) {
+		out, err := Kubectl(ctx, r, "get --raw /readyz/etcd")
+		if err != nil {
+			return false, converge.State{Object: "etcd member", Status: "not healthy"}, err
+		}
+		status := strings.TrimSpace(out)
+		return status == "ok", converge.State{Object: "etcd member", Status: status}, nil
+	}, converge.Options{Name: "etcd-healthy", Deadline: deadline, Reporter: rep})
+	if err != nil {
+		return err
+	}
+	return res.Err()
+}
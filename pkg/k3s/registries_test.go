@@ -0,0 +1,176 @@
+package k3s_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"kubenest.io/cli/pkg/component/componenttest"
+	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/k3s"
+	"kubenest.io/cli/pkg/sshx"
+)
+
+// The file is k3s's registries.yaml schema exactly, mirrors in the order the
+// operator gave them, credentials keyed by the mirror's host.
+func TestRegistriesRenderK3sSchema(t *testing.T) {
+	var regs k3s.Registries
+	for _, raw := range []string{
+		"docker.io=https://mirror.example.com:5000/",
+		"docker.io=https://fallback.example.com",
+		"*=https://all.example.com",
+	} {
+		m, err := k3s.ParseMirror(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		regs.Mirrors = append(regs.Mirrors, m)
+	}
+	regs.Auth = map[string]k3s.RegistryAuth{"mirror.example.com:5000": {Username: "kubenest", Password: "s3cret"}}
+
+	out, err := regs.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file struct {
+		Mirrors map[string]struct {
+			Endpoint []string `yaml:"endpoint"`
+		} `yaml:"mirrors"`
+		Configs map[string]struct {
+			Auth map[string]string `yaml:"auth"`
+		} `yaml:"configs"`
+	}
+	if err := yaml.Unmarshal(out, &file); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(file.Mirrors["docker.io"].Endpoint, " "); got != "https://mirror.example.com:5000 https://fallback.example.com" {
+		t.Errorf("docker.io endpoints: %s", got)
+	}
+	if file.Configs["mirror.example.com:5000"].Auth["password"] != "s3cret" {
+		t.Errorf("credentials must be keyed by the mirror's host:\n%s", out)
+	}
+	if got := regs.MirrorsOf("ghcr.io"); len(got) != 1 || got[0] != "https://all.example.com" {
+		t.Errorf("a registry with no mirror of its own goes to *, got %v", got)
+	}
+
+	redacted, err := regs.Redacted()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(redacted), "s3cret") {
+		t.Errorf("the redacted file carries the password:\n%s", redacted)
+	}
+}
+
+func TestParseMirrorRefusesWhatK3sWouldMisread(t *testing.T) {
+	for _, raw := range []string{
+		"docker.io",
+		"docker.io=mirror.example.com",
+		"docker.io/library=https://mirror.example.com",
+		"=https://mirror.example.com",
+	} {
+		if _, err := k3s.ParseMirror(raw); err == nil {
+			t.Errorf("%q must be refused", raw)
+		}
+	}
+}
+
+// The file carries passwords, so it crosses on stdin; a changed file restarts
+// the node's k3s and an unchanged one does not, which is what makes a
+// re-run roll-out resume instead of restarting every node again.
+func TestConfigureRegistriesRestartsOnlyOnChange(t *testing.T) {
+	for _, c := range []struct {
+		result      string
+		wantRestart bool
+	}{
+		{"changed", true},
+		{"unchanged", false},
+	} {
+		fake := &streamingRunner{streamed: map[string]string{}}
+		fake.Respond = func(cmd string) (sshx.Result, error) {
+			switch {
+			case strings.Contains(cmd, "is-enabled"):
+				return sshx.Result{Stdout: "k3s-agent\n"}, nil
+			case strings.Contains(cmd, "is-active"):
+				return sshx.Result{Stdout: "active\n"}, nil
+			}
+			return sshx.Result{}, nil
+		}
+		fake.input = func(string) sshx.Result { return sshx.Result{Stdout: c.result} }
+
+		changed, err := k3s.ConfigureRegistries(context.Background(), fake, bundle(t), []byte("configs: {}\n"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != c.wantRestart {
+			t.Errorf("%s: changed = %v", c.result, changed)
+		}
+		for cmd, body := range fake.streamed {
+			if !strings.Contains(cmd, k3s.RegistriesPath) || body != "configs: {}\n" {
+				t.Errorf("want the file streamed to %s, got %q <- %q", k3s.RegistriesPath, cmd, body)
+			}
+		}
+		restarted := false
+		for _, cmd := range fake.Commands() {
+			if strings.Contains(cmd, "configs") {
+				t.Errorf("the file's content appeared in a command line: %s", cmd)
+			}
+			if cmd == "sudo -n systemctl restart k3s-agent" {
+				restarted = true
+			}
+		}
+		if restarted != c.wantRestart {
+			t.Errorf("%s: restarted = %v, want %v", c.result, restarted, c.wantRestart)
+		}
+	}
+}
+
+// A restarted node is back when its kubelet has renewed its lease since the
+// restart: the Ready condition it still shows may be the old kubelet's.
+func TestWaitRejoinedNeedsAHeartbeatAfterTheRestart(t *testing.T) {
+	node := &componenttest.FakeRunner{Respond: func(cmd string) (sshx.Result, error) {
+		switch {
+		case strings.Contains(cmd, "is-enabled"):
+			return sshx.Result{Stdout: "k3s-agent\n"}, nil
+		case strings.Contains(cmd, "ExecMainStartTimestamp --value k3s-agent"):
+			return sshx.Result{Stdout: "1792227600\n"}, nil
+		}
+		return sshx.Result{}, nil
+	}}
+	since, err := k3s.Started(context.Background(), node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Unix(1792227601, 0); !since.Equal(want) {
+		t.Fatalf("since = %s, want %s: rounded up past the restart's second", since, want)
+	}
+
+	leases := 0
+	server := &componenttest.FakeRunner{Respond: func(cmd string) (sshx.Result, error) {
+		switch {
+		case strings.Contains(cmd, "get nodes"):
+			return sshx.Result{Stdout: `{"items":[{"metadata":{"name":"worker-2"},"status":{
+				"conditions":[{"type":"Ready","status":"True"}],"addresses":[{"type":"InternalIP","address":"10.0.1.12"}]}}]}`}, nil
+		case strings.Contains(cmd, "get lease -n kube-node-lease 'worker-2'"):
+			leases++
+			// The first answer is the old kubelet's last renewal, in the
+			// same second the restart began.
+			if leases == 1 {
+				return sshx.Result{Stdout: since.Add(-time.Second / 2).Format(time.RFC3339Nano)}, nil
+			}
+			return sshx.Result{Stdout: since.Add(3 * time.Second).Format(time.RFC3339Nano)}, nil
+		}
+		return sshx.Result{}, nil
+	}}
+	var seen []string
+	rep := converge.ReporterFunc(func(e converge.Event) { seen = append(seen, e.State.Status) })
+	if err := k3s.WaitRejoined(context.Background(), server, bundle(t), "10.0.1.12", since, rep); err != nil {
+		t.Fatal(err)
+	}
+	if leases < 2 || len(seen) == 0 || seen[0] != "not heard from since its restart" {
+		t.Errorf("want the old heartbeat refused before the new one passed, saw %v after %d lease reads", seen, leases)
+	}
+}
@@ -99,7 +99,6 @@ func InstallServer(ctx context.Context, r Runner, bundle *manifest.Manifest, opt
 		return waitNodeReady(ctx, r, bundle, rep)
 	}
 
-	args := append([]string{"server"}, serverFlags...)
 	if opts.JoinURL != "" {
 		if opts.Token == "" {
 			return fmt.Errorf("joining %s needs the cluster token", opts.JoinURL)
@@ -107,10 +106,9 @@ func InstallServer(ctx context.Context, r Runner, bundle *manifest.Manifest, opt
 		if err := writeTokenFile(ctx, r, opts.Token); err != nil {
 			return err
 		}
-		args = append(args, "--server", opts.JoinURL, "--token-file", tokenFile)
 	}
 
-	if err := runInstaller(ctx, r, version, args); err != nil {
+	if err := runInstaller(ctx, r, bundle, version, ServerArgs(opts), true); err != nil {
 		return err
 	}
 	return waitNodeReady(ctx, r, bundle, rep)
@@ -141,15 +139,53 @@ func InstallAgent(ctx context.Context, r Runner, bundle *manifest.Manifest, serv
 	if err := writeTokenFile(ctx, r, token); err != nil {
 		return err
 	}
-	return runInstaller(ctx, r, version, []string{"agent", "--server", serverURL, "--token-file", tokenFile})
+	return runInstaller(ctx, r, bundle, version, AgentArgs(serverURL), false)
 }
 
-// runInstaller runs get.k3s.io with the pinned version. INSTALL_K3S_VERSION
-// is what pins it; there is no "latest" in a platform bundle.
-func runInstaller(ctx context.Context, r Runner, version string, args []string) error {
-	cmd := fmt.Sprintf("curl -sfL https://get.k3s.io | sudo -n INSTALL_K3S_VERSION=%s sh -s - %s",
+// ServerArgs is the installer argument list for one control-plane node. The
+// token is never among them: a joining server reads it from tokenFile.
+func ServerArgs(opts ServerOptions) []string {
+	args := append([]string{"server"}, serverFlags...)
+	if opts.JoinURL != "" {
+		args = append(args, "--server", opts.JoinURL, "--token-file", tokenFile)
+	}
+	return args
+}
+
+// AgentArgs is the installer argument list for a worker joining serverURL.
+func AgentArgs(serverURL string) []string {
+	return []string{"agent", "--server", serverURL, "--token-file", tokenFile}
+}
+
+// InstallerCommand is the exact command a node runs to install k3s at
+// version. Exported so `platform install --plan` shows the command the
+// installer runs rather than a description of it.
+func InstallerCommand(version string, args []string) string {
+	return fmt.Sprintf("curl -sfL https://get.k3s.io | sudo -n INSTALL_K3S_VERSION=%s sh -s - %s",
 		shellQuote(version), strings.Join(args, " "))
-	res, err := r.Run(ctx, cmd)
+}
+
+// TokenFile is where a joining node reads the cluster token from.
+func TokenFile() string { return tokenFile }
+
+// runInstaller runs get.k3s.io with the pinned version. INSTALL_K3S_VERSION
+// is what pins it; there is no "latest" in a platform bundle. From an offline
+// bundle the same script runs from the node's disk, after stageOffline has
+// pushed it there with the binary and images it would otherwise download. On
+// a node with a proxy configured, the download goes through it.
+func runInstaller(ctx context.Context, r Runner, bundle *manifest.Manifest, version string, args []string, server bool) error {
+	command := InstallerCommand(version, args)
+	if bundle.Offline != nil {
+		if err := stageOffline(ctx, r, bundle, version, server); err != nil {
+			return err
+		}
+		command = OfflineInstallerCommand(version, args)
+	} else if behind, err := proxied(ctx, r); err != nil {
+		return err
+	} else if behind {
+		command = ProxiedInstallerCommand(version, args)
+	}
+	res, err := r.Run(ctx, command)
 	if err != nil {
 		return fmt.Errorf("installing k3s %s: %w", version, err)
 	}
@@ -232,7 +268,8 @@ func waitNodeReady(ctx context.Context, r Runner, bundle *manifest.Manifest, rep
 }
 
 // WaitNodesReady waits until `count` nodes are Ready — stage 4's condition
-// once every agent has been joined.
+// once every agent has been joined. The node list is watched when r can
+// stream, so a node is seen Ready as it becomes Ready.
 func WaitNodesReady(ctx context.Context, r Runner, bundle *manifest.Manifest, count int, rep converge.Reporter) error {
 	deadline, err := bundle.Limits.Timeouts.For("node-ready")
 	if err != nil {
@@ -240,6 +277,7 @@ func WaitNodesReady(ctx context.Context, r Runner, bundle *manifest.Manifest, co
 	}
 	res, err := converge.Wait(ctx, nodesReadyProbe(r, count), converge.Options{
 		Name: "nodes-ready", Deadline: deadline, Reporter: rep,
+		Watch: Watch(r, "nodes"),
 	})
 	if err != nil {
 		return err
@@ -259,10 +297,16 @@ type nodeList struct {
 				Reason  string `json:"reason"`
 				Message string `json:"message"`
 			} `json:"conditions"`
+			Addresses []nodeAddress `json:"addresses"`
 		} `json:"status"`
 	} `json:"items"`
 }
 
+type nodeAddress struct {
+	Type    string `json:"type"`
+	Address string `json:"address"`
+}
+
 // nodesReadyProbe observes how many nodes are Ready, naming the first that is
 // not and why. An API server that is still starting is an observation, not a
 // verdict — the deadline decides.
@@ -2,6 +2,8 @@ package k3s_test
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"strings"
 	"testing"
 
@@ -221,3 +223,112 @@ func TestNodesReadyFailsWithTheStuckNodeNamed(t *testing.T) {
 		}
 	}
 }
+
+// memArchive is an offline bundle held in memory.
+type memArchive map[string]string
+
+func (a memArchive) Open(name string) (*io.SectionReader, error) {
+	content, ok := a[name]
+	if !ok {
+		return nil, fmt.Errorf("no %s", name)
+	}
+	return io.NewSectionReader(strings.NewReader(content), 0, int64(len(content))), nil
+}
+
+func (a memArchive) Names() []string {
+	var names []string
+	for name := range a {
+		names = append(names, name)
+	}
+	return names
+}
+
+// streamingRunner is a FakeRunner that also takes stdin, recording what was
+// streamed to which command.
+type streamingRunner struct {
+	componenttest.FakeRunner
+	streamed map[string]string
+	// input answers a streamed command; nil succeeds with no output.
+	input func(command string) sshx.Result
+}
+
+func (s *streamingRunner) RunInput(_ context.Context, command string, stdin io.Reader) (sshx.Result, error) {
+	body, err := io.ReadAll(stdin)
+	if err != nil {
+		return sshx.Result{}, err
+	}
+	s.streamed[command] = string(body)
+	if s.input != nil {
+		return s.input(command), nil
+	}
+	return sshx.Result{}, nil
+}
+
+// From an offline bundle nothing is downloaded: the installer, binary and
+// images are pushed over the connection first, a server gets every chart, and
+// the installer is told to skip its download.
+func TestOfflineServerInstallPushesEverythingThenSkipsTheDownload(t *testing.T) {
+	m := bundle(t)
+	m.Offline = memArchive{
+		"k3s/v1.35.7+k3s1/install.sh":                      "#!/bin/sh",
+		"k3s/v1.35.7+k3s1/k3s-arm64":                       "ELF",
+		"k3s/v1.35.7+k3s1/k3s-airgap-images-arm64.tar.zst": "images",
+		"images/docker.io_traefik_v3.3.2.tar":              "traefik",
+		"charts/traefik-34.0.0.tgz":                        "chart",
+		// Already on the node at this size, from an earlier attempt.
+		"charts/kured-5.6.1.tgz": "kured",
+	}
+	fake := &streamingRunner{streamed: map[string]string{}}
+	fake.Respond = func(cmd string) (sshx.Result, error) {
+		switch {
+		case cmd == "uname -m":
+			return sshx.Result{Stdout: "aarch64\n"}, nil
+		case strings.Contains(cmd, "stat -c %s '"+k3s.ChartDir+"/kured-5.6.1.tgz'"):
+			return sshx.Result{Stdout: "5\n"}, nil
+		case strings.Contains(cmd, "get nodes"):
+			return sshx.Result{Stdout: readyNode}, nil
+		}
+		return sshx.Result{}, nil
+	}
+	if err := k3s.InstallServer(context.Background(), fake, m, k3s.ServerOptions{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for dest, want := range map[string]string{
+		"/usr/local/bin/k3s":                              "ELF",
+		k3s.ImageDir + "/k3s-airgap-images-arm64.tar.zst": "images",
+		k3s.ImageDir + "/docker.io_traefik_v3.3.2.tar":    "traefik",
+		k3s.ChartDir + "/traefik-34.0.0.tgz":              "chart",
+	} {
+		found := false
+		for cmd, body := range fake.streamed {
+			if strings.HasSuffix(cmd, "'"+dest+"'") {
+				found = true
+				if body != want {
+					t.Errorf("%s received %q, want %q", dest, body, want)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("nothing was pushed to %s", dest)
+		}
+	}
+	for cmd := range fake.streamed {
+		if strings.Contains(cmd, "kured") {
+			t.Errorf("a file already on the node at its size was pushed again: %s", cmd)
+		}
+	}
+
+	var install string
+	for _, c := range fake.Commands() {
+		if strings.Contains(c, "get.k3s.io") {
+			t.Fatalf("an offline install reached for the internet: %s", c)
+		}
+		if strings.Contains(c, "INSTALL_K3S_SKIP_DOWNLOAD=true") {
+			install = c
+		}
+	}
+	if install == "" || !strings.Contains(install, "--cluster-init") {
+		t.Errorf("want the pushed installer run with the canonical flags, got %q", install)
+	}
+}
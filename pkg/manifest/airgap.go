@@ -0,0 +1,78 @@
+package manifest
+
+import (
+	"fmt"
+	"io"
+)
+
+// Airgap is what an offline bundle archive needs that the rest of the
+// manifest does not say: every container image the platform runs, and where
+// the agent's chart lives.
+//
+// Neither can be derived. The images are whatever the pinned charts' default
+// values name at the pinned versions, which only rendering every chart would
+// reveal; the agent's chart reference is minted per cluster when the
+// installer is online (kn-z6e4), and an archive is packed before there is a
+// cluster to mint for. So both are listed here, per bundle release, by whoever
+// publishes the release — the same way every pin is.
+type Airgap struct {
+	// Images are full references, registry included
+	// (docker.io/traefik:v3.3.2, ghcr.io/…@sha256:…). The k3s system images
+	// are not listed: k3s publishes them as its own airgap tarball.
+	Images []string `yaml:"images"`
+	// AgentChart is the kubenest-agent chart's OCI repository, without a
+	// tag — the version is core.kubenest-agent, as online.
+	AgentChart string `yaml:"agent-chart"`
+}
+
+// ImageList returns the images an offline bundle carries. A manifest that
+// lists none cannot be packed: an archive without the platform's images
+// installs a cluster whose every pod is ImagePullBackOff, which is a worse
+// place to find out than here.
+func (a Airgap) ImageList() ([]string, error) {
+	if len(a.Images) == 0 {
+		return nil, fmt.Errorf("bundle manifest does not list airgap.images: an offline bundle carries exactly the images the manifest names, and cannot guess them")
+	}
+	return a.Images, nil
+}
+
+// Chart returns the agent chart's repository, with the same refusal.
+func (a Airgap) Chart() (string, error) {
+	if a.AgentChart == "" {
+		return "", fmt.Errorf("bundle manifest does not name airgap.agent-chart: an offline bundle must carry the agent's chart, and online installs learn where it lives from the control plane")
+	}
+	return a.AgentChart, nil
+}
+
+// Artifact is one file an offline bundle carries: the name it has inside the
+// archive and the URL it is packed from. Each component package says what
+// its own artifacts are, so the archive cannot drift from what the installers
+// read — the same rule that builds the egress list from the installers' own
+// chart repositories.
+type Artifact struct {
+	Name string
+	URL  string
+}
+
+// Artifacts is an offline bundle's contents as the installers read them.
+// When Manifest.Offline is set, nothing is downloaded: the k3s binary, the
+// charts, the release manifests and the images all come from here and are
+// pushed to the nodes over SSH.
+type Artifacts interface {
+	// Open returns one member. A SectionReader, because the largest members
+	// are image tarballs of hundreds of megabytes: a retried stream must be
+	// able to seek back to the start rather than hold the file in memory.
+	Open(name string) (*io.SectionReader, error)
+	// Names lists every member, in archive order.
+	Names() []string
+}
+
+// ReadArtifact reads one member whole. For the small ones only — release
+// manifests, not images.
+func ReadArtifact(a Artifacts, name string) ([]byte, error) {
+	r, err := a.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
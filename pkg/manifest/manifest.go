@@ -2,8 +2,9 @@
 // platform release contains (see docs.kubenest.io/platform/bundle).
 //
 // This package parses only what the CLI consumes: the core pins, the tested
-// OS matrix, the HA tiers, the profile set, and limits — the deadlines for
-// every convergence check and the sizing thresholds preflight enforces.
+// OS matrix, the HA tiers, the profile set, the images an offline bundle
+// carries, and limits — the deadlines for every convergence check and the
+// sizing thresholds preflight enforces.
 // Limits are part of the bundle, not constants in the code: a missing timeout
 // or a missing floor is an error here, never a hardcoded default. The shipped
 // manifest carries the defaults; the code carries none.
@@ -41,6 +42,14 @@ type Manifest struct {
 	// Health is the thresholds fleet health is evaluated against (kn-j5s).
 	Health   Health   `yaml:"health"`
 	Profiles Profiles `yaml:"profiles"`
+	// Airgap lists what an offline bundle carries beyond the pins.
+	Airgap Airgap `yaml:"airgap"`
+
+	// Offline is set when the install reads its artifacts from an offline
+	// bundle archive rather than the internet. It is not part of the
+	// document: the same manifest installs either way, and every installer
+	// that downloads something checks this first.
+	Offline Artifacts `yaml:"-"`
 }
 
 // Components maps component name to pinned version.
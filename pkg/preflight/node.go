@@ -37,7 +37,12 @@ func checkNode(ctx context.Context, opts Options, node Node, rep *Report) {
 	checkExistingKubernetes(ctx, node, rep)
 	checkResources(ctx, opts, node, rep)
 	checkVolumeGroup(ctx, opts, node, rep)
-	checkEgress(ctx, opts, node, rep)
+	checkReplicatedStorage(ctx, opts, node, rep)
+	if opts.Offline != nil {
+		checkArchitecture(ctx, opts, node, rep)
+	} else {
+		checkEgress(ctx, opts, node, rep)
+	}
 }
 
 // checkOS refuses anything outside the bundle's tested matrix. Locking the
@@ -255,13 +260,95 @@ func checkVolumeGroup(ctx context.Context, opts Options, node Node, rep *Report)
 	rep.add(Result{Check: CheckVolumeGroup, Node: node.Address, Outcome: Pass, Detail: detail})
 }
 
+// checkReplicatedStorage is the requested profiles' host requirements on one
+// node: the dedicated device, the hugepage reservation and the kernel modules
+// their pinned components need. Every shortfall is reported in one result,
+// because they are all fixed on the host before a re-run.
+func checkReplicatedStorage(ctx context.Context, opts Options, node Node, rep *Report) {
+	var req storage.Requirement
+	var needing []string
+	for _, name := range opts.Profiles {
+		r := profileRequirement(opts, name)
+		if r.Zero() {
+			continue
+		}
+		needing = append(needing, name)
+		req = req.Merge(r)
+	}
+	if len(needing) == 0 {
+		return
+	}
+
+	var problems, fixes, found []string
+	switch {
+	case !req.Device:
+	case opts.ReplicatedDevice == "":
+		problems = append(problems, "no --replicated-device given")
+		fixes = append(fixes, "pass --replicated-device with a blank device present on every node, separate from kubenest-vg's")
+	case node.ReplicatedIsOurs:
+		found = append(found, opts.ReplicatedDevice+" pooled by this install — resuming")
+	default:
+		if err := storage.PreflightReplicatedDevice(ctx, node.Runner, opts.ReplicatedDevice, opts.StorageDevice); err != nil {
+			problems = append(problems, err.Error())
+			fixes = append(fixes, "--replicated-device must name a device with no partition table, filesystem or volume group, on every node")
+		} else {
+			found = append(found, opts.ReplicatedDevice+" is blank")
+		}
+	}
+
+	if req.HugePages > 0 {
+		// Hugepagesize is KiB, like MemTotal.
+		const script = `awk '/^HugePages_Total:/{print "pages=" $2} /^Hugepagesize:/{print "sizekb=" $2}' /proc/meminfo`
+		out, err := run(ctx, node.Runner, script)
+		reserved := manifest.Quantity(0)
+		if err == nil {
+			values := parseKeyValues(out)
+			reserved = manifest.Quantity(atoi64(values["pages"]) * atoi64(values["sizekb"]) * 1024)
+		}
+		if reserved < req.HugePages {
+			problems = append(problems, fmt.Sprintf("%s of hugepages reserved, need %s", reserved, req.HugePages))
+			fixes = append(fixes, fmt.Sprintf("reserve them before installing, so the kubelet sees them: `echo vm.nr_hugepages=%d | sudo tee /etc/sysctl.d/20-kubenest-hugepages.conf && sudo sysctl --system`",
+				req.HugePages.Bytes()/(2<<20)))
+		} else {
+			found = append(found, reserved.String()+" of hugepages")
+		}
+	}
+
+	for _, m := range req.KernelModules {
+		if _, err := run(ctx, node.Runner, "modinfo -F filename "+m); err != nil {
+			problems = append(problems, "kernel module "+m+" is not available")
+			fixes = append(fixes, "install the kernel's extra modules (`sudo apt-get install linux-modules-extra-$(uname -r)`) so "+m+" can load")
+		} else {
+			found = append(found, m+" loadable")
+		}
+	}
+
+	profiles := strings.Join(needing, ", ")
+	if len(problems) > 0 {
+		rep.add(Result{
+			Check: CheckReplicatedStorage, Node: node.Address, Outcome: Fail,
+			Detail: profiles + ": " + strings.Join(problems, "; "),
+			Fix:    strings.Join(fixes, "; "),
+		})
+		return
+	}
+	rep.add(Result{Check: CheckReplicatedStorage, Node: node.Address, Outcome: Pass, Detail: profiles + ": " + strings.Join(found, ", ")})
+}
+
 // checkEgress proves the node can reach the registries and chart repositories
-// the install pulls from. Air-gapped installs are not supported, and finding
-// that out at stage 5 rather than stage 1 is what preflight exists to prevent.
+// the install pulls from. An install that downloads needs egress, and finding
+// that out at stage 5 rather than stage 1 is what preflight exists to prevent;
+// a site without it installs from an offline bundle, where checkArchitecture
+// runs instead.
 //
 // ANY HTTP response counts as reachable: a 401 from a registry means egress
 // works and authentication is a different subject. Only a failed connection,
 // DNS or TLS handshake is a refusal.
+//
+// Behind a proxy every target is tested through it, as the install will
+// reach it. The proxy settings, credentials included, are streamed with the
+// script on stdin rather than put in a command line, and a proxy that refuses
+// the credentials is named as such rather than as missing egress.
 func checkEgress(ctx context.Context, opts Options, node Node, rep *Report) {
 	if len(opts.Egress) == 0 {
 		return
@@ -273,9 +360,15 @@ func checkEgress(ctx context.Context, opts Options, node Node, rep *Report) {
 		byURL[t.URL] = t.Name
 	}
 	script := "for u in " + strings.Join(urls, " ") + `; do ` +
-		`code=$(curl -s -o /dev/null -w '%{http_code}' --max-time 15 "$u" 2>/dev/null); ` +
+		`code=$(curl -s -o /dev/null -w '%{http_code} %{http_connect}' --max-time 15 "$u" 2>/dev/null); ` +
 		`echo "$u ${code:-000}"; done; true`
-	out, err := run(ctx, node.Runner, script)
+	var out string
+	var err error
+	if opts.Proxy.Empty() {
+		out, err = run(ctx, node.Runner, script)
+	} else {
+		out, err = runInput(ctx, node.Runner, "sh -s", opts.Proxy.ExportScript()+script+"\n")
+	}
 	if err != nil {
 		rep.add(Result{
 			Check: CheckEgress, Node: node.Address, Outcome: Fail,
@@ -285,27 +378,73 @@ func checkEgress(ctx context.Context, opts Options, node Node, rep *Report) {
 		return
 	}
 
-	var unreachable []string
+	var unreachable, refused []string
 	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
-		url, code, ok := strings.Cut(strings.TrimSpace(line), " ")
-		if !ok {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		url, code := fields[0], fields[1]
+		if code != "000" {
 			continue
 		}
-		if code == "000" {
+		if len(fields) > 2 && fields[2] == "407" {
+			refused = append(refused, fmt.Sprintf("%s (%s)", byURL[url], url))
+		} else {
 			unreachable = append(unreachable, fmt.Sprintf("%s (%s)", byURL[url], url))
 		}
 	}
+	if len(refused) > 0 {
+		rep.add(Result{
+			Check: CheckEgress, Node: node.Address, Outcome: Fail,
+			Detail: "the proxy demanded authentication (407) for " + strings.Join(refused, ", "),
+			Fix:    "set KUBENEST_PROXY_USERNAME and KUBENEST_PROXY_PASSWORD to credentials the proxy accepts",
+		})
+		return
+	}
 	if len(unreachable) > 0 {
+		via := "no HTTPS egress to "
+		if !opts.Proxy.Empty() {
+			via = "no HTTPS egress through the proxy to "
+		}
 		rep.add(Result{
 			Check: CheckEgress, Node: node.Address, Outcome: Fail,
-			Detail: "no HTTPS egress to " + strings.Join(unreachable, ", "),
-			Fix:    "both the install and the running cluster require outbound internet access — air-gapped installs are not supported. Open HTTPS egress to the container registries and Helm repositories above",
+			Detail: via + strings.Join(unreachable, ", "),
+			Fix:    "open HTTPS egress to the container registries and Helm repositories above, send image pulls to a mirror the nodes can reach with --registry-mirror, name a proxy that reaches them with --https-proxy, or install with no egress at all from an offline bundle (`kubenest bundle pack`, then `platform install --offline-bundle`)",
+		})
+		return
+	}
+	detail := fmt.Sprintf("HTTPS egress to %d registries and chart repositories", len(urls))
+	if !opts.Proxy.Empty() {
+		detail += ", through the proxy"
+	}
+	rep.add(Result{Check: CheckEgress, Node: node.Address, Outcome: Pass, Detail: detail})
+}
+
+// checkArchitecture is the offline bundle's per-node check: the archive holds
+// one architecture's binaries and images, and a node of another would install
+// a k3s that cannot execute.
+func checkArchitecture(ctx context.Context, opts Options, node Node, rep *Report) {
+	arch, err := k3s.NodeArch(ctx, node.Runner)
+	if err != nil {
+		rep.add(Result{
+			Check: CheckOfflineBundle, Node: node.Address, Outcome: Fail,
+			Detail: "could not determine the architecture: " + err.Error(),
+			Fix:    "the offline bundle supports amd64 and arm64 nodes",
+		})
+		return
+	}
+	if arch != opts.Offline.Arch() {
+		rep.add(Result{
+			Check: CheckOfflineBundle, Node: node.Address, Outcome: Fail,
+			Detail: fmt.Sprintf("the node is %s, the offline bundle was packed for %s", arch, opts.Offline.Arch()),
+			Fix:    fmt.Sprintf("pack the bundle for this node with `kubenest bundle pack --arch %s`; every node of a cluster must share one architecture", arch),
 		})
 		return
 	}
 	rep.add(Result{
-		Check: CheckEgress, Node: node.Address, Outcome: Pass,
-		Detail: fmt.Sprintf("HTTPS egress to %d registries and chart repositories", len(urls)),
+		Check: CheckOfflineBundle, Node: node.Address, Outcome: Pass,
+		Detail: arch + ", as packed; no egress required",
 	})
 }
 
@@ -326,6 +465,22 @@ func run(ctx context.Context, r k3s.Runner, command string) (string, error) {
 	return res.Stdout, nil
 }
 
+// runInput is run with a script on stdin, for one that carries a secret.
+func runInput(ctx context.Context, r k3s.Runner, command, input string) (string, error) {
+	ir, ok := r.(k3s.InputRunner)
+	if !ok {
+		return "", fmt.Errorf("the SSH runner must support stdin to carry the proxy credentials")
+	}
+	res, err := ir.RunInput(ctx, command, strings.NewReader(input))
+	if err != nil {
+		return "", err
+	}
+	if res.ExitCode != 0 {
+		return "", fmt.Errorf("exit %d: %s", res.ExitCode, firstLine(strings.TrimSpace(res.Stderr)))
+	}
+	return res.Stdout, nil
+}
+
 func parseKeyValues(out string) map[string]string {
 	values := map[string]string{}
 	for _, line := range strings.Split(out, "\n") {
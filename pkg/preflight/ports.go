@@ -77,6 +77,85 @@ func checkPorts(ctx context.Context, opts Options, rep *Report) {
 	}
 }
 
+// checkJoinPorts is checkPorts for an add-node: every path between a joining
+// node and the cluster, in both directions, and none of the paths between
+// existing peers — those have been carrying traffic since the install.
+//
+// A joining node is probed exactly as at install, from every peer. An
+// existing peer is probed on its TCP ports only, from the joining nodes: k3s
+// already holds 6443 and 10250 there, and connecting to the real service is
+// the proof. Its UDP overlay port is held by flannel, which does not answer a
+// probe datagram, so the only overlay direction preflight can prove is
+// peer-to-joiner; the reverse is a cluster that is already running, not a
+// host this command may stop a service on to find out.
+//
+// peers must carry ExistingK3sIsOurs, which is what stops the probe waiting
+// for k3s's own ports to come free afterwards.
+func checkJoinPorts(ctx context.Context, opts Options, peers []Node, rep *Report) {
+	var joining, existing []Node
+	for _, n := range opts.Nodes {
+		if n.Runner != nil && n.DialErr == nil {
+			joining = append(joining, n)
+		}
+	}
+	for _, n := range peers {
+		if n.Runner != nil && n.DialErr == nil {
+			existing = append(existing, n)
+		}
+	}
+	if len(joining) == 0 {
+		// Every joining node already failed SSH reachability, which says
+		// all there is to say.
+		return
+	}
+	if len(existing) == 0 {
+		rep.add(Result{
+			Check: CheckPorts, Outcome: Fail,
+			Detail: "no existing cluster node could be reached to probe from",
+			Fix:    "the joining node must reach a server on 6443; check --ssh-user and --ssh-key reach the cluster's nodes too",
+		})
+		return
+	}
+
+	all := append(append([]Node(nil), existing...), joining...)
+	for _, target := range joining {
+		specs := portsFor(opts.HATier, target)
+		if len(specs) == 0 {
+			continue
+		}
+		if targetPeers := peersOf(all, target, specs); len(targetPeers) > 0 {
+			probeOneTarget(ctx, opts, target, targetPeers, specs, rep)
+		}
+	}
+	var joiningServers []Node
+	for _, n := range joining {
+		if n.Role == "server" {
+			joiningServers = append(joiningServers, n)
+		}
+	}
+	for _, target := range existing {
+		var specs, etcd []portSpec
+		for _, spec := range portsFor(opts.HATier, target) {
+			switch {
+			case spec.Proto != "tcp":
+			case spec.HAOnly:
+				etcd = append(etcd, spec)
+			default:
+				specs = append(specs, spec)
+			}
+		}
+		if len(specs) > 0 {
+			probeOneTarget(ctx, opts, target, joining, specs, rep)
+		}
+		// A server joining the embedded etcd reaches the existing members
+		// on the client and peer ports; k3s has served both on the node
+		// address since --cluster-init, on every tier.
+		if len(etcd) > 0 && len(joiningServers) > 0 {
+			probeOneTarget(ctx, opts, target, joiningServers, etcd, rep)
+		}
+	}
+}
+
 // portsFor is which ports must be open ON this node, given its role and the
 // tier.
 func portsFor(tier string, target Node) []portSpec {
@@ -194,6 +273,11 @@ func probeOneTarget(ctx context.Context, opts Options, target Node, peers []Node
 // free, and the next thing to want these ports is k3s itself.
 func stopListeners(ctx context.Context, target Node, specs []portSpec) {
 	_, _ = run(ctx, target.Runner, "pkill -f "+shellQuote(listenerMarker)+" || true")
+	if target.ExistingK3sIsOurs {
+		// k3s itself holds these ports and will go on holding them; there
+		// is nothing to wait for, only the loop's whole budget to waste.
+		return
+	}
 
 	var tcpPorts []string
 	for _, s := range specs {
@@ -22,6 +22,7 @@ import (
 
 	"kubenest.io/cli/pkg/k3s"
 	"kubenest.io/cli/pkg/manifest"
+	"kubenest.io/cli/pkg/storage"
 )
 
 // Check names, exactly as install.mdx's table names them. A check that fails
@@ -38,6 +39,14 @@ const (
 	CheckResources    = "Host resources"
 	CheckNodeCount    = "Node count"
 	CheckBundle       = "Bundle availability"
+	// CheckOfflineBundle replaces CheckEgress on an install from an offline
+	// bundle: the archive must match the manifest's pins, and every node
+	// must be the architecture it was packed for.
+	CheckOfflineBundle = "Offline bundle"
+	// CheckReplicatedStorage is what the replicated-storage profile's engine
+	// asks of every node beyond core: a dedicated device, hugepages and
+	// kernel modules. It runs only when a requested profile needs them.
+	CheckReplicatedStorage = "Replicated storage"
 )
 
 // Outcome is one check's verdict. Warn exists for exactly one reason: the
@@ -53,16 +62,16 @@ const (
 
 // Result is one check against one node (or the whole request).
 type Result struct {
-	Check string
+	Check string `json:"check"`
 	// Node is the address checked, or empty for request-wide checks.
-	Node    string
-	Outcome Outcome
+	Node    string  `json:"node,omitempty"`
+	Outcome Outcome `json:"outcome"`
 	// Detail is what was observed — the measured value, the found binary,
 	// the refused device.
-	Detail string
+	Detail string `json:"detail"`
 	// Fix is what to do about it. A check that fails without one is a check
 	// that has told the operator they have a problem and nothing more.
-	Fix string
+	Fix string `json:"fix,omitempty"`
 }
 
 func (r Result) String() string {
@@ -134,6 +143,10 @@ type Node struct {
 	// the same reason: after stage 7, "--storage-device must be blank" would
 	// otherwise refuse the installer's own work on every resume.
 	StorageIsOurs bool
+	// ReplicatedIsOurs marks a node whose --replicated-device this install
+	// may already have pooled: the journal records the device before the
+	// pools are created, and a pooled device is not blank.
+	ReplicatedIsOurs bool
 }
 
 // EgressTarget is one URL the install needs to reach from the nodes. The list
@@ -170,9 +183,28 @@ type Options struct {
 	// StorageDevice is --storage-device: empty means the customer created
 	// kubenest-vg themselves, which is the default path.
 	StorageDevice string
-	Nodes         []Node
-	Egress        []EgressTarget
-	Catalog       Catalog
+	// ReplicatedDevice is --replicated-device: the blank device every node
+	// gives a profile whose components need one.
+	ReplicatedDevice string
+	Nodes            []Node
+	Egress           []EgressTarget
+	Catalog          Catalog
+	// Offline is the archive an air-gapped install reads from. When set, the
+	// egress check is not run — nothing is downloaded by the nodes — and the
+	// archive is verified in its place.
+	Offline OfflineBundle
+	// Proxy is the proxy the nodes reach Egress through, credentials
+	// included. Empty means direct egress.
+	Proxy k3s.Proxy
+}
+
+// OfflineBundle is an offline bundle archive, as far as preflight needs it.
+// *airgap.Archive satisfies it.
+type OfflineBundle interface {
+	// Arch is the node architecture the archive was packed for.
+	Arch() string
+	// Verify proves the archive carries exactly what the manifest pins.
+	Verify(m *manifest.Manifest) error
 }
 
 // Run executes every check against every node and returns the full report.
@@ -185,6 +217,7 @@ func Run(ctx context.Context, opts Options) (Report, error) {
 
 	checkControlPlaneAndBundle(ctx, opts, &rep)
 	checkNodeCount(opts, &rep)
+	checkOfflineBundle(opts, &rep)
 
 	for _, node := range opts.Nodes {
 		checkNode(ctx, opts, node, &rep)
@@ -194,6 +227,47 @@ func Run(ctx context.Context, opts Options) (Report, error) {
 	return rep, rep.Err()
 }
 
+// RunJoin is preflight for nodes joining a cluster that already exists
+// (`kubenest platform add-node` and `promote-ha`): the node-level checks against the NEW hosts
+// only, and the port check between them and the peers already in the
+// cluster. The request-wide checks are not run — the bundle, tier and node
+// count were settled by the install, and re-litigating them against a
+// running cluster could only refuse an add that is otherwise sound.
+//
+// opts.Nodes are the joining hosts. peers are the cluster's current nodes,
+// dialled, and are never checked themselves: they already run k3s, which is
+// exactly what checkNode would refuse.
+func RunJoin(ctx context.Context, opts Options, peers []Node) (Report, error) {
+	var rep Report
+	for _, node := range opts.Nodes {
+		checkNode(ctx, opts, node, &rep)
+	}
+	checkJoinPorts(ctx, opts, peers, &rep)
+	return rep, rep.Err()
+}
+
+// checkOfflineBundle proves the archive before anything is pushed from it. An
+// archive copied across an air gap has been through hands and media this
+// installer knows nothing about; a truncated image tarball found here is a
+// re-copy, and found at stage 5 it is a pod that can never start.
+func checkOfflineBundle(opts Options, rep *Report) {
+	if opts.Offline == nil || opts.Bundle == nil {
+		return
+	}
+	if err := opts.Offline.Verify(opts.Bundle); err != nil {
+		rep.add(Result{
+			Check: CheckOfflineBundle, Outcome: Fail,
+			Detail: err.Error(),
+			Fix:    fmt.Sprintf("pack a fresh archive with `kubenest bundle pack --bundle %s --arch %s` on a machine with internet access and copy it again", opts.Bundle.Bundle, opts.Offline.Arch()),
+		})
+		return
+	}
+	rep.add(Result{
+		Check: CheckOfflineBundle, Outcome: Pass,
+		Detail: fmt.Sprintf("every pinned artifact of bundle %s present for %s, every digest matching", opts.Bundle.Bundle, opts.Offline.Arch()),
+	})
+}
+
 // checkControlPlaneAndBundle covers two of the eleven: the control plane is
 // reachable and this CLI is logged in, and the requested bundle exists and
 // offers the requested tier and profiles.
@@ -264,8 +338,9 @@ func checkControlPlaneAndBundle(ctx context.Context, opts Options, rep *Report)
 }
 
 // checkNodeCount is the arithmetic the tier requires. It is checked here as
-// well as at the flag surface because the tier is permanent and installing
-// the wrong one is not a mistake anyone can undo cheaply.
+// well as at the flag surface because the tier only ever moves one way —
+// single-server can be promoted, ha cannot be undone — and installing the
+// wrong one is not a mistake anyone can undo cheaply.
 func checkNodeCount(opts Options, rep *Report) {
 	servers, agents := 0, 0
 	for _, n := range opts.Nodes {
@@ -302,12 +377,40 @@ func checkNodeCount(opts Options, rep *Report) {
 		})
 		return
 	}
+	// A profile can need more nodes than its tier: replicated storage keeps
+	// each volume on three nodes, which a single-server install without
+	// agents does not have.
+	for _, name := range opts.Profiles {
+		req := profileRequirement(opts, name)
+		if total := servers + agents; total < req.Nodes {
+			rep.add(Result{
+				Check: CheckNodeCount, Outcome: Fail,
+				Detail: fmt.Sprintf("the %s profile needs %d nodes, got %d", name, req.Nodes, total),
+				Fix:    fmt.Sprintf("add --agent addresses until there are %d nodes, or install without --profile %s", req.Nodes, name),
+			})
+			return
+		}
+	}
 	rep.add(Result{
 		Check: CheckNodeCount, Outcome: Pass,
 		Detail: fmt.Sprintf("%d server(s), %d agent(s) for the %s tier", servers, agents, opts.HATier),
 	})
 }
 
+// profileRequirement is what a requested profile's pinned components ask of
+// the hosts. A profile the bundle does not offer asks nothing here; the
+// bundle check refuses it.
+func profileRequirement(opts Options, name string) storage.Requirement {
+	if opts.Bundle == nil {
+		return storage.Requirement{}
+	}
+	prof, err := opts.Bundle.Profiles.Get(name)
+	if err != nil {
+		return storage.Requirement{}
+	}
+	return storage.ProfileRequirement(prof)
+}
+
 func contains(haystack []string, want string) bool {
 	for _, h := range haystack {
 		if h == want {
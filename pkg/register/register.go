@@ -151,6 +151,17 @@ func EnsureCluster(
 	return raced, true, nil
 }
 
+// FindCluster returns the cluster with this name in the resolved org, or nil
+// when none is registered. Unlike EnsureCluster it never creates one: it is
+// for reading what a cluster already has, before anything is decided.
+func FindCluster(ctx context.Context, client API, org, name string) (*api.Cluster, error) {
+	resolved, err := ResolveOrg(ctx, client, org)
+	if err != nil {
+		return nil, err
+	}
+	return findByName(ctx, client, resolved.ID, name)
+}
+
 func findByName(ctx context.Context, client API, orgID, name string) (*api.Cluster, error) {
 	clusters, err := client.ListOrgClusters(ctx, orgID)
 	if err != nil {
@@ -17,11 +17,14 @@ import (
 	"os/user"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/kevinburke/ssh_config"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
+
+	"kubenest.io/cli/pkg/trace"
 )
 
 // Options controls how a target host is resolved and authenticated.
@@ -108,13 +111,17 @@ func Resolve(host string, opts Options) (*Endpoint, error) {
 
 	// Authentication, in precedence order. All available sources are offered;
 	// the SSH handshake tries them in order.
-	if opts.KeyPath != "" {
-		m, err := keyFileAuth(opts.KeyPath, opts.Passphrase)
+	// A shell expands ~ in `--ssh-key ~/.ssh/k` but not in `--ssh-key=~/.ssh/k`,
+	// so the second reaches here literally; it is expanded as ssh_config's
+	// IdentityFile is below.
+	keyPath := expandHome(opts.KeyPath)
+	if keyPath != "" {
+		m, err := keyFileAuth(keyPath, opts.Passphrase)
 		if err != nil {
 			return nil, err
 		}
 		ep.auth = append(ep.auth, m)
-		ep.AuthSources = append(ep.AuthSources, "key file "+opts.KeyPath)
+		ep.AuthSources = append(ep.AuthSources, "key file "+keyPath)
 	}
 
 	sock := opts.AgentSocket
@@ -131,7 +138,7 @@ func Resolve(host string, opts Options) (*Endpoint, error) {
 
 	if idf := cfgGet(cfg, host, "IdentityFile"); idf != "" && idf != "~/.ssh/identity" {
 		path := expandHome(idf)
-		if path != opts.KeyPath {
+		if path != keyPath {
 			if _, err := os.Stat(path); err == nil {
 				if m, err := keyFileAuth(path, opts.Passphrase); err == nil {
 					ep.auth = append(ep.auth, m)
@@ -282,7 +289,24 @@ func (c *Client) RunInput(ctx context.Context, command string, stdin io.Reader)
 	return c.run(ctx, command, stdin)
 }
 
-func (c *Client) run(ctx context.Context, command string, stdin io.Reader) (Result, error) {
+func (c *Client) run(ctx context.Context, command string, stdin io.Reader) (res Result, err error) {
+	// In a traced run every command is a span, named by its program and
+	// nothing else: a command line can carry a join token or a URL with a
+	// password in it, and a span leaves the site.
+	name := CommandName(command)
+	ctx, span := trace.StartKind(ctx, trace.KindClient, "ssh "+name,
+		trace.String("kubenest.node", c.Endpoint.HostName), trace.String("kubenest.command", name))
+	defer func() {
+		span.SetAttributes(trace.Int("kubenest.command.exit_code", res.ExitCode))
+		switch {
+		case err != nil:
+			span.Fail(err.Error())
+		case res.ExitCode != 0:
+			span.Fail("exit " + strconv.Itoa(res.ExitCode))
+		}
+		span.End()
+	}()
+
 	sess, err := c.conn.NewSession()
 	if err != nil {
 		return Result{}, err
@@ -318,8 +342,114 @@ func (c *Client) run(ctx context.Context, command string, stdin io.Reader) (Resu
 	}
 }
 
+// Stream executes a long-lived command — a kubectl watch — and copies its
+// stdout to w as it arrives, over one channel of the existing connection. It
+// returns when the command exits, when w refuses a write, or when ctx is
+// done; the last closes the channel, which ends the command on the host.
+//
+// Unlike Run, a command's exit is an error whatever its status: a stream the
+// caller wanted held open has ended, and the caller decides what that means.
+func (c *Client) Stream(ctx context.Context, command string, w io.Writer) (err error) {
+	name := CommandName(command)
+	ctx, span := trace.StartKind(ctx, trace.KindClient, "ssh "+name,
+		trace.String("kubenest.node", c.Endpoint.HostName), trace.String("kubenest.command", name),
+		trace.Bool("kubenest.command.stream", true))
+	defer func() {
+		if err != nil && ctx.Err() == nil {
+			span.Fail(err.Error())
+		}
+		span.End()
+	}()
+
+	sess, err := c.conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+	var stderr limitedBuffer
+	sess.Stderr = &stderr
+	stdout, err := sess.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := sess.Start(command); err != nil {
+		return err
+	}
+
+	copied := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(w, stdout)
+		copied <- err
+	}()
+	select {
+	case <-ctx.Done():
+		sess.Close()
+		<-copied
+		return ctx.Err()
+	case err := <-copied:
+		if err != nil {
+			sess.Close()
+			return err
+		}
+	}
+	err = sess.Wait()
+	detail, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+	var exitErr *ssh.ExitError
+	switch {
+	case errors.As(err, &exitErr) && detail != "":
+		return fmt.Errorf("%s exited %d: %s", name, exitErr.ExitStatus(), detail)
+	case errors.As(err, &exitErr):
+		return fmt.Errorf("%s exited %d", name, exitErr.ExitStatus())
+	case err != nil:
+		return err
+	}
+	return fmt.Errorf("%s ended", name)
+}
+
 func (c *Client) Close() error { return c.conn.Close() }
 
+// subcommands are the programs whose first argument says what a command did
+// — k3s kubectl, systemctl restart — and is never a value.
+var subcommands = map[string]bool{"k3s": true, "kubectl": true, "systemctl": true, "helm": true, "apt-get": true}
+
+// CommandName is what a command runs, without what it runs it on: the program,
+// past sudo, env and any variable assignments, and for a few programs its
+// subcommand. "sudo env 'A=b' k3s kubectl apply -f -" is "k3s kubectl". It is
+// for traces and logs that must name a command without carrying its
+// arguments.
+func CommandName(command string) string {
+	fields := strings.Fields(command)
+	for len(fields) > 0 {
+		f := strings.Trim(fields[0], "'\"")
+		if f == "sudo" || f == "env" || strings.HasPrefix(f, "-") || strings.Contains(f, "=") {
+			fields = fields[1:]
+			continue
+		}
+		break
+	}
+	if len(fields) == 0 {
+		return "command"
+	}
+	name := filepath.Base(clean(fields[0]))
+	if subcommands[name] && len(fields) > 1 && !strings.HasPrefix(fields[1], "-") {
+		if sub := clean(fields[1]); sub != "" && sub == fields[1] {
+			name += " " + sub
+		}
+	}
+	return name
+}
+
+// clean keeps what can appear in a program's name.
+func clean(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.', r == '/':
+			return r
+		}
+		return -1
+	}, s)
+}
+
 // limitedBuffer caps captured output at 8 MiB so a runaway remote command
 // cannot exhaust memory on the installer machine.
 type limitedBuffer struct {
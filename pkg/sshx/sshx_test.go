@@ -1,12 +1,14 @@
 package sshx
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -278,6 +280,11 @@ func startSSHServer(t *testing.T, authorized ssh.PublicKey) (addr string, hostKe
 								ch.Write([]byte("hello\n"))
 							case "false":
 								exit = 1
+							case "watch":
+								// Held open until the client closes the
+								// channel, as kubectl --watch is.
+								ch.Write([]byte("{\"kind\":\"Node\"}\n"))
+								io.Copy(io.Discard, ch)
 							case "wc -c":
 								// Consume streamed stdin fully; report bytes.
 								n, _ := io.Copy(io.Discard, ch)
@@ -395,6 +402,56 @@ func TestRunInputStreamsLargePayload(t *testing.T) {
 	}
 }
 
+// A stream is held open until the caller is done with it, and a command that
+// ends is a stream that ended, whatever its exit status.
+func TestStreamHoldsTheCommandOpenUntilCancelled(t *testing.T) {
+	pemBytes, pub := genKey(t)
+	keyPath := writeKey(t, pemBytes)
+	addr, _ := startSSHServer(t, pub)
+
+	host, portStr, _ := net.SplitHostPort(addr)
+	var port int
+	fmt.Sscanf(portStr, "%d", &port)
+
+	opts := Options{
+		User: "test", KeyPath: keyPath, Port: port,
+		ConfigPath:     filepath.Join(t.TempDir(), "config"),
+		KnownHostsPath: filepath.Join(t.TempDir(), "known_hosts"),
+		AgentSocket:    noAgent,
+	}
+	ep, err := Resolve(host, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := Dial(context.Background(), ep, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pr, pw := io.Pipe()
+	streamed := make(chan error, 1)
+	go func() { streamed <- client.Stream(ctx, "watch", pw) }()
+	line, err := bufio.NewReader(pr).ReadString('\n')
+	if err != nil || !strings.Contains(line, "Node") {
+		t.Fatalf("read %q, %v", line, err)
+	}
+	cancel()
+	go io.Copy(io.Discard, pr)
+	if err := <-streamed; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled stream returned %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := client.Stream(context.Background(), "echo hello", &out); err == nil || out.String() != "hello\n" {
+		t.Errorf("stream = %q, %v: want the output, then an error saying it ended", out.String(), err)
+	}
+	if err := client.Stream(context.Background(), "nonexistent", &out); err == nil || !strings.Contains(err.Error(), "exited 127: unknown command") {
+		t.Errorf("failed stream: %v", err)
+	}
+}
+
 func TestChangedHostKeyIsRefused(t *testing.T) {
 	pemBytes, pub := genKey(t)
 	keyPath := writeKey(t, pemBytes)
@@ -427,3 +484,23 @@ func TestChangedHostKeyIsRefused(t *testing.T) {
 		t.Errorf("dial with a changed host key must refuse loudly, got: %v", err)
 	}
 }
+
+// A traced command is named by what it runs, never by what it runs it on.
+func TestCommandNameKeepsOnlyTheProgram(t *testing.T) {
+	cases := map[string]string{
+		"sudo k3s kubectl apply -f -": "k3s kubectl",
+		"sudo env 'K3S_TOKEN=K10abc::server:s3cr3t' sh -c 'curl -sfL https://get.k3s.io | sh -'": "sh",
+		"env 'KUBENEST_STAGE=k3s-server' sh -c '/opt/site/bin/cmdb-register'":                    "sh",
+		"/usr/local/bin/k3s etcd-snapshot save":                                                  "k3s etcd-snapshot",
+		"systemctl restart k3s":                                                                  "systemctl restart",
+		"cat /var/lib/rancher/k3s/server/node-token":                                             "cat",
+		"sudo -n vgs --noheadings kubenest-vg":                                                   "vgs",
+		"kubectl get secret $(echo admin-token)":                                                 "kubectl get",
+		"":                                                                                       "command",
+	}
+	for command, want := range cases {
+		if got := CommandName(command); got != want {
+			t.Errorf("CommandName(%q) = %q, want %q", command, got, want)
+		}
+	}
+}
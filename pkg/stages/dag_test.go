@@ -0,0 +1,204 @@
+package stages_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kubenest.io/cli/pkg/stages"
+)
+
+// graph declares the install's dependencies on the test sequence: the
+// component stages after k3s-agents, certs after networking, backup after
+// storage, and everything from kubenest-agent on after all of them.
+func graph(table []stages.Stage) []stages.Stage {
+	after := map[string][]string{
+		stageNetworking: {stageK3sAgents},
+		stageCerts:      {stageNetworking},
+		stageStorage:    {stageK3sAgents},
+		stageBackup:     {stageStorage},
+		stageDay2:       {stageK3sAgents},
+	}
+	for i := range table {
+		table[i].After = after[table[i].Name]
+	}
+	return table
+}
+
+// ranLog records which stages ran, from whichever goroutine ran them.
+type ranLog struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (l *ranLog) add(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.names = append(l.names, name)
+}
+
+func (l *ranLog) has(name string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Contains(l.names, name)
+}
+
+// concurrent builds the test sequence with every Run recorded in log, and
+// runs[name] run after the recording where given.
+func concurrent(log *ranLog, runs map[string]stages.StageFunc) []stages.Stage {
+	var table []stages.Stage
+	for _, name := range stageNames {
+		table = append(table, stages.Stage{
+			Name:      name,
+			AlwaysRun: name == stagePreflight || name == stageRegister || name == stageVerify,
+			Run: func(ctx context.Context) error {
+				log.add(name)
+				if run := runs[name]; run != nil {
+					return run(ctx)
+				}
+				return nil
+			},
+		})
+	}
+	return graph(table)
+}
+
+// Networking and storage only meet if they are running at the same time: a
+// serial engine leaves the first waiting alone until it gives up.
+func TestIndependentStagesRunConcurrently(t *testing.T) {
+	rec := &recorder{}
+	s := newSession(t, rec)
+	meet := make(chan struct{})
+	rendezvous := func(ctx context.Context) error {
+		select {
+		case meet <- struct{}{}:
+			return nil
+		case <-meet:
+			return nil
+		case <-time.After(5 * time.Second):
+			return errors.New("ran alone: the other stage never started beside this one")
+		}
+	}
+	var log ranLog
+	res, err := stages.Execute(context.Background(), s, concurrent(&log, map[string]stages.StageFunc{
+		stageNetworking: rendezvous,
+		stageStorage:    rendezvous,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Ran, stageNames) {
+		t.Errorf("Ran = %v, want the sequence's order whatever the finishing order", res.Ran)
+	}
+	for _, e := range rec.events {
+		if want := stages.Index(concurrent(&log, nil), e.Stage); e.StageIndex != want {
+			t.Errorf("%s carries StageIndex %d, want its place in the sequence, %d", e.Stage, e.StageIndex, want)
+		}
+	}
+}
+
+// A stage starts only once what it is after has completed, and a stage that
+// declares nothing still waits for everything before it.
+func TestADependentStageWaitsForItsDependencies(t *testing.T) {
+	s := newSession(t, &recorder{})
+	completed := func(names ...string) stages.StageFunc {
+		return func(context.Context) error {
+			for _, name := range names {
+				if _, ok := s.journal.Completed(name); !ok {
+					return errors.New("started before " + name + " completed")
+				}
+			}
+			return nil
+		}
+	}
+	var log ranLog
+	_, err := stages.Execute(context.Background(), s, concurrent(&log, map[string]stages.StageFunc{
+		stageNetworking: completed(stageK3sServer, stageK3sAgents),
+		stageCerts:      completed(stageNetworking),
+		stageBackup:     completed(stageStorage),
+		stageAgent:      completed(stageNetworking, stageCerts, stageStorage, stageBackup, stageDay2),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Networking fails while storage is still running: storage is let finish and
+// completes, nothing that needed networking starts, the earliest failure is
+// the one reported, and the resume picks up only what did not complete.
+func TestAFailureLetsRunningStagesFinishAndStartsNothingNew(t *testing.T) {
+	s := newSession(t, &recorder{})
+	var log ranLog
+	_, err := stages.Execute(context.Background(), s, concurrent(&log, map[string]stages.StageFunc{
+		stageNetworking: func(context.Context) error { return errors.New("traefik is CrashLoopBackOff") },
+		stageDay2:       func(context.Context) error { return errors.New("kured is CrashLoopBackOff") },
+		stageStorage: func(context.Context) error {
+			// Networking's own failed entry: day2 fails too, and its
+			// failure may be the journal's last.
+			failed := func() bool {
+				for _, run := range s.journal.Runs() {
+					for _, step := range run.Steps {
+						if step.Stage == stageNetworking && step.Status == stages.StatusFailed {
+							return true
+						}
+					}
+				}
+				return false
+			}
+			for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+				if failed() {
+					return nil
+				}
+			}
+			return errors.New("networking never failed")
+		},
+	}))
+	var stageErr *stages.StageError
+	if !errors.As(err, &stageErr) || stageErr.Stage != stageNetworking {
+		t.Fatalf("err = %v, want the networking failure: it is the earlier stage", err)
+	}
+	if _, ok := s.journal.Completed(stageStorage); !ok {
+		t.Error("storage was abandoned rather than let finish")
+	}
+	for _, name := range []string{stageCerts, stageAgent} {
+		if log.has(name) {
+			t.Errorf("%s started after a failure", name)
+		}
+	}
+
+	var resumed ranLog
+	res, err := stages.Execute(context.Background(), s, concurrent(&resumed, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.has(stageStorage) || !slices.Contains(res.Skipped, stageStorage) {
+		t.Errorf("the resume re-ran storage: ran %v", res.Ran)
+	}
+	for _, name := range []string{stageNetworking, stageCerts, stageDay2, stageAgent} {
+		if !resumed.has(name) {
+			t.Errorf("the resume did not run %s", name)
+		}
+	}
+}
+
+// A dependency that is not an earlier stage is a broken plan, refused before
+// anything runs.
+func TestADependencyMustBeAnEarlierStage(t *testing.T) {
+	for name, after := range map[string]string{"unknown": "platform-dns", "later": stageDay2} {
+		s := newSession(t, &recorder{})
+		var log ranLog
+		table := concurrent(&log, nil)
+		table[stages.Index(table, stageCerts)-1].After = []string{after}
+		_, err := stages.Execute(context.Background(), s, table)
+		if err == nil || !strings.Contains(err.Error(), "not a stage before it") {
+			t.Errorf("%s: err = %v", name, err)
+		}
+		if len(s.journal.Entries) != 0 {
+			t.Errorf("%s: a refused plan wrote to the journal", name)
+		}
+	}
+}
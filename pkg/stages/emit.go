@@ -31,6 +31,12 @@ type Event struct {
 	// Summarising it turns a fix back into "install failed".
 	ReasonCode string
 	Message    string
+	// Attempt and Attempts number the run of a stage whose Retry policy
+	// allows more than one — attempt 2 of 3 — and are zero otherwise. The
+	// started event of a retry says in Message what the last attempt failed
+	// on.
+	Attempt  int
+	Attempts int
 }
 
 // Emitter publishes stage events to the control plane.
@@ -71,6 +77,12 @@ func (t TextEmitter) Emit(_ context.Context, e Event) error {
 	prefix := fmt.Sprintf("[%2d/%d] %-21s", e.StageIndex, e.StageTotal, e.Stage)
 	switch e.Status {
 	case StatusStarted:
+		// The first attempt looks like any other stage; only a retry is
+		// worth a line that says so.
+		if e.Attempt > 1 {
+			fmt.Fprintf(t.W, "%s ... (%s)\n", prefix, e.Message)
+			return nil
+		}
 		fmt.Fprintf(t.W, "%s ...\n", prefix)
 	case StatusCompleted:
 		if e.Message != "" {
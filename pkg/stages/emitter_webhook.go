@@ -0,0 +1,330 @@
+package stages
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Notification kinds: what an on-call channel hears about a run. They are a
+// run's milestones, not its stage transitions — thirteen messages per install
+// would train a channel to mute the one that matters.
+const (
+	NotifyStarted   = "started"
+	NotifyFailed    = "failed"
+	NotifyPaused    = "paused"
+	NotifyCompleted = "completed"
+)
+
+// NotifyKinds is every notification kind, in the order a run reaches them.
+var NotifyKinds = []string{NotifyStarted, NotifyFailed, NotifyPaused, NotifyCompleted}
+
+// Webhook payload formats.
+const (
+	FormatJSON  = "json"
+	FormatSlack = "slack"
+	FormatTeams = "teams"
+)
+
+// Notification is what a webhook says about a run, and what a custom
+// template is executed against.
+type Notification struct {
+	Kind          string    `json:"event"`
+	Operation     string    `json:"operation"`
+	Cluster       string    `json:"cluster"`
+	RunID         string    `json:"run_id"`
+	BundleVersion string    `json:"bundle_version,omitempty"`
+	Stage         string    `json:"stage,omitempty"`
+	Component     string    `json:"component,omitempty"`
+	ReasonCode    string    `json:"reason_code,omitempty"`
+	Message       string    `json:"message,omitempty"`
+	At            time.Time `json:"at"`
+	// Text is the one line a chat message shows.
+	Text string `json:"text"`
+}
+
+// defaultText is the line a notification shows unless the webhook has a
+// template of its own.
+var defaultText = template.Must(template.New("text").Parse(
+	`KubeNest {{.Operation}} of {{.Cluster}}` +
+		`{{if eq .Kind "started"}} started{{if .BundleVersion}} (bundle {{.BundleVersion}}){{end}}` +
+		`{{else if eq .Kind "failed"}} failed{{if .Stage}} at stage {{.Stage}}{{end}}{{if .Component}} ({{.Component}}){{end}}{{if .Message}}: {{.Message}}{{end}}` +
+		`{{else if eq .Kind "paused"}} paused before stage {{.Stage}}{{if .Message}}: {{.Message}}{{end}}` +
+		`{{else}} completed{{if .Message}} in {{.Message}}{{end}}{{end}}` +
+		` [run {{.RunID}}]`))
+
+// Webhook notifies a chat channel or any HTTP endpoint of a run's
+// milestones: it started, it failed and where, it paused for its maintenance
+// window, it completed.
+//
+// It is an Emitter, composed with the others, so it sees the run start from
+// the first transition; the other three come from Result, which the operation
+// calls with what Execute returned, because a pause is not a transition and
+// the failure worth a page is the one the run reports, not each concurrent
+// stage's.
+//
+// Delivery is queued, and off the run's path: Emit only queues the started
+// notification and sends it from a goroutine of its own, because every
+// transition of every stage waits behind Emit, and a chat host that has
+// stopped answering must not hold them for its timeout. A notification that
+// cannot be sent is held, in order, and sent ahead of the next one; Result
+// waits for a send in flight, then tries the queue a few times more since
+// nothing comes after it. A notification that still cannot be sent is
+// reported and dropped. Nothing a webhook does fails the run — the engine
+// prints an Emit error and carries on, and the operation prints a Result
+// error.
+//
+// The URL is a credential: a Slack or Teams webhook URL is all it takes to
+// post to the channel. It is never printed; messages name its host.
+type Webhook struct {
+	URL string
+	// Format is json, slack or teams.
+	Format string
+	// On is the kinds to send; empty means all of them.
+	On []string
+	// Template, when set, is a text/template executed against the
+	// Notification for its Text.
+	Template string
+
+	Operation string
+	Cluster   string
+	RunID     string
+
+	// Client is the HTTP client; nil means one with a ten-second timeout.
+	Client *http.Client
+	// Backoff is the wait between Result's final attempts; zero means two
+	// seconds.
+	Backoff time.Duration
+
+	mu      sync.Mutex
+	started bool
+	pending []Notification
+	// sending is closed when the send Emit started has finished; nil while
+	// none is in flight.
+	sending chan struct{}
+}
+
+// webhookAttempts is how many times Result tries to empty the queue.
+const webhookAttempts = 3
+
+// ValidateWebhook checks a webhook's settings before anything is sent with
+// them: a misspelt format found at the end of a forty-minute upgrade is a
+// notification that never arrived.
+func ValidateWebhook(rawURL, format string, on []string, text string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.New("the webhook URL must be an absolute http or https URL")
+	}
+	switch format {
+	case FormatJSON, FormatSlack, FormatTeams:
+	default:
+		return fmt.Errorf("webhook format %q is not one of json, slack and teams", format)
+	}
+	for _, kind := range on {
+		if !slices.Contains(NotifyKinds, kind) {
+			return fmt.Errorf("%q is not a notification: the notifications are %s", kind, strings.Join(NotifyKinds, ", "))
+		}
+	}
+	if text != "" {
+		if _, err := template.New("text").Parse(text); err != nil {
+			return fmt.Errorf("webhook template: %w", err)
+		}
+	}
+	return nil
+}
+
+// Emit queues the started notification on the run's first transition and
+// sends it in the background. What cannot be sent stays queued for Result.
+func (w *Webhook) Emit(ctx context.Context, ev Event) error {
+	w.mu.Lock()
+	first := !w.started && ev.Status == StatusStarted
+	w.started = w.started || first
+	w.mu.Unlock()
+	if !first || !w.enqueue(Notification{Kind: NotifyStarted, BundleVersion: ev.BundleVersion}) {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.sending != nil {
+		return nil
+	}
+	done := make(chan struct{})
+	w.sending = done
+	go func(ctx context.Context) {
+		_ = w.flush(ctx)
+		w.mu.Lock()
+		w.sending = nil
+		w.mu.Unlock()
+		close(done)
+	}(context.WithoutCancel(ctx))
+	return nil
+}
+
+// Result sends the run's outcome — completed, paused or failed — and tries
+// what is queued a few times more before giving up on it. It sends even when
+// ctx has been cancelled: an operator's Ctrl-C is a run that stopped, and the
+// channel should hear that it did.
+func (w *Webhook) Result(ctx context.Context, r Result, err error) error {
+	ctx = context.WithoutCancel(ctx)
+	n := Notification{Kind: NotifyCompleted, Message: r.Elapsed.Round(time.Second).String()}
+	var stageErr *StageError
+	var paused *PausedError
+	switch {
+	case errors.As(err, &paused):
+		n = Notification{Kind: NotifyPaused, Stage: paused.Stage, Message: Sanitize(paused.Reason)}
+	case errors.As(err, &stageErr):
+		n = Notification{Kind: NotifyFailed, Stage: stageErr.Stage, Component: stageErr.Component, ReasonCode: stageErr.ReasonCode, Message: Sanitize(stageErr.Err.Error())}
+		if stageErr.Hook != "" {
+			n.Component = "hook " + stageErr.Hook
+		}
+	case err != nil:
+		n = Notification{Kind: NotifyFailed, Message: Sanitize(err.Error())}
+	}
+	w.mu.Lock()
+	sending := w.sending
+	w.mu.Unlock()
+	if sending != nil {
+		<-sending
+	}
+	w.enqueue(n)
+	sendErr := w.flush(ctx)
+	backoff := w.Backoff
+	if backoff <= 0 {
+		backoff = 2 * time.Second
+	}
+	for attempt := 1; sendErr != nil && attempt < webhookAttempts; attempt++ {
+		if sleep(ctx, backoff) != nil {
+			break
+		}
+		sendErr = w.flush(ctx)
+	}
+	if sendErr != nil {
+		w.mu.Lock()
+		dropped := len(w.pending)
+		w.pending = nil
+		w.mu.Unlock()
+		return fmt.Errorf("%d notification(s) to %s could not be delivered: %w", dropped, w.host(), sendErr)
+	}
+	return nil
+}
+
+// enqueue queues n if it is one this webhook sends, and reports whether it
+// was.
+func (w *Webhook) enqueue(n Notification) bool {
+	if len(w.On) > 0 && !slices.Contains(w.On, n.Kind) {
+		return false
+	}
+	n.Operation, n.Cluster, n.RunID = w.Operation, w.Cluster, w.RunID
+	n.At = time.Now().UTC()
+	w.mu.Lock()
+	w.pending = append(w.pending, n)
+	w.mu.Unlock()
+	return true
+}
+
+// flush sends what is queued, in order, and leaves whatever it could not
+// send queued for the next attempt. As in ControlPlaneEmitter, the lock
+// guards the queue and is never held across a request.
+func (w *Webhook) flush(ctx context.Context) error {
+	w.mu.Lock()
+	queued := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+	for i, n := range queued {
+		if err := w.send(ctx, n); err != nil {
+			w.mu.Lock()
+			w.pending = append(slices.Clone(queued[i:]), w.pending...)
+			held := len(w.pending)
+			w.mu.Unlock()
+			return fmt.Errorf("holding %d notification(s) to %s for the next attempt: %w", held, w.host(), err)
+		}
+	}
+	return nil
+}
+
+func (w *Webhook) send(ctx context.Context, n Notification) error {
+	body, err := w.payload(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.New("the webhook URL is not valid")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// The client's error quotes the URL, and the URL is the
+		// credential.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("%s is unreachable: %w", w.host(), err)
+	}
+	defer resp.Body.Close()
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s: %s", w.host(), resp.Status, Sanitize(string(detail)))
+	}
+	return nil
+}
+
+// payload renders n in the webhook's format.
+func (w *Webhook) payload(n Notification) ([]byte, error) {
+	text := defaultText
+	if w.Template != "" {
+		custom, err := template.New("text").Parse(w.Template)
+		if err != nil {
+			return nil, fmt.Errorf("webhook template: %w", err)
+		}
+		text = custom
+	}
+	var b strings.Builder
+	if err := text.Execute(&b, n); err != nil {
+		return nil, fmt.Errorf("webhook template: %w", err)
+	}
+	n.Text = b.String()
+
+	switch w.Format {
+	case FormatSlack:
+		// An incoming webhook's minimal message: Slack, and the chat tools
+		// that accept Slack's payload, show text as it is.
+		return json.Marshal(map[string]string{"text": n.Text})
+	case FormatTeams:
+		// A connector MessageCard, coloured by outcome.
+		colour := map[string]string{NotifyStarted: "0076D7", NotifyFailed: "D70000", NotifyPaused: "FFB900", NotifyCompleted: "2EB886"}[n.Kind]
+		return json.Marshal(map[string]string{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    n.Text,
+			"themeColor": colour,
+			"title":      fmt.Sprintf("KubeNest %s of %s: %s", n.Operation, n.Cluster, n.Kind),
+			"text":       n.Text,
+		})
+	default:
+		return json.Marshal(n)
+	}
+}
+
+// host names the webhook in messages without the credential its URL is.
+func (w *Webhook) host() string {
+	if u, err := url.Parse(w.URL); err == nil && u.Host != "" {
+		return "webhook " + u.Host
+	}
+	return "the webhook"
+}
@@ -0,0 +1,258 @@
+package stages_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kubenest.io/cli/pkg/stages"
+)
+
+// channel is a chat webhook standing in for Slack's or Teams': it keeps each
+// body it accepts, and refuses while down.
+type channel struct {
+	mu     sync.Mutex
+	bodies []map[string]any
+	down   bool
+	tries  int
+}
+
+func (c *channel) serve(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.tries++
+		if c.down {
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(raw, &body); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("not a JSON POST: %s %q", r.Header.Get("Content-Type"), raw)
+		}
+		c.bodies = append(c.bodies, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/services/T000/B000/secret-token"
+}
+
+func (c *channel) setDown(down bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.down = down
+}
+
+func (c *channel) texts(key string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []string
+	for _, b := range c.bodies {
+		s, _ := b[key].(string)
+		out = append(out, s)
+	}
+	return out
+}
+
+func webhook(url, format string) *stages.Webhook {
+	return &stages.Webhook{URL: url, Format: format, Operation: "install", Cluster: "prod-1", RunID: "run-1", Backoff: time.Millisecond}
+}
+
+// A run that fails is two messages — it started, and where it failed — not
+// twenty-six transitions.
+func TestWebhookSendsStartedOnceAndTheFailure(t *testing.T) {
+	var c channel
+	w := webhook(c.serve(t), stages.FormatSlack)
+	s := newSession(t, &recorder{})
+	s.emitter = stages.Emitters{s.emitter, w}
+	var ran []string
+	res, err := stages.Execute(context.Background(), s, sequence(t, &ran, map[string]error{
+		stageStorage: errors.New("longhorn-manager is CrashLoopBackOff"),
+	}))
+	if err == nil {
+		t.Fatal("the run did not fail")
+	}
+	if notifyErr := w.Result(context.Background(), res, err); notifyErr != nil {
+		t.Fatal(notifyErr)
+	}
+	got := c.texts("text")
+	if len(got) != 2 {
+		t.Fatalf("sent %q, want started and failed", got)
+	}
+	if !strings.Contains(got[0], "install of prod-1 started") {
+		t.Errorf("started = %q", got[0])
+	}
+	if !strings.Contains(got[1], "failed at stage "+stageStorage) || !strings.Contains(got[1], "CrashLoopBackOff") || !strings.Contains(got[1], "[run run-1]") {
+		t.Errorf("failed = %q", got[1])
+	}
+}
+
+// Teams gets a MessageCard; a pause names the stage it will resume at.
+func TestWebhookTeamsCardForAPause(t *testing.T) {
+	var c channel
+	w := webhook(c.serve(t), stages.FormatTeams)
+	err := w.Result(context.Background(), stages.Result{}, &stages.PausedError{Stage: stageCerts, Reason: "the maintenance window closed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	card := c.bodies[0]
+	if card["@type"] != "MessageCard" || card["themeColor"] == "" || !strings.Contains(card["text"].(string), "paused before stage "+stageCerts) {
+		t.Errorf("card = %v", card)
+	}
+}
+
+// Generic JSON carries the fields, and a template replaces the message line.
+func TestWebhookJSONWithATemplate(t *testing.T) {
+	var c channel
+	w := webhook(c.serve(t), stages.FormatJSON)
+	w.Template = `{{.Cluster}} {{.Kind}}`
+	if err := w.Result(context.Background(), stages.Result{Elapsed: 90 * time.Second}, nil); err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b := c.bodies[0]
+	if b["event"] != stages.NotifyCompleted || b["run_id"] != "run-1" || b["text"] != "prod-1 completed" {
+		t.Errorf("body = %v", b)
+	}
+}
+
+// A notification that cannot be sent is held and sent, in order, ahead of
+// the next; only those the webhook is configured for are sent at all.
+func TestWebhookQueuesUntilTheChannelRecovers(t *testing.T) {
+	var c channel
+	w := webhook(c.serve(t), stages.FormatSlack)
+	w.On = []string{stages.NotifyStarted, stages.NotifyFailed}
+	c.setDown(true)
+	if err := w.Emit(context.Background(), stages.Event{Stage: stagePreflight, Status: stages.StatusStarted}); err != nil {
+		t.Errorf("Emit only queues, and failed: %v", err)
+	}
+	// The failed send is the one Emit started; Result waits for it.
+	waitForTries(t, &c, 1)
+	c.setDown(false)
+	if err := w.Result(context.Background(), stages.Result{}, errors.New("context canceled")); err != nil {
+		t.Fatal(err)
+	}
+	got := c.texts("text")
+	if len(got) != 2 || !strings.Contains(got[0], "started") || !strings.Contains(got[1], "failed") {
+		t.Errorf("sent %q, want the held started, then failed", got)
+	}
+
+	w.On = []string{stages.NotifyFailed}
+	if err := w.Result(context.Background(), stages.Result{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.texts("text")) != 2 {
+		t.Error("a completed notification was sent to a webhook that asked only for failures")
+	}
+}
+
+func waitForTries(t *testing.T, c *channel, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		c.mu.Lock()
+		tries := c.tries
+		c.mu.Unlock()
+		if tries >= n {
+			return
+		}
+	}
+	t.Fatalf("the webhook was not tried %d time(s)", n)
+}
+
+// A chat host that stops answering holds up no transition: Emit returns at
+// once, and Result delivers the started notification ahead of the outcome.
+func TestWebhookNeverHoldsUpTheRun(t *testing.T) {
+	release := make(chan struct{})
+	var c channel
+	url := c.serve(t)
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+	}))
+	t.Cleanup(hung.Close)
+	t.Cleanup(func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	})
+	w := webhook(hung.URL+"/services/T000/B000/secret-token", stages.FormatSlack)
+
+	start := time.Now()
+	for _, stage := range []string{stagePreflight, stageRegister} {
+		for _, status := range []stages.Status{stages.StatusStarted, stages.StatusCompleted} {
+			if err := w.Emit(context.Background(), stages.Event{Stage: stage, Status: status}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Emit waited %s on the webhook", elapsed)
+	}
+
+	close(release)
+	if err := w.Result(context.Background(), stages.Result{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	got := c.texts("text")
+	if len(got) != 2 || !strings.Contains(got[0], "started") || !strings.Contains(got[1], "completed") {
+		t.Errorf("sent %q, want started, then completed", got)
+	}
+}
+
+// A channel that never recovers costs a few attempts and a warning. The URL
+// is the channel's credential, so the warning names only its host.
+func TestWebhookGivesUpWithoutPrintingItsURL(t *testing.T) {
+	var c channel
+	w := webhook(c.serve(t), stages.FormatSlack)
+	c.setDown(true)
+	err := w.Result(context.Background(), stages.Result{}, nil)
+	if err == nil {
+		t.Fatal("an undelivered notification was not reported")
+	}
+	if strings.Contains(err.Error(), "secret-token") || !strings.Contains(err.Error(), "127.0.0.1") {
+		t.Errorf("err = %v, want the host and not the URL", err)
+	}
+	c.mu.Lock()
+	if c.tries != 3 {
+		t.Errorf("tried %d times, want 3", c.tries)
+	}
+	c.mu.Unlock()
+
+	w.URL = "http://127.0.0.1:1/services/T000/B000/secret-token"
+	if err := w.Result(context.Background(), stages.Result{}, nil); err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("unreachable: err = %v", err)
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	for name, tc := range map[string]struct {
+		url, format string
+		on          []string
+		text        string
+		ok          bool
+	}{
+		"slack":      {"https://hooks.slack.com/services/x", stages.FormatSlack, []string{"failed"}, "", true},
+		"relative":   {"hooks.slack.com/services/x", stages.FormatSlack, nil, "", false},
+		"format":     {"https://example.com/hook", "discord", nil, "", false},
+		"event":      {"https://example.com/hook", stages.FormatJSON, []string{"succeeded"}, "", false},
+		"template":   {"https://example.com/hook", stages.FormatJSON, nil, "{{.Cluster", false},
+		"customised": {"https://example.com/hook", stages.FormatTeams, nil, "{{.Cluster}}: {{.Kind}}", true},
+	} {
+		if err := stages.ValidateWebhook(tc.url, tc.format, tc.on, tc.text); (err == nil) != tc.ok {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}
@@ -8,7 +8,11 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/trace"
 )
 
 // Controller is what the engine needs from whichever operation it is
@@ -40,6 +44,18 @@ type Controller interface {
 	Exits() []string
 }
 
+// Diagnoser is a Controller that can capture the evidence for a convergence
+// check that failed — the stuck object's describe, events and logs — at the
+// moment it failed, before a resume or a reschedule replaces it. It returns
+// the directory the capture was written to.
+type Diagnoser interface {
+	Diagnose(ctx context.Context, stage string, state converge.State) (dir string, err error)
+}
+
+// diagnoseTimeout bounds a capture. It runs after the check has used its
+// deadline, and perhaps the run its own, so it has a budget of its own.
+const diagnoseTimeout = 2 * time.Minute
+
 // Result is what a completed run reports.
 type Result struct {
 	// Elapsed is wall-clock for the whole run. install.mdx budgets fifteen
@@ -59,11 +75,17 @@ type Result struct {
 // next. Those three things are the entire difference between an installer and
 // a thing that prints "error".
 type StageError struct {
-	Stage       string
-	Component   string
+	Stage     string
+	Component string
+	// Hook names the site hook that failed, when it was one of the stage's
+	// hooks rather than the stage itself.
+	Hook        string
 	ReasonCode  string
 	Err         error
 	JournalPath string
+	// Diagnostics is the directory holding the evidence captured for the
+	// check that failed, empty when there was none.
+	Diagnostics string
 	// PreflightOnly marks the cheap failure — the first stage, which by
 	// convention in both operations writes nothing anywhere, so the
 	// two-exit advice does not apply.
@@ -77,10 +99,15 @@ type StageError struct {
 func (e *StageError) Error() string {
 	var b strings.Builder
 	fmt.Fprintf(&b, "stage %s failed", e.Stage)
-	if e.Component != "" {
+	if e.Hook != "" {
+		fmt.Fprintf(&b, " in its hook %s", e.Hook)
+	} else if e.Component != "" {
 		fmt.Fprintf(&b, " installing %s", e.Component)
 	}
 	fmt.Fprintf(&b, ":\n  %s\n", e.Err)
+	if e.Diagnostics != "" {
+		fmt.Fprintf(&b, "\nDescribe output, events and logs from the moment it failed are in %s.\n", e.Diagnostics)
+	}
 
 	if e.PreflightOnly {
 		b.WriteString("\nNothing was written to any node and no cluster record was created.\n")
@@ -104,8 +131,23 @@ func (e *StageError) Error() string {
 // Unwrap exposes the underlying failure to errors.Is / errors.As.
 func (e *StageError) Unwrap() error { return e.Err }
 
-// Execute runs the stages in order, journalling every transition, skipping
-// what a previous run completed, and stopping at the first failure.
+// Execute runs the stages, journalling every transition, skipping what a
+// previous run completed, and stopping at the first failure.
+//
+// Stages run in sequence order unless they say otherwise. A stage that
+// declares After runs as soon as those stages have completed, alongside any
+// other stage that is ready too — which is how the install's component stages
+// share the wall-clock instead of queueing for it. Concurrency changes when a
+// stage starts and nothing else: every transition is still journalled and
+// emitted, StageIndex is still the stage's place in the sequence, and resume
+// still reads only the journal.
+//
+// A failure stops anything new from starting, and lets what is already
+// running finish: abandoning a stage half-way to report another's failure
+// sooner would leave the cluster in a state neither journal entry describes.
+// Then the failure of the earliest stage in the sequence is the one reported
+// — the others are in the journal — so the same failures always read the same
+// way, whichever happened to finish first.
 //
 // The whole run is bounded by the bundle's limits.timeouts.install-total.
 // That deadline is NOT the fifteen-minute budget: the budget is a target the
@@ -118,10 +160,18 @@ func Execute(ctx context.Context, c Controller, sequence []Stage) (Result, error
 	if journal == nil {
 		return Result{}, errors.New("this operation has no journal: resume is deterministic because of the journal, so running without one is not supported")
 	}
+	deps, err := dependencies(sequence)
+	if err != nil {
+		return Result{}, err
+	}
 	emitter := c.Emitter()
 	if emitter == nil {
 		emitter = NopEmitter{}
 	}
+	// Concurrent stages emit from their own goroutines; one at a time, so
+	// no emitter has to be written for a concurrency it never sees in a
+	// serial sequence.
+	emitter = &serialEmitter{e: emitter}
 	started := time.Now()
 
 	total, err := c.TotalDeadline()
@@ -134,116 +184,354 @@ func Execute(ctx context.Context, c Controller, sequence []Stage) (Result, error
 	var result Result
 	bundleVersion := c.BundleVersion()
 
-	for _, stage := range sequence {
-		index := Index(sequence, stage.Name)
-		event := Event{
-			RunID:         c.RunID(),
-			Stage:         stage.Name,
-			StageIndex:    index,
-			StageTotal:    len(sequence),
-			Component:     stage.Component,
-			BundleVersion: bundleVersion,
+	type outcome struct {
+		index int
+		err   error
+	}
+	const (
+		pending = iota
+		running
+		done
+		failed
+	)
+	state := make([]int, len(sequence))
+	resumed := make([]bool, len(sequence))
+	finished := make(chan outcome)
+	inFlight := 0
+	errs := map[int]error{}
+	ready := func(i int) bool {
+		for _, d := range deps[i] {
+			if state[d] != done {
+				return false
+			}
+		}
+		return true
+	}
+
+	for {
+		// Start everything that is ready, in sequence order, until the
+		// first failure. Dependencies always come earlier in the sequence,
+		// so one pass sees every stage a skip has just made ready.
+		for i, stage := range sequence {
+			if len(errs) > 0 || state[i] != pending || !ready(i) {
+				continue
+			}
+			event := Event{
+				RunID:         c.RunID(),
+				Stage:         stage.Name,
+				StageIndex:    i + 1,
+				StageTotal:    len(sequence),
+				Component:     stage.Component,
+				BundleVersion: bundleVersion,
+			}
+
+			if at, completed := journal.Completed(stage.Name); completed && !stage.AlwaysRun {
+				// Skipped stages still emit both transitions so the
+				// console's progress bar is not a mystery on a resumed
+				// run. They are not re-journalled: the stage's completion
+				// is already recorded, and a journal that grows an entry
+				// per resume records the resuming, not the install.
+				skipped := "skipped: completed " + at.Format(time.RFC3339)
+				emit(ctx, c, emitter, withStatus(event, StatusStarted, "", skipped))
+				emit(ctx, c, emitter, withStatus(event, StatusCompleted, "", skipped))
+				_, span := trace.Start(ctx, "stage "+stage.Name, append(stageAttrs(event), trace.Bool("kubenest.stage.skipped", true))...)
+				span.End()
+				resumed[i] = true
+				state[i] = done
+				continue
+			}
+
+			if stage.Run == nil {
+				state[i] = failed
+				errs[i] = &StageError{
+					Stage:       stage.Name,
+					Component:   stage.Component,
+					ReasonCode:  ReasonCode(stage.Name),
+					Err:         errors.New("this stage is not implemented in this build of the CLI"),
+					JournalPath: journal.Path(),
+				}
+				continue
+			}
+
+			state[i] = running
+			inFlight++
+			go func(i int, stage Stage, event Event) {
+				finished <- outcome{i, executeStage(ctx, c, emitter, stage, event, total)}
+			}(i, stage, event)
+		}
+		if inFlight == 0 {
+			break
 		}
+		o := <-finished
+		inFlight--
+		if o.err != nil {
+			state[o.index] = failed
+			errs[o.index] = o.err
+			continue
+		}
+		state[o.index] = done
+	}
 
-		if at, done := journal.Completed(stage.Name); done && !stage.AlwaysRun {
-			// Skipped stages still emit both transitions so the console's
-			// progress bar is not a mystery on a resumed run. They are not
-			// re-journalled: the stage's completion is already recorded, and
-			// a journal that grows an entry per resume records the resuming,
-			// not the install.
-			skipped := "skipped: completed " + at.Format(time.RFC3339)
-			emit(ctx, c, emitter, withStatus(event, StatusStarted, "", skipped))
-			emit(ctx, c, emitter, withStatus(event, StatusCompleted, "", skipped))
+	// Reported in sequence order, whatever order they finished in.
+	for i, stage := range sequence {
+		switch {
+		case resumed[i]:
 			result.Skipped = append(result.Skipped, stage.Name)
+		case state[i] == done:
+			result.Ran = append(result.Ran, stage.Name)
+		}
+	}
+	result.Elapsed = time.Since(started)
+	return result, firstError(c, sequence, errs, &result)
+}
+
+// firstError picks the error a run reports: the earliest stage's failure, or
+// — when nothing failed — the earliest pause. A pause beside a failure is not
+// the story; the failure is. Any other failure is logged, as it is journalled.
+func firstError(c Controller, sequence []Stage, errs map[int]error, result *Result) error {
+	var first, pause error
+	for i := range sequence {
+		err, ok := errs[i]
+		if !ok {
 			continue
 		}
+		var paused *PausedError
+		switch {
+		case errors.As(err, &paused):
+			if pause == nil {
+				pause = err
+				result.Paused = paused.Stage
+			}
+		case first == nil:
+			first = err
+		default:
+			c.Logf("  %s failed as well (the journal has both): %s", sequence[i].Name, Sanitize(err.Error()))
+		}
+	}
+	if first != nil {
+		result.Paused = ""
+		return first
+	}
+	return pause
+}
 
-		if stage.Run == nil {
-			return result, &StageError{
-				Stage:       stage.Name,
-				Component:   stage.Component,
-				ReasonCode:  ReasonCode(stage.Name),
-				Err:         errors.New("this stage is not implemented in this build of the CLI"),
-				JournalPath: journal.Path(),
+// executeStage runs one stage that the journal does not let the engine skip:
+// every attempt, the failure, or the completion. It returns a *StageError, a
+// *PausedError, a journal write error, or nil.
+func executeStage(ctx context.Context, c Controller, emitter Emitter, stage Stage, event Event, total time.Duration) (err error) {
+	journal := c.Journal()
+
+	// In a traced run the stage is a span, every attempt included, and
+	// everything it calls is beneath it.
+	ctx, span := trace.Start(ctx, "stage "+stage.Name, stageAttrs(event)...)
+	defer func() {
+		span.SetAttributes(trace.Int("kubenest.stage.attempts", max(event.Attempt, 1)))
+		var paused *PausedError
+		switch {
+		case errors.As(err, &paused):
+			span.SetAttributes(trace.Bool("kubenest.stage.paused", true))
+		case err != nil:
+			// The cause, not the advice on resuming a StageError wraps
+			// it in: a trace is read beside the journal, not instead of it.
+			cause := err
+			var stageErr *StageError
+			if errors.As(err, &stageErr) {
+				cause = stageErr.Err
 			}
+			span.Fail(Sanitize(cause.Error()))
 		}
+		span.End()
+	}()
 
-		emit(ctx, c, emitter, withStatus(event, StatusStarted, "", ""))
+	// Each attempt is journalled and emitted as a transition of its own,
+	// numbered when the stage may have more than one. A failed attempt that
+	// will be retried is journalled as retrying and NOT emitted as failed:
+	// `failed` marks the cluster failed on the console, and an operation
+	// that is about to try again has not. The next attempt's `started` says
+	// why it is running again.
+	attempts := stage.Retry.attempts()
+	var runErr error
+	for attempt, retryingAfter := 1, ""; ; attempt++ {
+		if attempts > 1 {
+			event.Attempt, event.Attempts = attempt, attempts
+		}
+		message := ""
+		if retryingAfter != "" {
+			message = attemptLabel(attempt, attempts) + ", retrying after: " + retryingAfter
+		}
+		emit(ctx, c, emitter, withStatus(event, StatusStarted, "", message))
 		if err := journal.Append(Entry{
 			Stage: stage.Name, Status: StatusStarted, Component: stage.Component, RunID: c.RunID(),
+			Attempt: event.Attempt,
 		}); err != nil {
-			return result, fmt.Errorf("write install journal: %w", err)
+			return fmt.Errorf("write install journal: %w", err)
 		}
 
-		runErr := stage.Run(ctx)
-		if errors.Is(runErr, ErrPaused) {
-			// A pause is not a failure: no terminal entry, no failed event,
-			// no failed cluster state. The stage's `started` entry stands,
-			// which is what "in progress" looks like, and a resume re-runs
-			// it because it never completed.
-			reason := strings.TrimPrefix(runErr.Error(), ErrPaused.Error()+": ")
-			c.Logf("  %s", reason)
-			result.Elapsed = time.Since(started)
-			result.Paused = stage.Name
-			return result, &PausedError{
-				Stage:       stage.Name,
-				Reason:      reason,
-				JournalPath: journal.Path(),
-				Resume:      c.ResumeAdvice(),
-			}
+		runErr = runStage(ctx, c, stage, event.Attempt)
+		if runErr == nil || !retryable(ctx, stage, attempt, runErr) {
+			break
 		}
-		if runErr != nil {
-			runErr = annotateDeadline(ctx, stage.Name, total, runErr)
-			reason := ReasonCode(stage.Name)
-			// WHICH component failed, not which one the stage happens to
-			// list first. platform-networking installs the Gateway API CRDs
-			// and Traefik; platform-day2 installs system-upgrade-controller
-			// and kured. The failing call tags its own error, and that tag
-			// wins over the stage's declared component for the failed
-			// event and the failed journal entry alike.
-			if actual := ComponentOf(runErr); actual != "" {
-				event.Component = actual
-				stage.Component = actual
-			}
-			// Everything that leaves this process — the wire event and the
-			// journal — is sanitized. The text comes from component
-			// installers and remote shells, so "no secrets, no raw command
-			// output" (install_journal_entry.json) has to be enforced at the
-			// sink, not trusted at the source.
-			detail := Sanitize(runErr.Error())
-			emit(ctx, c, emitter, withStatus(event, StatusFailed, reason, detail))
-			// The journal write must happen even though the install is
-			// failing: the record of WHERE it failed is the whole resume
-			// path. A journal error here is reported alongside, not instead.
-			if jErr := journal.Append(Entry{
-				Stage: stage.Name, Status: StatusFailed, Component: stage.Component,
-				Detail: detail, RunID: c.RunID(),
-			}); jErr != nil {
-				c.Logf("warning: could not write the journal: %v", jErr)
-			}
-			result.Elapsed = time.Since(started)
-			return result, &StageError{
-				Stage:         stage.Name,
-				Component:     stage.Component,
-				ReasonCode:    reason,
-				Err:           runErr,
-				JournalPath:   journal.Path(),
-				PreflightOnly: index == 1,
-				Exits:         c.Exits(),
-			}
+		retryingAfter = Sanitize(runErr.Error())
+		wait := stage.Retry.backoff(attempt + 1)
+		component := stage.Component
+		if actual := ComponentOf(runErr); actual != "" {
+			component = actual
 		}
-
-		emit(ctx, c, emitter, withStatus(event, StatusCompleted, "", ""))
 		if err := journal.Append(Entry{
-			Stage: stage.Name, Status: StatusCompleted, Component: stage.Component, RunID: c.RunID(),
+			Stage: stage.Name, Status: StatusRetrying, Component: component,
+			Detail: retryingAfter, RunID: c.RunID(), Attempt: attempt,
 		}); err != nil {
-			return result, fmt.Errorf("write install journal: %w", err)
+			return fmt.Errorf("write install journal: %w", err)
+		}
+		c.Logf("  %s failed on %s, retrying in %s: %s", stage.Name, attemptLabel(attempt, attempts), wait, retryingAfter)
+		if err := sleep(ctx, wait); err != nil {
+			// Out of time, or stopped, while waiting: the failure that is
+			// reported is the attempt's, not the wait's.
+			break
+		}
+	}
+	if errors.Is(runErr, ErrPaused) {
+		// A pause is not a failure: no terminal entry, no failed event, no
+		// failed cluster state. The stage's `started` entry stands, which
+		// is what "in progress" looks like, and a resume re-runs it because
+		// it never completed.
+		reason := strings.TrimPrefix(runErr.Error(), ErrPaused.Error()+": ")
+		c.Logf("  %s", reason)
+		return &PausedError{
+			Stage:       stage.Name,
+			Reason:      reason,
+			JournalPath: journal.Path(),
+			Resume:      c.ResumeAdvice(),
+		}
+	}
+	if runErr != nil {
+		runErr = annotateDeadline(ctx, stage.Name, total, runErr)
+		reason := ReasonCode(stage.Name)
+		// WHICH component failed, not which one the stage happens to list
+		// first. platform-networking installs the Gateway API CRDs and
+		// Traefik; platform-day2 installs system-upgrade-controller and
+		// kured. The failing call tags its own error, and that tag wins over
+		// the stage's declared component for the failed event and the failed
+		// journal entry alike.
+		if actual := ComponentOf(runErr); actual != "" {
+			event.Component = actual
+			stage.Component = actual
+		}
+		// A failed hook is the site's step, not the stage's component, and
+		// the failure names it instead.
+		var hook string
+		var hookErr *HookError
+		if errors.As(runErr, &hookErr) {
+			hook = hookErr.Hook
+			event.Component, stage.Component = "", ""
+		}
+		// Everything that leaves this process — the wire event and the
+		// journal — is sanitized. The text comes from component installers
+		// and remote shells, so "no secrets, no raw command output"
+		// (install_journal_entry.json) has to be enforced at the sink, not
+		// trusted at the source.
+		detail := Sanitize(runErr.Error())
+		emit(ctx, c, emitter, withStatus(event, StatusFailed, reason, detail))
+		// The journal write must happen even though the install is failing:
+		// the record of WHERE it failed is the whole resume path. A journal
+		// error here is reported alongside, not instead.
+		if jErr := journal.Append(Entry{
+			Stage: stage.Name, Status: StatusFailed, Component: stage.Component,
+			Detail: detail, RunID: c.RunID(), Attempt: event.Attempt, Hook: hook,
+		}); jErr != nil {
+			c.Logf("warning: could not write the journal: %v", jErr)
+		}
+		return &StageError{
+			Stage:         stage.Name,
+			Component:     stage.Component,
+			Hook:          hook,
+			ReasonCode:    reason,
+			Err:           runErr,
+			JournalPath:   journal.Path(),
+			Diagnostics:   diagnose(ctx, c, stage.Name, runErr),
+			PreflightOnly: event.StageIndex == 1 && hook == "",
+			Exits:         c.Exits(),
 		}
-		result.Ran = append(result.Ran, stage.Name)
 	}
 
-	result.Elapsed = time.Since(started)
-	return result, nil
+	emit(ctx, c, emitter, withStatus(event, StatusCompleted, "", ""))
+	if err := journal.Append(Entry{
+		Stage: stage.Name, Status: StatusCompleted, Component: stage.Component, RunID: c.RunID(),
+		Attempt: event.Attempt,
+	}); err != nil {
+		return fmt.Errorf("write install journal: %w", err)
+	}
+	return nil
+}
+
+// diagnose captures the evidence for a failed stage whose cause is a
+// convergence check, when the controller can, and returns where it went. It
+// runs even when ctx is done — a run out of time is when the evidence is
+// wanted most — and a capture that fails is a warning, never the failure.
+func diagnose(ctx context.Context, c Controller, stage string, err error) string {
+	d, ok := c.(Diagnoser)
+	var fail *converge.FailError
+	if !ok || !errors.As(err, &fail) {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), diagnoseTimeout)
+	defer cancel()
+	c.Logf("  capturing diagnostics for %s", fail.Result.Last.Object)
+	dir, err := d.Diagnose(ctx, stage, fail.Result.Last)
+	if err != nil {
+		c.Logf("  warning: no diagnostics were captured: %v", err)
+		return ""
+	}
+	return dir
+}
+
+// stageAttrs are a stage span's attributes.
+func stageAttrs(event Event) []trace.Attr {
+	attrs := []trace.Attr{
+		trace.String("kubenest.stage", event.Stage),
+		trace.Int("kubenest.stage.index", event.StageIndex),
+	}
+	if event.Component != "" {
+		attrs = append(attrs, trace.String("kubenest.component", event.Component))
+	}
+	return attrs
+}
+
+// dependencies resolves each stage's After into sequence indexes. A stage
+// without After depends on every stage before it, which is what a sequence
+// that declares nothing has always meant. A dependency must come earlier in
+// the sequence: the sequence stays the order a person reads and StageIndex
+// stays a stage's place in it, and a cycle cannot be written down.
+func dependencies(sequence []Stage) ([][]int, error) {
+	deps := make([][]int, len(sequence))
+	for i, stage := range sequence {
+		if stage.After == nil {
+			for j := 0; j < i; j++ {
+				deps[i] = append(deps[i], j)
+			}
+			continue
+		}
+		for _, name := range stage.After {
+			j := Index(sequence, name) - 1
+			if j < 0 || j >= i {
+				return nil, fmt.Errorf("stage %s runs after %q, which is not a stage before it in the sequence: this is a bug in the operation's plan, not in your cluster", stage.Name, name)
+			}
+			deps[i] = append(deps[i], j)
+		}
+	}
+	return deps, nil
+}
+
+// serialEmitter lets one event through at a time.
+type serialEmitter struct {
+	mu sync.Mutex
+	e  Emitter
+}
+
+func (s *serialEmitter) Emit(ctx context.Context, ev Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e.Emit(ctx, ev)
 }
 
 // emit publishes an event, printing but never propagating an emitter error:
@@ -263,8 +551,12 @@ func withStatus(e Event, status Status, reason, message string) Event {
 
 // annotateDeadline turns the generic context error into the sentence the
 // deadline exists to produce: which stage was still running when the whole
-// install ran out of time.
+// install ran out of time. A run stopped because another took its lease over
+// says that instead.
 func annotateDeadline(ctx context.Context, stage string, total time.Duration, err error) error {
+	if cause := context.Cause(ctx); errors.Is(cause, ErrLockLost) {
+		return fmt.Errorf("stage %s was stopped: %v (last state: %w)", stage, cause, err)
+	}
 	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
 		return err
 	}
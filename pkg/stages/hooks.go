@@ -0,0 +1,277 @@
+package stages
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"kubenest.io/cli/pkg/sshx"
+	"kubenest.io/cli/pkg/trace"
+)
+
+// Hooks are a site's own steps between stages: registering hosts in a CMDB
+// once k3s is on them, putting a corporate CA bundle in place before Traefik
+// starts pulling. They are declared in a file rather than built in because
+// every site's are different, and run by the engine rather than around it so
+// they are journalled, ordered and resumed like the stages themselves:
+//
+//	hooks:
+//	  - name: cmdb-register
+//	    after: k3s-server
+//	    node: 10.0.1.10
+//	    run: /opt/site/bin/cmdb-register --role server
+//	  - name: corporate-ca
+//	    before: platform-networking
+//	    run: ./site/push-ca.sh
+//
+// A hook runs with its stage, inside it: the before hooks, then the stage,
+// then the after hooks, and the stage is not completed until all of them
+// are. So a hook that fails fails its stage, and a resume re-runs the stage
+// and every hook on it — deterministically, from the journal, with nothing to
+// remember about which hook got how far. The price is that a hook must be
+// safe to run again, exactly as the stages are. A stage a resume skips skips
+// its hooks; --rerun-stage runs both again.
+//
+// A hook without a node runs on this machine under sh; one with a node runs
+// there over the operation's own SSH connection, as the SSH user — a hook
+// that needs root says sudo itself. Either way it gets KUBENEST_CLUSTER,
+// KUBENEST_STAGE, KUBENEST_HOOK and KUBENEST_RUN_ID in its environment.
+
+// HookWhen is which side of its stage a hook runs on.
+type HookWhen string
+
+const (
+	HookBefore HookWhen = "before"
+	HookAfter  HookWhen = "after"
+)
+
+// Hook is one hook bound to its stage.
+type Hook struct {
+	Name    string
+	When    HookWhen
+	Command string
+	// Node is the address the hook runs on, empty for this machine.
+	Node string
+	// Runner returns the connection to Node when the hook runs rather than
+	// when it is bound: an install has no connections until its preflight
+	// opens them. Nil runs the hook here.
+	Runner func() (Runner, error)
+}
+
+// where names where the hook runs, for messages.
+func (h Hook) where() string {
+	if h.Node == "" {
+		return "this machine"
+	}
+	return h.Node
+}
+
+// HookSpec is one hook as a hooks file declares it: a name, exactly one of
+// before and after, and the command.
+type HookSpec struct {
+	Name   string `yaml:"name"`
+	Before string `yaml:"before,omitempty"`
+	After  string `yaml:"after,omitempty"`
+	Node   string `yaml:"node,omitempty"`
+	Run    string `yaml:"run"`
+}
+
+type hooksFile struct {
+	Hooks []HookSpec `yaml:"hooks"`
+}
+
+// ReadHooks decodes a hooks file strictly — `afer:` is an error here, not a
+// hook that silently never runs — and checks each hook's shape. Which stages
+// exist is AttachHooks' to check, since that depends on the operation.
+func ReadHooks(path string) ([]HookSpec, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading --hooks: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	var f hooksFile
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("--hooks %s: %w", path, err)
+	}
+	seen := map[string]bool{}
+	for i, h := range f.Hooks {
+		switch {
+		case h.Name == "":
+			return nil, fmt.Errorf("--hooks %s: hook %d has no name: the name is what the journal and a failure report", path, i+1)
+		case seen[h.Name]:
+			return nil, fmt.Errorf("--hooks %s: two hooks are named %q: a failure naming one would not say which", path, h.Name)
+		case (h.Before == "") == (h.After == ""):
+			return nil, fmt.Errorf("--hooks %s: hook %s needs exactly one of before: and after:, naming the stage it runs beside", path, h.Name)
+		case strings.TrimSpace(h.Run) == "":
+			return nil, fmt.Errorf("--hooks %s: hook %s has no run: command", path, h.Name)
+		}
+		seen[h.Name] = true
+	}
+	return f.Hooks, nil
+}
+
+// AttachHooks returns a copy of sequence with each hook bound to its stage,
+// in the order the file declares them. A hook naming a stage the operation
+// does not have is refused with the stages it does: an install hook pointed
+// at an upgrade stage would otherwise never run, silently. node resolves a
+// hook's node to its connection when the hook runs.
+func AttachHooks(sequence []Stage, specs []HookSpec, node func(address string) (Runner, error)) ([]Stage, error) {
+	out := slices.Clone(sequence)
+	for _, spec := range specs {
+		h := Hook{Name: spec.Name, When: HookBefore, Command: spec.Run, Node: spec.Node}
+		stage := spec.Before
+		if spec.After != "" {
+			h.When, stage = HookAfter, spec.After
+		}
+		i := Index(out, stage) - 1
+		if i < 0 {
+			var names []string
+			for _, s := range out {
+				names = append(names, s.Name)
+			}
+			return nil, fmt.Errorf("hook %s runs %s %q, which is not a stage of this operation: the stages are %s", h.Name, h.When, stage, strings.Join(names, ", "))
+		}
+		if h.Node != "" {
+			address := h.Node
+			h.Runner = func() (Runner, error) { return node(address) }
+		}
+		out[i].Hooks = append(slices.Clone(out[i].Hooks), h)
+	}
+	return out, nil
+}
+
+// HookError is a hook that failed. The stage it ran beside fails with it,
+// and its StageError names the hook rather than the stage's component.
+type HookError struct {
+	Hook  string
+	When  HookWhen
+	Where string
+	Err   error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("hook %s (%s the stage, on %s): %v", e.Hook, e.When, e.Where, e.Err)
+}
+
+func (e *HookError) Unwrap() error { return e.Err }
+
+// runStage runs a stage's before hooks, the stage, and its after hooks.
+func runStage(ctx context.Context, c Controller, stage Stage, attempt int) error {
+	for _, h := range stage.Hooks {
+		if h.When == HookBefore {
+			if err := runHook(ctx, c, stage, h, attempt); err != nil {
+				return err
+			}
+		}
+	}
+	if err := stage.Run(ctx); err != nil {
+		return err
+	}
+	for _, h := range stage.Hooks {
+		if h.When == HookAfter {
+			if err := runHook(ctx, c, stage, h, attempt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// runHook runs one hook and journals its output. The output is a site's
+// script talking, so it is sanitized before it is written, like everything
+// else that reaches the journal.
+func runHook(ctx context.Context, c Controller, stage Stage, h Hook, attempt int) error {
+	ctx, span := trace.Start(ctx, "hook "+h.Name, trace.String("kubenest.hook", h.Name), trace.String("kubenest.hook.when", string(h.When)))
+	defer span.End()
+	fail := func(err error) error {
+		err = &HookError{Hook: h.Name, When: h.When, Where: h.where(), Err: err}
+		span.Fail(Sanitize(err.Error()))
+		return err
+	}
+	env := []string{
+		"KUBENEST_CLUSTER=" + c.Journal().Identity.Cluster,
+		"KUBENEST_STAGE=" + stage.Name,
+		"KUBENEST_HOOK=" + h.Name,
+		"KUBENEST_RUN_ID=" + c.RunID(),
+	}
+	var res sshx.Result
+	var err error
+	if h.Runner == nil {
+		res, err = LocalRunner{Env: env}.Run(ctx, h.Command)
+	} else {
+		var r Runner
+		if r, err = h.Runner(); err != nil {
+			return fail(err)
+		}
+		quoted := make([]string, len(env))
+		for i, kv := range env {
+			quoted[i] = shellQuote(kv)
+		}
+		res, err = r.Run(ctx, "env "+strings.Join(quoted, " ")+" sh -c "+shellQuote(h.Command))
+	}
+	output := Sanitize(strings.TrimSpace(res.Stdout + "\n" + res.Stderr))
+	if err != nil {
+		return fail(err)
+	}
+	if res.ExitCode != 0 {
+		if output == "" {
+			output = "no output"
+		}
+		return fail(fmt.Errorf("exit %d: %s", res.ExitCode, output))
+	}
+	c.Logf("  hook %s (%s %s, on %s): ok", h.Name, h.When, stage.Name, h.where())
+	if err := c.Journal().Append(Entry{
+		Stage: stage.Name, Status: StatusHook, Hook: h.Name, Detail: output,
+		RunID: c.RunID(), Attempt: attempt,
+	}); err != nil {
+		return fmt.Errorf("write install journal: %w", err)
+	}
+	return nil
+}
+
+// LocalRunner runs commands on this machine under sh (cmd on Windows), for
+// hooks without a node.
+type LocalRunner struct {
+	// Env is added to this process's environment.
+	Env []string
+}
+
+// Run runs command and reports its exit code in the result, as an SSH
+// session does: an error is a command that could not be run at all.
+func (l LocalRunner) Run(ctx context.Context, command string) (sshx.Result, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), l.Env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	res := sshx.Result{Stdout: stdout.String(), Stderr: stderr.String()}
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && ctx.Err() == nil {
+		res.ExitCode = exit.ExitCode()
+		return res, nil
+	}
+	if err != nil && ctx.Err() != nil {
+		return res, ctx.Err()
+	}
+	return res, err
+}
+
+// shellQuote single-quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
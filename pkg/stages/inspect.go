@@ -0,0 +1,187 @@
+package stages
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ReadJournals loads every journal in dir, install and upgrade alike, sorted
+// by path. A journal that cannot be read is reported in the error and the
+// rest are still returned: the one that is corrupt is the one an operator is
+// most likely looking for, and hiding it behind a clean listing of the others
+// would send them to the wrong cluster's record. A missing dir is no journals.
+func ReadJournals(dir string) ([]*Journal, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var journals []*Journal
+	var errs []error
+	for _, e := range entries {
+		// Save's temp files start with a dot; a half-written one is not a
+		// journal.
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		j, err := ReadJournal(filepath.Join(dir, e.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		journals = append(journals, j)
+	}
+	return journals, errors.Join(errs...)
+}
+
+// Last returns the most recent entry, false for a journal with none.
+func (j *Journal) Last() (Entry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.Entries) == 0 {
+		return Entry{}, false
+	}
+	return j.Entries[len(j.Entries)-1], true
+}
+
+// Run is one process's part of a journal: every entry with one RunID, in the
+// order written. A resume is a new run, so a journal with three runs is an
+// operation that was started and resumed twice.
+type Run struct {
+	// ID is empty for entries written before run ids were journalled.
+	ID    string
+	Steps []Step
+}
+
+// Step is one stage within a run: when it started, and how and when it
+// ended. An unended step is a stage its process never finished — killed, or
+// paused before it by a maintenance window, or still running.
+type Step struct {
+	Stage     string
+	Component string
+	Started   time.Time
+	// Status is the terminal status, or StatusStarted while unended.
+	Status Status
+	Ended  time.Time
+	Detail string
+	// Attempt numbers a stage run under a Retry policy, zero otherwise. A
+	// stage retried twice is three steps.
+	Attempt int
+	// Hook names the hook a hook step ran, or the one a failed step failed
+	// in.
+	Hook string
+}
+
+// Duration is how long the step ran, zero while unended.
+func (s Step) Duration() time.Duration {
+	if s.Ended.IsZero() || s.Started.IsZero() {
+		return 0
+	}
+	return s.Ended.Sub(s.Started)
+}
+
+// Runs groups the entries by RunID in the order each run first appears, and
+// pairs every started entry with the terminal entry that closes it. An entry
+// with no start — an operator's reopened entry, a hook's, or a terminal one
+// from a hand-edited journal — is kept as a step of its own rather than
+// dropped.
+func (j *Journal) Runs() []Run {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var runs []Run
+	index := map[string]int{}
+	for _, e := range j.Entries {
+		i, ok := index[e.RunID]
+		if !ok {
+			i = len(runs)
+			index[e.RunID] = i
+			runs = append(runs, Run{ID: e.RunID})
+		}
+		run := &runs[i]
+		if e.Status == StatusStarted {
+			run.Steps = append(run.Steps, Step{Stage: e.Stage, Component: e.Component, Started: e.At, Status: StatusStarted, Attempt: e.Attempt})
+			continue
+		}
+		// A hook ran inside its stage's step and does not end it: it is a
+		// step of its own, with the stage's still open around it.
+		if e.Status == StatusHook {
+			run.Steps = append(run.Steps, Step{Stage: e.Stage, Status: StatusHook, Ended: e.At, Detail: e.Detail, Attempt: e.Attempt, Hook: e.Hook})
+			continue
+		}
+		open := slices.IndexFunc(run.Steps, func(s Step) bool { return s.Stage == e.Stage && s.Status == StatusStarted })
+		if open < 0 {
+			run.Steps = append(run.Steps, Step{Stage: e.Stage, Component: e.Component, Status: e.Status, Ended: e.At, Detail: e.Detail, Attempt: e.Attempt})
+			continue
+		}
+		step := &run.Steps[open]
+		step.Status, step.Ended, step.Detail, step.Hook = e.Status, e.At, e.Detail, e.Hook
+		// The failing call's component wins, as it does on the wire.
+		if e.Component != "" {
+			step.Component = e.Component
+		}
+	}
+	return runs
+}
+
+// Export is the journal as JSON for a support ticket, with every string in
+// it — identity, entries and the operation's own record — passed through
+// Sanitize. The journal is written sanitized and holds nothing secret by
+// construction; exporting is sanitized again anyway, because a file on disk
+// may have been edited by hand and a ticket is read by people outside the
+// customer's organization.
+func (j *Journal) Export() ([]byte, error) {
+	out := Journal{
+		Identity:  j.Identity,
+		ClusterID: j.ClusterID,
+		Entries:   make([]Entry, len(j.Entries)),
+	}
+	out.Identity.Cluster = Sanitize(j.Identity.Cluster)
+	out.Identity.Fields = make(map[string]string, len(j.Identity.Fields))
+	for k, v := range j.Identity.Fields {
+		out.Identity.Fields[k] = Sanitize(v)
+	}
+	for i, e := range j.Entries {
+		e.Detail = Sanitize(e.Detail)
+		out.Entries[i] = e
+	}
+	if len(j.State) > 0 {
+		var state any
+		if err := json.Unmarshal(j.State, &state); err != nil {
+			return nil, err
+		}
+		raw, err := json.Marshal(sanitizeValue(state))
+		if err != nil {
+			return nil, err
+		}
+		out.State = raw
+	}
+	data, err := json.MarshalIndent(&out, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// sanitizeValue sanitizes every string in a decoded JSON value.
+func sanitizeValue(v any) any {
+	switch v := v.(type) {
+	case string:
+		return Sanitize(v)
+	case []any:
+		for i := range v {
+			v[i] = sanitizeValue(v[i])
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = sanitizeValue(v[k])
+		}
+	}
+	return v
+}
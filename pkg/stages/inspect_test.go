@@ -0,0 +1,91 @@
+package stages_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kubenest.io/cli/pkg/stages"
+)
+
+// A resume is a new run: the timeline groups by RunID and pairs each start
+// with the entry that ended it, and a stage whose process died stays open.
+func TestRunsPairStartsWithTheirEndsPerRun(t *testing.T) {
+	at := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	j := &stages.Journal{Entries: []stages.Entry{
+		{Stage: stagePreflight, Status: stages.StatusStarted, At: at, RunID: "a"},
+		{Stage: stagePreflight, Status: stages.StatusCompleted, At: at.Add(12 * time.Second), RunID: "a"},
+		{Stage: stageStorage, Status: stages.StatusStarted, At: at.Add(time.Minute), RunID: "a", Component: "openebs"},
+		{Stage: stageStorage, Status: stages.StatusFailed, At: at.Add(4 * time.Minute), RunID: "a", Component: "openebs-lvm-localpv", Detail: "volume group kubenest-vg not found"},
+		{Stage: stagePreflight, Status: stages.StatusStarted, At: at.Add(time.Hour), RunID: "b"},
+	}}
+	runs := j.Runs()
+	if len(runs) != 2 || runs[0].ID != "a" || runs[1].ID != "b" {
+		t.Fatalf("runs = %+v", runs)
+	}
+	steps := runs[0].Steps
+	if len(steps) != 2 || steps[0].Duration() != 12*time.Second {
+		t.Fatalf("run a = %+v", steps)
+	}
+	if steps[1].Status != stages.StatusFailed || steps[1].Duration() != 3*time.Minute ||
+		steps[1].Component != "openebs-lvm-localpv" || steps[1].Detail == "" {
+		t.Errorf("failed step = %+v", steps[1])
+	}
+	if open := runs[1].Steps[0]; open.Status != stages.StatusStarted || open.Duration() != 0 {
+		t.Errorf("a stage its process never finished must stay open, got %+v", open)
+	}
+}
+
+// An export is for people outside the customer's organization, so a token
+// pasted into a hand-edited journal is redacted wherever it sits.
+func TestExportRedactsEveryString(t *testing.T) {
+	const token = "knp_live_0123456789abcdef"
+	j := &stages.Journal{
+		Identity: identity("prod-1", map[string]string{"--http-proxy": "http://" + token + "@proxy:3128"}),
+		Entries:  []stages.Entry{{Stage: stageRegister, Status: stages.StatusFailed, Detail: "401 for " + token}},
+		State:    json.RawMessage(`{"repo_url":"https://git.example.com","notes":["` + token + `"]}`),
+	}
+	data, err := j.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), token) {
+		t.Errorf("export carries the token:\n%s", data)
+	}
+	var back stages.Journal
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatalf("export is not a journal: %v", err)
+	}
+	if back.Identity.Cluster != "prod-1" || len(back.Entries) != 1 || !strings.Contains(string(back.State), "git.example.com") {
+		t.Errorf("export lost the record: %+v", &back)
+	}
+}
+
+// A corrupt journal is reported alongside the readable ones, not in place of
+// them and not silently.
+func TestReadJournalsReportsTheUnreadableOne(t *testing.T) {
+	dir := t.TempDir()
+	j, err := stages.OpenJournal(filepath.Join(dir, "prod-1.json"), identity("prod-1", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Append(stages.Entry{Stage: stagePreflight, Status: stages.StatusStarted}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "prod-2.json"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	journals, err := stages.ReadJournals(dir)
+	if len(journals) != 1 || journals[0].Identity.Cluster != "prod-1" {
+		t.Errorf("journals = %v", journals)
+	}
+	if err == nil || !strings.Contains(err.Error(), "prod-2.json") {
+		t.Errorf("err = %v, want it to name the corrupt journal", err)
+	}
+	if journals, err := stages.ReadJournals(filepath.Join(dir, "missing")); journals != nil || err != nil {
+		t.Errorf("a missing directory is no journals, got %v, %v", journals, err)
+	}
+}
@@ -3,10 +3,12 @@ package stages
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,6 +23,21 @@ const (
 	StatusStarted   Status = "started"
 	StatusCompleted Status = "completed"
 	StatusFailed    Status = "failed"
+	// StatusReopened is never on the wire: not a transition but an
+	// operator's instruction, written by Reopen, that a completed stage must
+	// run again. It is the last word on the stage until the stage completes
+	// again, so a run that fails before reaching it leaves the instruction
+	// standing for the resume.
+	StatusReopened Status = "reopened"
+	// StatusRetrying closes an attempt that failed and will be tried again
+	// under the stage's Retry policy. It is never on the wire either: on
+	// the wire a failure is final, and marks the cluster failed, while this
+	// one is followed by the next attempt's `started`.
+	StatusRetrying Status = "retrying"
+	// StatusHook records a site hook that ran, with its output, between its
+	// stage's started entry and its terminal one. Journal-only too: a hook
+	// is not a stage, and the wire has no word for it.
+	StatusHook Status = "hook"
 )
 
 // Entry is one stage transition.
@@ -33,6 +50,12 @@ type Entry struct {
 	// RunID ties an entry to one installer process, so a resumed run is
 	// legible in the record rather than inferred from timestamps.
 	RunID string `json:"run_id,omitempty"`
+	// Attempt numbers the entry's attempt, for a stage whose Retry policy
+	// allows more than one; zero for a stage that runs once.
+	Attempt int `json:"attempt,omitempty"`
+	// Hook names the site hook a hook entry records, or the one a failed
+	// entry failed in.
+	Hook string `json:"hook,omitempty"`
 }
 
 // Identity is what makes a resume a resume rather than a different operation
@@ -107,6 +130,12 @@ type Journal struct {
 	State json.RawMessage `json:"state,omitempty"`
 
 	path string
+	// replicas are the copies kept off this machine; see Replicate.
+	replicas []*replica
+	// mu serializes every read and write of the journal: stages that run
+	// concurrently append their transitions, and set the operation's state,
+	// from goroutines of their own.
+	mu sync.Mutex
 }
 
 // SetState stores the caller's record and persists the journal.
@@ -115,13 +144,17 @@ func (j *Journal) SetState(v any) error {
 	if err != nil {
 		return err
 	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
 	j.State = raw
-	return j.Save()
+	return j.save()
 }
 
 // DecodeState reads the caller's record back. A journal with no state leaves
 // v untouched and returns nil, so a first run needs no special case.
 func (j *Journal) DecodeState(v any) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
 	if len(j.State) == 0 {
 		return nil
 	}
@@ -203,13 +236,34 @@ func (j *Journal) Append(e Entry) error {
 	if e.At.IsZero() {
 		e.At = time.Now().UTC()
 	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
 	j.Entries = append(j.Entries, e)
-	return j.Save()
+	return j.save()
+}
+
+// Amend records a change to what the operation is about — a node change, a
+// new registry — as one save: fields replace those of the identity, and e is
+// appended. It returns e as recorded, stamped.
+func (j *Journal) Amend(fields map[string]string, e Entry) (Entry, error) {
+	if e.At.IsZero() {
+		e.At = time.Now().UTC()
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.Identity.Fields == nil {
+		j.Identity.Fields = map[string]string{}
+	}
+	maps.Copy(j.Identity.Fields, fields)
+	j.Entries = append(j.Entries, e)
+	return e, j.save()
 }
 
 // Completed reports whether a stage finished successfully in a previous run,
 // which is the only condition under which the engine skips it.
 func (j *Journal) Completed(stage string) (time.Time, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
 	for i := len(j.Entries) - 1; i >= 0; i-- {
 		if j.Entries[i].Stage != stage {
 			continue
@@ -224,8 +278,24 @@ func (j *Journal) Completed(stage string) (time.Time, bool) {
 	return time.Time{}, false
 }
 
+// EverCompleted reports whether a stage completed in any run, including one
+// an operator has since reopened. It answers "did this operation do that
+// work", where Completed answers "may the engine skip it".
+func (j *Journal) EverCompleted(stage string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, e := range j.Entries {
+		if e.Stage == stage && e.Status == StatusCompleted {
+			return true
+		}
+	}
+	return false
+}
+
 // LastFailure returns the most recent failed entry, for the resume banner.
 func (j *Journal) LastFailure() (Entry, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
 	for i := len(j.Entries) - 1; i >= 0; i-- {
 		if j.Entries[i].Status == StatusFailed {
 			return j.Entries[i], true
@@ -234,10 +304,33 @@ func (j *Journal) LastFailure() (Entry, bool) {
 	return Entry{}, false
 }
 
+// SetClusterID records the control-plane id once the operation knows it; the
+// next save persists it. It and ClusterIDValue lock, because the copies and
+// the emitters read the id from goroutines of their own while a stage sets
+// it.
+func (j *Journal) SetClusterID(id string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.ClusterID = id
+}
+
+// ClusterIDValue returns the control-plane id, or "" while it is not known.
+func (j *Journal) ClusterIDValue() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.ClusterID
+}
+
 // Save writes the journal atomically: a temp file in the same directory, then
 // a rename. A journal truncated by a crash mid-write would make resume read
 // garbage about a cluster that exists.
 func (j *Journal) Save() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.save()
+}
+
+func (j *Journal) save() error {
 	if j.path == "" {
 		return nil // in-memory journal (tests)
 	}
@@ -272,24 +365,34 @@ func (j *Journal) Save() error {
 	if err := tmp.Close(); err != nil {
 		return err
 	}
-	return os.Rename(tmpName, j.path)
+	if err := os.Rename(tmpName, j.path); err != nil {
+		return err
+	}
+	// Only once the local write has landed: a copy must never be ahead of
+	// the journal it copies. The copies are written off this path; see
+	// replica.
+	for _, rep := range j.replicas {
+		rep.offer(data)
+	}
+	return nil
 }
 
 // Path returns where this journal is stored, for messages that tell an
 // operator where to look.
 func (j *Journal) Path() string { return j.path }
 
-// Remove deletes the journal. Uninstall calls it last, once the hosts are
-// actually clean — a journal outliving its cluster would make the next
-// operation refuse for a cluster that no longer exists.
+// Remove deletes the journal and every copy of it. Uninstall calls it last,
+// once the hosts are actually clean — a journal outliving its cluster would
+// make the next operation refuse for a cluster that no longer exists, and a
+// copy outliving it would make the next one resume.
 func (j *Journal) Remove() error {
 	if j.path == "" {
-		return nil
+		return j.removeReplicas()
 	}
 	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
 		return err
 	}
-	return nil
+	return j.removeReplicas()
 }
 
 // JournalDir is where every cluster's journal lives.
@@ -0,0 +1,249 @@
+package stages
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The lease every operation on a cluster holds, install and upgrade alike:
+// an upgrade and an install resume on one cluster collide as surely as two
+// upgrades do.
+const (
+	LeaseNamespace = "kubenest-system"
+	LeaseName      = "kubenest-operation"
+	// LeaseDuration is how long a lease lasts unrenewed. A holder renews
+	// every third of it, so one missed renewal — a dropped SSH session, a
+	// slow API server — never expires it.
+	LeaseDuration = 90 * time.Second
+)
+
+// Annotations carry the holder's user and host: a Lease's holderIdentity is
+// one string, and the run id alone does not tell anyone whom to ask.
+const (
+	annotationUser  = "kubenest.io/user"
+	annotationHost  = "kubenest.io/host"
+	annotationSince = "kubenest.io/since"
+)
+
+// microTime is the Lease API's time format.
+const microTime = "2006-01-02T15:04:05.000000Z07:00"
+
+// KubeLease is the lock's cross-machine half as a coordination.k8s.io Lease,
+// read and written with `k3s kubectl` on a server node like everything else
+// the CLI does to a cluster.
+//
+// Every write is a create or a replace carrying the resourceVersion it read,
+// so two runs racing for one lease cannot both win: the API server refuses
+// the second write, and the loser reads the winner back and is refused by
+// name.
+//
+// Expiry is judged against this machine's clock and the holder's renewTime,
+// which was the holder's clock. Workstation clocks that disagree by more than
+// a lease duration would misjudge it; NTP makes that a misconfiguration, not a
+// case to design around.
+type KubeLease struct {
+	Runner Runner
+	// Now overrides the clock, for tests.
+	Now func() time.Time
+}
+
+func (l KubeLease) Name() string { return "the lease " + LeaseNamespace + "/" + LeaseName }
+
+func (l KubeLease) Duration() time.Duration { return LeaseDuration }
+
+// leaseObject is the slice of a Lease this reads and writes.
+type leaseObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string            `json:"name"`
+		Namespace       string            `json:"namespace"`
+		ResourceVersion string            `json:"resourceVersion,omitempty"`
+		Annotations     map[string]string `json:"annotations,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       string `json:"holderIdentity,omitempty"`
+		LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+		AcquireTime          string `json:"acquireTime,omitempty"`
+		RenewTime            string `json:"renewTime,omitempty"`
+	} `json:"spec"`
+}
+
+func (o *leaseObject) holder() Holder {
+	h := Holder{RunID: o.Spec.HolderIdentity, User: "unknown", Host: "unknown"}
+	if v := o.Metadata.Annotations[annotationUser]; v != "" {
+		h.User = v
+	}
+	if v := o.Metadata.Annotations[annotationHost]; v != "" {
+		h.Host = v
+	}
+	h.Since, _ = time.Parse(time.RFC3339, o.Metadata.Annotations[annotationSince])
+	return h
+}
+
+// expiresAt is the last renewal plus the duration the holder asked for.
+func (o *leaseObject) expiresAt() time.Time {
+	at := o.Spec.RenewTime
+	if at == "" {
+		at = o.Spec.AcquireTime
+	}
+	renewed, err := time.Parse(microTime, at)
+	if err != nil {
+		// A lease with no readable time cannot be shown to have expired,
+		// so it is treated as live: the safe reading.
+		return time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return renewed.Add(time.Duration(o.Spec.LeaseDurationSeconds) * time.Second)
+}
+
+func (l KubeLease) now() time.Time {
+	if l.Now != nil {
+		return l.Now()
+	}
+	return time.Now()
+}
+
+// get reads the lease, nil when there is none.
+func (l KubeLease) get(ctx context.Context) (*leaseObject, error) {
+	res, err := l.Runner.Run(ctx, fmt.Sprintf("sudo -n k3s kubectl get lease %s -n %s -o json --ignore-not-found", LeaseName, LeaseNamespace))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", l.Name(), err)
+	}
+	if res.ExitCode != 0 {
+		return nil, fmt.Errorf("read %s: exit %d: %s", l.Name(), res.ExitCode, firstLine(res.Stderr))
+	}
+	if strings.TrimSpace(res.Stdout) == "" {
+		return nil, nil
+	}
+	var o leaseObject
+	if err := json.Unmarshal([]byte(res.Stdout), &o); err != nil {
+		return nil, fmt.Errorf("read %s: %w", l.Name(), err)
+	}
+	return &o, nil
+}
+
+// write creates the lease, or replaces the one read as prev, holding it for h.
+// It reports a lost race as false with no error.
+func (l KubeLease) write(ctx context.Context, prev *leaseObject, h Holder) (bool, error) {
+	o := leaseObject{APIVersion: "coordination.k8s.io/v1", Kind: "Lease"}
+	o.Metadata.Name, o.Metadata.Namespace = LeaseName, LeaseNamespace
+	o.Metadata.Annotations = map[string]string{
+		annotationUser:  h.User,
+		annotationHost:  h.Host,
+		annotationSince: h.Since.UTC().Format(time.RFC3339),
+	}
+	now := l.now().UTC().Format(microTime)
+	o.Spec.HolderIdentity = h.RunID
+	o.Spec.LeaseDurationSeconds = int(LeaseDuration / time.Second)
+	o.Spec.AcquireTime, o.Spec.RenewTime = now, now
+	verb := "create"
+	if prev != nil {
+		verb = "replace"
+		o.Metadata.ResourceVersion = prev.Metadata.ResourceVersion
+		if prev.Spec.HolderIdentity == h.RunID {
+			o.Spec.AcquireTime = prev.Spec.AcquireTime
+		}
+	}
+	data, err := json.Marshal(o)
+	if err != nil {
+		return false, err
+	}
+	// The namespace exists once an install is past its kubenest-agent
+	// stage. An install takes the lease at stage 3, before then, so the
+	// namespace is created when it is missing.
+	cmd := fmt.Sprintf("(sudo -n k3s kubectl get namespace %s >/dev/null 2>&1 || sudo -n k3s kubectl create namespace %s >/dev/null) && printf '%%s' %s | base64 -d | sudo -n k3s kubectl %s -f -",
+		LeaseNamespace, LeaseNamespace, base64.StdEncoding.EncodeToString(data), verb)
+	res, err := l.Runner.Run(ctx, cmd)
+	if err != nil {
+		return false, fmt.Errorf("%s %s: %w", verb, l.Name(), err)
+	}
+	if res.ExitCode != 0 {
+		if strings.Contains(res.Stderr, "AlreadyExists") || strings.Contains(res.Stderr, "the object has been modified") {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s %s: exit %d: %s", verb, l.Name(), res.ExitCode, firstLine(res.Stderr))
+	}
+	return true, nil
+}
+
+// Acquire takes the lease when there is none, when it was released, when
+// this run already holds it, or — with breakExpired — when it has expired.
+func (l KubeLease) Acquire(ctx context.Context, h Holder, breakExpired bool) error {
+	prev, err := l.get(ctx)
+	if err != nil {
+		return err
+	}
+	if prev != nil && prev.Spec.HolderIdentity != "" && prev.Spec.HolderIdentity != h.RunID {
+		expires := prev.expiresAt()
+		if !l.now().After(expires) {
+			return &LockedError{Where: l.Name(), Holder: prev.holder()}
+		}
+		if !breakExpired {
+			return &LockedError{Where: l.Name(), Holder: prev.holder(), Expired: true, ExpiredAt: expires}
+		}
+	}
+	won, err := l.write(ctx, prev, h)
+	if err != nil {
+		return err
+	}
+	if !won {
+		// Another run wrote between the read and the write. It holds the
+		// lease now, and is live by construction.
+		winner, err := l.get(ctx)
+		if err != nil {
+			return err
+		}
+		locked := &LockedError{Where: l.Name(), Holder: Holder{RunID: "unknown", User: "unknown", Host: "unknown"}}
+		if winner != nil {
+			locked.Holder = winner.holder()
+		}
+		return locked
+	}
+	return nil
+}
+
+// Renew moves renewTime on, if h still holds the lease. A lease deleted by
+// hand is created again: nobody else holds it, and this run is still running.
+func (l KubeLease) Renew(ctx context.Context, h Holder) error {
+	prev, err := l.get(ctx)
+	if err != nil {
+		return err
+	}
+	if prev != nil && prev.Spec.HolderIdentity != h.RunID {
+		return &LockedError{Where: l.Name(), Holder: prev.holder()}
+	}
+	won, err := l.write(ctx, prev, h)
+	if err != nil {
+		return err
+	}
+	if !won {
+		// Written by someone between the read and the write; the next
+		// renewal reads who.
+		return fmt.Errorf("renew %s: it changed while being renewed", l.Name())
+	}
+	return nil
+}
+
+// Release deletes the lease if h holds it. One already taken over or gone is
+// left alone.
+func (l KubeLease) Release(ctx context.Context, h Holder) error {
+	prev, err := l.get(ctx)
+	if err != nil {
+		return err
+	}
+	if prev == nil || prev.Spec.HolderIdentity != h.RunID {
+		return nil
+	}
+	res, err := l.Runner.Run(ctx, fmt.Sprintf("sudo -n k3s kubectl delete lease %s -n %s --ignore-not-found", LeaseName, LeaseNamespace))
+	if err != nil {
+		return fmt.Errorf("delete %s: %w", l.Name(), err)
+	}
+	if res.ExitCode != 0 {
+		return fmt.Errorf("delete %s: exit %d: %s", l.Name(), res.ExitCode, firstLine(res.Stderr))
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+package stages
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"kubenest.io/cli/pkg/converge"
+)
+
+// Live is the terminal view of a run: every stage of the sequence with its
+// status and elapsed time, the run's remaining deadline, and the checks
+// converging right now with what each last saw — redrawn in place, once a
+// second and on every change.
+//
+// It exists because a line per transition scrolls away. Forty minutes into an
+// upgrade the question is "where is it, and what is it waiting on", and the
+// answer was sixty lines up, under a heartbeat per check. So the view holds
+// the answer still and lets the narrative scroll above it.
+//
+// It is an Emitter, a converge.Reporter and an io.Writer, and replaces the
+// text emitter, the text reporter and the operation's output writer together.
+// Whatever is written to it — the narrative, a resume notice, a check that
+// failed — is printed above the view, which is redrawn beneath; nothing that
+// the plain text would have said permanently is only shown in the view. The
+// nodes of a k3s join are checks like any other, one per node, so a join is
+// visibly each node's progress.
+//
+// It draws with two ANSI sequences — cursor up, clear to the end of the
+// screen — so it is only for a terminal; the caller falls back to the text
+// emitter and reporter anywhere else, and the journal, the control plane and
+// a failure's message are the same whichever was used.
+type Live struct {
+	// Title heads the view, e.g. "Installing prod-1".
+	Title string
+	// Deadline is the run's total deadline, counted down in the title.
+	Deadline time.Duration
+
+	w     io.Writer
+	width func() int
+
+	mu      sync.Mutex
+	start   time.Time
+	names   []string
+	stages  map[string]*liveStage
+	checks  map[string]converge.Event
+	pending []byte
+	drawn   int
+	running bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+type liveStage struct {
+	status  string
+	started time.Time
+	elapsed time.Duration
+	note    string
+}
+
+// liveChecks is how many converging checks the view lists; the rest are
+// counted. A fifty-agent join is fifty checks, and a view taller than the
+// terminal cannot be redrawn in place.
+const liveChecks = 8
+
+// NewLive draws to w, a terminal, trimming lines to width() columns.
+func NewLive(w io.Writer, width func() int) *Live {
+	return &Live{w: w, width: width, stages: map[string]*liveStage{}, checks: map[string]converge.Event{}}
+}
+
+// Stages lists the sequence, so stages not yet reached are shown too.
+func (l *Live) Stages(names []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.names = append([]string(nil), names...)
+}
+
+// Start draws the view and redraws it every second until Stop.
+func (l *Live) Start() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.running {
+		return
+	}
+	l.running, l.start = true, time.Now()
+	l.stop, l.done = make(chan struct{}), make(chan struct{})
+	l.redraw()
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		tick := time.NewTicker(time.Second)
+		defer tick.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-tick.C:
+				l.mu.Lock()
+				l.redraw()
+				l.mu.Unlock()
+			}
+		}
+	}(l.stop, l.done)
+}
+
+// Stop draws the view a last time and leaves it on the screen, with what is
+// written afterwards printed below it. A second Stop does nothing.
+func (l *Live) Stop() {
+	l.mu.Lock()
+	if !l.running {
+		l.mu.Unlock()
+		return
+	}
+	close(l.stop)
+	done := l.done
+	l.mu.Unlock()
+	<-done
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.redraw()
+	l.running, l.drawn = false, 0
+	if len(l.pending) > 0 {
+		_, _ = l.w.Write(l.pending)
+		l.pending = nil
+	}
+}
+
+// Emit records one stage transition.
+func (l *Live) Emit(_ context.Context, e Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.stages[e.Stage]
+	if s == nil {
+		s = &liveStage{}
+		l.stages[e.Stage] = s
+	}
+	if len(l.names) < e.StageTotal && !containsName(l.names, e.Stage) {
+		l.names = append(l.names, e.Stage)
+	}
+	skipped := strings.HasPrefix(e.Message, "skipped")
+	switch e.Status {
+	case StatusStarted:
+		s.status, s.started, s.note = "running", time.Now(), ""
+		if e.Attempt > 1 {
+			s.note = fmt.Sprintf("attempt %d/%d", e.Attempt, e.Attempts)
+		}
+		if skipped {
+			s.status = "skipped"
+		}
+	case StatusCompleted:
+		s.status, s.elapsed, s.note = "done", time.Since(s.started), ""
+		if skipped {
+			s.status, s.elapsed = "skipped", 0
+		}
+	case StatusFailed:
+		s.status, s.elapsed, s.note = "failed", time.Since(s.started), ""
+	}
+	// Why a stage is being retried, and why it failed, are what the plain
+	// text would have printed for good; they are printed above the view.
+	if (e.Status == StatusStarted && e.Attempt > 1) || e.Status == StatusFailed {
+		var line strings.Builder
+		_ = TextEmitter{W: &line}.Emit(context.Background(), e)
+		l.print(line.String())
+		return nil
+	}
+	l.redraw()
+	return nil
+}
+
+// Report records one check's progress. A check that fails is also printed
+// above the view, where it stays, as the text reporter would have.
+func (l *Live) Report(e converge.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch e.Outcome {
+	case converge.Converging:
+		l.checks[e.Check] = e
+	case converge.Fail:
+		delete(l.checks, e.Check)
+		l.print(fmt.Sprintf("✗ %s: fail after %s: %s\n", e.Check, e.Elapsed.Round(time.Second), e.State))
+		return
+	default:
+		delete(l.checks, e.Check)
+	}
+	l.redraw()
+}
+
+// Write prints p above the view. A line is printed once it is complete;
+// without a view on the screen, p is written as it is.
+func (l *Live) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.running {
+		return l.w.Write(p)
+	}
+	l.pending = append(l.pending, p...)
+	if end := strings.LastIndexByte(string(l.pending), '\n'); end >= 0 {
+		text := string(l.pending[:end+1])
+		l.pending = append(l.pending[:0], l.pending[end+1:]...)
+		l.print(text)
+	}
+	return len(p), nil
+}
+
+// print writes text where the view was and draws the view beneath it; l.mu
+// is held.
+func (l *Live) print(text string) {
+	l.erase()
+	_, _ = io.WriteString(l.w, text)
+	l.redraw()
+}
+
+// erase clears the view from the screen; l.mu is held.
+func (l *Live) erase() {
+	if l.drawn > 0 {
+		fmt.Fprintf(l.w, "\r\x1b[%dA\x1b[J", l.drawn)
+		l.drawn = 0
+	}
+}
+
+// redraw replaces the view on the screen; l.mu is held. Before Start and
+// after Stop there is no view, and output passes straight through.
+func (l *Live) redraw() {
+	if !l.running {
+		return
+	}
+	lines := l.frame(time.Now())
+	width := 0
+	if l.width != nil {
+		width = l.width()
+	}
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(trim(line, width))
+		b.WriteByte('\n')
+	}
+	l.erase()
+	_, _ = io.WriteString(l.w, b.String())
+	l.drawn = len(lines)
+}
+
+// frame renders the view's lines.
+func (l *Live) frame(now time.Time) []string {
+	elapsed := now.Sub(l.start)
+	title := fmt.Sprintf("%s · %s elapsed", l.Title, clock(elapsed))
+	if l.Deadline > 0 {
+		title += fmt.Sprintf(" · %s of the deadline left", clock(max(l.Deadline-elapsed, 0)))
+	}
+	lines := []string{title}
+
+	width := 0
+	for _, name := range l.names {
+		width = max(width, len(name))
+	}
+	for i, name := range l.names {
+		s := l.stages[name]
+		if s == nil {
+			s = &liveStage{}
+		}
+		mark, detail := "·", ""
+		switch s.status {
+		case "running":
+			mark, detail = "▸", clock(now.Sub(s.started))
+		case "done":
+			mark, detail = "✓", clock(s.elapsed)
+		case "skipped":
+			mark, detail = "✓", "skipped, completed earlier"
+		case "failed":
+			mark, detail = "✗", "FAILED after "+clock(s.elapsed)
+		}
+		if s.note != "" {
+			detail += " (" + s.note + ")"
+		}
+		lines = append(lines, strings.TrimRight(fmt.Sprintf("  %s %2d/%d %-*s  %s", mark, i+1, len(l.names), width, name, detail), " "))
+	}
+
+	if len(l.checks) > 0 {
+		names := make([]string, 0, len(l.checks))
+		for name := range l.checks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		lines = append(lines, "  waiting on:")
+		for i, name := range names {
+			if i == liveChecks {
+				lines = append(lines, fmt.Sprintf("    … and %d more", len(names)-liveChecks))
+				break
+			}
+			e := l.checks[name]
+			left := max(e.Deadline-e.Elapsed, 0)
+			line := fmt.Sprintf("    %s: %s", name, e.State)
+			if e.Deadline > 0 {
+				line += fmt.Sprintf(" (%s left)", clock(left))
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// clock is a duration as the view shows it: 42s, 3m07s, 1h02m.
+func clock(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
+// trim cuts a line to the terminal's width: a line that wraps is two lines
+// on the screen and one in the count, and the next redraw would leave half of
+// it behind.
+func trim(line string, width int) string {
+	if width <= 1 || utf8.RuneCountInString(line) < width {
+		return line
+	}
+	runes := []rune(line)
+	return string(runes[:width-2]) + "…"
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package stages_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/stages"
+)
+
+// screen is the last frame drawn: everything after the last clear, which is
+// what a terminal would be showing beneath the scrolled lines.
+func screen(out string) string {
+	if i := strings.LastIndex(out, "\x1b[J"); i >= 0 {
+		return out[i+len("\x1b[J"):]
+	}
+	return out
+}
+
+// The view shows every stage, reached or not, and what each converging check
+// last saw — during a join, each node.
+func TestLiveShowsStagesAndConvergingChecks(t *testing.T) {
+	var out bytes.Buffer
+	l := stages.NewLive(&out, func() int { return 200 })
+	l.Title, l.Deadline = "Installing prod-1", time.Hour
+	l.Stages([]string{stagePreflight, stageCerts, stageStorage})
+	l.Start()
+	defer l.Stop()
+
+	ctx := context.Background()
+	_ = l.Emit(ctx, stages.Event{Stage: stagePreflight, StageIndex: 1, StageTotal: 3, Status: stages.StatusStarted, Message: "skipped: completed 2026-10-17T09:00:00Z"})
+	_ = l.Emit(ctx, stages.Event{Stage: stagePreflight, StageIndex: 1, StageTotal: 3, Status: stages.StatusCompleted, Message: "skipped: completed 2026-10-17T09:00:00Z"})
+	_ = l.Emit(ctx, stages.Event{Stage: stageCerts, StageIndex: 2, StageTotal: 3, Status: stages.StatusStarted})
+	l.Report(converge.Event{Check: "k3s-agent 10.0.1.11", Outcome: converge.Converging, State: converge.State{Object: "10.0.1.11", Status: "started"}})
+	l.Report(converge.Event{Check: "k3s-agent 10.0.1.12", Outcome: converge.Converging, State: converge.State{Object: "10.0.1.12", Status: "started"}})
+	l.Report(converge.Event{Check: "k3s-agent 10.0.1.11", Outcome: converge.Pass})
+	l.Report(converge.Event{Check: "nodes-ready", Outcome: converge.Converging,
+		State: converge.State{Object: "node worker-2", Status: "NotReady"}, Elapsed: time.Minute, Deadline: 5 * time.Minute})
+
+	got := screen(out.String())
+	for _, want := range []string{
+		"Installing prod-1 · 0s elapsed · 1h00m of the deadline left",
+		"✓  1/3 " + stagePreflight,
+		"skipped, completed earlier",
+		"▸  2/3 " + stageCerts,
+		"·  3/3 " + stageStorage,
+		"k3s-agent 10.0.1.12: 10.0.1.12 is started",
+		"nodes-ready: node worker-2 is NotReady (4m00s left)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("view lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "10.0.1.11") {
+		t.Errorf("a node that has joined is still shown:\n%s", got)
+	}
+}
+
+// What the plain text would have printed for good is printed above the view
+// and stays there: the narrative, a retry's reason, a failed check, a failed
+// stage.
+func TestLivePrintsPermanentLinesAboveTheView(t *testing.T) {
+	var out bytes.Buffer
+	l := stages.NewLive(&out, nil)
+	l.Title = "Upgrading prod-1"
+	l.Stages([]string{stageStorage})
+	l.Start()
+
+	ctx := context.Background()
+	_, _ = l.Write([]byte("Resuming: the previous run "))
+	if strings.Contains(out.String(), "Resuming") {
+		t.Error("printed half a line")
+	}
+	_, _ = l.Write([]byte("stopped at stage storage.\n"))
+	_ = l.Emit(ctx, stages.Event{Stage: stageStorage, StageIndex: 1, StageTotal: 1, Status: stages.StatusStarted, Attempt: 2, Attempts: 3, Message: "retrying: timed out"})
+	l.Report(converge.Event{Check: "longhorn", Outcome: converge.Fail, State: converge.State{Object: "pod longhorn-manager-x in longhorn-system", Status: "CrashLoopBackOff"}, Elapsed: 3 * time.Minute})
+	_ = l.Emit(ctx, stages.Event{Stage: stageStorage, StageIndex: 1, StageTotal: 1, Status: stages.StatusFailed, Message: "longhorn-manager is CrashLoopBackOff"})
+	l.Stop()
+	l.Stop()
+	_, _ = l.Write([]byte("Journal: /tmp/j.json\n"))
+
+	text := out.String()
+	for _, want := range []string{
+		"Resuming: the previous run stopped at stage storage.\n",
+		"(retrying: timed out)",
+		"✗ longhorn: fail after 3m0s: pod longhorn-manager-x in longhorn-system is CrashLoopBackOff\n",
+		"FAILED: longhorn-manager is CrashLoopBackOff\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("output lacks %q:\n%s", want, text)
+		}
+	}
+	// The last frame stays on the screen, and what follows it is written as
+	// it is, beneath.
+	last := screen(text)
+	if !strings.Contains(last, "✗  1/1 "+stageStorage) || !strings.HasSuffix(last, "\nJournal: /tmp/j.json\n") {
+		t.Errorf("final screen:\n%s", last)
+	}
+}
+
+// A line wider than the terminal would wrap, and a wrapped line is one the
+// next redraw does not clear.
+func TestLiveTrimsLinesToTheTerminal(t *testing.T) {
+	var out bytes.Buffer
+	l := stages.NewLive(&out, func() int { return 40 })
+	l.Stages([]string{stageCerts})
+	l.Start()
+	l.Report(converge.Event{Check: "certificate", Outcome: converge.Converging,
+		State: converge.State{Object: "certificate kubenest-gateway-tls in traefik", Status: "False", Detail: "waiting for the ACME order"}})
+	l.Stop()
+	for _, line := range strings.Split(strings.TrimSuffix(screen(out.String()), "\n"), "\n") {
+		if n := len([]rune(line)); n >= 40 {
+			t.Errorf("%d columns: %q", n, line)
+		}
+	}
+}
+
+// Before Start and without a terminal, nothing is drawn: Live writes exactly
+// what it is given.
+func TestLiveWithoutAViewWritesThrough(t *testing.T) {
+	var out bytes.Buffer
+	l := stages.NewLive(&out, nil)
+	_, _ = l.Write([]byte("Installing platform bundle 2026.10 "))
+	_, _ = l.Write([]byte("on 3 node(s).\n"))
+	if out.String() != "Installing platform bundle 2026.10 on 3 node(s).\n" {
+		t.Errorf("out = %q", out.String())
+	}
+}
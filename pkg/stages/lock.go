@@ -0,0 +1,256 @@
+package stages
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Two engineers running the same upgrade at the same moment would both write
+// one journal and both drive one set of system-upgrade-controller Plans, and
+// neither journal would then describe the cluster. So a run holds a lock for
+// as long as stages.Execute runs, in two halves:
+//
+//	the journal file   an OS lock on <journal>.lock on this machine. The OS
+//	                   drops it when the process exits, however it exits,
+//	                   so it can never be stale and is never broken.
+//	a Lease            on the cluster, for runs from two machines. A lease
+//	                   outlives a process that dies without releasing it,
+//	                   which is why it expires: a holder renews it while it
+//	                   runs, and one that has stopped renewing for a whole
+//	                   lease duration is provably dead. --break-lock takes
+//	                   over an expired lease and only an expired one — a
+//	                   flag that broke a live lock would be the race this
+//	                   exists to prevent, with a flag in front of it.
+
+// Holder identifies the run holding a lock: enough for the engineer it
+// refuses to go and find the engineer it belongs to.
+type Holder struct {
+	RunID string    `json:"run_id"`
+	User  string    `json:"user"`
+	Host  string    `json:"host"`
+	Since time.Time `json:"since"`
+}
+
+// NewHolder describes this process as the holder of run runID.
+func NewHolder(runID string) Holder {
+	h := Holder{RunID: runID, User: "unknown", Host: "unknown", Since: time.Now().UTC()}
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		h.User = u.Username
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		h.Host = host
+	}
+	return h
+}
+
+func (h Holder) String() string {
+	return fmt.Sprintf("run %s by %s on %s, since %s", h.RunID, h.User, h.Host, h.Since.Format(time.RFC3339))
+}
+
+// LockedError refuses a run because another run holds the lock.
+type LockedError struct {
+	// Where names the lock: the journal's lock file, or the lease.
+	Where  string
+	Holder Holder
+	// Expired is set for a lease its holder stopped renewing, at ExpiredAt:
+	// that holder is dead, and --break-lock may take the lease over.
+	Expired   bool
+	ExpiredAt time.Time
+	// Local is set for the journal's lock file, which the OS releases with
+	// its process and so is only ever held by a live one.
+	Local bool
+}
+
+func (e *LockedError) Error() string {
+	switch {
+	case e.Expired:
+		return fmt.Sprintf("%s is held by %s, but its lease expired at %s without being renewed, so that run is dead.\nPass --break-lock to take the lease over; this run then resumes from the journal",
+			e.Where, e.Holder, e.ExpiredAt.Format(time.RFC3339))
+	case e.Local:
+		return fmt.Sprintf("%s is held by %s, a process still running on this machine.\nWait for it to finish or stop it; the lock goes with the process, so --break-lock has nothing to break",
+			e.Where, e.Holder)
+	default:
+		return fmt.Sprintf("%s is held by %s, which renewed it in the last lease period and is still running.\nTwo runs at once would write one journal and drive the same nodes: wait for it to finish, or ask %s on %s to stop it",
+			e.Where, e.Holder, e.Holder.User, e.Holder.Host)
+	}
+}
+
+// ErrLockLost cancels a run whose lease another run has taken over. It only
+// happens to a run that stopped renewing for a whole lease duration — asleep,
+// or cut off from the cluster — and was then broken by --break-lock: the
+// other run is now driving the cluster, and this one must stop.
+var ErrLockLost = errors.New("this run's lease was taken over by another run")
+
+// Lease is the cross-machine half of the lock, held on the cluster.
+type Lease interface {
+	// Name says where the lease is, for messages.
+	Name() string
+	// Duration is how long the lease lasts unrenewed.
+	Duration() time.Duration
+	// Acquire takes the lease for h, or returns a *LockedError naming the
+	// run that has it. An expired lease is taken only when breakExpired is
+	// set; a lease already held by h's run is taken again, for a retry.
+	Acquire(ctx context.Context, h Holder, breakExpired bool) error
+	// Renew extends h's hold, returning a *LockedError if another run
+	// holds the lease now.
+	Renew(ctx context.Context, h Holder) error
+	// Release gives the lease up, if h still holds it.
+	Release(ctx context.Context, h Holder) error
+}
+
+// Lock is one run's hold on an operation: the journal's lock file from
+// LockJournal, and the lease from Hold once there is a cluster to hold one on.
+type Lock struct {
+	Holder    Holder
+	breakLock bool
+	logf      func(format string, args ...any)
+	file      *os.File
+
+	mu     sync.Mutex
+	lease  Lease
+	stop   chan struct{}
+	done   chan struct{}
+	cancel context.CancelCauseFunc
+	lost   error
+}
+
+// LockJournal takes the OS lock on the journal at journalPath for h, before
+// the journal is opened: a journal read while another process writes it is
+// already stale. breakLock is kept for Hold; it never applies to this half.
+func LockJournal(journalPath string, h Holder, breakLock bool, logf func(format string, args ...any)) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(journalPath), dirMode); err != nil {
+		return nil, err
+	}
+	path := journalPath + ".lock"
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, fileMode)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		defer f.Close()
+		if !errors.Is(err, errLockHeld) {
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+		locked := &LockedError{Where: "the journal " + journalPath, Local: true, Holder: Holder{RunID: "unknown", User: "unknown", Host: "unknown"}}
+		if data, readErr := io.ReadAll(f); readErr == nil {
+			_ = json.Unmarshal(data, &locked.Holder)
+		}
+		return nil, locked
+	}
+	// The holder is written into the lock file for the next process's
+	// refusal to name. It is what the lock file says, not the lock itself.
+	data, err := json.Marshal(h)
+	if err == nil {
+		if err = f.Truncate(0); err == nil {
+			_, err = f.WriteAt(data, 0)
+		}
+	}
+	if err != nil {
+		_ = unlockFile(f)
+		f.Close()
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	if logf == nil {
+		logf = func(string, ...any) {}
+	}
+	return &Lock{Holder: h, breakLock: breakLock, logf: logf, file: f}, nil
+}
+
+// Hold takes the lease and renews it, every third of its duration, until
+// Release. A renewal that fails for want of a connection is warned about and
+// retried; one that finds another run holding the lease cancels the context
+// from Guard, with ErrLockLost. Holding again is a no-op.
+func (l *Lock) Hold(ctx context.Context, lease Lease) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.lease != nil {
+		return nil
+	}
+	if err := lease.Acquire(ctx, l.Holder, l.breakLock); err != nil {
+		return err
+	}
+	l.lease = lease
+	l.stop, l.done = make(chan struct{}), make(chan struct{})
+	go l.renew(lease, l.stop, l.done)
+	return nil
+}
+
+func (l *Lock) renew(lease Lease, stop, done chan struct{}) {
+	defer close(done)
+	tick := time.NewTicker(lease.Duration() / 3)
+	defer tick.Stop()
+	failing := false
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), lease.Duration()/3)
+		err := lease.Renew(ctx, l.Holder)
+		cancel()
+		var locked *LockedError
+		switch {
+		case errors.As(err, &locked):
+			l.logf("error: %s was taken over by %s; stopping this run", lease.Name(), locked.Holder)
+			l.mu.Lock()
+			l.lost = fmt.Errorf("%w: %s", ErrLockLost, locked.Holder)
+			if l.cancel != nil {
+				l.cancel(l.lost)
+			}
+			l.mu.Unlock()
+			return
+		case err != nil && !failing:
+			failing = true
+			l.logf("warning: could not renew %s: %v\nthe run continues; if the lease expires before a renewal lands, another run may break it", lease.Name(), err)
+		case err == nil && failing:
+			failing = false
+			l.logf("%s is being renewed again", lease.Name())
+		}
+	}
+}
+
+// Guard returns ctx, cancelled if the lease is lost. Execute runs under it.
+func (l *Lock) Guard(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	l.mu.Lock()
+	l.cancel = cancel
+	if l.lost != nil {
+		cancel(l.lost)
+	}
+	l.mu.Unlock()
+	return ctx, func() { cancel(nil) }
+}
+
+// Release stops renewing, gives the lease up and unlocks the journal. A lease
+// that cannot be released is warned about and left to expire: the next run
+// waits out its duration, or breaks it.
+func (l *Lock) Release() {
+	l.mu.Lock()
+	lease, stop, done := l.lease, l.stop, l.done
+	l.lease = nil
+	l.mu.Unlock()
+	if lease != nil {
+		close(stop)
+		<-done
+		ctx, cancel := context.WithTimeout(context.Background(), lease.Duration()/3)
+		if err := lease.Release(ctx, l.Holder); err != nil {
+			l.logf("warning: could not release %s: %v\nit expires %s after its last renewal; until then the next run is refused", lease.Name(), err, lease.Duration())
+		}
+		cancel()
+	}
+	if l.file != nil {
+		_ = l.file.Truncate(0)
+		_ = unlockFile(l.file)
+		_ = l.file.Close()
+		l.file = nil
+	}
+}
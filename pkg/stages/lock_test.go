@@ -0,0 +1,180 @@
+package stages_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"kubenest.io/cli/pkg/component/componenttest"
+	"kubenest.io/cli/pkg/sshx"
+	"kubenest.io/cli/pkg/stages"
+)
+
+// A second run of one operation on this machine is refused by name while the
+// first holds the journal, and admitted the moment it lets go.
+func TestJournalLockRefusesASecondRunNamingTheFirst(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prod-1-upgrade.json")
+	first := stages.Holder{RunID: "run-a", User: "alice", Host: "ws-7", Since: time.Now()}
+	lock, err := stages.LockJournal(path, first, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = stages.LockJournal(path, stages.Holder{RunID: "run-b", User: "bob", Host: "ws-9"}, true, nil)
+	var locked *stages.LockedError
+	if !errors.As(err, &locked) || locked.Holder.RunID != "run-a" || !locked.Local {
+		t.Fatalf("err = %v, want the journal refused as held by run-a", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "alice") || !strings.Contains(msg, "ws-7") {
+		t.Errorf("the refusal must name who holds the lock: %s", msg)
+	}
+
+	lock.Release()
+	again, err := stages.LockJournal(path, stages.Holder{RunID: "run-b"}, false, nil)
+	if err != nil {
+		t.Fatalf("a released lock still refuses: %v", err)
+	}
+	again.Release()
+}
+
+// fakeKubectl keeps one Lease the way the API server would: create refuses
+// an existing object, replace refuses a stale resourceVersion.
+func fakeKubectl(t *testing.T) (*componenttest.FakeRunner, func() map[string]any) {
+	t.Helper()
+	var stored map[string]any
+	version := 0
+	payload := regexp.MustCompile(`printf '%s' (\S+) \|`)
+	fake := &componenttest.FakeRunner{Respond: func(cmd string) (sshx.Result, error) {
+		switch {
+		case strings.Contains(cmd, "get lease"):
+			if stored == nil {
+				return sshx.Result{}, nil
+			}
+			data, _ := json.Marshal(stored)
+			return sshx.Result{Stdout: string(data)}, nil
+		case strings.Contains(cmd, "delete lease"):
+			stored = nil
+			return sshx.Result{}, nil
+		case strings.Contains(cmd, "base64 -d"):
+			data, err := base64.StdEncoding.DecodeString(payload.FindStringSubmatch(cmd)[1])
+			if err != nil {
+				return sshx.Result{}, err
+			}
+			var obj map[string]any
+			if err := json.Unmarshal(data, &obj); err != nil {
+				return sshx.Result{}, err
+			}
+			meta := obj["metadata"].(map[string]any)
+			if strings.HasSuffix(cmd, "create -f -") {
+				if stored != nil {
+					return sshx.Result{ExitCode: 1, Stderr: `Error from server (AlreadyExists): leases.coordination.k8s.io "kubenest-operation" already exists`}, nil
+				}
+			} else if stored == nil || meta["resourceVersion"] != stored["metadata"].(map[string]any)["resourceVersion"] {
+				return sshx.Result{ExitCode: 1, Stderr: "Operation cannot be fulfilled: the object has been modified; please apply your changes to the latest version and try again"}, nil
+			}
+			version++
+			meta["resourceVersion"] = strconv.Itoa(version)
+			stored = obj
+		}
+		return sshx.Result{}, nil
+	}}
+	return fake, func() map[string]any { return stored }
+}
+
+// Across machines the lease decides: a live holder is refused whatever the
+// flag says, an expired one is refused without --break-lock and taken over
+// with it, and the run that holds it is named every time.
+func TestLeaseIsBrokenOnlyOnceItHasExpired(t *testing.T) {
+	fake, stored := fakeKubectl(t)
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	lease := stages.KubeLease{Runner: fake, Now: func() time.Time { return now }}
+	alice := stages.Holder{RunID: "run-a", User: "alice", Host: "ws-7", Since: now}
+	bob := stages.Holder{RunID: "run-b", User: "bob", Host: "ws-9", Since: now}
+	ctx := context.Background()
+
+	if err := lease.Acquire(ctx, alice, false); err != nil {
+		t.Fatal(err)
+	}
+	spec := stored()["spec"].(map[string]any)
+	if spec["holderIdentity"] != "run-a" || spec["leaseDurationSeconds"] != 90.0 {
+		t.Fatalf("lease = %v", stored())
+	}
+
+	now = now.Add(30 * time.Second)
+	err := lease.Acquire(ctx, bob, true)
+	var locked *stages.LockedError
+	if !errors.As(err, &locked) || locked.Expired || locked.Holder.User != "alice" || locked.Holder.Host != "ws-7" {
+		t.Fatalf("a live lease was not refused by name, even with --break-lock: %v", err)
+	}
+
+	// Alice's renewal lands; then she goes silent past a whole duration.
+	if err := lease.Renew(ctx, alice); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * stages.LeaseDuration)
+	err = lease.Acquire(ctx, bob, false)
+	if !errors.As(err, &locked) || !locked.Expired || !strings.Contains(err.Error(), "--break-lock") {
+		t.Fatalf("an expired lease must be refused with the way to break it, got %v", err)
+	}
+	if err := lease.Acquire(ctx, bob, true); err != nil {
+		t.Fatalf("--break-lock on an expired lease: %v", err)
+	}
+
+	// Alice wakes up: her renewal finds Bob, and her release leaves his
+	// lease alone.
+	if err := lease.Renew(ctx, alice); !errors.As(err, &locked) || locked.Holder.RunID != "run-b" {
+		t.Fatalf("renewing a lease taken over = %v", err)
+	}
+	if err := lease.Release(ctx, alice); err != nil || stored() == nil {
+		t.Fatalf("a stale holder released the new holder's lease: %v", err)
+	}
+	if err := lease.Release(ctx, bob); err != nil || stored() != nil {
+		t.Fatalf("release = %v, lease %v", err, stored())
+	}
+}
+
+// takenLease is a lease another run takes over at the first renewal.
+type takenLease struct{ released bool }
+
+func (takenLease) Name() string                                       { return "the lease" }
+func (takenLease) Duration() time.Duration                            { return 30 * time.Millisecond }
+func (takenLease) Acquire(context.Context, stages.Holder, bool) error { return nil }
+func (l *takenLease) Release(context.Context, stages.Holder) error    { l.released = true; return nil }
+func (takenLease) Renew(context.Context, stages.Holder) error {
+	return &stages.LockedError{Where: "the lease", Holder: stages.Holder{RunID: "run-b", User: "bob", Host: "ws-9"}}
+}
+
+// A run whose lease was taken over stops: the context it runs under is
+// cancelled, with the reason.
+func TestALostLeaseStopsTheRun(t *testing.T) {
+	lock, err := stages.LockJournal(filepath.Join(t.TempDir(), "prod-1.json"), stages.Holder{RunID: "run-a"}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lease := &takenLease{}
+	if err := lock.Hold(context.Background(), lease); err != nil {
+		t.Fatal(err)
+	}
+	ctx, stop := lock.Guard(context.Background())
+	defer stop()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the run kept going after losing its lease")
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, stages.ErrLockLost) || !strings.Contains(cause.Error(), "bob") {
+		t.Errorf("cause = %v", cause)
+	}
+	lock.Release()
+	if !lease.released {
+		t.Error("Release did not give the lease up")
+	}
+}
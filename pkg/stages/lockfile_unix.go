@@ -0,0 +1,24 @@
+//go:build !windows
+
+package stages
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errLockHeld = errors.New("held by another process")
+
+// lockFile takes an exclusive flock without waiting for it.
+func lockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return errLockHeld
+	}
+	return err
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,32 @@
+//go:build windows
+
+package stages
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+var errLockHeld = errors.New("held by another process")
+
+// Windows locks are mandatory: a locked byte cannot be read by another
+// process. The lock is taken on one byte far past the holder written at the
+// start of the file, so a refused process can still read who holds it.
+const lockOffsetHigh = 0x7fffffff
+
+// lockFile takes an exclusive lock without waiting for it.
+func lockFile(f *os.File) error {
+	ol := windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return errLockHeld
+	}
+	return err
+}
+
+func unlockFile(f *os.File) error {
+	ol := windows.Overlapped{OffsetHigh: lockOffsetHigh}
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &ol)
+}
@@ -0,0 +1,183 @@
+package stages
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+
+	"kubenest.io/cli/pkg/converge"
+)
+
+// NDJSON writes everything an operation reports as one JSON object per line,
+// for a pipeline to read instead of scraping the text meant for a person.
+//
+// It is an Emitter and a converge.Reporter like the text ones, and is
+// composed with them rather than replacing anything: the stages, the journal
+// and what a failure says are the same whichever way they are printed. Every
+// line carries a "type" — stage, converge, preflight, gate or result — and
+// the last line of a run is always its result, so a reader that stops at the
+// first result line has seen the whole run.
+//
+// Unlike TextReporter, every converge event is written, not only the state
+// changes: throttling is a decision about a terminal, and a machine reading
+// the stream can make its own.
+type NDJSON struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewNDJSON writes to w.
+func NewNDJSON(w io.Writer) *NDJSON { return &NDJSON{w: w} }
+
+// Finding kinds: the structured results of an operation's checks, which are
+// neither stage transitions nor convergence.
+const (
+	FindingPreflight = "preflight"
+	FindingGate      = "gate"
+)
+
+// Findings receives an operation's check results one at a time — every
+// preflight result of an install, every gate verdict of an upgrade — for
+// output that wants them individually rather than as the summary the text
+// prints. v must marshal to a JSON object.
+type Findings interface {
+	Finding(kind string, v any)
+}
+
+type stageLine struct {
+	Type          string `json:"type"`
+	RunID         string `json:"run_id"`
+	Stage         string `json:"stage"`
+	StageIndex    int    `json:"stage_index"`
+	StageTotal    int    `json:"stage_total"`
+	Component     string `json:"component,omitempty"`
+	Status        Status `json:"status"`
+	BundleVersion string `json:"bundle_version"`
+	ReasonCode    string `json:"reason_code,omitempty"`
+	Message       string `json:"message,omitempty"`
+	Attempt       int    `json:"attempt,omitempty"`
+	Attempts      int    `json:"attempts,omitempty"`
+}
+
+type convergeLine struct {
+	Type    string           `json:"type"`
+	Check   string           `json:"check"`
+	Outcome converge.Outcome `json:"outcome"`
+	State   struct {
+		Object string `json:"object,omitempty"`
+		Status string `json:"status,omitempty"`
+		Detail string `json:"detail,omitempty"`
+	} `json:"state"`
+	ElapsedMS  int64 `json:"elapsed_ms"`
+	DeadlineMS int64 `json:"deadline_ms"`
+}
+
+type resultLine struct {
+	Type      string   `json:"type"`
+	OK        bool     `json:"ok"`
+	Ran       []string `json:"ran"`
+	Skipped   []string `json:"skipped"`
+	Paused    string   `json:"paused,omitempty"`
+	ElapsedMS int64    `json:"elapsed_ms"`
+	// The failure, when there was one, as the journal records it.
+	Stage      string `json:"stage,omitempty"`
+	Component  string `json:"component,omitempty"`
+	ReasonCode string `json:"reason_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Emit writes one stage transition.
+func (n *NDJSON) Emit(_ context.Context, e Event) error {
+	return n.write(stageLine{
+		Type:          "stage",
+		RunID:         e.RunID,
+		Stage:         e.Stage,
+		StageIndex:    e.StageIndex,
+		StageTotal:    e.StageTotal,
+		Component:     e.Component,
+		Status:        e.Status,
+		BundleVersion: e.BundleVersion,
+		ReasonCode:    e.ReasonCode,
+		Message:       e.Message,
+		Attempt:       e.Attempt,
+		Attempts:      e.Attempts,
+	})
+}
+
+// Report writes one convergence observation. A reporter has nowhere to
+// return an error to; a write that fails here fails again, visibly, on the
+// next stage event.
+func (n *NDJSON) Report(e converge.Event) {
+	line := convergeLine{
+		Type:       "converge",
+		Check:      e.Check,
+		Outcome:    e.Outcome,
+		ElapsedMS:  e.Elapsed.Milliseconds(),
+		DeadlineMS: e.Deadline.Milliseconds(),
+	}
+	line.State.Object, line.State.Status, line.State.Detail = e.State.Object, e.State.Status, e.State.Detail
+	_ = n.write(line)
+}
+
+// Finding writes one check result with its kind as the line's type.
+func (n *NDJSON) Finding(kind string, v any) {
+	raw, err := json.Marshal(v)
+	if err != nil || len(raw) < 2 || raw[0] != '{' {
+		return
+	}
+	head := `{"type":` + strconv.Quote(kind)
+	if len(raw) > 2 {
+		head += ","
+	}
+	line := append([]byte(head), raw[1:]...)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, _ = n.w.Write(append(line, '\n'))
+}
+
+// Result writes the run's last line: what ran, what a resume skipped, where a
+// clean pause stopped, and the failure if err is one. OK is true only for a
+// run that finished — a paused run has not, though it has not failed either,
+// and says so with Paused and no error. Stage, component and reason code are
+// the StageError's, so a pipeline can branch on the reason without parsing
+// the message.
+func (n *NDJSON) Result(r Result, err error) error {
+	line := resultLine{
+		Type:      "result",
+		OK:        err == nil,
+		Ran:       nonNil(r.Ran),
+		Skipped:   nonNil(r.Skipped),
+		Paused:    r.Paused,
+		ElapsedMS: r.Elapsed.Milliseconds(),
+	}
+	var stageErr *StageError
+	var paused *PausedError
+	switch {
+	case errors.As(err, &stageErr):
+		line.Stage, line.Component, line.ReasonCode = stageErr.Stage, stageErr.Component, stageErr.ReasonCode
+		line.Error = Sanitize(stageErr.Err.Error())
+	case errors.As(err, &paused):
+		// Paused already says where; there is nothing to report as an error.
+	case err != nil:
+		line.Error = Sanitize(err.Error())
+	}
+	return n.write(line)
+}
+
+func (n *NDJSON) write(v any) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return json.NewEncoder(n.w).Encode(v)
+}
+
+// nonNil keeps an empty list a list on the wire, so a reader can iterate
+// "ran" without checking it for null first.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
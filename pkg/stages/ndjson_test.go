@@ -0,0 +1,106 @@
+package stages_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/stages"
+)
+
+func lines(t *testing.T, out []byte) []map[string]any {
+	t.Helper()
+	var got []map[string]any
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("not one JSON object per line: %q: %v", sc.Text(), err)
+		}
+		got = append(got, line)
+	}
+	return got
+}
+
+// A pipeline reads the same run the terminal shows: every transition with
+// its run id and position, convergence and check results between them, and a
+// last line it can branch on without parsing prose.
+func TestNDJSONStreamsTheRunAndEndsWithItsResult(t *testing.T) {
+	var buf bytes.Buffer
+	stream := stages.NewNDJSON(&buf)
+	s := newController(t, stream, filepath.Join(t.TempDir(), "journal.json"),
+		identity("prod-1", map[string]string{"bundle": "1.0"}))
+	var ran []string
+	table := sequence(t, &ran, map[string]error{stageK3sServer: errors.New("node 10.0.1.10 is NotReady")})
+	table[0].Run = func(context.Context) error {
+		stream.Finding(stages.FindingPreflight, struct {
+			Check   string `json:"check"`
+			Outcome string `json:"outcome"`
+		}{"memory", "pass"})
+		return nil
+	}
+	table[1].Run = func(context.Context) error {
+		stream.Report(converge.Event{
+			Check: "register", Outcome: converge.Converging,
+			State:   converge.State{Object: "cluster prod-1", Status: "Pending"},
+			Elapsed: 2 * time.Second, Deadline: time.Minute,
+		})
+		return nil
+	}
+
+	res, err := stages.Execute(context.Background(), s, table)
+	if err == nil {
+		t.Fatal("want the k3s-server failure")
+	}
+	if err := stream.Result(res, err); err != nil {
+		t.Fatal(err)
+	}
+
+	got := lines(t, buf.Bytes())
+	types := map[string]int{}
+	for _, line := range got {
+		types[line["type"].(string)]++
+	}
+	// started+completed for preflight and register, started+failed for
+	// k3s-server.
+	if types["stage"] != 6 || types["preflight"] != 1 || types["converge"] != 1 || types["result"] != 1 {
+		t.Fatalf("line types = %v", types)
+	}
+
+	first := got[0]
+	if first["run_id"] != "run-1" || first["stage"] != stagePreflight || first["stage_index"] != 1.0 || first["stage_total"] != 13.0 || first["status"] != "started" {
+		t.Errorf("first stage line = %v", first)
+	}
+	if got[1]["check"] != "memory" || got[1]["outcome"] != "pass" {
+		t.Errorf("finding line = %v", got[1])
+	}
+	for _, line := range got {
+		if line["type"] == "converge" {
+			state, _ := line["state"].(map[string]any)
+			if line["elapsed_ms"] != 2000.0 || line["deadline_ms"] != 60000.0 || state["object"] != "cluster prod-1" {
+				t.Errorf("converge line = %v", line)
+			}
+		}
+	}
+
+	failed := got[len(got)-2]
+	if failed["status"] != "failed" || failed["reason_code"] != "K3S_SERVER_FAILED" || failed["message"] != "node 10.0.1.10 is NotReady" {
+		t.Errorf("failed stage line = %v", failed)
+	}
+	result := got[len(got)-1]
+	if result["type"] != "result" || result["ok"] != false || result["stage"] != stageK3sServer || result["reason_code"] != "K3S_SERVER_FAILED" {
+		t.Errorf("result line = %v", result)
+	}
+	if ran, _ := result["ran"].([]any); len(ran) != 2 {
+		t.Errorf("result ran = %v, want the two stages that completed", result["ran"])
+	}
+	if skipped, ok := result["skipped"].([]any); !ok || len(skipped) != 0 {
+		t.Errorf("result skipped = %v, want an empty list rather than null", result["skipped"])
+	}
+}
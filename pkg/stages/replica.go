@@ -0,0 +1,341 @@
+package stages
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"kubenest.io/cli/pkg/api"
+	"kubenest.io/cli/pkg/sshx"
+)
+
+// Replica is a copy of a journal kept off this machine, so an operation
+// started on one workstation can be resumed or undone from another.
+//
+// The local file stays THE journal. A replica is written after every local
+// save and read only when a journal is recovered; it never decides anything
+// while an operation runs. That is what lets a replica be best-effort: a copy
+// that could not be written costs the next workstation its head start, while
+// failing the install over it would let the backup break the thing it backs
+// up.
+type Replica interface {
+	// Name says where the copy is, for messages.
+	Name() string
+	// Write replaces the copy with data, the whole journal.
+	Write(ctx context.Context, data []byte) error
+	// Read returns the copy, or nil and no error when there is none.
+	Read(ctx context.Context) ([]byte, error)
+	// Remove deletes the copy. One already gone is not an error.
+	Remove(ctx context.Context) error
+}
+
+// replicaTimeout bounds one replica write. A write runs off the append path,
+// but Flush waits for it, and a hung copy must not hold the end of a run.
+const replicaTimeout = 30 * time.Second
+
+// replica is one copy and the writer that keeps it up to date. Saves hand it
+// the journal and return; a goroutine of its own writes it, one write at a
+// time, and a save made while a write is in flight replaces any snapshot
+// still waiting — the journal is written whole, so only the latest matters.
+// A slow copy therefore costs the copy its freshness, never a stage its
+// transition: stages append concurrently, behind the journal's lock, and a
+// write taken under that lock would hold every one of them.
+type replica struct {
+	Replica
+	logf func(format string, args ...any)
+
+	mu sync.Mutex
+	// latest is the snapshot waiting to be written, nil when none is.
+	latest []byte
+	// idle is closed when the writer has nothing left to write; nil while no
+	// writer runs.
+	idle chan struct{}
+	// failing is the writer's alone: whether the last write failed.
+	failing bool
+}
+
+// Replicate keeps a copy of the journal in r from the next save on, and
+// removes it with the journal. A write that fails is reported through logf,
+// once until it works again rather than at every transition.
+func (j *Journal) Replicate(r Replica, logf func(format string, args ...any)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.replicas = append(j.replicas, &replica{Replica: r, logf: logf})
+}
+
+// Flush waits for every copy to be written with the journal as it was last
+// saved. A run calls it before it ends, with its connections still open; a
+// write that fails is reported as any other, and Flush returns regardless.
+func (j *Journal) Flush() {
+	j.mu.Lock()
+	replicas := slices.Clone(j.replicas)
+	j.mu.Unlock()
+	for _, rep := range replicas {
+		rep.flush()
+	}
+}
+
+// offer hands the writer a snapshot, starting it when it is not running.
+func (rep *replica) offer(data []byte) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	rep.latest = data
+	if rep.idle != nil {
+		return
+	}
+	rep.idle = make(chan struct{})
+	go rep.run(rep.idle)
+}
+
+// run writes the latest snapshot until none is left.
+func (rep *replica) run(idle chan struct{}) {
+	for {
+		rep.mu.Lock()
+		data := rep.latest
+		rep.latest = nil
+		if data == nil {
+			rep.idle = nil
+			close(idle)
+			rep.mu.Unlock()
+			return
+		}
+		rep.mu.Unlock()
+		rep.write(data)
+	}
+}
+
+func (rep *replica) flush() {
+	rep.mu.Lock()
+	idle := rep.idle
+	rep.mu.Unlock()
+	if idle != nil {
+		<-idle
+	}
+}
+
+func (rep *replica) write(data []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaTimeout)
+	defer cancel()
+	err := rep.Write(ctx, data)
+	switch {
+	case err != nil && !rep.failing:
+		rep.failing = true
+		if rep.logf != nil {
+			rep.logf("warning: could not copy the journal to %s: %v\nthe journal on this machine is complete; only resuming from another machine is affected", rep.Name(), err)
+		}
+	case err == nil && rep.failing:
+		rep.failing = false
+		if rep.logf != nil {
+			rep.logf("the journal is being copied to %s again", rep.Name())
+		}
+	}
+}
+
+// RecoverJournal brings the journal at path up to date from its replicas,
+// before it is opened. Journals only ever grow, so the copy with the most
+// entries is the latest — the local file included, which may be the stale
+// one when another workstation has resumed since. A copy for a different
+// operation or cluster than kind and cluster is ignored: a server reused by
+// another cluster carries that cluster's record, not this one's.
+//
+// It returns the name of the replica the journal was recovered from, or ""
+// when the local file was already the latest (or there is no journal
+// anywhere). A replica that cannot be read is reported through logf and
+// skipped: recovery is for when something has already been lost, and one
+// unreachable copy must not stop the other being used.
+func RecoverJournal(ctx context.Context, path, kind, cluster string, replicas []Replica, logf func(format string, args ...any)) (string, error) {
+	best := 0
+	if local, err := ReadJournal(path); err == nil {
+		best = len(local.Entries)
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	var recovered *Journal
+	from := ""
+	for _, r := range replicas {
+		data, err := r.Read(ctx)
+		if err != nil {
+			if logf != nil {
+				logf("warning: could not read the journal copy on %s: %v", r.Name(), err)
+			}
+			continue
+		}
+		if len(data) == 0 {
+			continue
+		}
+		var j Journal
+		if err := json.Unmarshal(data, &j); err != nil {
+			if logf != nil {
+				logf("warning: the journal copy on %s is unreadable: %v", r.Name(), err)
+			}
+			continue
+		}
+		if j.Identity.Kind != kind || j.Identity.Cluster != cluster {
+			continue
+		}
+		if len(j.Entries) > best {
+			best, recovered, from = len(j.Entries), &j, r.Name()
+		}
+	}
+	if recovered == nil {
+		return "", nil
+	}
+	recovered.path = path
+	if err := recovered.Save(); err != nil {
+		return "", fmt.Errorf("write the journal recovered from %s: %w", from, err)
+	}
+	return from, nil
+}
+
+// ControlPlaneReplica keeps the copy in the control plane, against the
+// cluster. Until the operation knows its cluster id there is nowhere to keep
+// it, and a write is a no-op: the whole journal is written every time, so
+// the first write after registration carries everything before it.
+type ControlPlaneReplica struct {
+	Client    *api.Client
+	ClusterID func() string
+	Kind      string
+}
+
+func (r ControlPlaneReplica) Name() string { return "the control plane" }
+
+func (r ControlPlaneReplica) Write(ctx context.Context, data []byte) error {
+	id := r.ClusterID()
+	if id == "" {
+		return nil
+	}
+	return r.Client.PutOperationJournal(ctx, id, r.Kind, data)
+}
+
+func (r ControlPlaneReplica) Read(ctx context.Context) ([]byte, error) {
+	id := r.ClusterID()
+	if id == "" {
+		return nil, nil
+	}
+	return r.Client.OperationJournal(ctx, id, r.Kind)
+}
+
+func (r ControlPlaneReplica) Remove(ctx context.Context) error {
+	id := r.ClusterID()
+	if id == "" {
+		return nil
+	}
+	return r.Client.DeleteOperationJournal(ctx, id, r.Kind)
+}
+
+// ServerJournalDir is where a server node keeps its copies: root's alone,
+// beside nothing k3s owns, so removing k3s does not remove the record of the
+// operation that is removing it.
+const ServerJournalDir = "/var/lib/kubenest/journal"
+
+// Runner runs one command on a node. *sshx.Client implements it.
+type Runner interface {
+	Run(ctx context.Context, command string) (sshx.Result, error)
+}
+
+// InputRunner is a Runner that can stream stdin. *sshx.Client implements it,
+// and a server copy of the journal needs it: the journal only grows, and
+// past a couple hundred KB it no longer fits in one command line.
+type InputRunner interface {
+	Runner
+	RunInput(ctx context.Context, command string, stdin io.Reader) (sshx.Result, error)
+}
+
+// ServerReplica keeps the copy in a root-only file on a server node.
+type ServerReplica struct {
+	Runner  Runner
+	Address string
+	Kind    string
+}
+
+func (r ServerReplica) Name() string { return r.Address + ":" + r.path() }
+
+func (r ServerReplica) path() string { return ServerJournalDir + "/" + safeFileName(r.Kind) + ".json" }
+
+// Write replaces the file by rename, so a copy interrupted mid-write leaves
+// the previous one whole. The content is streamed on stdin rather than put in
+// the command line, which has room for a young journal and not for the one
+// a cluster's hooks, retries and node changes make of it.
+func (r ServerReplica) Write(ctx context.Context, data []byte) error {
+	path := r.path()
+	ir, ok := r.Runner.(InputRunner)
+	if !ok {
+		return fmt.Errorf("write %s: the SSH runner must support stdin", path)
+	}
+	cmd := fmt.Sprintf("sudo -n install -d -m 0700 %s && sudo -n install -m 0600 /dev/stdin %s.tmp && sudo -n mv %s.tmp %s",
+		ServerJournalDir, path, path, path)
+	res, err := ir.RunInput(ctx, cmd, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if res.ExitCode != 0 {
+		return fmt.Errorf("write %s: exit %d: %s", path, res.ExitCode, firstLine(res.Stderr))
+	}
+	return nil
+}
+
+func (r ServerReplica) Read(ctx context.Context) ([]byte, error) {
+	path := r.path()
+	res, err := r.Runner.Run(ctx, fmt.Sprintf("if sudo -n test -f %s; then sudo -n cat %s; fi", path, path))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if res.ExitCode != 0 {
+		return nil, fmt.Errorf("read %s: exit %d: %s", path, res.ExitCode, firstLine(res.Stderr))
+	}
+	if strings.TrimSpace(res.Stdout) == "" {
+		return nil, nil
+	}
+	return []byte(res.Stdout), nil
+}
+
+func (r ServerReplica) Remove(ctx context.Context) error {
+	return runRemote(ctx, r.Runner, "sudo -n rm -f "+r.path(), "remove "+r.path())
+}
+
+func runRemote(ctx context.Context, r Runner, cmd, what string) error {
+	res, err := r.Run(ctx, cmd)
+	if err != nil {
+		return fmt.Errorf("%s: %w", what, err)
+	}
+	if res.ExitCode != 0 {
+		return fmt.Errorf("%s: exit %d: %s", what, res.ExitCode, firstLine(res.Stderr))
+	}
+	return nil
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// removeReplicas deletes every copy, reporting the ones that could not be.
+// A write still in flight lands first, so it cannot put back a copy just
+// removed.
+func (j *Journal) removeReplicas() error {
+	j.Flush()
+	var failed []string
+	for _, rep := range j.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), replicaTimeout)
+		err := rep.Remove(ctx)
+		cancel()
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", rep.Name(), err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not remove the journal's copies (%s); remove them before installing a cluster of this name again, or it will resume from them", strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+package stages_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kubenest.io/cli/pkg/component/componenttest"
+	"kubenest.io/cli/pkg/sshx"
+	"kubenest.io/cli/pkg/stages"
+)
+
+// memReplica is a copy held in memory, failing while err is set.
+type memReplica struct {
+	name   string
+	data   []byte
+	err    error
+	writes int
+}
+
+func (r *memReplica) Name() string { return r.name }
+
+func (r *memReplica) Write(_ context.Context, data []byte) error {
+	r.writes++
+	if r.err != nil {
+		return r.err
+	}
+	r.data = append([]byte(nil), data...)
+	return nil
+}
+
+func (r *memReplica) Read(context.Context) ([]byte, error) { return r.data, r.err }
+
+func (r *memReplica) Remove(context.Context) error {
+	r.data = nil
+	return r.err
+}
+
+func journalJSON(t *testing.T, id stages.Identity, n int) []byte {
+	t.Helper()
+	j := stages.Journal{Identity: id}
+	for i := 0; i < n; i++ {
+		j.Entries = append(j.Entries, stages.Entry{Stage: fmt.Sprintf("stage-%d", i), Status: stages.StatusCompleted})
+	}
+	data, err := json.Marshal(&j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// Every save reaches the copy, and a copy that cannot be written neither
+// fails the operation nor repeats its warning at every transition.
+func TestAFailingReplicaWarnsOnceAndNeverFailsTheJournal(t *testing.T) {
+	j, err := stages.OpenJournal(filepath.Join(t.TempDir(), "prod-1.json"), identity("prod-1", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &memReplica{name: "10.0.1.10", err: errors.New("connection reset")}
+	var warnings []string
+	j.Replicate(server, func(format string, args ...any) { warnings = append(warnings, fmt.Sprintf(format, args...)) })
+
+	for _, stage := range []string{stagePreflight, stageRegister} {
+		if err := j.Append(stages.Entry{Stage: stage, Status: stages.StatusCompleted}); err != nil {
+			t.Fatalf("a replica failure reached the journal: %v", err)
+		}
+		j.Flush()
+	}
+	if server.writes != 2 || len(warnings) != 1 || !strings.Contains(warnings[0], "10.0.1.10") {
+		t.Fatalf("writes = %d, warnings = %q", server.writes, warnings)
+	}
+
+	server.err = nil
+	if err := j.Append(stages.Entry{Stage: stageK3sServer, Status: stages.StatusStarted}); err != nil {
+		t.Fatal(err)
+	}
+	j.Flush()
+	var back stages.Journal
+	if err := json.Unmarshal(server.data, &back); err != nil || len(back.Entries) != 3 {
+		t.Errorf("the copy is not the whole journal: %s", server.data)
+	}
+	if len(warnings) != 2 || !strings.Contains(warnings[1], "again") {
+		t.Errorf("recovery was not reported: %q", warnings)
+	}
+
+	if err := j.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if server.data != nil {
+		t.Error("removing the journal left its copy to be recovered")
+	}
+}
+
+// The longest copy is the latest one, because journals only grow; a copy of
+// another cluster's journal on a reused server is never taken for this one.
+func TestRecoverTakesTheLongestCopyOfThisCluster(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prod-1.json")
+	j, err := stages.OpenJournal(path, identity("prod-1", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Append(stages.Entry{Stage: stagePreflight, Status: stages.StatusCompleted}); err != nil {
+		t.Fatal(err)
+	}
+
+	other := &memReplica{name: "10.0.1.10", data: journalJSON(t, identity("staging", nil), 9)}
+	plane := &memReplica{name: "the control plane", data: journalJSON(t, identity("prod-1", nil), 5)}
+	unreachable := &memReplica{name: "10.0.1.11", err: errors.New("no route to host")}
+	var warnings []string
+	from, err := stages.RecoverJournal(context.Background(), path, "install", "prod-1",
+		[]stages.Replica{other, plane, unreachable},
+		func(format string, args ...any) { warnings = append(warnings, fmt.Sprintf(format, args...)) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != "the control plane" {
+		t.Fatalf("recovered from %q", from)
+	}
+	got, err := stages.ReadJournal(path)
+	if err != nil || got.Identity.Cluster != "prod-1" || len(got.Entries) != 5 {
+		t.Fatalf("recovered journal = %+v, %v", got, err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "no route to host") {
+		t.Errorf("warnings = %q", warnings)
+	}
+
+	// Now the local journal is the latest: nothing to recover.
+	from, err = stages.RecoverJournal(context.Background(), path, "install", "prod-1", []stages.Replica{plane}, nil)
+	if err != nil || from != "" {
+		t.Errorf("recovered %q, %v from a copy no newer than the journal", from, err)
+	}
+}
+
+// stdinRunner is a FakeRunner that also takes stdin, answering a streamed
+// command as the fake would answer it and keeping what it was given.
+type stdinRunner struct {
+	componenttest.FakeRunner
+	stored string
+}
+
+func (r *stdinRunner) RunInput(_ context.Context, command string, stdin io.Reader) (sshx.Result, error) {
+	body, err := io.ReadAll(stdin)
+	if err != nil {
+		return sshx.Result{}, err
+	}
+	r.stored = string(body)
+	return r.Run(context.Background(), command)
+}
+
+// The server copy is root's alone, replaced by rename, streamed rather than
+// put in the command line however large the journal grows, and reads back
+// byte for byte; a server with no copy is no copy, not an error.
+func TestServerReplicaRoundTrips(t *testing.T) {
+	fake := &stdinRunner{}
+	fake.Respond = func(cmd string) (sshx.Result, error) {
+		if strings.Contains(cmd, "cat ") {
+			return sshx.Result{Stdout: fake.stored}, nil
+		}
+		return sshx.Result{}, nil
+	}
+	r := stages.ServerReplica{Runner: fake, Address: "10.0.1.10", Kind: "install"}
+
+	if data, err := r.Read(context.Background()); data != nil || err != nil {
+		t.Fatalf("empty server read %q, %v", data, err)
+	}
+	want := journalJSON(t, identity("prod-1", nil), 5000)
+	if err := r.Write(context.Background(), want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.Read(context.Background())
+	if err != nil || string(got) != string(want) {
+		t.Fatalf("read back %d bytes, %v; want %d", len(got), err, len(want))
+	}
+	write := fake.Commands()[1]
+	for _, want := range []string{"install -d -m 0700 " + stages.ServerJournalDir, "install -m 0600 /dev/stdin", "mv " + stages.ServerJournalDir + "/install.json.tmp"} {
+		if !strings.Contains(write, want) {
+			t.Errorf("write %q lacks %q", write, want)
+		}
+	}
+	if len(write) > 512 {
+		t.Errorf("the journal travelled in the command line: %d bytes", len(write))
+	}
+
+	// A runner that cannot stream is refused, not worked around.
+	plain := stages.ServerReplica{Runner: &componenttest.FakeRunner{}, Address: "10.0.1.10", Kind: "install"}
+	if err := plain.Write(context.Background(), want); err == nil || !strings.Contains(err.Error(), "stdin") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+// slowReplica is a copy whose writes wait for release.
+type slowReplica struct {
+	release chan struct{}
+	mu      sync.Mutex
+	writes  [][]byte
+}
+
+func (r *slowReplica) Name() string { return "the control plane" }
+
+func (r *slowReplica) Write(ctx context.Context, data []byte) error {
+	<-r.release
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writes = append(r.writes, data)
+	return nil
+}
+
+func (r *slowReplica) Read(context.Context) ([]byte, error) { return nil, nil }
+
+func (r *slowReplica) Remove(context.Context) error { return nil }
+
+// A copy that is slow to write holds up no transition: appends return at
+// once, the saves made meanwhile collapse into the latest, and Flush waits
+// for it to land.
+func TestASlowReplicaNeverHoldsTheJournal(t *testing.T) {
+	j, err := stages.OpenJournal(filepath.Join(t.TempDir(), "prod-1.json"), identity("prod-1", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow := &slowReplica{release: make(chan struct{})}
+	j.Replicate(slow, nil)
+
+	start := time.Now()
+	for _, stage := range []string{stagePreflight, stageRegister, stageK3sServer, stageAgent} {
+		if err := j.Append(stages.Entry{Stage: stage, Status: stages.StatusCompleted}); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := j.Completed(stage); !ok {
+			t.Fatalf("%s is not recorded", stage)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("appends waited %s on the copy", elapsed)
+	}
+
+	close(slow.release)
+	j.Flush()
+	slow.mu.Lock()
+	defer slow.mu.Unlock()
+	if len(slow.writes) > 2 {
+		t.Errorf("wrote %d snapshots, want the one in flight and the latest", len(slow.writes))
+	}
+	var last stages.Journal
+	if err := json.Unmarshal(slow.writes[len(slow.writes)-1], &last); err != nil || len(last.Entries) != 4 {
+		t.Errorf("the last copy is not the latest journal: %d entries, %v", len(last.Entries), err)
+	}
+}
+
+// idReplica reads the cluster id as ControlPlaneReplica does, from the
+// writer's goroutine.
+type idReplica struct {
+	memReplica
+	clusterID func() string
+	mu        sync.Mutex
+	seen      []string
+}
+
+func (r *idReplica) Write(ctx context.Context, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seen = append(r.seen, r.clusterID())
+	return nil
+}
+
+// The id is set by a stage while the copies are being written; under -race
+// this is the test that the two never touch it unlocked.
+func TestTheClusterIDIsSetWhileCopiesAreWritten(t *testing.T) {
+	j, err := stages.OpenJournal(filepath.Join(t.TempDir(), "prod-1.json"), identity("prod-1", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &idReplica{clusterID: j.ClusterIDValue}
+	j.Replicate(r, nil)
+	if err := j.Append(stages.Entry{Stage: stagePreflight, Status: stages.StatusCompleted}); err != nil {
+		t.Fatal(err)
+	}
+	j.SetClusterID("c-123")
+	if err := j.Append(stages.Entry{Stage: stageRegister, Status: stages.StatusCompleted}); err != nil {
+		t.Fatal(err)
+	}
+	j.Flush()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if last := r.seen[len(r.seen)-1]; last != "c-123" {
+		t.Errorf("the last copy was written for %q", last)
+	}
+}
@@ -0,0 +1,80 @@
+package stages
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rerun is an operator's instruction to redo stages the journal records as
+// completed: someone deleted the Traefik HelmChart by hand, and resume would
+// skip platform-networking because the journal says it is done.
+//
+// It only ever makes a stage run again. Nothing is undone first — the stage
+// re-applies what it applies, as a resume does — so the engine's rule that
+// nothing is automatically undone holds for a rerun too.
+type Rerun struct {
+	// Stages are redone by name (--rerun-stage).
+	Stages []string
+	// From redoes every stage from this one on (--from-stage).
+	From string
+}
+
+// Empty reports whether there is nothing to redo.
+func (r Rerun) Empty() bool { return len(r.Stages) == 0 && r.From == "" }
+
+// Reopen validates r against the sequence and records every stage it names
+// that the journal has completed as reopened, for run runID, so Execute runs
+// it again. It returns the stages reopened, in sequence order; one named but
+// not completed is already going to run and needs no entry.
+//
+// The entries are the record that an operator, not the engine, decided a
+// stage needed redoing. Nothing is written unless every name is valid: a typo
+// in the third name must not reopen the first two.
+func Reopen(j *Journal, sequence []Stage, r Rerun, runID string) ([]string, error) {
+	reopen := map[string]string{}
+	for _, name := range r.Stages {
+		i := Index(sequence, name)
+		if i == 0 {
+			return nil, unknownStage("--rerun-stage", name, sequence)
+		}
+		if sequence[i-1].AlwaysRun {
+			return nil, fmt.Errorf("--rerun-stage %s: %s runs on every run already, so there is nothing to redo", name, name)
+		}
+		reopen[name] = "operator: --rerun-stage " + name
+	}
+	if r.From != "" {
+		from := Index(sequence, r.From)
+		if from == 0 {
+			return nil, unknownStage("--from-stage", r.From, sequence)
+		}
+		for _, stage := range sequence[from-1:] {
+			if _, named := reopen[stage.Name]; !named && !stage.AlwaysRun {
+				reopen[stage.Name] = "operator: --from-stage " + r.From
+			}
+		}
+	}
+
+	var reopened []string
+	for _, stage := range sequence {
+		detail, ok := reopen[stage.Name]
+		if !ok {
+			continue
+		}
+		if _, done := j.Completed(stage.Name); !done {
+			continue
+		}
+		if err := j.Append(Entry{Stage: stage.Name, Status: StatusReopened, Detail: detail, RunID: runID}); err != nil {
+			return reopened, fmt.Errorf("write the journal: %w", err)
+		}
+		reopened = append(reopened, stage.Name)
+	}
+	return reopened, nil
+}
+
+func unknownStage(flag, name string, sequence []Stage) error {
+	names := make([]string, len(sequence))
+	for i, s := range sequence {
+		names[i] = s.Name
+	}
+	return fmt.Errorf("%s %q is not a stage of this operation; the stages are %s", flag, name, strings.Join(names, ", "))
+}
@@ -0,0 +1,112 @@
+package stages_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"kubenest.io/cli/pkg/stages"
+)
+
+// completedRun runs the whole sequence once, so every stage is completed.
+func completedRun(t *testing.T) *testController {
+	t.Helper()
+	s := newSession(t, &recorder{})
+	var ran []string
+	if _, err := stages.Execute(context.Background(), s, sequence(t, &ran, nil)); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// Someone deleted the Traefik HelmChart by hand: --rerun-stage runs that one
+// stage again on a resume that would otherwise skip everything, and the
+// journal says who asked.
+func TestRerunStageRunsACompletedStageAgain(t *testing.T) {
+	s := completedRun(t)
+	s.id = "run-2"
+	var ran []string
+	table := sequence(t, &ran, nil)
+
+	reopened, err := stages.Reopen(s.journal, table, stages.Rerun{Stages: []string{stageNetworking}}, "run-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(reopened, []string{stageNetworking}) {
+		t.Fatalf("reopened = %v", reopened)
+	}
+	last, _ := s.journal.Last()
+	if last.Status != stages.StatusReopened || last.RunID != "run-2" || !strings.Contains(last.Detail, "--rerun-stage") {
+		t.Errorf("the operator's instruction is not on the record: %+v", last)
+	}
+
+	if _, err := stages.Execute(context.Background(), s, table); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{stagePreflight, stageRegister, stageNetworking, stageVerify}
+	if !slices.Equal(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+	if !s.journal.EverCompleted(stageNetworking) {
+		t.Error("the rerun stage is not completed again")
+	}
+}
+
+// --from-stage reopens the rest of the sequence, and an instruction a failed
+// run never reached is still standing for the resume after it.
+func TestFromStageReopensTheRestAndSurvivesAFailure(t *testing.T) {
+	s := completedRun(t)
+	var ran []string
+	reopened, err := stages.Reopen(s.journal, sequence(t, &ran, nil), stages.Rerun{From: stageBackup}, "run-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// verify always runs, so it is not reopened.
+	if want := []string{stageBackup, stageDay2, stageAgent, stageProfiles, stageRecord}; !slices.Equal(reopened, want) {
+		t.Fatalf("reopened = %v, want %v", reopened, want)
+	}
+
+	_, err = stages.Execute(context.Background(), s, sequence(t, &ran, map[string]error{stageDay2: errors.New("kured is CrashLoopBackOff")}))
+	if err == nil {
+		t.Fatal("want the day2 failure")
+	}
+	ran = nil
+	if _, err := stages.Execute(context.Background(), s, sequence(t, &ran, nil)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{stagePreflight, stageRegister, stageDay2, stageAgent, stageProfiles, stageRecord, stageVerify}
+	if !slices.Equal(ran, want) {
+		t.Errorf("the resume ran %v, want %v", ran, want)
+	}
+}
+
+// A name that is not a stage, or a stage that runs anyway, is refused before
+// anything is written: a typo in the second name must not reopen the first.
+func TestRerunRefusesBadNamesWithoutWriting(t *testing.T) {
+	s := completedRun(t)
+	entries := len(s.journal.Entries)
+	var ran []string
+	table := sequence(t, &ran, nil)
+
+	cases := map[string]struct {
+		rerun stages.Rerun
+		want  string
+	}{
+		"unknown stage": {stages.Rerun{Stages: []string{stageNetworking, "platform-netwrking"}}, "the stages are preflight, register"},
+		"unknown from":  {stages.Rerun{From: "storage"}, "--from-stage"},
+		"always runs":   {stages.Rerun{Stages: []string{stagePreflight}}, "every run already"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := stages.Reopen(s.journal, table, tc.rerun, "run-2")
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("err = %v, want it to contain %q", err, tc.want)
+			}
+			if len(s.journal.Entries) != entries {
+				t.Errorf("a refused rerun wrote to the journal")
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package stages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"kubenest.io/cli/pkg/api"
+	"kubenest.io/cli/pkg/converge"
+)
+
+// Retry is a stage's bounded retry policy: how many times Execute runs it
+// before writing the failed entry, and which failures are worth another try.
+//
+// It exists because a chart repo answering 502 for a minute, or an image pull
+// that outlives its converge window, used to fail the whole operation — and
+// the operator's re-run then repeated preflight and register to get back to
+// the stage that had merely been unlucky. A retry costs the stage's own
+// duration; a re-run costs that plus everything before it plus a person.
+//
+// The zero value runs a stage once. That is deliberate, and it is how a stage
+// opts out: a retry re-runs the stage from the top, so only a stage that is
+// safe to run twice in one process may declare a policy. The install's
+// register stage mints credentials, and every mint rotates: a second one after
+// a timeout that was really a success would invalidate what the first issued.
+// It declares none.
+type Retry struct {
+	// Attempts is the most times the stage runs, the first included. Zero
+	// and one both mean once.
+	Attempts int
+	// Backoff is the wait before the second attempt, doubling before each
+	// one after it.
+	Backoff time.Duration
+	// Retriable says whether a failure is worth another attempt. Nil means
+	// IsTransient.
+	Retriable func(error) bool
+}
+
+// DefaultRetry is the policy for a stage that converges components: three
+// attempts, thirty seconds apart and then a minute. Long enough for a chart
+// repo or registry to come back, short enough that a real failure is
+// reported in minutes rather than after a retry storm.
+var DefaultRetry = Retry{Attempts: 3, Backoff: 30 * time.Second}
+
+func (r Retry) attempts() int {
+	if r.Attempts < 1 {
+		return 1
+	}
+	return r.Attempts
+}
+
+func (r Retry) retriable(err error) bool {
+	if r.Retriable != nil {
+		return r.Retriable(err)
+	}
+	return IsTransient(err)
+}
+
+// backoff is the wait before attempt n, for n of two and up.
+func (r Retry) backoff(n int) time.Duration {
+	d := r.Backoff
+	for i := 2; i < n; i++ {
+		d *= 2
+	}
+	return d
+}
+
+// transientError marks a failure as one that may not happen again.
+type transientError struct{ err error }
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+// Transient marks err as worth retrying, for a failure IsTransient cannot
+// recognise by its type: a helm job that logged a 502, say. Nil stays nil.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err: err}
+}
+
+// IsTransient is the default answer to "would the same attempt, a little
+// later, plausibly succeed?":
+//
+//	marked by Transient          the stage said so
+//	a check stuck on something   an image pull, an API server or a chart
+//	outside the cluster          repo not answering (retryFixes)
+//	a control-plane 5xx or 429   the control plane, not the request
+//	a network error              a dropped or refused connection, a timeout
+//
+// Everything else is not: a refusal, a bad flag, a rendering error, and a
+// check whose deadline passed on anything else. A pod in CrashLoopBackOff or
+// Pending on a missing volume fails the same way the second time, and
+// retrying it only delays the message that names the fix — and the
+// diagnostics, which are captured when the stage finally fails. A stage
+// whose checks are worth retrying whatever they saw says so with
+// RetriesChecks.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	var marked *transientError
+	if errors.As(err, &marked) {
+		return true
+	}
+	var failed *converge.FailError
+	if errors.As(err, &failed) {
+		return retryFixes(failed.Result.Last)
+	}
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Status >= 500 || apiErr.Status == 429
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// RetriesChecks is a Retriable for a stage that opts every failed check into
+// a retry, not only those IsTransient recognises: one whose checks wait on
+// something IsTransient cannot see, and whose re-run starts them over.
+func RetriesChecks(err error) bool {
+	var failed *converge.FailError
+	return errors.As(err, &failed) || IsTransient(err)
+}
+
+// retryFixes says whether a check's last observation is of something a retry
+// can fix: a wait on a registry, an API server or a chart repo that was not
+// answering, rather than on the cluster itself.
+func retryFixes(last converge.State) bool {
+	switch last.Status {
+	case "ErrImagePull", "ImagePullBackOff", "unobservable":
+		return true
+	}
+	if strings.Contains(last.Status, "not answering") {
+		return true
+	}
+	for _, cause := range unanswered {
+		if strings.Contains(last.Detail, cause) {
+			return true
+		}
+	}
+	return false
+}
+
+// unanswered are the words a failed pull or request leaves in a check's
+// detail when the far end, not the request, was the problem.
+var unanswered = []string{
+	"429 Too Many Requests", "502 Bad Gateway", "503 Service Unavailable", "504 Gateway Timeout",
+	"i/o timeout", "connection refused", "connection reset", "TLS handshake timeout",
+}
+
+// retryable reports whether Execute may run stage again after attempt failed
+// with err. A run that has been stopped — cancelled, out of its total
+// deadline, or its lease taken over — is never retried, whatever the error
+// looks like: the next attempt would fail on the same context. Nor is a
+// failed hook: it is a site's script, and whether running it twice is safe
+// is the site's to say by running it again.
+func retryable(ctx context.Context, stage Stage, attempt int, err error) bool {
+	var hookErr *HookError
+	if ctx.Err() != nil || errors.Is(err, ErrPaused) || errors.Is(err, ErrLockLost) || errors.As(err, &hookErr) {
+		return false
+	}
+	return attempt < stage.Retry.attempts() && stage.Retry.retriable(err)
+}
+
+// sleep waits d or until ctx is done, reporting which.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// attemptLabel is "attempt 2/3", for the messages that carry it.
+func attemptLabel(attempt, attempts int) string {
+	return fmt.Sprintf("attempt %d/%d", attempt, attempts)
+}
@@ -0,0 +1,161 @@
+package stages_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"kubenest.io/cli/pkg/api"
+	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/stages"
+)
+
+// flaky fails its first n runs with err, then succeeds.
+func flaky(name string, ran *[]string, n int, err error) stages.StageFunc {
+	return func(context.Context) error {
+		*ran = append(*ran, name)
+		if n > 0 {
+			n--
+			return err
+		}
+		return nil
+	}
+}
+
+// A chart download that timed out once is retried in the same process: the
+// attempt is on the record, the console sees "attempt 2/3" and never sees
+// the stage failed, and the operator never re-runs preflight to get past it.
+func TestATransientFailureIsRetriedAndRecorded(t *testing.T) {
+	rec := &recorder{}
+	s := newSession(t, rec)
+	var ran []string
+	timeout := (converge.Result{Check: "traefik", Outcome: converge.Fail, Last: converge.State{Object: "job helm-install-traefik", Status: "Failed", Detail: "502 Bad Gateway"}}).Err()
+	table := sequence(t, &ran, nil)
+	table[4].Retry = stages.Retry{Attempts: 3, Backoff: time.Millisecond}
+	table[4].Run = flaky(stageNetworking, &ran, 1, timeout)
+
+	if _, err := stages.Execute(context.Background(), s, table); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(strings.Join(ran, " "), stageNetworking); n != 2 {
+		t.Fatalf("%s ran %d times, want 2", stageNetworking, n)
+	}
+
+	var got []string
+	for _, e := range rec.events {
+		if e.Stage != stageNetworking {
+			continue
+		}
+		got = append(got, fmt.Sprintf("%s %d/%d", e.Status, e.Attempt, e.Attempts))
+		if e.Status == stages.StatusFailed {
+			t.Errorf("a retried attempt reached the wire as failed: %+v", e)
+		}
+		if e.Attempt == 2 && e.Status == stages.StatusStarted && !strings.Contains(e.Message, "attempt 2/3, retrying after: traefik") {
+			t.Errorf("the retry does not say why: %q", e.Message)
+		}
+	}
+	if want := []string{"started 1/3", "started 2/3", "completed 2/3"}; !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+
+	var journalled []string
+	for _, e := range s.journal.Entries {
+		if e.Stage == stageNetworking {
+			journalled = append(journalled, fmt.Sprintf("%s %d", e.Status, e.Attempt))
+		}
+	}
+	if want := []string{"started 1", "retrying 1", "started 2", "completed 2"}; !slices.Equal(journalled, want) {
+		t.Errorf("journal = %v, want %v", journalled, want)
+	}
+}
+
+// A refusal fails the same way every time: it is reported at once, with the
+// attempt it failed on, rather than after two backoffs of waiting for it.
+func TestANonTransientFailureIsNotRetried(t *testing.T) {
+	rec := &recorder{}
+	s := newSession(t, rec)
+	var ran []string
+	table := sequence(t, &ran, nil)
+	table[4].Retry = stages.Retry{Attempts: 3, Backoff: time.Hour}
+	table[4].Run = flaky(stageNetworking, &ran, 3, errors.New("traefik: values.yaml: unknown field \"ingressClas\""))
+
+	_, err := stages.Execute(context.Background(), s, table)
+	var stageErr *stages.StageError
+	if !errors.As(err, &stageErr) || stageErr.Stage != stageNetworking {
+		t.Fatalf("err = %v", err)
+	}
+	if n := strings.Count(strings.Join(ran, " "), stageNetworking); n != 1 {
+		t.Errorf("%s ran %d times, want 1", stageNetworking, n)
+	}
+	last, _ := s.journal.Last()
+	if last.Status != stages.StatusFailed || last.Attempt != 1 {
+		t.Errorf("last entry = %+v", last)
+	}
+}
+
+// A stage that declares no policy runs once whatever the failure: minting
+// credentials twice rotates them, so register must be able to say no.
+func TestAStageWithoutAPolicyIsNotRetried(t *testing.T) {
+	rec := &recorder{}
+	s := newSession(t, rec)
+	var ran []string
+	table := sequence(t, &ran, nil)
+	table[1].Run = flaky(stageRegister, &ran, 1, &api.Error{Status: 503})
+
+	if _, err := stages.Execute(context.Background(), s, table); err == nil {
+		t.Fatal("want the register failure")
+	}
+	if !slices.Equal(ran, []string{stagePreflight, stageRegister}) {
+		t.Errorf("ran %v", ran)
+	}
+	for _, e := range rec.events {
+		if e.Attempt != 0 || e.Attempts != 0 {
+			t.Errorf("a stage that runs once is numbered: %+v", e)
+		}
+	}
+}
+
+// stuck is a check that failed on last.
+func stuck(last converge.State) error {
+	return converge.Result{Check: "ready", Outcome: converge.Fail, Last: last}.Err()
+}
+
+// A check whose deadline passed is final unless what it last saw is a wait a
+// retry can fix, or the stage opts its checks in.
+func TestIsTransient(t *testing.T) {
+	cases := map[string]struct {
+		err  error
+		want bool
+	}{
+		"marked":         {fmt.Errorf("install traefik: %w", stages.Transient(errors.New("helm job logged 502"))), true},
+		"image pull":     {fmt.Errorf("wrap: %w", stuck(converge.State{Object: "pod traefik-7c9f in kube-system", Status: "ImagePullBackOff"})), true},
+		"chart repo 502": {stuck(converge.State{Object: "job helm-install-traefik", Status: "Failed", Detail: "502 Bad Gateway"}), true},
+		"api unanswered": {stuck(converge.State{Object: "nodes", Status: "the API server is not answering yet"}), true},
+		"crash loop":     {stuck(converge.State{Object: "pod longhorn-manager-x in longhorn-system", Status: "CrashLoopBackOff"}), false},
+		"deadline":       {converge.Result{Check: "nodes-ready", Outcome: converge.Fail}.Err(), false},
+		"server error":   {&api.Error{Status: 502}, true},
+		"rate limited":   {&api.Error{Status: 429}, true},
+		"client error":   {&api.Error{Status: 403}, false},
+		"plain":          {errors.New("no --backup-target given"), false},
+		"nil":            {nil, false},
+	}
+	for name, tc := range cases {
+		if got := stages.IsTransient(tc.err); got != tc.want {
+			t.Errorf("%s: IsTransient(%v) = %v, want %v", name, tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestRetriesChecks(t *testing.T) {
+	crash := stuck(converge.State{Object: "pod longhorn-manager-x in longhorn-system", Status: "CrashLoopBackOff"})
+	if !stages.RetriesChecks(crash) {
+		t.Error("an opted-in stage does not retry a failed check")
+	}
+	if !stages.RetriesChecks(&api.Error{Status: 503}) || stages.RetriesChecks(errors.New("no --backup-target given")) {
+		t.Error("opting checks in changed what else is transient")
+	}
+}
@@ -40,6 +40,18 @@ var (
 // CrashLoopBackOff — volume group kubenest-vg not found" — and sanitizing it
 // into "install failed" would defeat the whole failure path.
 func Sanitize(s string) string {
+	s = strings.TrimSpace(Redact(s))
+	if len(s) > DetailLimit {
+		const note = "… (truncated)"
+		s = s[:DetailLimit-len(note)] + note
+	}
+	return s
+}
+
+// Redact strips the credential-shaped runs Sanitize does, and nothing else:
+// it is for text that is kept whole, such as a diagnostics capture, where a
+// truncated describe is no use and a leaked token still is a leak.
+func Redact(s string) string {
 	s = pemKey.ReplaceAllString(s, "[redacted private key]")
 	s = jwt.ReplaceAllString(s, "[redacted token]")
 	s = cliToken.ReplaceAllString(s, "[redacted token]")
@@ -50,11 +62,6 @@ func Sanitize(s string) string {
 		}
 		return m // lowercase hex digest, or an uppercase constant — not a secret
 	})
-	s = strings.TrimSpace(s)
-	if len(s) > DetailLimit {
-		const note = "… (truncated)"
-		s = s[:DetailLimit-len(note)] + note
-	}
 	return s
 }
 
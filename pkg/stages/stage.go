@@ -49,6 +49,23 @@ type Stage struct {
 	// a step whose output cannot be recovered from a previous process, and
 	// a verification that would not be one if it were skipped.
 	AlwaysRun bool
+	// After names the stages this one needs completed before it starts,
+	// all of them earlier in the sequence. Nil means every stage before
+	// it, which is how a sequence that says nothing runs: in order, one at
+	// a time. A stage with After runs alongside any other stage that is
+	// ready, so declaring it is a claim that the two cannot get in each
+	// other's way — the same hosts, the same API server, but never the
+	// same object. An empty, non-nil After means nothing: the stage can
+	// start first.
+	After []string
+	// Retry is how many times the stage may run before it fails, and on
+	// which failures. The zero value runs it once, which is every stage
+	// that is not safe to run twice in one process.
+	Retry Retry
+	// Hooks are a site's own steps on either side of the stage, from a
+	// hooks file by way of AttachHooks. The stage completes only once they
+	// have all run.
+	Hooks []Hook
 	// Run does the work. A nil Run is a stage that is not wired yet and the
 	// engine refuses to pretend otherwise.
 	Run StageFunc
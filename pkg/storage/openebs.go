@@ -35,16 +35,19 @@ const (
 	// StorageClassName is the platform's default StorageClass
 	// (install.mdx: "OpenEBS Local PV LVM and the default StorageClass").
 	StorageClassName = "kubenest-local"
+
+	// StorageClassManifestName is the file the StorageClass is written to.
+	StorageClassManifestName = "kubenest-storageclass"
 )
 
-// storageClassManifest is the default StorageClass, applied through the same
+// StorageClassManifest is the default StorageClass, applied through the same
 // auto-deploy directory as the chart.
 //
 //   - WaitForFirstConsumer: a Local PV must be carved on the node the pod
 //     lands on, so binding must wait for scheduling.
 //   - allowVolumeExpansion: lvextend is one of the few free lunches LVM
 //     offers; leaving it off would force delete-and-restore for a resize.
-const storageClassManifest = `apiVersion: storage.k8s.io/v1
+const StorageClassManifest = `apiVersion: storage.k8s.io/v1
 kind: StorageClass
 metadata:
   name: ` + StorageClassName + `
@@ -66,6 +69,23 @@ const chartValues = `analytics:
   enabled: false
 `
 
+// Chart renders the OpenEBS Local PV LVM HelmChart resource at the bundle's
+// pin.
+func Chart(m *manifest.Manifest) (k3s.HelmChart, error) {
+	version, err := m.Core.Version(ComponentKey)
+	if err != nil {
+		return k3s.HelmChart{}, err
+	}
+	return k3s.Sourced(m, k3s.HelmChart{
+		Name:            ComponentKey,
+		Repo:            ChartRepo,
+		Chart:           ChartName,
+		Version:         version,
+		TargetNamespace: Namespace,
+		ValuesYAML:      chartValues,
+	}), nil
+}
+
 // Install applies OpenEBS Local PV LVM at the version the bundle manifest
 // pins, plus the default StorageClass, and waits for the component to
 // converge (all pods Ready, CSI driver registered on every node). The
@@ -75,7 +95,7 @@ const chartValues = `analytics:
 // r must reach the k3s server node. EnsureVolumeGroup must have run first on
 // every data-bearing node; nothing here touches block devices.
 func Install(ctx context.Context, r k3s.Runner, m *manifest.Manifest, rep converge.Reporter) error {
-	version, err := m.Core.Version(ComponentKey)
+	chart, err := Chart(m)
 	if err != nil {
 		return err
 	}
@@ -84,14 +104,6 @@ func Install(ctx context.Context, r k3s.Runner, m *manifest.Manifest, rep conver
 		return err
 	}
 
-	chart := k3s.HelmChart{
-		Name:            ComponentKey,
-		Repo:            ChartRepo,
-		Chart:           ChartName,
-		Version:         version,
-		TargetNamespace: Namespace,
-		ValuesYAML:      chartValues,
-	}
 	cr, err := chart.Manifest()
 	if err != nil {
 		return err
@@ -99,7 +111,7 @@ func Install(ctx context.Context, r k3s.Runner, m *manifest.Manifest, rep conver
 	if err := k3s.WriteManifest(ctx, r, ComponentKey, cr); err != nil {
 		return err
 	}
-	if err := k3s.WriteManifest(ctx, r, "kubenest-storageclass", []byte(storageClassManifest)); err != nil {
+	if err := k3s.WriteManifest(ctx, r, StorageClassManifestName, []byte(StorageClassManifest)); err != nil {
 		return err
 	}
 
@@ -288,3 +300,91 @@ func nodesMissingDriver(ctx context.Context, r k3s.Runner) ([]string, error) {
 	}
 	return missing, nil
 }
+
+// LocalVolume is one platform PersistentVolume and the claim bound to it.
+type LocalVolume struct {
+	Name string
+	// Claim is namespace/name, or empty for a released volume.
+	Claim string
+}
+
+func (v LocalVolume) String() string {
+	if v.Claim == "" {
+		return v.Name + " (unclaimed)"
+	}
+	return v.Name + " (" + v.Claim + ")"
+}
+
+type persistentVolumeList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Spec struct {
+			CSI *struct {
+				Driver string `json:"driver"`
+			} `json:"csi"`
+			ClaimRef *struct {
+				Namespace string `json:"namespace"`
+				Name      string `json:"name"`
+			} `json:"claimRef"`
+			NodeAffinity *struct {
+				Required struct {
+					NodeSelectorTerms []nodeSelectorTerm `json:"nodeSelectorTerms"`
+				} `json:"required"`
+			} `json:"nodeAffinity"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+// VolumesOn lists the Local PV LVM volumes pinned to one node. They live in
+// that node's kubenest-vg and nowhere else: a node that leaves the cluster
+// strands every one of them, and the pods that claim them can never schedule
+// again. Taking a node away is therefore a question about this list first.
+func VolumesOn(ctx context.Context, r k3s.Runner, node string) ([]LocalVolume, error) {
+	out, err := k3s.Kubectl(ctx, r, "get persistentvolumes -o json")
+	if err != nil {
+		return nil, err
+	}
+	var list persistentVolumeList
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		return nil, fmt.Errorf("parsing persistent volumes: %w", err)
+	}
+	var volumes []LocalVolume
+	for _, pv := range list.Items {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != CSIDriverName || pv.Spec.NodeAffinity == nil {
+			continue
+		}
+		if !pinnedTo(pv.Spec.NodeAffinity.Required.NodeSelectorTerms, node) {
+			continue
+		}
+		v := LocalVolume{Name: pv.Metadata.Name}
+		if pv.Spec.ClaimRef != nil {
+			v.Claim = pv.Spec.ClaimRef.Namespace + "/" + pv.Spec.ClaimRef.Name
+		}
+		volumes = append(volumes, v)
+	}
+	return volumes, nil
+}
+
+type nodeSelectorTerm struct {
+	MatchExpressions []struct {
+		Values []string `json:"values"`
+	} `json:"matchExpressions"`
+}
+
+// pinnedTo reports whether a volume's node affinity names the node. The
+// driver writes its own topology key; matching on the value alone keeps this
+// correct across the driver versions a bundle may pin.
+func pinnedTo(terms []nodeSelectorTerm, node string) bool {
+	for _, term := range terms {
+		for _, expr := range term.MatchExpressions {
+			for _, v := range expr.Values {
+				if v == node {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -207,3 +207,22 @@ func keys(m map[string]string) []string {
 	}
 	return out
 }
+
+// Only this driver's volumes, and only those pinned to the node, are the
+// ones a departing node strands.
+func TestVolumesOnListsOnlyTheNodesLocalVolumes(t *testing.T) {
+	r := &prefixRunner{t: t, replies: []prefixReply{{"sudo -n k3s kubectl get persistentvolumes", sshx.Result{Stdout: `{"items":[
+		{"metadata":{"name":"pvc-1"},"spec":{"csi":{"driver":"local.csi.openebs.io"},"claimRef":{"namespace":"payments","name":"data-db-0"},
+			"nodeAffinity":{"required":{"nodeSelectorTerms":[{"matchExpressions":[{"key":"openebs.io/nodename","operator":"In","values":["worker-2"]}]}]}}}},
+		{"metadata":{"name":"pvc-2"},"spec":{"csi":{"driver":"local.csi.openebs.io"},
+			"nodeAffinity":{"required":{"nodeSelectorTerms":[{"matchExpressions":[{"key":"openebs.io/nodename","operator":"In","values":["worker-3"]}]}]}}}},
+		{"metadata":{"name":"nfs-1"},"spec":{"csi":{"driver":"nfs.csi.k8s.io"},
+			"nodeAffinity":{"required":{"nodeSelectorTerms":[{"matchExpressions":[{"key":"kubernetes.io/hostname","operator":"In","values":["worker-2"]}]}]}}}}]}`}}}}
+	volumes, err := VolumesOn(context.Background(), r, "worker-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volumes) != 1 || volumes[0].String() != "pvc-1 (payments/data-db-0)" {
+		t.Errorf("want pvc-1 and its claim alone, got %v", volumes)
+	}
+}
@@ -0,0 +1,574 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/k3s"
+	"kubenest.io/cli/pkg/manifest"
+)
+
+// The replicated-storage profile (kn-54ni): OpenEBS Mayastor, replicating
+// every volume to three nodes' dedicated devices, behind a second
+// StorageClass. kubenest-local stays the one default — a workload opts into
+// replication by naming kubenest-replicated, because replicated block
+// storage costs a device, hugepages and network on every node, and that is a
+// choice, not a default.
+const (
+	// ReplicatedProfile is the profile name in the bundle manifest and on
+	// --profile.
+	ReplicatedProfile = "replicated-storage"
+
+	// ReplicatedComponentKey is the engine's pin, under
+	// profiles.replicated-storage.components, and the key its host
+	// requirements are listed under.
+	ReplicatedComponentKey = "mayastor"
+
+	// ReplicatedChartResourceName is the HelmChart resource, and manifest
+	// file, of the engine.
+	ReplicatedChartResourceName = "kubenest-mayastor"
+
+	ReplicatedChartRepo = "https://openebs.github.io/mayastor-extensions"
+	ReplicatedChartName = "mayastor"
+
+	// ReplicatedNamespace is where the engine and its DiskPools live.
+	ReplicatedNamespace = "mayastor"
+
+	// ReplicatedCSIDriverName is the provisioner Mayastor registers.
+	ReplicatedCSIDriverName = "io.openebs.csi-mayastor"
+
+	// ReplicatedStorageClassName is the opt-in class, alongside
+	// kubenest-local and never the default.
+	ReplicatedStorageClassName = "kubenest-replicated"
+
+	// ReplicatedStorageClassManifestName is the file the class is written to.
+	ReplicatedStorageClassManifestName = "kubenest-replicated-storageclass"
+
+	// DiskPoolManifestName is the file every node's DiskPool is written to.
+	DiskPoolManifestName = "kubenest-replicated-pools"
+
+	// Replicas is how many nodes hold a copy of every volume: one can be lost
+	// and the volume still has two.
+	Replicas = 3
+
+	// engineLabel is the io-engine DaemonSet's node selector. The installer
+	// labels every node, because every node gets a pool.
+	engineLabel = "openebs.io/engine=mayastor"
+)
+
+// ReplicatedStorageClassManifest is the opt-in replicated class.
+//
+//   - repl is Replicas: the profile's node-count requirement is the same
+//     number, so a class asking for more copies than there are nodes cannot
+//     ship.
+//   - Immediate binding: a replicated volume is not tied to the node its
+//     first consumer lands on, which is the point.
+//   - no is-default-class annotation: kubenest-local remains the one default.
+const ReplicatedStorageClassManifest = `apiVersion: storage.k8s.io/v1
+kind: StorageClass
+metadata:
+  name: ` + ReplicatedStorageClassName + `
+provisioner: ` + ReplicatedCSIDriverName + `
+parameters:
+  protocol: nvmf
+  repl: "3"
+  fsType: ext4
+volumeBindingMode: Immediate
+allowVolumeExpansion: true
+reclaimPolicy: Delete
+`
+
+// replicatedValues: the chart's own local provisioner and log stack are off —
+// the platform has Local PV LVM for the first and the observability profile
+// for the second — and, as with Local PV LVM, nothing phones home.
+const replicatedValues = `obs:
+  callhome:
+    enabled: false
+localpv-provisioner:
+  enabled: false
+loki-stack:
+  enabled: false
+`
+
+// Requirement is what a profile's components ask of the hosts beyond core:
+// preflight checks it before anything is written, so a cluster that cannot
+// carry replicated storage is refused at stage 1 rather than half-installed
+// at stage 11.
+type Requirement struct {
+	// Nodes is the fewest nodes the install may have, servers and agents
+	// together.
+	Nodes int
+	// Device means every node needs a dedicated blank device
+	// (--replicated-device), separate from kubenest-vg.
+	Device bool
+	// HugePages is the 2 MiB hugepage memory every node must have reserved.
+	// It is not configured by the installer: the kubelet only sees
+	// hugepages reserved before it starts.
+	HugePages manifest.Quantity
+	// KernelModules must be loadable on every node; the installer loads
+	// them.
+	KernelModules []string
+}
+
+// Zero reports whether the requirement asks nothing of the hosts.
+func (r Requirement) Zero() bool {
+	return r.Nodes == 0 && !r.Device && r.HugePages == 0 && len(r.KernelModules) == 0
+}
+
+// componentRequirements is keyed by the component names profiles pin, so a
+// bundle that moves replicated storage to a different engine moves its
+// requirements with it. Mayastor's are its documented minimums: a device to
+// pool, 2 GiB of hugepages for the SPDK io-engine, and NVMe over TCP.
+var componentRequirements = map[string]Requirement{
+	ReplicatedComponentKey: {
+		Nodes:         Replicas,
+		Device:        true,
+		HugePages:     2 << 30,
+		KernelModules: []string{"nvme_tcp"},
+	},
+}
+
+// Merge is the requirement of hosts carrying both: the larger node count and
+// hugepage reservation, and every device and module either needs.
+func (r Requirement) Merge(o Requirement) Requirement {
+	r.Nodes = max(r.Nodes, o.Nodes)
+	r.Device = r.Device || o.Device
+	r.HugePages = max(r.HugePages, o.HugePages)
+	modules := slices.Clone(r.KernelModules)
+	for _, m := range o.KernelModules {
+		if !slices.Contains(modules, m) {
+			modules = append(modules, m)
+		}
+	}
+	slices.Sort(modules)
+	r.KernelModules = modules
+	return r
+}
+
+// ProfileRequirement is the combined requirement of a profile's pinned
+// components.
+func ProfileRequirement(p manifest.Profile) Requirement {
+	var req Requirement
+	for component := range p.Components {
+		req = req.Merge(componentRequirements[component])
+	}
+	return req
+}
+
+// ReplicatedVersion is the bundle's pin for the engine.
+func ReplicatedVersion(m *manifest.Manifest) (string, error) {
+	prof, err := m.Profiles.Get(ReplicatedProfile)
+	if err != nil {
+		return "", err
+	}
+	version, ok := prof.Components[ReplicatedComponentKey]
+	if !ok || version == "" {
+		return "", fmt.Errorf("bundle %s does not pin profiles.%s.components.%s: the bundle decides every version, add the pin to the manifest rather than defaulting in code",
+			m.Bundle, ReplicatedProfile, ReplicatedComponentKey)
+	}
+	return version, nil
+}
+
+// ReplicatedChart renders the engine's HelmChart resource at the bundle's pin.
+func ReplicatedChart(m *manifest.Manifest) (k3s.HelmChart, error) {
+	version, err := ReplicatedVersion(m)
+	if err != nil {
+		return k3s.HelmChart{}, err
+	}
+	return k3s.Sourced(m, k3s.HelmChart{
+		Name:            ReplicatedChartResourceName,
+		Repo:            ReplicatedChartRepo,
+		Chart:           ReplicatedChartName,
+		Version:         version,
+		TargetNamespace: ReplicatedNamespace,
+		ValuesYAML:      replicatedValues,
+	}), nil
+}
+
+// PreflightReplicatedDevice is the device half of the profile's preflight on
+// ONE node: --replicated-device must be a blank device, by the same blkid
+// rule as --storage-device, and must not be the device kubenest-vg is, or
+// will be, on.
+func PreflightReplicatedDevice(ctx context.Context, r k3s.Runner, device, storageDevice string) error {
+	if !devicePath.MatchString(device) {
+		return fmt.Errorf("--replicated-device %q is not a /dev path; use the stable /dev/disk/by-id/... form (unstable /dev/sdX names move between boots)", device)
+	}
+	if device == storageDevice {
+		return fmt.Errorf("--replicated-device and --storage-device are both %s: replicated storage pools a whole device of its own, so name a second one", device)
+	}
+	return deviceIsBlank(ctx, r, "--replicated-device", device)
+}
+
+// PrepareReplicatedHost loads the profile's kernel modules on one node now
+// and at every boot. Preflight proved they are loadable.
+func PrepareReplicatedHost(ctx context.Context, r k3s.Runner, req Requirement) error {
+	if len(req.KernelModules) == 0 {
+		return nil
+	}
+	conf := base64.StdEncoding.EncodeToString([]byte(strings.Join(req.KernelModules, "\n") + "\n"))
+	cmd := "sudo -n modprobe -a " + strings.Join(req.KernelModules, " ") +
+		" && printf '%s' " + conf + " | base64 -d | sudo -n tee /etc/modules-load.d/kubenest-replicated.conf >/dev/null"
+	res, err := r.Run(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	if res.ExitCode != 0 {
+		return fmt.Errorf("loading %s: exit %d: %s", strings.Join(req.KernelModules, ", "), res.ExitCode, firstLine(res.Stderr))
+	}
+	return nil
+}
+
+// InstallReplicated labels every node for the io-engine, applies the engine
+// at the bundle's pin and waits for it, then pools device on every node and
+// writes the replicated StorageClass.
+//
+// device == "" leaves the pools as they are: an upgrade moves the engine and
+// never touches the devices it already owns.
+func InstallReplicated(ctx context.Context, r k3s.Runner, m *manifest.Manifest, device string, rep converge.Reporter) error {
+	chart, err := ReplicatedChart(m)
+	if err != nil {
+		return err
+	}
+	deadline, err := m.Limits.Timeouts.For("component-ready")
+	if err != nil {
+		return err
+	}
+	nodes, err := nodeNames(ctx, r)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if _, err := k3s.Kubectl(ctx, r, "label node "+node+" "+engineLabel+" --overwrite"); err != nil {
+			return err
+		}
+	}
+
+	cr, err := chart.Manifest()
+	if err != nil {
+		return err
+	}
+	if err := k3s.WriteManifest(ctx, r, chart.Name, cr); err != nil {
+		return err
+	}
+	if err := waitFor(ctx, replicatedReadyProbe(r), ReplicatedComponentKey+"-ready", deadline, rep); err != nil {
+		return err
+	}
+
+	if device != "" {
+		if err := k3s.WriteManifest(ctx, r, DiskPoolManifestName, DiskPoolManifest(nodes, device)); err != nil {
+			return err
+		}
+		if err := waitFor(ctx, poolsOnlineProbe(r, nodes), ReplicatedComponentKey+"-pools", deadline, rep); err != nil {
+			return err
+		}
+	}
+	return k3s.WriteManifest(ctx, r, ReplicatedStorageClassManifestName, []byte(ReplicatedStorageClassManifest))
+}
+
+// DiskPoolManifest is one DiskPool per node, each pooling the same device
+// path — the same contract as --storage-device, where the path names a
+// device that exists on every node.
+func DiskPoolManifest(nodes []string, device string) []byte {
+	var b strings.Builder
+	for i, node := range nodes {
+		if i > 0 {
+			b.WriteString("---\n")
+		}
+		fmt.Fprintf(&b, `apiVersion: openebs.io/v1beta2
+kind: DiskPool
+metadata:
+  name: kubenest-%s
+  namespace: %s
+spec:
+  node: %s
+  disks: ["aio://%s"]
+`, node, ReplicatedNamespace, node, device)
+	}
+	return []byte(b.String())
+}
+
+func waitFor(ctx context.Context, probe converge.Probe, name string, deadline time.Duration, rep converge.Reporter) error {
+	res, err := converge.Wait(ctx, probe, converge.Options{Name: name, Deadline: deadline, Reporter: rep})
+	if err != nil {
+		return err
+	}
+	return res.Err()
+}
+
+// nodeNames lists the cluster's Node objects by name, sorted.
+func nodeNames(ctx context.Context, r k3s.Runner) ([]string, error) {
+	out, err := k3s.Kubectl(ctx, r, "get nodes -o jsonpath='{.items[*].metadata.name}'")
+	if err != nil {
+		return nil, err
+	}
+	nodes := strings.Fields(out)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("the cluster lists no nodes")
+	}
+	slices.Sort(nodes)
+	return nodes, nil
+}
+
+// replicatedReadyProbe is the engine's convergence: pods Ready (the
+// io-engine among them, on every labelled node), then the CSI driver
+// registered.
+func replicatedReadyProbe(r k3s.Runner) converge.Probe {
+	return func(ctx context.Context) (bool, converge.State, error) {
+		done, state, err := k3s.CheckPodsReady(ctx, r, ReplicatedNamespace)
+		if err != nil || !done {
+			return false, state, err
+		}
+		if _, err := k3s.Kubectl(ctx, r, "get csidriver "+ReplicatedCSIDriverName+" -o name"); err != nil {
+			return false, converge.State{
+				Object: "csidriver " + ReplicatedCSIDriverName,
+				Status: "not registered",
+				Detail: "the Mayastor CSI controller creates it once running; check the " + ReplicatedNamespace + " namespace if this persists",
+			}, nil
+		}
+		return true, converge.State{Object: ReplicatedComponentKey, Status: "Ready", Detail: "pods Ready, " + ReplicatedCSIDriverName + " registered"}, nil
+	}
+}
+
+type diskPoolList struct {
+	Items []struct {
+		Spec struct {
+			Node string `json:"node"`
+		} `json:"spec"`
+		Status struct {
+			PoolStatus string `json:"pool_status"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// poolsOnlineProbe requires an Online pool on every node. A pool that stays
+// offline is nearly always its device: missing on that node, or not the
+// device preflight checked.
+func poolsOnlineProbe(r k3s.Runner, nodes []string) converge.Probe {
+	return func(ctx context.Context) (bool, converge.State, error) {
+		out, err := k3s.Kubectl(ctx, r, "get diskpools -n "+ReplicatedNamespace+" -o json")
+		if err != nil {
+			return false, converge.State{Object: "diskpools", Status: "unobservable"}, err
+		}
+		var list diskPoolList
+		if err := json.Unmarshal([]byte(out), &list); err != nil {
+			return false, converge.State{Object: "diskpools", Status: "unparsable"}, err
+		}
+		status := map[string]string{}
+		for _, p := range list.Items {
+			status[p.Spec.Node] = p.Status.PoolStatus
+		}
+		for _, node := range nodes {
+			if s := status[node]; s != "Online" {
+				if s == "" {
+					s = "not created yet"
+				}
+				return false, converge.State{
+					Object: "diskpool kubenest-" + node,
+					Status: s,
+					Detail: "the io-engine on " + node + " pools --replicated-device; check the device exists there and its io-engine pod's log",
+				}, nil
+			}
+		}
+		return true, converge.State{Object: "diskpools", Status: "Online", Detail: fmt.Sprintf("%d node(s)", len(nodes))}, nil
+	}
+}
+
+// VerifyReplicated is the profile's acceptance check: the replicated class
+// is as written and kubenest-local is still the one default, then proof that
+// a replicated volume outlives its node — data written on one node is read
+// back on another while the first is cordoned.
+//
+// The proof runs in its own namespace and cleans up after itself, and the
+// node is uncordoned on every path out.
+func VerifyReplicated(ctx context.Context, r k3s.Runner, m *manifest.Manifest, rep converge.Reporter) error {
+	deadline, err := m.Limits.Timeouts.For("component-ready")
+	if err != nil {
+		return err
+	}
+	if err := waitFor(ctx, replicatedClassProbe(r), "storageclass-replicated", deadline, rep); err != nil {
+		return err
+	}
+	if err := waitFor(ctx, storageClassProbe(r), "storageclass-default", deadline, rep); err != nil {
+		return err
+	}
+	return proveSurvivesCordon(ctx, r, deadline, rep)
+}
+
+func replicatedClassProbe(r k3s.Runner) converge.Probe {
+	object := "storageclass " + ReplicatedStorageClassName
+	return func(ctx context.Context) (bool, converge.State, error) {
+		out, err := k3s.Kubectl(ctx, r, "get storageclass "+ReplicatedStorageClassName+" -o json --ignore-not-found")
+		if err != nil {
+			return false, converge.State{Object: object, Status: "unobservable"}, err
+		}
+		if strings.TrimSpace(out) == "" {
+			return false, converge.State{Object: object, Status: "not found", Detail: "the auto-deploy manifest " + ReplicatedStorageClassManifestName + ".yaml has not applied yet"}, nil
+		}
+		var sc struct {
+			storageClassDoc
+			Parameters struct {
+				Repl string `json:"repl"`
+			} `json:"parameters"`
+		}
+		if err := json.Unmarshal([]byte(out), &sc); err != nil {
+			return false, converge.State{Object: object, Status: "unparsable"}, err
+		}
+		var wrong []string
+		if sc.Provisioner != ReplicatedCSIDriverName {
+			wrong = append(wrong, fmt.Sprintf("provisioner is %s, want %s", sc.Provisioner, ReplicatedCSIDriverName))
+		}
+		if sc.Parameters.Repl != fmt.Sprint(Replicas) {
+			wrong = append(wrong, fmt.Sprintf("repl is %q, want %d", sc.Parameters.Repl, Replicas))
+		}
+		if sc.Metadata.Annotations["storageclass.kubernetes.io/is-default-class"] == "true" {
+			wrong = append(wrong, "marked default: "+StorageClassName+" is the one default, and replication is opted into by name")
+		}
+		if len(wrong) > 0 {
+			return false, converge.State{Object: object, Status: "misconfigured", Detail: strings.Join(wrong, "; ")}, nil
+		}
+		return true, converge.State{Object: object, Status: "present", Detail: fmt.Sprintf("provisioner %s, %d replicas", ReplicatedCSIDriverName, Replicas)}, nil
+	}
+}
+
+// checkNamespace is where the cordon proof runs.
+const checkNamespace = "kubenest-storage-check"
+
+// proveSurvivesCordon writes a random token to a replicated volume, cordons
+// the node that wrote it, and reads the token back from a pod the scheduler
+// must place elsewhere. A volume that only lived on the writer's node cannot
+// pass: the reader would never start.
+func proveSurvivesCordon(ctx context.Context, r k3s.Runner, deadline time.Duration, rep converge.Reporter) (err error) {
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(raw)
+
+	// Cleanup is best effort and never the error: a failed proof is what
+	// the operator needs to read, not a failed delete.
+	defer func() {
+		_, _ = k3s.Kubectl(context.WithoutCancel(ctx), r, "delete namespace "+checkNamespace+" --ignore-not-found --wait=false")
+	}()
+	if err := kubectlApply(ctx, r, cordonProof(token)); err != nil {
+		return err
+	}
+	if err := waitFor(ctx, podSucceededProbe(r, "writer"), "replicated-write", deadline, rep); err != nil {
+		return err
+	}
+	out, err := k3s.Kubectl(ctx, r, "get pod writer -n "+checkNamespace+" -o jsonpath='{.spec.nodeName}'")
+	if err != nil {
+		return err
+	}
+	node := strings.TrimSpace(out)
+	if node == "" {
+		return fmt.Errorf("the writer pod in %s ran on no node", checkNamespace)
+	}
+	if _, err := k3s.Kubectl(ctx, r, "cordon "+node); err != nil {
+		return err
+	}
+	defer func() {
+		if _, uerr := k3s.Kubectl(context.WithoutCancel(ctx), r, "uncordon "+node); uerr != nil && err == nil {
+			err = fmt.Errorf("node %s is still cordoned after the replicated-storage check: `kubectl uncordon %s`: %w", node, node, uerr)
+		}
+	}()
+	if _, err := k3s.Kubectl(ctx, r, "delete pod writer -n "+checkNamespace+" --wait=true"); err != nil {
+		return err
+	}
+	if err := kubectlApply(ctx, r, readerPod); err != nil {
+		return err
+	}
+	if err := waitFor(ctx, podSucceededProbe(r, "reader"), "replicated-read", deadline, rep); err != nil {
+		return fmt.Errorf("with %s cordoned, the replicated volume written there could not be read elsewhere: %w", node, err)
+	}
+	got, err := k3s.Kubectl(ctx, r, "logs reader -n "+checkNamespace)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(got) != token {
+		return fmt.Errorf("with %s cordoned, the replicated volume read back %q, not what was written there", node, firstLine(got))
+	}
+	return nil
+}
+
+func cordonProof(token string) string {
+	return `apiVersion: v1
+kind: Namespace
+metadata: {name: ` + checkNamespace + `}
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata: {name: proof, namespace: ` + checkNamespace + `}
+spec:
+  storageClassName: ` + ReplicatedStorageClassName + `
+  accessModes: [ReadWriteOnce]
+  resources: {requests: {storage: 64Mi}}
+---
+apiVersion: v1
+kind: Pod
+metadata: {name: writer, namespace: ` + checkNamespace + `}
+spec:
+  restartPolicy: Never
+  containers:
+    - name: w
+      image: busybox:1.36
+      command: ["sh", "-c", "echo ` + token + ` > /data/proof && sync"]
+      volumeMounts: [{name: v, mountPath: /data}]
+  volumes:
+    - name: v
+      persistentVolumeClaim: {claimName: proof}
+`
+}
+
+const readerPod = `apiVersion: v1
+kind: Pod
+metadata: {name: reader, namespace: ` + checkNamespace + `}
+spec:
+  restartPolicy: Never
+  containers:
+    - name: r
+      image: busybox:1.36
+      command: ["cat", "/data/proof"]
+      volumeMounts: [{name: v, mountPath: /data}]
+  volumes:
+    - name: v
+      persistentVolumeClaim: {claimName: proof}
+`
+
+func kubectlApply(ctx context.Context, r k3s.Runner, doc string) error {
+	encoded := base64.StdEncoding.EncodeToString([]byte(doc))
+	res, err := r.Run(ctx, fmt.Sprintf("printf '%%s' %s | base64 -d | sudo -n k3s kubectl apply -f -", encoded))
+	if err != nil {
+		return err
+	}
+	if res.ExitCode != 0 {
+		return fmt.Errorf("kubectl apply: exit %d: %s", res.ExitCode, firstLine(res.Stderr))
+	}
+	return nil
+}
+
+// podSucceededProbe observes one proof pod until phase Succeeded.
+func podSucceededProbe(r k3s.Runner, name string) converge.Probe {
+	object := "pod " + checkNamespace + "/" + name
+	return func(ctx context.Context) (bool, converge.State, error) {
+		out, err := k3s.Kubectl(ctx, r, "get pod "+name+" -n "+checkNamespace+" -o jsonpath='{.status.phase}'")
+		if err != nil {
+			return false, converge.State{Object: object, Status: "unobservable"}, err
+		}
+		switch phase := strings.TrimSpace(out); phase {
+		case "Succeeded":
+			return true, converge.State{Object: object, Status: phase}, nil
+		case "":
+			return false, converge.State{Object: object, Status: "not created yet"}, nil
+		default:
+			return false, converge.State{Object: object, Status: phase, Detail: "a Pending pod is usually its volume: check `kubectl describe pvc proof -n " + checkNamespace + "`"}, nil
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"kubenest.io/cli/pkg/component/componenttest"
+	"kubenest.io/cli/pkg/manifest"
+	"kubenest.io/cli/pkg/sshx"
+)
+
+func replicatedManifest(t *testing.T) *manifest.Manifest {
+	t.Helper()
+	m, err := manifest.Parse([]byte(
+		"bundle: \"1.0\"\nlimits:\n  timeouts:\n    component-ready: 2s\nprofiles:\n  replicated-storage:\n    components:\n      mayastor: 2.9.1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// written decodes every manifest a fake runner was asked to write, by file.
+func written(t *testing.T, commands []string) map[string]string {
+	t.Helper()
+	docs := map[string]string{}
+	for _, cmd := range commands {
+		if m := writeRe.FindStringSubmatch(cmd); m != nil {
+			decoded, err := base64.StdEncoding.DecodeString(m[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			docs[m[2]] = string(decoded)
+		}
+	}
+	return docs
+}
+
+// The requirements come from the components a profile pins, so a bundle
+// pinning an engine this build has no requirements for asks nothing extra.
+func TestProfileRequirementFollowsTheComponentsPinned(t *testing.T) {
+	req := ProfileRequirement(manifest.Profile{Components: manifest.Components{ReplicatedComponentKey: "2.9.1"}})
+	if req.Nodes != Replicas || !req.Device || req.HugePages != 2<<30 || !slices.Equal(req.KernelModules, []string{"nvme_tcp"}) {
+		t.Errorf("mayastor requirement = %+v", req)
+	}
+	if req := ProfileRequirement(manifest.Profile{Components: manifest.Components{"grafana": "10.5.15"}}); !req.Zero() {
+		t.Errorf("a profile without host requirements asks %+v", req)
+	}
+	merged := Requirement{Nodes: 5, KernelModules: []string{"rbd"}}.Merge(req)
+	if merged.Nodes != 5 || !slices.Equal(merged.KernelModules, []string{"nvme_tcp", "rbd"}) {
+		t.Errorf("merged = %+v", merged)
+	}
+}
+
+func healthyReplicated(cmd string) (sshx.Result, error) {
+	switch {
+	case strings.Contains(cmd, "get nodes"):
+		return sshx.Result{Stdout: "node-b node-a"}, nil
+	case strings.Contains(cmd, "get pods -n "+ReplicatedNamespace):
+		return sshx.Result{Stdout: `{"items":[{"metadata":{"name":"io-engine-x"},"status":{"phase":"Running","conditions":[{"type":"Ready","status":"True"}]}}]}`}, nil
+	case strings.Contains(cmd, "get diskpools"):
+		return sshx.Result{Stdout: `{"items":[
+			{"spec":{"node":"node-a"},"status":{"pool_status":"Online"}},
+			{"spec":{"node":"node-b"},"status":{"pool_status":"Online"}}]}`}, nil
+	}
+	return sshx.Result{}, nil
+}
+
+// Every node is labelled for the io-engine and pools the device; the engine
+// is at the profile's pin, and its class is written beside kubenest-local
+// without displacing it as the default.
+func TestInstallReplicatedPoolsEveryNodeBesideTheDefaultClass(t *testing.T) {
+	r := &componenttest.FakeRunner{Respond: healthyReplicated}
+	device := "/dev/disk/by-id/scsi-0HC_Volume_2"
+	if err := InstallReplicated(context.Background(), r, replicatedManifest(t), device, nil); err != nil {
+		t.Fatal(err)
+	}
+	commands := r.Commands()
+	for _, node := range []string{"node-a", "node-b"} {
+		if !slices.Contains(commands, "sudo -n k3s kubectl label node "+node+" "+engineLabel+" --overwrite") {
+			t.Errorf("%s was not labelled for the io-engine", node)
+		}
+	}
+	docs := written(t, commands)
+	if chart := docs[ReplicatedChartResourceName]; !strings.Contains(chart, "version: 2.9.1") || !strings.Contains(chart, "targetNamespace: "+ReplicatedNamespace) {
+		t.Errorf("chart:\n%s", chart)
+	}
+	pools := docs[DiskPoolManifestName]
+	for _, want := range []string{"node: node-a", "node: node-b", `disks: ["aio://` + device + `"]`} {
+		if !strings.Contains(pools, want) {
+			t.Errorf("pools do not carry %q:\n%s", want, pools)
+		}
+	}
+	class := docs[ReplicatedStorageClassManifestName]
+	if class == "" || strings.Contains(class, "is-default-class") {
+		t.Errorf("replicated class must be written and not default:\n%s", class)
+	}
+
+	// An upgrade moves the engine and leaves the pools alone.
+	r = &componenttest.FakeRunner{Respond: healthyReplicated}
+	if err := InstallReplicated(context.Background(), r, replicatedManifest(t), "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := written(t, r.Commands())[DiskPoolManifestName]; ok {
+		t.Error("an install without a device rewrote the pools")
+	}
+}
+
+func TestReplicatedClassMustNotBeTheDefault(t *testing.T) {
+	r := &componenttest.FakeRunner{Respond: func(string) (sshx.Result, error) {
+		return sshx.Result{Stdout: `{"metadata":{"name":"kubenest-replicated","annotations":{"storageclass.kubernetes.io/is-default-class":"true"}},
+			"provisioner":"io.openebs.csi-mayastor","parameters":{"repl":"3"}}`}, nil
+	}}
+	done, state, err := replicatedClassProbe(r)(context.Background())
+	if err != nil || done || !strings.Contains(state.Detail, "kubenest-local is the one default") {
+		t.Errorf("done=%v state=%+v err=%v", done, state, err)
+	}
+}
+
+var tokenRe = regexp.MustCompile(`echo ([0-9a-f]+) > /data/proof`)
+
+// cordonCluster is a cluster where the writer runs on node-a and the reader
+// reads back whatever reads returns, given the token the writer wrote.
+func cordonCluster(t *testing.T, reads func(token string) string) *componenttest.FakeRunner {
+	var token string
+	return &componenttest.FakeRunner{Respond: func(cmd string) (sshx.Result, error) {
+		switch {
+		case strings.Contains(cmd, "kubectl apply"):
+			doc, err := base64.StdEncoding.DecodeString(strings.Fields(cmd)[2])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m := tokenRe.FindStringSubmatch(string(doc)); m != nil {
+				token = m[1]
+			}
+		case strings.Contains(cmd, "{.status.phase}"):
+			return sshx.Result{Stdout: "Succeeded"}, nil
+		case strings.Contains(cmd, "{.spec.nodeName}"):
+			return sshx.Result{Stdout: "node-a"}, nil
+		case strings.Contains(cmd, "logs reader"):
+			return sshx.Result{Stdout: reads(token) + "\n"}, nil
+		}
+		return sshx.Result{}, nil
+	}}
+}
+
+// The proof cordons the writer's node, reads the token back from elsewhere,
+// and uncordons and cleans up whether or not it passed.
+func TestCordonProofReadsTheVolumeBackOnAnotherNode(t *testing.T) {
+	r := cordonCluster(t, func(token string) string { return token })
+	if err := proveSurvivesCordon(context.Background(), r, 2*time.Second, nil); err != nil {
+		t.Fatal(err)
+	}
+	commands := r.Commands()
+	cordon := slices.Index(commands, "sudo -n k3s kubectl cordon node-a")
+	reader := slices.IndexFunc(commands, func(c string) bool { return strings.Contains(c, "logs reader") })
+	uncordon := slices.Index(commands, "sudo -n k3s kubectl uncordon node-a")
+	if cordon < 0 || reader < cordon || uncordon < reader {
+		t.Errorf("want cordon, then the read, then uncordon:\n%s", strings.Join(commands, "\n"))
+	}
+
+	r = cordonCluster(t, func(string) string { return "stale" })
+	err := proveSurvivesCordon(context.Background(), r, 2*time.Second, nil)
+	if err == nil || !strings.Contains(err.Error(), "node-a cordoned") {
+		t.Fatalf("err = %v, want it to name the cordoned node", err)
+	}
+	commands = r.Commands()
+	if !slices.Contains(commands, "sudo -n k3s kubectl uncordon node-a") {
+		t.Error("a failed proof left the node cordoned")
+	}
+	if !slices.ContainsFunc(commands, func(c string) bool { return strings.Contains(c, "delete namespace "+checkNamespace) }) {
+		t.Error("a failed proof left its namespace behind")
+	}
+}
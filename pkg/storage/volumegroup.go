@@ -96,7 +96,7 @@ func PreflightVolumeGroup(ctx context.Context, r k3s.Runner, device string) (Own
 			VolumeGroup)
 	}
 
-	if err := deviceIsBlank(ctx, r, device); err != nil {
+	if err := deviceIsBlank(ctx, r, "--storage-device", device); err != nil {
 		return "", err
 	}
 	return InstallerCreated, nil
@@ -121,7 +121,7 @@ func EnsureVolumeGroup(ctx context.Context, r k3s.Runner, device string) error {
 	if !devicePath.MatchString(device) {
 		return fmt.Errorf("--storage-device %q is not a /dev path; use the stable /dev/disk/by-id/... form", device)
 	}
-	if err := deviceIsBlank(ctx, r, device); err != nil {
+	if err := deviceIsBlank(ctx, r, "--storage-device", device); err != nil {
 		return err
 	}
 	for _, cmd := range []string{
@@ -162,13 +162,15 @@ func vgFreeBytes(ctx context.Context, r k3s.Runner) (int64, error) {
 // the definition of blank (install.mdx preflight table; verified on a real
 // host in kn-bkwa): a partition table reports PTTYPE, a filesystem or LVM PV
 // reports TYPE, and either is data this installer must not overwrite.
-func deviceIsBlank(ctx context.Context, r k3s.Runner, device string) error {
+//
+// flag is the flag that named the device, for the error to quote.
+func deviceIsBlank(ctx context.Context, r k3s.Runner, flag, device string) error {
 	res, err := r.Run(ctx, "test -b "+device)
 	if err != nil {
 		return err
 	}
 	if res.ExitCode != 0 {
-		return fmt.Errorf("--storage-device %s is not a block device on this node: attach the volume, or check the by-id path (ls /dev/disk/by-id/)", device)
+		return fmt.Errorf("%s %s is not a block device on this node: attach the volume, or check the by-id path (ls /dev/disk/by-id/)", flag, device)
 	}
 	// -p probes the device directly instead of trusting the blkid cache — a
 	// device wiped or written since the cache was built must not be misread.
@@ -178,8 +180,8 @@ func deviceIsBlank(ctx context.Context, r k3s.Runner, device string) error {
 	}
 	if out := strings.TrimSpace(res.Stdout); out != "" {
 		return fmt.Errorf(
-			"--storage-device %s is NOT blank and the installer will not overwrite it (%s): wipe it deliberately yourself if it is disposable, or name a different device",
-			device, firstLine(out))
+			"%s %s is NOT blank and the installer will not overwrite it (%s): wipe it deliberately yourself if it is disposable, or name a different device",
+			flag, device, firstLine(out))
 	}
 	// blkid exits 2 with empty output on a truly blank device; exit 0 with
 	// empty output does not occur, but empty output is the contract either
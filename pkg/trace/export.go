@@ -0,0 +1,238 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"kubenest.io/cli/pkg/version"
+)
+
+// Exporter sends finished spans somewhere. Export is called with one batch
+// at a time, never concurrently.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+	Close() error
+}
+
+// scope names the instrumentation in every export, as OTLP asks.
+const scope = "kubenest.io/cli"
+
+// The OTLP JSON encoding of an ExportTraceServiceRequest: ids in hex,
+// timestamps and integer attributes as decimal strings.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttr `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	otlpSpan struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              Kind       `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []otlpAttr `json:"attributes,omitempty"`
+		Status            otlpStatus `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttr struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string `json:"stringValue,omitempty"`
+		IntValue    *string `json:"intValue,omitempty"`
+		BoolValue   *bool   `json:"boolValue,omitempty"`
+	}
+)
+
+// statusError is OTLP's STATUS_CODE_ERROR. A span that did not fail is left
+// unset rather than marked ok, as the specification asks of instrumentation.
+const statusError = 2
+
+// Encode renders spans as one OTLP JSON export request.
+func Encode(spans []SpanData) ([]byte, error) {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.SpanID[:]),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        encodeAttrs(s.Attrs),
+		}
+		if s.ParentID != ([8]byte{}) {
+			span.ParentSpanID = hex.EncodeToString(s.ParentID[:])
+		}
+		if s.Failed {
+			span.Status = otlpStatus{Code: statusError, Message: s.Message}
+		}
+		out = append(out, span)
+	}
+	return json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: encodeAttrs([]Attr{
+			String("service.name", "kubenest-cli"),
+			String("service.version", version.Version),
+		})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: scope, Version: version.Version}, Spans: out}},
+	}}})
+}
+
+func encodeAttrs(attrs []Attr) []otlpAttr {
+	out := make([]otlpAttr, 0, len(attrs))
+	for _, a := range attrs {
+		var v otlpValue
+		switch value := a.Value.(type) {
+		case string:
+			v.StringValue = &value
+		case int64:
+			s := strconv.FormatInt(value, 10)
+			v.IntValue = &s
+		case int:
+			s := strconv.Itoa(value)
+			v.IntValue = &s
+		case bool:
+			v.BoolValue = &value
+		default:
+			s := fmt.Sprint(value)
+			v.StringValue = &s
+		}
+		out = append(out, otlpAttr{Key: a.Key, Value: v})
+	}
+	return out
+}
+
+// OTLP exports to an OpenTelemetry collector over OTLP/HTTP, JSON-encoded.
+type OTLP struct {
+	url    string
+	client *http.Client
+}
+
+// NewOTLP exports to endpoint, a collector's base URL such as
+// http://otel-collector:4318; the /v1/traces path is added unless the URL
+// already names one.
+func NewOTLP(endpoint string) (*OTLP, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("--trace-otlp %q is not a collector URL: give its OTLP/HTTP base, e.g. http://otel-collector:4318", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	return &OTLP{url: u.String(), client: &http.Client{Timeout: exportTimeout}}, nil
+}
+
+func (o *OTLP) Export(ctx context.Context, spans []SpanData) error {
+	body, err := Encode(spans)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "kubenest-cli/"+version.Version)
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("collector %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector %s answered %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+func (o *OTLP) Close() error { return nil }
+
+// File writes each export request as one line of JSON: the format the
+// collector's otlpjsonfile receiver reads, so a trace written at a site with
+// no backend can be loaded into one later, from a support ticket.
+type File struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFile writes to path, replacing what was there. Like the journal it is
+// 0600: it names the site's hosts and stages.
+func NewFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("--trace-file: %w", err)
+	}
+	return &File{f: f}, nil
+}
+
+func (f *File) Export(_ context.Context, spans []SpanData) error {
+	line, err := Encode(spans)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err = f.f.Write(append(line, '\n'))
+	return err
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.f.Close()
+}
+
+// Exporters sends every batch to each exporter in turn, so a run can go to
+// a collector and a file at once. The first error is returned, after all of
+// them have been tried.
+type Exporters []Exporter
+
+func (e Exporters) Export(ctx context.Context, spans []SpanData) error {
+	var first error
+	for _, x := range e {
+		if err := x.Export(ctx, spans); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (e Exporters) Close() error {
+	var first error
+	for _, x := range e {
+		if err := x.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
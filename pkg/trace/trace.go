@@ -0,0 +1,284 @@
+// Package trace records where an operation's time went, as OpenTelemetry
+// spans: one trace per run, a span per stage, and beneath them a span per
+// convergence check, remote command and control-plane request.
+//
+// It exists for the upgrade that took forty minutes when it should have taken
+// fifteen. The journal says which stage ran long; it cannot say whether that
+// stage spent its time in one converge.Wait, in three hundred SSH round trips,
+// or waiting on the control plane. A trace can, and it can be read in any
+// tracing backend a site already runs — or, written to a file, attached to a
+// support ticket from a site with no backend at all.
+//
+// The package is deliberately small rather than the OpenTelemetry SDK: the
+// CLI needs spans, two exporters and nothing else, and every dependency is
+// one more thing in a binary operators download onto a bastion. What it
+// writes is OTLP's JSON encoding, so a collector reads it as it would the
+// SDK's.
+//
+// Instrumented code calls Start with its context and gets a span back, or nil
+// when the run is not traced; every Span method is a no-op on nil. So a
+// package never asks whether tracing is on, and a run without --trace-otlp or
+// --trace-file costs a context lookup per span.
+//
+// Nothing secret goes into a span. The callers record names — the stage, the
+// check, the command's program, the request's method and path — and never
+// arguments, output or bodies; an error is recorded as the caller sanitized
+// it.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Kind is a span's OTLP kind: whether it is this process's own work or a
+// call to something else.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindClient   Kind = 3
+)
+
+// Attr is one span attribute. Value is a string, an int, an int64 or a bool.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// String, Int and Bool build attributes.
+func String(key, value string) Attr    { return Attr{Key: key, Value: value} }
+func Int(key string, value int) Attr   { return Attr{Key: key, Value: int64(value)} }
+func Bool(key string, value bool) Attr { return Attr{Key: key, Value: value} }
+
+// SpanData is a finished span, as an exporter receives it.
+type SpanData struct {
+	TraceID  [16]byte
+	SpanID   [8]byte
+	ParentID [8]byte
+	Name     string
+	Kind     Kind
+	Start    time.Time
+	End      time.Time
+	Attrs    []Attr
+	// Failed marks a span whose work failed, and Message says how.
+	Failed  bool
+	Message string
+}
+
+// Tracer collects one run's spans and hands them to its exporter.
+//
+// The exporter is called from a goroutine of the tracer's own, never from the
+// code that ends a span: that is a stage, a convergence loop or an SSH
+// command, and a collector that has gone away must not hold it up for an
+// export's timeout — nor, through the tracer's lock, every other span's end
+// with it.
+type Tracer struct {
+	exporter Exporter
+	traceID  [16]byte
+	logf     func(format string, args ...any)
+
+	mu      sync.Mutex
+	pending []SpanData
+	// batches carries full batches to the export goroutine, which closes
+	// done when Close has drained it.
+	batches chan []SpanData
+	done    chan struct{}
+	closed  bool
+	// dropped counts spans let go while the exporter was behind.
+	dropped int
+	// failing is the export goroutine's alone.
+	failing bool
+}
+
+// batchSize is how many finished spans wait before they are exported. A run
+// of an hour produces a few thousand; exporting them a batch at a time keeps
+// a run that is killed from losing all of them.
+const batchSize = 256
+
+// exportTimeout bounds one export. A collector that has gone away must cost
+// the run seconds, not its deadline.
+const exportTimeout = 10 * time.Second
+
+// queuedBatches is how many full batches wait for a slow exporter before
+// further ones are dropped: a trace that stays complete at the cost of the
+// run's memory is the wrong way round.
+const queuedBatches = 8
+
+// New traces one run into exporter. The trace id is the run id when it is one
+// of ours — sixteen random bytes in hex — so the journal's run id finds the
+// trace in a backend and the trace finds the run in the journal; any other run
+// id is hashed into one. An export that fails is reported through logf, once
+// until one succeeds again: a trace is a diagnostic, and losing it never fails
+// the run it describes.
+func New(runID string, exporter Exporter, logf func(format string, args ...any)) *Tracer {
+	t := &Tracer{exporter: exporter, logf: logf, batches: make(chan []SpanData, queuedBatches), done: make(chan struct{})}
+	go t.export()
+	if id, err := hex.DecodeString(runID); err == nil && len(id) == len(t.traceID) {
+		copy(t.traceID[:], id)
+	} else {
+		sum := sha256.Sum256([]byte(runID))
+		copy(t.traceID[:], sum[:])
+	}
+	return t
+}
+
+// TraceID is the run's trace id in hex, as a tracing backend shows it.
+func (t *Tracer) TraceID() string { return hex.EncodeToString(t.traceID[:]) }
+
+// Root starts the run's root span. Every span started from the returned
+// context, however deep, belongs to this run's trace.
+func (t *Tracer) Root(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, data: SpanData{TraceID: t.traceID, SpanID: newSpanID(), Name: name, Kind: KindInternal, Start: time.Now(), Attrs: attrs}}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Close exports whatever has not been exported, waiting for the exports
+// still queued, and closes the exporter.
+func (t *Tracer) Close() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	if len(t.pending) > 0 {
+		t.batches <- t.pending
+		t.pending = nil
+	}
+	close(t.batches)
+	dropped := t.dropped
+	t.mu.Unlock()
+
+	<-t.done
+	if dropped > 0 && t.logf != nil {
+		t.logf("warning: %d spans were dropped while the trace exporter was behind; the run is unaffected", dropped)
+	}
+	return t.exporter.Close()
+}
+
+func (t *Tracer) finished(s SpanData) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.pending = append(t.pending, s)
+	if len(t.pending) < batchSize {
+		return
+	}
+	select {
+	case t.batches <- t.pending:
+	default:
+		t.dropped += len(t.pending)
+	}
+	t.pending = nil
+}
+
+// export runs on its own goroutine, exporting each batch as it comes.
+func (t *Tracer) export() {
+	defer close(t.done)
+	for batch := range t.batches {
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		err := t.exporter.Export(ctx, batch)
+		cancel()
+		switch {
+		case err != nil && !t.failing:
+			t.failing = true
+			if t.logf != nil {
+				t.logf("warning: could not export the trace: %v\nthe run is unaffected; only its trace is incomplete", err)
+			}
+		case err == nil && t.failing:
+			t.failing = false
+			if t.logf != nil {
+				t.logf("the trace is being exported again")
+			}
+		}
+	}
+}
+
+type spanKey struct{}
+
+// Span is one unit of timed work. A nil Span is a run that is not traced, and
+// every method does nothing.
+type Span struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+// Start starts a span as a child of the one in ctx. Without one — a run that
+// is not traced, or work outside any run — it returns ctx and nil.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	return StartKind(ctx, KindInternal, name, attrs...)
+}
+
+// StartKind is Start for a span of a kind other than internal work: a call to
+// another system.
+func StartKind(ctx context.Context, kind Kind, name string, attrs ...Attr) (context.Context, *Span) {
+	parent, _ := ctx.Value(spanKey{}).(*Span)
+	if parent == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: parent.tracer, data: SpanData{
+		TraceID: parent.data.TraceID, SpanID: newSpanID(), ParentID: parent.data.SpanID,
+		Name: name, Kind: kind, Start: time.Now(), Attrs: attrs,
+	}}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attrs = append(s.data.Attrs, attrs...)
+}
+
+// Fail marks the span failed with message, which the caller has sanitized.
+func (s *Span) Fail(message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Failed, s.data.Message = true, message
+}
+
+// End finishes the span. A second End does nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	s.tracer.finished(data)
+}
+
+func newSpanID() [8]byte {
+	var id [8]byte
+	// crypto/rand does not fail in practice, and a zero id only costs the
+	// span its parent link.
+	_, _ = rand.Read(id[:])
+	return id
+}
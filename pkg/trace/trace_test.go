@@ -0,0 +1,259 @@
+package trace_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kubenest.io/cli/pkg/trace"
+)
+
+// memory keeps what it is given.
+type memory struct {
+	mu    sync.Mutex
+	spans []trace.SpanData
+	err   error
+}
+
+func (m *memory) Export(_ context.Context, spans []trace.SpanData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.spans = append(m.spans, spans...)
+	return m.err
+}
+
+func (m *memory) Close() error { return nil }
+
+func (m *memory) named(name string) trace.SpanData {
+	for _, s := range m.spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	return trace.SpanData{}
+}
+
+const runID = "5f0c6a3e9b2d4c1f8e7a6b5c4d3e2f10"
+
+// One run is one trace, found by its run id, with every span linked to the
+// one it ran inside.
+func TestARunIsOneTraceKeyedByItsRunID(t *testing.T) {
+	m := &memory{}
+	tracer := trace.New(runID, m, nil)
+	if tracer.TraceID() != runID {
+		t.Fatalf("trace id = %s, want the run id", tracer.TraceID())
+	}
+	ctx, root := tracer.Root(context.Background(), "kubenest platform install")
+	ctx, stage := trace.Start(ctx, "stage platform-networking", trace.String("kubenest.stage", "platform-networking"))
+	_, check := trace.Start(ctx, "converge traefik")
+	check.SetAttributes(trace.Int("kubenest.check.observations", 14))
+	check.Fail("fail after 5m0s: pod traefik-7c9f is Pending")
+	check.End()
+	stage.End()
+	root.End()
+	root.End()
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.spans) != 3 {
+		t.Fatalf("exported %d spans, want 3 (a second End is not a second span)", len(m.spans))
+	}
+	r, s, c := m.named("kubenest platform install"), m.named("stage platform-networking"), m.named("converge traefik")
+	if r.ParentID != ([8]byte{}) || s.ParentID != r.SpanID || c.ParentID != s.SpanID {
+		t.Error("the spans are not linked root > stage > check")
+	}
+	for _, span := range m.spans {
+		if span.TraceID != r.TraceID {
+			t.Errorf("%s is in another trace", span.Name)
+		}
+	}
+	if !c.Failed || !strings.Contains(c.Message, "Pending") || s.Failed {
+		t.Errorf("status: check %v %q, stage %v", c.Failed, c.Message, s.Failed)
+	}
+}
+
+// Code that is not in a traced run records nothing and needs no check of its
+// own to say so.
+func TestStartOutsideATraceIsANoOp(t *testing.T) {
+	ctx, span := trace.Start(context.Background(), "ssh k3s")
+	if span != nil || ctx != context.Background() {
+		t.Fatal("a span was started with no trace to belong to")
+	}
+	span.SetAttributes(trace.Int("exit_code", 1))
+	span.Fail("exit 1")
+	span.End()
+
+	var tracer *trace.Tracer
+	_, root := tracer.Root(context.Background(), "run")
+	root.End()
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The file is OTLP JSON, one export request per line, as a collector's
+// otlpjsonfile receiver reads it.
+func TestFileExporterWritesOTLPJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.json")
+	f, err := trace.NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := trace.New(runID, f, nil)
+	ctx, root := tracer.Root(context.Background(), "kubenest platform upgrade")
+	_, call := trace.StartKind(ctx, trace.KindClient, "GET /api/v1/bundles", trace.Int("http.response.status_code", 200))
+	call.End()
+	root.End()
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	if info, _ := raw.Stat(); info.Mode().Perm() != 0o600 {
+		t.Errorf("trace file mode = %v, want 0600", info.Mode().Perm())
+	}
+	lines := bufio.NewScanner(raw)
+	if !lines.Scan() {
+		t.Fatal("the file is empty")
+	}
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID      string `json:"traceId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string `json:"name"`
+					Kind         int    `json:"kind"`
+					Attributes   []struct {
+						Key   string `json:"key"`
+						Value struct {
+							IntValue string `json:"intValue"`
+						} `json:"value"`
+					} `json:"attributes"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(lines.Bytes(), &req); err != nil {
+		t.Fatalf("line is not JSON: %v", err)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 || spans[0].TraceID != runID || spans[0].ParentSpanID == "" || spans[0].Kind != int(trace.KindClient) {
+		t.Fatalf("spans = %+v", spans)
+	}
+	if a := spans[0].Attributes[0]; a.Key != "http.response.status_code" || a.Value.IntValue != "200" {
+		t.Errorf("attribute = %+v, want the status as an OTLP intValue string", a)
+	}
+}
+
+// The collector gets OTLP/HTTP JSON at /v1/traces. One that stops answering
+// is reported once, and never fails the run.
+func TestOTLPExporterPostsAndFailsQuietly(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	down := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") != "application/json" || !json.Valid(body) {
+			t.Errorf("got %s %q", r.Header.Get("Content-Type"), body)
+		}
+		paths = append(paths, r.URL.Path)
+		if down {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	if _, err := trace.NewOTLP("otel-collector:4318"); err == nil {
+		t.Error("a collector without a scheme was accepted")
+	}
+	o, err := trace.NewOTLP(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warnings []string
+	tracer := trace.New("not-hex", o, func(format string, args ...any) { warnings = append(warnings, format) })
+	if len(tracer.TraceID()) != 32 {
+		t.Errorf("trace id %q is not sixteen bytes", tracer.TraceID())
+	}
+	ctx, root := tracer.Root(context.Background(), "run")
+	down = true
+	for i := 0; i < 600; i++ {
+		_, s := trace.Start(ctx, "ssh k3s kubectl")
+		s.End()
+	}
+	root.End()
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 3 || paths[0] != "/v1/traces" {
+		t.Errorf("posted to %v, want three batches to /v1/traces", paths)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "run is unaffected") {
+		t.Errorf("warnings = %q, want one", warnings)
+	}
+}
+
+func TestExportersTriesEveryOne(t *testing.T) {
+	a, b := &memory{err: errors.New("collector down")}, &memory{}
+	err := trace.Exporters{a, b}.Export(context.Background(), []trace.SpanData{{Name: "x"}})
+	if err == nil || len(b.spans) != 1 {
+		t.Errorf("err = %v, second exporter got %d spans", err, len(b.spans))
+	}
+}
+
+// hung is a collector that answers nothing until released.
+type hung struct {
+	memory
+	release chan struct{}
+}
+
+func (h *hung) Export(ctx context.Context, spans []trace.SpanData) error {
+	<-h.release
+	return h.memory.Export(ctx, spans)
+}
+
+// A collector that hangs holds up no span's end: the batches wait for it off
+// the run's path, and Close waits for them.
+func TestAHungCollectorNeverHoldsUpTheRun(t *testing.T) {
+	h := &hung{release: make(chan struct{})}
+	tracer := trace.New(runID, h, nil)
+	ctx, root := tracer.Root(context.Background(), "kubenest platform install")
+
+	start := time.Now()
+	for i := 0; i < 3*256; i++ {
+		_, s := trace.Start(ctx, "ssh k3s")
+		s.End()
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("ending spans waited %s on the collector", elapsed)
+	}
+	root.End()
+
+	close(h.release)
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.spans) != 3*256+1 {
+		t.Errorf("exported %d spans, want %d", len(h.spans), 3*256+1)
+	}
+}
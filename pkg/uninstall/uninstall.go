@@ -26,6 +26,7 @@ import (
 	"strings"
 
 	"kubenest.io/cli/pkg/k3s"
+	"kubenest.io/cli/pkg/stages"
 	"kubenest.io/cli/pkg/storage"
 )
 
@@ -134,6 +135,11 @@ func removeK3s(ctx context.Context, node Node) error {
 	}
 	// The join token, if an interrupted install left one staged.
 	_, _ = node.Runner.Run(ctx, "sudo -n rm -f /etc/rancher/kubenest-join-token")
+	// The journal copies: left behind, the next install of the same name
+	// would resume from them into a cluster that no longer exists.
+	if node.Role != RoleAgent {
+		_, _ = node.Runner.Run(ctx, "sudo -n rm -rf "+stages.ServerJournalDir)
+	}
 	return nil
 }
 
@@ -8,6 +8,7 @@ import (
 
 	"kubenest.io/cli/pkg/component/componenttest"
 	"kubenest.io/cli/pkg/sshx"
+	"kubenest.io/cli/pkg/stages"
 	"kubenest.io/cli/pkg/storage"
 	"kubenest.io/cli/pkg/uninstall"
 )
@@ -49,6 +50,9 @@ func TestDefaultRemovesK3sAndKeepsData(t *testing.T) {
 	if !ranAny(cmds, "k3s-uninstall.sh") {
 		t.Error("k3s was not removed")
 	}
+	if !ranAny(cmds, "rm -rf "+stages.ServerJournalDir) {
+		t.Error("the server's journal copy was left for the next install to resume from")
+	}
 	if ranAny(cmds, "lvremove") || ranAny(cmds, "vgremove") {
 		t.Fatalf("uninstall destroyed data without --destroy-data:\n%s", strings.Join(cmds, "\n"))
 	}
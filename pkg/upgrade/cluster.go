@@ -40,7 +40,7 @@ func LoadRecord(ctx context.Context, client *api.Client, clusterID string) (Reco
 // during an upgrade.
 func (s *Session) installedProfiles() []string { return s.Cluster.Profiles }
 
-// haTier is the cluster's permanent tier.
+// haTier is the cluster's tier, which an upgrade never changes.
 func (s *Session) haTier() string { return s.Cluster.HATier }
 
 // volumeGroupOwnership is carried through unchanged: an upgrade never touches
@@ -38,13 +38,13 @@ const (
 
 // GateResult is one gate's verdict.
 type GateResult struct {
-	Gate   string
-	Passed bool
+	Gate   string `json:"gate"`
+	Passed bool   `json:"passed"`
 	// Detail is what was observed.
-	Detail string
+	Detail string `json:"detail"`
 	// Fix is what to do about it. A failed gate without one has told the
 	// operator they have a problem and nothing more.
-	Fix string
+	Fix string `json:"fix,omitempty"`
 }
 
 func (g GateResult) String() string {
@@ -236,7 +236,7 @@ func checkBundlePath(from, to *manifest.Manifest, profiles []string, haTier stri
 		return GateResult{
 			Gate: GateBundlePath, Passed: false,
 			Detail: err.Error(),
-			Fix:    "this cluster's HA tier is permanent, so a bundle that does not offer it cannot be a target",
+			Fix:    "an upgrade never changes this cluster's HA tier, so a bundle that does not offer it cannot be a target",
 		}
 	}
 	for _, p := range profiles {
@@ -403,22 +403,6 @@ func checkDiskHeadroom(ctx context.Context, nodes []Node, need manifest.Quantity
 		Detail: fmt.Sprintf("every node has at least %s free on /var/lib", need)}
 }
 
-// pdbList is what the disruption gate reads.
-type pdbList struct {
-	Items []struct {
-		Metadata struct {
-			Name      string `json:"name"`
-			Namespace string `json:"namespace"`
-		} `json:"metadata"`
-		Status struct {
-			DisruptionsAllowed int32 `json:"disruptionsAllowed"`
-			CurrentHealthy     int32 `json:"currentHealthy"`
-			DesiredHealthy     int32 `json:"desiredHealthy"`
-			ExpectedPods       int32 `json:"expectedPods"`
-		} `json:"status"`
-	} `json:"items"`
-}
-
 // checkDisruptionBudgets asks whether a drain would FINISH, which is
 // answerable in advance — not whether a PDB is reasonable, which is not.
 //
@@ -428,30 +412,12 @@ type pdbList struct {
 // currently allowing no disruptions AND with no slack (every expected pod is
 // needed) can never let a pod move.
 func checkDisruptionBudgets(ctx context.Context, r k3s.Runner) GateResult {
-	out, err := k3s.Kubectl(ctx, r, "get poddisruptionbudgets -A -o json")
+	blocking, total, err := k3s.BlockingDisruptionBudgets(ctx, r)
 	if err != nil {
 		return GateResult{Gate: GateDisruption, Passed: false,
 			Detail: "could not read pod disruption budgets: " + err.Error(),
 			Fix:    "the cluster must be readable before it can be upgraded"}
 	}
-	var pdbs pdbList
-	if err := json.Unmarshal([]byte(out), &pdbs); err != nil {
-		return GateResult{Gate: GateDisruption, Passed: false, Detail: "unparsable pod disruption budgets",
-			Fix: "the cluster must be readable before it can be upgraded"}
-	}
-
-	var blocking []string
-	for _, p := range pdbs.Items {
-		if p.Status.DisruptionsAllowed > 0 {
-			continue
-		}
-		// Zero allowed right now is normal while something is rescheduling.
-		// It is permanent only when the budget requires every pod it has.
-		if p.Status.DesiredHealthy >= p.Status.ExpectedPods && p.Status.ExpectedPods > 0 {
-			blocking = append(blocking, fmt.Sprintf("%s/%s requires %d of %d pods, so no pod may ever be evicted",
-				p.Metadata.Namespace, p.Metadata.Name, p.Status.DesiredHealthy, p.Status.ExpectedPods))
-		}
-	}
 	if len(blocking) > 0 {
 		return GateResult{
 			Gate: GateDisruption, Passed: false,
@@ -460,5 +426,5 @@ func checkDisruptionBudgets(ctx context.Context, r k3s.Runner) GateResult {
 		}
 	}
 	return GateResult{Gate: GateDisruption, Passed: true,
-		Detail: fmt.Sprintf("%d budget(s), none of which would block a drain indefinitely", len(pdbs.Items))}
+		Detail: fmt.Sprintf("%d budget(s), none of which would block a drain indefinitely", total)}
 }
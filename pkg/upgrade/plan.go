@@ -10,6 +10,8 @@ import (
 	"kubenest.io/cli/pkg/component/certmanager"
 	"kubenest.io/cli/pkg/component/day2"
 	"kubenest.io/cli/pkg/component/gatewayapi"
+	"kubenest.io/cli/pkg/component/observability"
+	"kubenest.io/cli/pkg/component/secrets"
 	"kubenest.io/cli/pkg/component/traefik"
 	"kubenest.io/cli/pkg/converge"
 	"kubenest.io/cli/pkg/deprecation"
@@ -40,15 +42,24 @@ func Plan(s *Session) []stages.Stage {
 			return f(ctx, s)
 		}
 	}
+	// Only the Helm stages and the record retry a transient failure: each is
+	// a release re-applied or a PUT repeated, which a resume does anyway.
+	// Preflight and verify are checks, and a check retried until it passes
+	// is not one. A retried backup would be a second backup racing the
+	// first. And StageKubernetes is the point of no return: a failure there
+	// goes to an operator and the rollback advice, not round again. The
+	// window check in bind runs before every attempt, so a retry never
+	// starts after the window has closed.
+	retry := stages.DefaultRetry
 	return []stages.Stage{
 		{Name: StagePreflight, AlwaysRun: true, Run: bind(StagePreflight, stagePreflight)},
 		{Name: StageBackup, Component: "velero", AlwaysRun: true, Run: bind(StageBackup, stageBackup)},
-		{Name: StageComponents, Run: bind(StageComponents, stageComponents)},
-		{Name: StageProfiles, Run: bind(StageProfiles, stageProfiles)},
-		{Name: StageAgent, Component: "kubenest-agent", Run: bind(StageAgent, stageAgent)},
+		{Name: StageComponents, Retry: retry, Run: bind(StageComponents, stageComponents)},
+		{Name: StageProfiles, Retry: retry, Run: bind(StageProfiles, stageProfiles)},
+		{Name: StageAgent, Component: "kubenest-agent", Retry: retry, Run: bind(StageAgent, stageAgent)},
 		{Name: StageKubernetes, Component: "k3s", Run: bind(StageKubernetes, stageKubernetes)},
 		{Name: StageVerify, AlwaysRun: true, Run: bind(StageVerify, stageVerify)},
-		{Name: StageRecord, Run: bind(StageRecord, stageRecord)},
+		{Name: StageRecord, Retry: retry, Run: bind(StageRecord, stageRecord)},
 	}
 }
 
@@ -90,6 +101,9 @@ func stagePreflight(ctx context.Context, s *Session) error {
 	report.add(s.scanDeprecatedAPIs(ctx, server, &report))
 
 	for _, g := range report.Results {
+		if s.Findings != nil {
+			s.Findings.Finding(stages.FindingGate, g)
+		}
 		if g.Passed {
 			s.Logf("  ok   %s: %s", g.Gate, g.Detail)
 		}
@@ -229,6 +243,12 @@ func confirmVersion(ctx context.Context, r k3s.Runner, key, want string, s *Sess
 	if resource == "" {
 		return nil
 	}
+	return confirmChart(ctx, r, key, resource, want, s)
+}
+
+// confirmChart is confirmVersion for a HelmChart resource named directly, as
+// a profile's components are.
+func confirmChart(ctx context.Context, r k3s.Runner, key, resource, want string, s *Session) error {
 	deadline, err := s.To.Limits.Timeouts.For("component-ready")
 	if err != nil {
 		return err
@@ -297,22 +317,145 @@ func coreComponents() []coreComponent {
 //
 // A cluster's profile set does not change during an upgrade — adding or
 // removing one is a separate operation — so this moves what is already there
-// and nothing else.
+// and nothing else. Within a profile the bundle decides the components: one
+// the new bundle pins that the old did not is installed, and one it no
+// longer pins is uninstalled, its volumes kept.
 func stageProfiles(ctx context.Context, s *Session) error {
 	installed := s.installedProfiles()
-	var components []string
+	var components, unbuilt []string
 	for _, name := range installed {
-		if name == "ha" {
-			continue // a topology, not components
+		switch name {
+		case "ha":
+			// a topology, not components
+		case observability.Profile, secrets.Profile, storage.ReplicatedProfile:
+			components = append(components, name)
+		default:
+			unbuilt = append(unbuilt, name)
 		}
-		components = append(components, name)
+	}
+	if len(unbuilt) > 0 {
+		return fmt.Errorf("this cluster has profile(s) %s installed, and this build of the CLI cannot move them: upgrade the CLI to one that knows them. Upgrading the rest alone would leave the cluster not matching its own record, so it is refused rather than done partially",
+			strings.Join(unbuilt, ", "))
 	}
 	if len(components) == 0 {
 		s.Logf("  core only, no component profiles installed")
 		return nil
 	}
-	return fmt.Errorf("this cluster has profile(s) %s installed, and this build of the CLI cannot move them yet — the component profiles land after core (kn-sev5, kn-ynaq, kn-54ni). Upgrading core alone would leave the cluster not matching its own record, so it is refused rather than done partially",
-		strings.Join(components, ", "))
+	server, err := s.Server()
+	if err != nil {
+		return err
+	}
+	for _, name := range components {
+		move := moveObservability
+		switch name {
+		case secrets.Profile:
+			move = moveSecrets
+		case storage.ReplicatedProfile:
+			move = moveReplicated
+		}
+		if err := stages.NewComponentError(name, move(ctx, server, s)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveSecrets moves the sealed-secrets controller to the new bundle's pin.
+// Its keys are Secrets the chart does not own, so they, and every
+// SealedSecret sealed against them, come through unchanged.
+func moveSecrets(ctx context.Context, server k3s.Runner, s *Session) error {
+	from, _ := secrets.Version(s.From)
+	to, err := secrets.Version(s.To)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		s.Logf("  sealed-secrets unchanged at %s", to)
+		return nil
+	}
+	s.Logf("  sealed-secrets %s → %s", from, to)
+	if err := secrets.Install(ctx, server, s.To, s.Reporter); err != nil {
+		return err
+	}
+	return confirmChart(ctx, server, "sealed-secrets", secrets.ChartName, to, s)
+}
+
+// moveReplicated moves the replicated storage engine to the new bundle's pin.
+// The pools and their devices are the engine's data and are left as they
+// are; only the chart moves, and the io-engine restarts node by node as its
+// DaemonSet rolls.
+func moveReplicated(ctx context.Context, server k3s.Runner, s *Session) error {
+	from, _ := storage.ReplicatedVersion(s.From)
+	to, err := storage.ReplicatedVersion(s.To)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		s.Logf("  %s unchanged at %s", storage.ReplicatedComponentKey, to)
+		return nil
+	}
+	s.Logf("  %s %s → %s", storage.ReplicatedComponentKey, from, to)
+	if err := storage.InstallReplicated(ctx, server, s.To, "", s.Reporter); err != nil {
+		return err
+	}
+	return confirmChart(ctx, server, storage.ReplicatedComponentKey, storage.ReplicatedChartResourceName, to, s)
+}
+
+// moveObservability compares the two bundles' observability pins and moves
+// what changed. Nothing changed is nothing done: re-applying identical
+// HelmCharts would be a no-op anyway, but saying so is what the operator
+// reads.
+func moveObservability(ctx context.Context, server k3s.Runner, s *Session) error {
+	from, err := s.From.Profiles.Get(observability.Profile)
+	if err != nil {
+		return err
+	}
+	to, err := observability.Pinned(s.To)
+	if err != nil {
+		return err
+	}
+	prof, _ := s.To.Profiles.Get(observability.Profile)
+
+	var changed []observability.Component
+	for _, c := range to {
+		was, want := from.Components[c.Key], prof.Components[c.Key]
+		switch {
+		case was == want:
+			s.Logf("  %s unchanged at %s", c.Key, want)
+		case was == "":
+			s.Logf("  %s added at %s", c.Key, want)
+			changed = append(changed, c)
+		default:
+			s.Logf("  %s %s → %s", c.Key, was, want)
+			changed = append(changed, c)
+		}
+	}
+	var dropped []observability.Component
+	for _, c := range observability.Components {
+		if _, pinned := prof.Components[c.Key]; !pinned && from.Components[c.Key] != "" {
+			s.Logf("  %s removed (the bundle no longer pins it; its volumes are kept)", c.Key)
+			dropped = append(dropped, c)
+		}
+	}
+
+	if len(dropped) > 0 {
+		if err := observability.Uninstall(ctx, server, s.To, dropped, s.Reporter); err != nil {
+			return err
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	if err := observability.Install(ctx, server, s.To, s.Reporter); err != nil {
+		return err
+	}
+	// Proved moved, for the reason the core components are (stageComponents).
+	for _, c := range changed {
+		if err := confirmChart(ctx, server, c.Key, observability.ChartName(c.Key), prof.Components[c.Key], s); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // stageAgent moves the KubeNest agent.
@@ -361,11 +504,13 @@ func stageRecord(ctx context.Context, s *Session) error {
 }
 
 // terminalEntries is the journal in the control plane's shape. Only terminal
-// transitions are persisted server-side.
+// transitions are persisted server-side; an operator's reopened entry is not
+// a transition, an attempt that was retried did not end its stage, and a site
+// hook is not a stage, so all three stay local.
 func terminalEntries(j *stages.Journal) []api.InstallJournalEntry {
 	var out []api.InstallJournalEntry
 	for _, e := range j.Entries {
-		if e.Status == stages.StatusStarted {
+		if e.Status == stages.StatusStarted || e.Status == stages.StatusReopened || e.Status == stages.StatusRetrying || e.Status == stages.StatusHook {
 			continue
 		}
 		at := e.At
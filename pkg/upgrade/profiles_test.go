@@ -0,0 +1,98 @@
+package upgrade
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"kubenest.io/cli/pkg/api"
+	"kubenest.io/cli/pkg/component/componenttest"
+	"kubenest.io/cli/pkg/manifest"
+	"kubenest.io/cli/pkg/sshx"
+)
+
+func observabilityBundle(t *testing.T, version, components string) *manifest.Manifest {
+	t.Helper()
+	m, err := manifest.Parse([]byte("bundle: \"" + version + "\"\nlimits:\n  timeouts:\n    component-ready: 2s\nprofiles:\n  observability:\n    components:\n" + components))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// The profile moves by its pins: a changed chart is re-applied and proved
+// deployed at the new version, an unchanged one is left, and one the new
+// bundle no longer pins is uninstalled.
+func TestObservabilityMovesByItsPins(t *testing.T) {
+	from := observabilityBundle(t, "1.0", "      kube-prometheus-stack: 79.5.0\n      loki: 6.46.0\n      alloy: 1.4.0\n      grafana: 10.5.15\n")
+	to := observabilityBundle(t, "1.1", "      kube-prometheus-stack: 79.5.0\n      loki: 6.46.0\n      grafana: 10.6.0\n")
+
+	server := &componenttest.FakeRunner{Respond: func(cmd string) (sshx.Result, error) {
+		switch {
+		case strings.Contains(cmd, "get deployments,daemonsets"):
+			return sshx.Result{Stdout: `{"items": [{"kind": "Deployment", "metadata": {"name": "kubenest-grafana"}, "status": {"conditions": [{"type": "Available", "status": "True"}]}}]}`}, nil
+		case strings.Contains(cmd, "get statefulsets"):
+			return sshx.Result{Stdout: `{"items": [
+				{"metadata": {"name": "prometheus-kubenest-prometheus"}, "status": {"readyReplicas": 1}},
+				{"metadata": {"name": "alertmanager-kubenest-alertmanager"}, "status": {"readyReplicas": 1}},
+				{"metadata": {"name": "kubenest-loki"}, "status": {"readyReplicas": 1}}]}`}, nil
+		case strings.Contains(cmd, "get pvc"):
+			return sshx.Result{Stdout: `{"items": [{"metadata": {"name": "storage-kubenest-loki-0"}, "spec": {"storageClassName": "kubenest-local"}, "status": {"phase": "Bound"}}]}`}, nil
+		case strings.Contains(cmd, "get httproute"):
+			return sshx.Result{Stdout: `{"status": {"parents": [{"parentRef": {"name": "kubenest-gateway"}, "conditions": [{"type": "Accepted", "status": "True"}, {"type": "ResolvedRefs", "status": "True"}]}]}}`}, nil
+		case strings.Contains(cmd, "get helmchart kubenest-grafana"):
+			return sshx.Result{Stdout: "10.6.0 helm-install-kubenest-grafana"}, nil
+		case strings.Contains(cmd, "get job helm-install-kubenest-grafana"):
+			return sshx.Result{Stdout: "1"}, nil
+		}
+		return sshx.Result{}, nil
+	}}
+	var out bytes.Buffer
+	s := &Session{
+		From: from, To: to, Out: &out,
+		Cluster: Recorded{api.ClusterBundle{Profiles: []string{"ha", "observability"}}},
+		Nodes:   []Node{{Address: "10.0.1.10", Server: true, Runner: server}},
+	}
+	if err := stageProfiles(context.Background(), s); err != nil {
+		t.Fatalf("stageProfiles: %v\n%s", err, out.String())
+	}
+
+	var alloyRemoved, grafanaMoved, lokiRewritten bool
+	for _, cmd := range server.Commands() {
+		alloyRemoved = alloyRemoved || cmd == "sudo -n rm -f /var/lib/rancher/k3s/server/manifests/kubenest-alloy.yaml"
+		if strings.Contains(cmd, "| sudo -n tee ") {
+			doc, err := base64.StdEncoding.DecodeString(strings.Fields(cmd)[2])
+			if err != nil {
+				t.Fatal(err)
+			}
+			grafanaMoved = grafanaMoved || strings.Contains(string(doc), "name: kubenest-grafana") && strings.Contains(string(doc), "version: 10.6.0")
+			lokiRewritten = lokiRewritten || strings.Contains(string(doc), "version: 6.46.0")
+		}
+	}
+	if !alloyRemoved {
+		t.Error("alloy, which the new bundle no longer pins, was not uninstalled")
+	}
+	if !grafanaMoved {
+		t.Error("grafana was not re-applied at the new pin")
+	}
+	if !lokiRewritten {
+		t.Error("the profile is applied whole, at the new bundle's pins, unchanged charts included")
+	}
+	for _, want := range []string{"grafana 10.5.15 → 10.6.0", "loki unchanged at 6.46.0", "alloy removed"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("the log does not say %q:\n%s", want, out.String())
+		}
+	}
+}
+
+// A profile this build cannot move — one a newer CLI installed — still
+// refuses the whole stage.
+func TestUnbuiltProfilesAreStillRefused(t *testing.T) {
+	s := &Session{Cluster: Recorded{api.ClusterBundle{Profiles: []string{"observability", "secrets", "replicated-storage", "edge"}}}}
+	err := stageProfiles(context.Background(), s)
+	if err == nil || !strings.Contains(err.Error(), "profile(s) edge installed") {
+		t.Errorf("want a refusal naming only edge, got %v", err)
+	}
+}
@@ -30,6 +30,7 @@ import (
 
 	"kubenest.io/cli/pkg/api"
 	"kubenest.io/cli/pkg/converge"
+	"kubenest.io/cli/pkg/diagnose"
 	"kubenest.io/cli/pkg/k3s"
 	"kubenest.io/cli/pkg/manifest"
 	"kubenest.io/cli/pkg/sshx"
@@ -155,6 +156,9 @@ type Session struct {
 	Jnl      *stages.Journal
 	Emit     stages.Emitter
 	Reporter converge.Reporter
+	// Findings receives every gate's verdict, passed or not. Nil drops
+	// them.
+	Findings stages.Findings
 	Out      io.Writer
 	API      *api.Client
 
@@ -169,6 +173,10 @@ type Session struct {
 
 	Nodes  []Node
 	Record record
+	// Lock is this run's hold on the journal and the cluster's lease, taken
+	// before the journal was opened and released by Close. Nil runs
+	// unlocked (tests).
+	Lock *stages.Lock
 
 	closers []io.Closer
 }
@@ -203,8 +211,17 @@ func (s *Session) Logf(format string, args ...any) {
 	fmt.Fprintf(s.Out, format+"\n", args...)
 }
 
-// Close releases every connection the session opened.
+// Close waits for the journal's copies to land, then releases the lock and
+// every connection the session opened — in that order, because the copies
+// are written and the lease released over those connections.
 func (s *Session) Close() {
+	if s.Jnl != nil {
+		s.Jnl.Flush()
+	}
+	if s.Lock != nil {
+		s.Lock.Release()
+		s.Lock = nil
+	}
 	for _, c := range s.closers {
 		_ = c.Close()
 	}
@@ -223,6 +240,28 @@ func (s *Session) Server() (k3s.Runner, error) {
 	return nil, fmt.Errorf("no server node connection")
 }
 
+// Diagnose captures the evidence for a check that failed at stage, from the
+// server node, into a directory beside the journal.
+func (s *Session) Diagnose(ctx context.Context, stage string, state converge.State) (string, error) {
+	server, err := s.Server()
+	if err != nil {
+		return "", err
+	}
+	dir := diagnose.Dir(s.Jnl.Path(), s.ID, stage)
+	return dir, diagnose.Capture(ctx, server, state, dir)
+}
+
+// NodeRunner returns the connection to the node at address, for a hook that
+// runs there.
+func (s *Session) NodeRunner(address string) (stages.Runner, error) {
+	for _, n := range s.Nodes {
+		if n.Address == address {
+			return n.Runner, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not one of this cluster's nodes: a hook runs on a node the upgrade is connected to", address)
+}
+
 // now is the session's clock.
 func (s *Session) now() time.Time {
 	if s.Opts.Now != nil {
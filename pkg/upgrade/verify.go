@@ -59,8 +59,11 @@ func verifyNodes(ctx context.Context, s *Session, server k3s.Runner) error {
 	if err != nil {
 		return err
 	}
+	// Watched: a node reports its new version and Ready the moment it has
+	// them, and twenty polls of the node list are twenty SSH round trips.
 	res, err := converge.Wait(ctx, allNodesProbe(server, target, len(s.Nodes)), converge.Options{
 		Name: "nodes-upgraded", Deadline: deadline, Reporter: s.Reporter,
+		Watch: k3s.Watch(server, "nodes"),
 	})
 	if err != nil {
 		return err
@@ -129,8 +132,11 @@ func verifyComponents(ctx context.Context, s *Session, server k3s.Runner) error
 		}
 		return true, converge.State{Object: "core components", Status: "all Ready"}, nil
 	}
+	// Workloads become Ready when their pods do, so the pods are what is
+	// watched: those of the namespaces the probe reads.
 	res, err := converge.Wait(ctx, probe, converge.Options{
 		Name: "core-components", Deadline: deadline, Reporter: s.Reporter,
+		Watch: k3s.WatchNamespaces(server, "pods", namespaces),
 	})
 	if err != nil {
 		return err